@@ -0,0 +1,530 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/filebeat/config"
+	"github.com/elastic/beats/v7/libbeat/cfgfile"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance/locks"
+	"github.com/elastic/beats/v7/libbeat/common/cli"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	// legacyLogKeyPrefix is the key prefix used by the log input.
+	legacyLogKeyPrefix = "filebeat::logs::"
+	keySeparator       = "::"
+)
+
+// registryFilter selects registry entries by input ID and path.
+type registryFilter struct {
+	InputID string
+	Path    string
+}
+
+// registryEntry is the summary of a registry entry shown by the registry list command.
+type registryEntry struct {
+	Key       string        `json:"key"`
+	InputType string        `json:"input_type"`
+	InputID   string        `json:"input_id,omitempty"`
+	Identity  string        `json:"identity,omitempty"`
+	Path      string        `json:"path,omitempty"`
+	Offset    *int64        `json:"offset,omitempty"`
+	TTL       time.Duration `json:"ttl"`
+	Updated   time.Time     `json:"updated,omitempty"`
+}
+
+// registryValue holds the fields of the registry documents used by the
+// registry commands. Inputs based on input-cursor and input-logfile store
+// cursor and meta data, the log input stores its fields at the top level.
+type registryValue struct {
+	TTL     time.Duration `struct:"ttl"`
+	Updated time.Time     `struct:"updated"`
+	Cursor  interface{}   `struct:"cursor"`
+	Meta    interface{}   `struct:"meta"`
+
+	Source    string    `struct:"source"`
+	Offset    *int64    `struct:"offset"`
+	Timestamp time.Time `struct:"timestamp"`
+}
+
+// registryDocument is the JSON representation of a registry entry used for export and import.
+type registryDocument struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func genRegistryCmd(settings instance.Settings) *cobra.Command {
+	registryCmd := &cobra.Command{
+		Use:   "registry",
+		Short: "Inspect and modify the registry",
+		Long: `Inspect and modify the registry holding the state of the inputs.
+Filebeat must not be running while the registry is accessed, the data path is locked for the duration of the command.`,
+	}
+	registryCmd.AddCommand(genRegistryListCmd(settings))
+	registryCmd.AddCommand(genRegistryExportCmd(settings))
+	registryCmd.AddCommand(genRegistryImportCmd(settings))
+	registryCmd.AddCommand(genRegistryResetOffsetsCmd(settings))
+	registryCmd.AddCommand(genRegistryCompactCmd(settings))
+
+	return registryCmd
+}
+
+func addRegistryFilterFlags(cmd *cobra.Command, filter *registryFilter) {
+	cmd.Flags().StringVar(&filter.InputID, "input-id", "", "Only select entries of the input with this ID")
+	cfgfile.AddAllowedBackwardsCompatibleFlag("input-id")
+	cmd.Flags().StringVar(&filter.Path, "path", "", "Only select entries of files matching this glob pattern")
+	cfgfile.AddAllowedBackwardsCompatibleFlag("path")
+}
+
+func genRegistryListCmd(settings instance.Settings) *cobra.Command {
+	var filter registryFilter
+	var asJSON bool
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "List registry entries with their file identity, offset and TTL",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			return withRegistryStore(settings, func(store backend.Store) error {
+				entries, err := listRegistryEntries(store, filter)
+				if err != nil {
+					return err
+				}
+				if asJSON {
+					enc := json.NewEncoder(cmd.OutOrStdout())
+					enc.SetIndent("", "  ")
+					return enc.Encode(entries)
+				}
+				return printRegistryEntries(cmd.OutOrStdout(), entries)
+			})
+		}),
+	}
+	addRegistryFilterFlags(command, &filter)
+	command.Flags().BoolVar(&asJSON, "json", false, "Print the entries as JSON")
+	cfgfile.AddAllowedBackwardsCompatibleFlag("json")
+
+	return command
+}
+
+func genRegistryExportCmd(settings instance.Settings) *cobra.Command {
+	var filter registryFilter
+	var output string
+	command := &cobra.Command{
+		Use:   "export",
+		Short: "Export registry entries as JSON",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			return withRegistryStore(settings, func(store backend.Store) error {
+				w := cmd.OutOrStdout()
+				if output != "" {
+					f, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+					if err != nil {
+						return fmt.Errorf("failed to create export file: %w", err)
+					}
+					defer f.Close()
+					w = f
+				}
+				return exportRegistryEntries(store, filter, w)
+			})
+		}),
+	}
+	addRegistryFilterFlags(command, &filter)
+	command.Flags().StringVarP(&output, "output", "o", "", "Write the entries to this file instead of stdout")
+	cfgfile.AddAllowedBackwardsCompatibleFlag("output")
+
+	return command
+}
+
+func genRegistryImportCmd(settings instance.Settings) *cobra.Command {
+	var force bool
+	command := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Import registry entries from a JSON export, overwriting existing entries with the same key",
+		Args:  cobra.ExactArgs(1),
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open import file: %w", err)
+			}
+			defer f.Close()
+
+			if !force {
+				ok, err := cli.Confirm("Existing registry entries with the same keys will be overwritten. Continue?", false)
+				if err != nil || !ok {
+					return err
+				}
+			}
+
+			return withRegistryStore(settings, func(store backend.Store) error {
+				n, err := importRegistryEntries(store, f)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Imported %d registry entries\n", n)
+				return nil
+			})
+		}),
+	}
+	command.Flags().BoolVar(&force, "force", false, "Do not ask for confirmation")
+	cfgfile.AddAllowedBackwardsCompatibleFlag("force")
+
+	return command
+}
+
+func genRegistryResetOffsetsCmd(settings instance.Settings) *cobra.Command {
+	var filter registryFilter
+	var offset int64
+	var force bool
+	command := &cobra.Command{
+		Use:   "reset-offsets",
+		Short: "Reset the offsets of the files matching a glob pattern",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			if filter.Path == "" {
+				return errors.New("--path is required")
+			}
+
+			if !force {
+				ok, err := cli.Confirm(fmt.Sprintf("The offsets of the files matching %q will be set to %d. Continue?", filter.Path, offset), false)
+				if err != nil || !ok {
+					return err
+				}
+			}
+
+			return withRegistryStore(settings, func(store backend.Store) error {
+				n, err := resetRegistryOffsets(store, filter, offset)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Reset the offset of %d registry entries\n", n)
+				return nil
+			})
+		}),
+	}
+	addRegistryFilterFlags(command, &filter)
+	command.Flags().Int64Var(&offset, "offset", 0, "The new offset")
+	cfgfile.AddAllowedBackwardsCompatibleFlag("offset")
+	command.Flags().BoolVar(&force, "force", false, "Do not ask for confirmation")
+	cfgfile.AddAllowedBackwardsCompatibleFlag("force")
+
+	return command
+}
+
+func genRegistryCompactCmd(settings instance.Settings) *cobra.Command {
+	return &cobra.Command{
+		Use:   "compact",
+		Short: "Write all entries to a new checkpoint and truncate the update log",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			return withRegistryStore(settings, func(store backend.Store) error {
+				return compactRegistry(store)
+			})
+		}),
+	}
+}
+
+// withRegistryStore locks the data path and opens the registry store of the beat.
+func withRegistryStore(settings instance.Settings, fn func(backend.Store) error) error {
+	b, err := instance.NewInitializedBeat(settings)
+	if err != nil {
+		return fmt.Errorf("error initializing beat: %w", err)
+	}
+
+	rawConfig, err := b.BeatConfig()
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %w", err)
+	}
+	cfg := config.DefaultConfig
+	if err := rawConfig.Unpack(&cfg); err != nil {
+		return fmt.Errorf("error reading configuration: %w", err)
+	}
+
+	// The registry must not be modified while a beat is using it.
+	lock := locks.New(b.Info)
+	if err := lock.Lock(); err != nil {
+		if errors.Is(err, locks.ErrAlreadyLocked) {
+			return fmt.Errorf("%s must be stopped before accessing the registry: %w", b.Info.Beat, err)
+		}
+		return err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	registry, err := memlog.New(logp.NewLogger("registry"), memlog.Settings{
+		Root:     paths.Resolve(paths.Data, cfg.Registry.Path),
+		FileMode: cfg.Registry.Permissions,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open the registry: %w", err)
+	}
+	defer registry.Close()
+
+	store, err := registry.Access(b.Info.Beat)
+	if err != nil {
+		return fmt.Errorf("failed to open the registry store: %w", err)
+	}
+	defer store.Close()
+
+	return fn(store)
+}
+
+// eachRegistryEntry calls fn for all entries matching the filter.
+func eachRegistryEntry(store backend.Store, filter registryFilter, fn func(registryEntry, backend.ValueDecoder) error) error {
+	if filter.Path != "" {
+		if _, err := filepath.Match(filter.Path, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", filter.Path, err)
+		}
+	}
+
+	return store.Each(func(key string, dec backend.ValueDecoder) (bool, error) {
+		var value registryValue
+		if err := dec.Decode(&value); err != nil {
+			return false, fmt.Errorf("failed to decode registry entry %s: %w", key, err)
+		}
+
+		entry := newRegistryEntry(key, value)
+		if !filter.matches(entry) {
+			return true, nil
+		}
+		if err := fn(entry, dec); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+func newRegistryEntry(key string, value registryValue) registryEntry {
+	entry := registryEntry{
+		Key:     key,
+		TTL:     value.TTL,
+		Updated: value.Updated,
+	}
+
+	if identity, ok := strings.CutPrefix(key, legacyLogKeyPrefix); ok {
+		entry.InputType = "log"
+		entry.Identity = identity
+		entry.Path = value.Source
+		entry.Offset = value.Offset
+		entry.Updated = value.Timestamp
+		return entry
+	}
+
+	parts := strings.SplitN(key, keySeparator, 3)
+	entry.InputType = parts[0]
+	if len(parts) > 1 {
+		entry.InputID = parts[1]
+	}
+	if len(parts) > 2 {
+		entry.Identity = parts[2]
+	}
+	if meta, ok := value.Meta.(map[string]interface{}); ok {
+		entry.Path, _ = meta["source"].(string)
+	}
+	if cursor, ok := value.Cursor.(map[string]interface{}); ok {
+		if offset, ok := toInt64(cursor["offset"]); ok {
+			entry.Offset = &offset
+		}
+	}
+	return entry
+}
+
+func (f registryFilter) matches(entry registryEntry) bool {
+	if f.InputID != "" && f.InputID != entry.InputID {
+		return false
+	}
+	if f.Path != "" {
+		if ok, _ := filepath.Match(f.Path, entry.Path); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func listRegistryEntries(store backend.Store, filter registryFilter) ([]registryEntry, error) {
+	entries := []registryEntry{}
+	err := eachRegistryEntry(store, filter, func(entry registryEntry, _ backend.ValueDecoder) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+func printRegistryEntries(w io.Writer, entries []registryEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INPUT TYPE\tINPUT ID\tIDENTITY\tPATH\tOFFSET\tTTL\tUPDATED")
+	for _, e := range entries {
+		offset := "-"
+		if e.Offset != nil {
+			offset = fmt.Sprint(*e.Offset)
+		}
+		ttl := e.TTL.String()
+		if e.TTL < 0 {
+			ttl = "never"
+		}
+		updated := "-"
+		if !e.Updated.IsZero() {
+			updated = e.Updated.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.InputType, e.InputID, e.Identity, e.Path, offset, ttl, updated)
+	}
+	return tw.Flush()
+}
+
+func exportRegistryEntries(store backend.Store, filter registryFilter, w io.Writer) error {
+	docs := []registryDocument{}
+	err := eachRegistryEntry(store, filter, func(entry registryEntry, dec backend.ValueDecoder) error {
+		var value map[string]interface{}
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("failed to decode registry entry %s: %w", entry.Key, err)
+		}
+		docs = append(docs, registryDocument{Key: entry.Key, Value: value})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Key < docs[j].Key })
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(docs)
+}
+
+func importRegistryEntries(store backend.Store, r io.Reader) (int, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var docs []registryDocument
+	if err := dec.Decode(&docs); err != nil {
+		return 0, fmt.Errorf("failed to parse registry export: %w", err)
+	}
+
+	for i, doc := range docs {
+		if doc.Key == "" {
+			return i, fmt.Errorf("registry entry %d has no key", i)
+		}
+		value, _ := normalizeJSONNumbers(doc.Value).(map[string]interface{})
+		if err := store.Set(doc.Key, value); err != nil {
+			return i, fmt.Errorf("failed to import registry entry %s: %w", doc.Key, err)
+		}
+	}
+	return len(docs), nil
+}
+
+func resetRegistryOffsets(store backend.Store, filter registryFilter, offset int64) (int, error) {
+	updates := map[string]map[string]interface{}{}
+	err := eachRegistryEntry(store, filter, func(entry registryEntry, dec backend.ValueDecoder) error {
+		if entry.Offset == nil {
+			return nil
+		}
+
+		var value map[string]interface{}
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("failed to decode registry entry %s: %w", entry.Key, err)
+		}
+		if cursor, ok := value["cursor"].(map[string]interface{}); ok {
+			cursor["offset"] = offset
+		} else {
+			value["offset"] = offset
+		}
+		updates[entry.Key] = value
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// the store must not be modified while iterating over it
+	for key, value := range updates {
+		if err := store.Set(key, value); err != nil {
+			return 0, fmt.Errorf("failed to update registry entry %s: %w", key, err)
+		}
+	}
+	return len(updates), nil
+}
+
+func compactRegistry(store backend.Store) error {
+	checkpointer, ok := store.(interface{ Checkpoint() error })
+	if !ok {
+		return errors.New("the registry store does not support compaction")
+	}
+	return checkpointer.Checkpoint()
+}
+
+// normalizeJSONNumbers converts json.Number values into int64 or float64 so
+// they are stored with the same types as written by the inputs.
+func normalizeJSONNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, elem := range v {
+			v[k] = normalizeJSONNumbers(elem)
+		}
+		return v
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = normalizeJSONNumbers(elem)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
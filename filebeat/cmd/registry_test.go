@@ -0,0 +1,214 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+type testCursorState struct {
+	TTL     time.Duration
+	Updated time.Time
+	Cursor  interface{}
+	Meta    interface{}
+}
+
+type testLogState struct {
+	Source    string        `struct:"source"`
+	Offset    int64         `struct:"offset"`
+	Timestamp time.Time     `struct:"timestamp"`
+	TTL       time.Duration `struct:"ttl"`
+}
+
+func TestRegistryCommands(t *testing.T) {
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	newStore := func(t *testing.T, dir string) backend.Store {
+		reg, err := memlog.New(logp.NewLogger("test"), memlog.Settings{Root: dir, FileMode: 0o600})
+		require.NoError(t, err)
+		t.Cleanup(func() { reg.Close() })
+
+		store, err := reg.Access("filebeat")
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+
+	populate := func(t *testing.T, store backend.Store) {
+		states := map[string]interface{}{
+			"filestream::app::native::1-2": testCursorState{
+				TTL: -1, Updated: updated,
+				Cursor: map[string]interface{}{"offset": int64(100)},
+				Meta:   map[string]interface{}{"source": "/var/log/app.log", "identifier_name": "native"},
+			},
+			"filestream::app::native::3-2": testCursorState{
+				TTL: -1, Updated: updated,
+				Cursor: map[string]interface{}{"offset": int64(42)},
+				Meta:   map[string]interface{}{"source": "/var/log/app.log.1", "identifier_name": "native"},
+			},
+			"filestream::other::fingerprint::abc": testCursorState{
+				TTL: 30 * time.Minute, Updated: updated,
+				Cursor: map[string]interface{}{"offset": int64(7)},
+				Meta:   map[string]interface{}{"source": "/var/log/other.log", "identifier_name": "fingerprint"},
+			},
+			"filebeat::logs::native::5-2": testLogState{
+				Source: "/var/log/legacy.log", Offset: 12, Timestamp: updated, TTL: -2,
+			},
+		}
+		for key, state := range states {
+			require.NoError(t, store.Set(key, state))
+		}
+	}
+
+	t.Run("list", func(t *testing.T) {
+		store := newStore(t, t.TempDir())
+		populate(t, store)
+
+		entries, err := listRegistryEntries(store, registryFilter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 4)
+
+		legacy := entries[0]
+		require.Equal(t, "filebeat::logs::native::5-2", legacy.Key)
+		require.Equal(t, "log", legacy.InputType)
+		require.Equal(t, "native::5-2", legacy.Identity)
+		require.Equal(t, "/var/log/legacy.log", legacy.Path)
+		require.Equal(t, int64(12), *legacy.Offset)
+		require.True(t, updated.Equal(legacy.Updated))
+
+		app := entries[1]
+		require.Equal(t, "filestream", app.InputType)
+		require.Equal(t, "app", app.InputID)
+		require.Equal(t, "native::1-2", app.Identity)
+		require.Equal(t, "/var/log/app.log", app.Path)
+		require.Equal(t, int64(100), *app.Offset)
+		require.Equal(t, time.Duration(-1), app.TTL)
+		require.True(t, updated.Equal(app.Updated))
+
+		var buf bytes.Buffer
+		require.NoError(t, printRegistryEntries(&buf, entries))
+		require.Contains(t, buf.String(), "/var/log/other.log")
+		require.Contains(t, buf.String(), "30m0s")
+	})
+
+	t.Run("list with filters", func(t *testing.T) {
+		store := newStore(t, t.TempDir())
+		populate(t, store)
+
+		entries, err := listRegistryEntries(store, registryFilter{InputID: "app"})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		entries, err = listRegistryEntries(store, registryFilter{Path: "/var/log/app.log*"})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		entries, err = listRegistryEntries(store, registryFilter{InputID: "app", Path: "/var/log/*.1"})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "filestream::app::native::3-2", entries[0].Key)
+
+		_, err = listRegistryEntries(store, registryFilter{Path: "["})
+		require.ErrorContains(t, err, "invalid path pattern")
+	})
+
+	t.Run("export and import", func(t *testing.T) {
+		src := newStore(t, t.TempDir())
+		populate(t, src)
+
+		var export bytes.Buffer
+		require.NoError(t, exportRegistryEntries(src, registryFilter{}, &export))
+
+		dst := newStore(t, t.TempDir())
+		n, err := importRegistryEntries(dst, &export)
+		require.NoError(t, err)
+		require.Equal(t, 4, n)
+
+		expected, err := listRegistryEntries(src, registryFilter{})
+		require.NoError(t, err)
+		actual, err := listRegistryEntries(dst, registryFilter{})
+		require.NoError(t, err)
+		require.Len(t, actual, len(expected))
+		for i := range expected {
+			require.Equal(t, expected[i].Key, actual[i].Key)
+			require.Equal(t, *expected[i].Offset, *actual[i].Offset)
+			require.Equal(t, expected[i].TTL, actual[i].TTL)
+			require.True(t, expected[i].Updated.Equal(actual[i].Updated))
+		}
+
+		// imported entries can be read by the inputs
+		var state testCursorState
+		require.NoError(t, dst.Get("filestream::app::native::1-2", &state))
+		require.True(t, updated.Equal(state.Updated))
+	})
+
+	t.Run("import rejects entries without key", func(t *testing.T) {
+		store := newStore(t, t.TempDir())
+		_, err := importRegistryEntries(store, bytes.NewBufferString(`[{"value": {}}]`))
+		require.ErrorContains(t, err, "has no key")
+	})
+
+	t.Run("reset offsets", func(t *testing.T) {
+		store := newStore(t, t.TempDir())
+		populate(t, store)
+
+		n, err := resetRegistryOffsets(store, registryFilter{Path: "/var/log/*.log"}, 0)
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+
+		entries, err := listRegistryEntries(store, registryFilter{})
+		require.NoError(t, err)
+		offsets := map[string]int64{}
+		for _, e := range entries {
+			offsets[e.Key] = *e.Offset
+		}
+		require.Equal(t, map[string]int64{
+			"filebeat::logs::native::5-2":         0,
+			"filestream::app::native::1-2":        0,
+			"filestream::app::native::3-2":        42,
+			"filestream::other::fingerprint::abc": 0,
+		}, offsets)
+
+		// the rest of the state is kept
+		var state testCursorState
+		require.NoError(t, store.Get("filestream::app::native::1-2", &state))
+		require.Equal(t, "native", state.Meta.(map[string]interface{})["identifier_name"])
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		dir := t.TempDir()
+		store := newStore(t, dir)
+		populate(t, store)
+
+		require.NoError(t, compactRegistry(store))
+
+		log, err := os.ReadFile(filepath.Join(dir, "filebeat", "log.json"))
+		require.NoError(t, err)
+		require.Empty(t, log, "the update log must be truncated after compaction")
+	})
+}
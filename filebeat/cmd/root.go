@@ -75,5 +75,6 @@ func Filebeat(inputs beater.PluginFactory, settings instance.Settings) *cmd.Beat
 	cfgfile.AddAllowedBackwardsCompatibleFlag("modules")
 	command.AddCommand(cmd.GenModulesCmd(Name, "", buildModulesManager))
 	command.AddCommand(genGenerateCmd())
+	command.AddCommand(genRegistryCmd(settings))
 	return command
 }
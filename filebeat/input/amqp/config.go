@@ -0,0 +1,141 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package amqp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+const (
+	failureRequeue    = "requeue"
+	failureDeadLetter = "dead_letter"
+)
+
+type config struct {
+	// Hosts are the URLs of the brokers, e.g. "amqp://localhost:5672".
+	// They are tried in order until a connection succeeds.
+	Hosts []string `config:"hosts" validate:"required"`
+	// VHost is the virtual host to connect to, it defaults to the path of
+	// the host URLs.
+	VHost    string            `config:"vhost"`
+	Username string            `config:"username"`
+	Password string            `config:"password"`
+	TLS      *tlscommon.Config `config:"ssl"`
+
+	Queues []queueConfig `config:"queues" validate:"required"`
+	// ConsumerTag identifies the consumers, the name of the queue is
+	// appended to it as consumer tags must be unique per channel. A unique
+	// tag is generated by the broker when empty.
+	ConsumerTag string `config:"consumer_tag"`
+	// PrefetchCount is the maximum number of unacknowledged messages the
+	// broker delivers to each consumer.
+	PrefetchCount int `config:"prefetch_count" validate:"min=1"`
+	// MaxMessageSize is the size of the largest message body accepted,
+	// larger messages are handled as failures.
+	MaxMessageSize cfgtype.ByteSize `config:"max_message_size" validate:"min=1"`
+	// OnFailure is what is done with the messages that can not be
+	// published, either "requeue" or "dead_letter".
+	OnFailure string `config:"on_failure"`
+
+	Heartbeat      time.Duration `config:"heartbeat" validate:"min=0"`
+	ConnectBackoff time.Duration `config:"connect_backoff" validate:"min=0"`
+	WaitClose      time.Duration `config:"wait_close" validate:"min=0"`
+}
+
+type queueConfig struct {
+	Name string `config:"name" validate:"required"`
+	// Declare declares the queue with the settings below, it is expected
+	// to exist otherwise.
+	Declare    bool                   `config:"declare"`
+	Durable    bool                   `config:"durable"`
+	AutoDelete bool                   `config:"auto_delete"`
+	Exclusive  bool                   `config:"exclusive"`
+	Arguments  map[string]interface{} `config:"arguments"`
+	// Bindings are the exchanges the queue is bound to.
+	Bindings []bindingConfig `config:"bindings"`
+}
+
+type bindingConfig struct {
+	Exchange   string                 `config:"exchange" validate:"required"`
+	RoutingKey string                 `config:"routing_key"`
+	Arguments  map[string]interface{} `config:"arguments"`
+}
+
+func defaultConfig() config {
+	return config{
+		PrefetchCount:  100,
+		MaxMessageSize: 10 * humanize.MiByte,
+		OnFailure:      failureDeadLetter,
+		Heartbeat:      10 * time.Second,
+		ConnectBackoff: 5 * time.Second,
+		WaitClose:      2 * time.Second,
+	}
+}
+
+func (c *config) Validate() error {
+	for _, h := range c.Hosts {
+		u, err := url.Parse(h)
+		if err != nil {
+			return fmt.Errorf("invalid host %q: %w", h, err)
+		}
+		if u.Scheme != "amqp" && u.Scheme != "amqps" {
+			return fmt.Errorf("invalid host %q: scheme must be amqp or amqps", h)
+		}
+		if u.User != nil {
+			return fmt.Errorf("invalid host %q: use the username and password settings for the credentials", h)
+		}
+		if c.TLS.IsEnabled() && u.Scheme != "amqps" {
+			return fmt.Errorf("invalid host %q: scheme must be amqps when ssl is enabled", h)
+		}
+	}
+	if c.OnFailure != failureRequeue && c.OnFailure != failureDeadLetter {
+		return fmt.Errorf("invalid on_failure %q, must be one of %s or %s", c.OnFailure, failureRequeue, failureDeadLetter)
+	}
+	names := make(map[string]bool, len(c.Queues))
+	for _, q := range c.Queues {
+		if names[q.Name] {
+			return fmt.Errorf("queue %q is configured more than once", q.Name)
+		}
+		names[q.Name] = true
+		if !q.Declare && (q.Durable || q.AutoDelete || q.Exclusive || len(q.Arguments) != 0) {
+			return fmt.Errorf("queue %q: durable, auto_delete, exclusive and arguments require declare", q.Name)
+		}
+	}
+	if (c.Username == "") != (c.Password == "") {
+		return errors.New("username and password must be set together")
+	}
+	return nil
+}
+
+// consumerTag returns the consumer tag of a queue. The tags of the consumers
+// of a channel must be unique, so the queue name is appended to the
+// configured tag.
+func (c *config) consumerTag(queue string) string {
+	if c.ConsumerTag == "" {
+		return ""
+	}
+	return c.ConsumerTag + "-" + queue
+}
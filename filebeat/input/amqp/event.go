@@ -0,0 +1,140 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package amqp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// newEvent returns the event of a delivery. The message properties are
+// stored under amqp and the headers under amqp.headers. The event timestamp
+// is the message timestamp when it is set.
+func newEvent(d amqp.Delivery, queue string, maxSize int64, now time.Time) (beat.Event, error) {
+	body, err := decodeBody(d.Body, d.ContentEncoding, maxSize)
+	if err != nil {
+		return beat.Event{}, err
+	}
+
+	fields := mapstr.M{
+		"queue":       queue,
+		"routing_key": d.RoutingKey,
+		"redelivered": d.Redelivered,
+	}
+	putString := func(key, value string) {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	putString("exchange", d.Exchange)
+	putString("content_type", d.ContentType)
+	putString("content_encoding", d.ContentEncoding)
+	putString("correlation_id", d.CorrelationId)
+	putString("reply_to", d.ReplyTo)
+	putString("expiration", d.Expiration)
+	putString("message_id", d.MessageId)
+	putString("type", d.Type)
+	putString("user_id", d.UserId)
+	putString("app_id", d.AppId)
+	if d.DeliveryMode != 0 {
+		fields["delivery_mode"] = d.DeliveryMode
+	}
+	if d.Priority != 0 {
+		fields["priority"] = d.Priority
+	}
+	if len(d.Headers) != 0 {
+		fields["headers"] = convertTable(d.Headers)
+	}
+
+	ts := now
+	if !d.Timestamp.IsZero() {
+		ts = d.Timestamp
+	}
+	return beat.Event{
+		Timestamp: ts,
+		Fields: mapstr.M{
+			"message": string(body),
+			"amqp":    fields,
+		},
+	}, nil
+}
+
+// decodeBody returns the message body, decompressed according to its
+// content encoding.
+func decodeBody(body []byte, encoding string, maxSize int64) ([]byte, error) {
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("message size %d exceeds the maximum of %d bytes", len(body), maxSize)
+	}
+	switch encoding {
+	case "", "identity", "utf-8", "UTF-8":
+		return body, nil
+	case "gzip":
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("decoding gzip message: %w", err)
+		}
+		// Read one more byte than allowed to detect oversized messages.
+		decoded, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("decoding gzip message: %w", err)
+		}
+		if int64(len(decoded)) > maxSize {
+			return nil, fmt.Errorf("decompressed message exceeds the maximum of %d bytes", maxSize)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// convertTable returns the fields of an AMQP table.
+func convertTable(t amqp.Table) mapstr.M {
+	m := make(mapstr.M, len(t))
+	for k, v := range t {
+		m[k] = convertValue(v)
+	}
+	return m
+}
+
+func convertValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case amqp.Table:
+		return convertTable(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = convertValue(e)
+		}
+		return values
+	case []byte:
+		return string(v)
+	case amqp.Decimal:
+		return float64(v.Value) / math.Pow10(int(v.Scale))
+	default:
+		return v
+	}
+}
@@ -0,0 +1,321 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/beats/v7/libbeat/common/backoff"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+	"github.com/elastic/go-concert/ctxtool"
+)
+
+const inputName = "amqp"
+
+func Plugin() input.Plugin {
+	return input.Plugin{
+		Name:       inputName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "AMQP input",
+		Doc:        "The AMQP input consumes messages from AMQP 0-9-1 queues, like RabbitMQ queues",
+		Manager:    input.ConfigureWith(configure),
+	}
+}
+
+func configure(cfg *conf.C) (input.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+	in := &amqpInput{config: config}
+	if config.TLS != nil {
+		tlsConfig, err := tlscommon.LoadTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		in.tlsConfig = tlsConfig
+	}
+	return in, nil
+}
+
+type amqpInput struct {
+	config    config
+	tlsConfig *tlscommon.TLSConfig
+}
+
+func (in *amqpInput) Name() string { return inputName }
+
+func (in *amqpInput) Test(_ input.TestContext) error {
+	conn, err := in.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	for _, q := range in.config.Queues {
+		if q.Declare {
+			continue
+		}
+		if _, err := ch.QueueDeclarePassive(q.Name, false, false, false, false, nil); err != nil {
+			return fmt.Errorf("queue %s: %w", q.Name, err)
+		}
+	}
+	return nil
+}
+
+func (in *amqpInput) Run(ctx input.Context, pipeline beat.Pipeline) error {
+	log := ctx.Logger.With("hosts", in.config.Hosts)
+
+	log.Info("Starting AMQP input")
+	defer log.Info("AMQP input stopped")
+
+	client, err := pipeline.ConnectWith(beat.ClientConfig{
+		EventListener: acker.ConnectionOnly(
+			acker.EventPrivateReporter(func(_ int, privates []interface{}) {
+				for _, p := range privates {
+					if d, ok := p.(*amqp.Delivery); ok {
+						ackDelivery(log, d)
+					}
+				}
+			}),
+		),
+		WaitClose: in.config.WaitClose,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	goContext := ctxtool.FromCanceller(ctx.Cancelation)
+	connectDelay := backoff.NewEqualJitterBackoff(goContext.Done(), in.config.ConnectBackoff, 8*in.config.ConnectBackoff)
+	for goContext.Err() == nil {
+		start := time.Now()
+		err := in.runConnection(goContext, log, client)
+		if goContext.Err() != nil {
+			break
+		}
+		log.Errorw("AMQP connection failed, reconnecting", "error", err)
+		// Reset the backoff when the connection was up for a while.
+		if time.Since(start) > 8*in.config.ConnectBackoff {
+			connectDelay.Reset()
+		}
+		connectDelay.Wait()
+	}
+	return nil
+}
+
+// runConnection connects to a broker, sets up the queues and publishes their
+// messages until the connection fails or ctx is cancelled. The messages that
+// were not acknowledged yet are requeued by the broker once the connection is
+// closed.
+func (in *amqpInput) runConnection(ctx context.Context, log *logp.Logger, client beat.Client) error {
+	conn, err := in.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	if err := ch.Qos(in.config.PrefetchCount, 0, false); err != nil {
+		return fmt.Errorf("setting prefetch count: %w", err)
+	}
+	deliveries := make(chan delivery)
+	done := make(chan struct{})
+	defer close(done)
+	for _, q := range in.config.Queues {
+		if err := setupQueue(ch, q); err != nil {
+			return err
+		}
+		msgs, err := ch.Consume(q.Name, in.config.consumerTag(q.Name), false, false, false, false, nil)
+		if err != nil {
+			return fmt.Errorf("consuming from queue %s: %w", q.Name, err)
+		}
+		go func(queue string) {
+			for d := range msgs {
+				select {
+				case deliveries <- delivery{queue: queue, Delivery: d}:
+				case <-done:
+					return
+				}
+			}
+		}(q.Name)
+	}
+	log.Infow("Consuming from AMQP queues", "url", conn.RemoteAddr().String(), "queues", len(in.config.Queues))
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Wait for the pending events to be acknowledged while the
+			// channel is still open.
+			client.Close()
+			return nil
+		case err := <-closed:
+			if err == nil {
+				return errors.New("channel closed")
+			}
+			return err
+		case d := <-deliveries:
+			in.handle(log, client, d)
+		}
+	}
+}
+
+// delivery is a message and the queue it was consumed from.
+type delivery struct {
+	queue string
+	amqp.Delivery
+}
+
+// handle publishes a delivery, it is acknowledged once its event is
+// acknowledged. Messages that can not be published are rejected according to
+// on_failure.
+func (in *amqpInput) handle(log *logp.Logger, client beat.Client, d delivery) {
+	evt, err := newEvent(d.Delivery, d.queue, int64(in.config.MaxMessageSize), time.Now())
+	if err != nil {
+		requeue := in.config.OnFailure == failureRequeue
+		log.Warnw("Rejecting message", "queue", d.queue, "delivery_tag", d.DeliveryTag, "requeue", requeue, "error", err)
+		if err := d.Nack(false, requeue); err != nil {
+			log.Warnw("Error rejecting message", "queue", d.queue, "error", err)
+		}
+		return
+	}
+	evt.Private = &d.Delivery
+	client.Publish(evt)
+}
+
+func ackDelivery(log *logp.Logger, d *amqp.Delivery) {
+	if err := d.Ack(false); err != nil {
+		// The message is redelivered by the broker when the channel was
+		// closed before it could be acknowledged.
+		log.Debugw("Error acknowledging message", "delivery_tag", d.DeliveryTag, "error", err)
+	}
+}
+
+// setupQueue declares and binds a queue as configured.
+func setupQueue(ch *amqp.Channel, q queueConfig) error {
+	if q.Declare {
+		args, err := toTable(q.Arguments)
+		if err != nil {
+			return fmt.Errorf("queue %s arguments: %w", q.Name, err)
+		}
+		if _, err := ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, q.Exclusive, false, args); err != nil {
+			return fmt.Errorf("declaring queue %s: %w", q.Name, err)
+		}
+	}
+	for _, b := range q.Bindings {
+		args, err := toTable(b.Arguments)
+		if err != nil {
+			return fmt.Errorf("queue %s binding arguments: %w", q.Name, err)
+		}
+		if err := ch.QueueBind(q.Name, b.RoutingKey, b.Exchange, false, args); err != nil {
+			return fmt.Errorf("binding queue %s to exchange %s: %w", q.Name, b.Exchange, err)
+		}
+	}
+	return nil
+}
+
+// toTable converts configured arguments to an AMQP table. Configuration
+// integers are unpacked as unsigned integers which AMQP does not support.
+func toTable(m map[string]interface{}) (amqp.Table, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	t := make(amqp.Table, len(m))
+	for k, v := range m {
+		t[k] = toField(v)
+	}
+	return t, t.Validate()
+}
+
+func toField(v interface{}) interface{} {
+	switch v := v.(type) {
+	case uint64:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case map[string]interface{}:
+		t := make(amqp.Table, len(v))
+		for k, e := range v {
+			t[k] = toField(e)
+		}
+		return t
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = toField(e)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// dial connects to the first reachable broker.
+func (in *amqpInput) dial() (*amqp.Connection, error) {
+	cfg := amqp.Config{
+		Vhost:     in.config.VHost,
+		Heartbeat: in.config.Heartbeat,
+		Locale:    "en_US",
+	}
+	if in.config.Username != "" {
+		cfg.SASL = []amqp.Authentication{&amqp.PlainAuth{Username: in.config.Username, Password: in.config.Password}}
+	} else if in.tlsConfig != nil && len(in.tlsConfig.Certificates) != 0 {
+		// Authenticate with the client certificate.
+		cfg.SASL = []amqp.Authentication{&amqp.ExternalAuth{}}
+	}
+
+	var errs []error
+	for _, h := range in.config.Hosts {
+		if in.tlsConfig != nil {
+			u, err := url.Parse(h)
+			if err != nil {
+				return nil, err
+			}
+			cfg.TLSClientConfig = in.tlsConfig.BuildModuleClientConfig(u.Hostname())
+		}
+		conn, err := amqp.DialConfig(h, cfg)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", h, err))
+	}
+	return nil, errors.Join(errs...)
+}
@@ -19,8 +19,16 @@ package inputs
 
 import (
 	"github.com/elastic/beats/v7/filebeat/beater"
+	"github.com/elastic/beats/v7/filebeat/input/amqp"
+	"github.com/elastic/beats/v7/filebeat/input/evtx"
 	"github.com/elastic/beats/v7/filebeat/input/filestream"
+	"github.com/elastic/beats/v7/filebeat/input/fluentforward"
+	"github.com/elastic/beats/v7/filebeat/input/gelf"
 	"github.com/elastic/beats/v7/filebeat/input/kafka"
+	"github.com/elastic/beats/v7/filebeat/input/nats"
+	"github.com/elastic/beats/v7/filebeat/input/otlp"
+	"github.com/elastic/beats/v7/filebeat/input/relp"
+	"github.com/elastic/beats/v7/filebeat/input/snmptrap"
 	"github.com/elastic/beats/v7/filebeat/input/tcp"
 	"github.com/elastic/beats/v7/filebeat/input/udp"
 	"github.com/elastic/beats/v7/filebeat/input/unix"
@@ -38,8 +46,16 @@ func Init(info beat.Info, log *logp.Logger, components beater.StateStore) []v2.P
 
 func genericInputs(log *logp.Logger, components beater.StateStore) []v2.Plugin {
 	return []v2.Plugin{
+		amqp.Plugin(),
+		evtx.Plugin(log, components),
 		filestream.Plugin(log, components),
+		fluentforward.Plugin(),
+		gelf.Plugin(),
 		kafka.Plugin(),
+		nats.Plugin(),
+		otlp.Plugin(),
+		relp.Plugin(),
+		snmptrap.Plugin(),
 		tcp.Plugin(),
 		udp.Plugin(),
 		unix.Plugin(),
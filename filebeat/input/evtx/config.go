@@ -0,0 +1,49 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package evtx
+
+import (
+	"errors"
+	"time"
+
+	"github.com/elastic/beats/v7/winlogbeat/eventlog"
+)
+
+// config stores the options of an evtx input.
+type config struct {
+	// Paths holds the glob patterns of the evtx files to read.
+	Paths []string `config:"paths"`
+	// ScanFrequency is how often the patterns are expanded to find new files.
+	ScanFrequency time.Duration `config:"scan_frequency" validate:"min=0,nonzero"`
+
+	eventlog.EvtxConfig `config:",inline"`
+}
+
+func defaultConfig() config {
+	return config{
+		ScanFrequency: 10 * time.Second,
+		EvtxConfig:    eventlog.DefaultEvtxConfig(),
+	}
+}
+
+func (c *config) Validate() error {
+	if len(c.Paths) == 0 {
+		return errors.New("no paths were defined for the evtx input")
+	}
+	return nil
+}
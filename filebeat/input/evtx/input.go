@@ -0,0 +1,260 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package evtx provides an input that reads exported Windows event log files
+// (.evtx) on any platform.
+package evtx
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	cursor "github.com/elastic/beats/v7/filebeat/input/v2/input-cursor"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/go-concert/ctxtool"
+	"github.com/elastic/go-concert/timed"
+
+	"github.com/elastic/beats/v7/winlogbeat/checkpoint"
+	"github.com/elastic/beats/v7/winlogbeat/eventlog"
+)
+
+const pluginName = "evtx"
+
+// checkpoints holds the position of each file read by an input, keyed by
+// the path of the file.
+type checkpoints map[string]checkpoint.EventLogState
+
+type publisher struct {
+	cursorPub   cursor.Publisher
+	checkpoints checkpoints
+}
+
+func (pub *publisher) Publish(records []eventlog.Record) error {
+	for i, record := range records {
+		pub.checkpoints[record.Offset.Name] = record.Offset
+
+		// The cursor is replaced by every update, so each update holds the
+		// checkpoints of all files. It is only updated with the last record
+		// of a batch.
+		var update interface{}
+		if i == len(records)-1 {
+			update = maps.Clone(pub.checkpoints)
+		}
+		if err := pub.cursorPub.Publish(record.ToEvent(), update); err != nil {
+			// Publisher indicates disconnect when returning an error.
+			// stop trying to publish records and quit
+			return err
+		}
+	}
+	return nil
+}
+
+// prune removes the checkpoints of the files that no longer match the path
+// patterns, so that the registry does not grow with rotated or archived
+// files.
+func (pub *publisher) prune(matched map[string]struct{}) error {
+	var removed bool
+	for path := range pub.checkpoints {
+		if _, ok := matched[path]; !ok {
+			delete(pub.checkpoints, path)
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	// The event has no fields, it only updates the cursor.
+	return pub.cursorPub.Publish(beat.Event{}, maps.Clone(pub.checkpoints))
+}
+
+// source is the set of files matching the path patterns of an input.
+type source struct {
+	patterns []string
+}
+
+func (s source) Name() string { return strings.Join(s.patterns, ",") }
+
+type evtxInput struct {
+	config config
+}
+
+// Plugin creates a stateful input Plugin collecting events from exported
+// Windows event log files.
+func Plugin(log *logp.Logger, store cursor.StateStore) input.Plugin {
+	return input.Plugin{
+		Name:       pluginName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "Windows event log files",
+		Doc:        "The evtx input reads events from exported Windows event log files",
+		Manager: &cursor.InputManager{
+			Logger:     log,
+			StateStore: store,
+			Type:       pluginName,
+			Configure:  configure,
+		},
+	}
+}
+
+func configure(cfg *conf.C) ([]cursor.Source, cursor.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, nil, err
+	}
+
+	for _, pattern := range config.Paths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+
+	sources := []cursor.Source{source{patterns: config.Paths}}
+	return sources, evtxInput{config: config}, nil
+}
+
+// expandPaths returns the files matching the glob patterns, each of them only
+// once.
+func expandPaths(patterns []string) ([]string, error) {
+	seen := map[string]struct{}{}
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+		for _, path := range matches {
+			if _, ok := seen[path]; ok {
+				continue
+			}
+			seen[path] = struct{}{}
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (evtxInput) Name() string { return pluginName }
+
+func (in evtxInput) Test(src cursor.Source, ctx input.TestContext) error {
+	paths, err := expandPaths(src.(source).patterns)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		api := eventlog.NewEvtxFile(path, in.config.EvtxConfig, ctx.Logger)
+		if err := api.Open(checkpoint.EventLogState{}); err != nil {
+			return fmt.Errorf("failed to open %q: %w", api.Name(), err)
+		}
+		if err := api.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run expands the path patterns every scan_frequency and reads the records
+// of the new files. Each file is read once from start to end. The identifier
+// of the last published record of each file is stored in the registry, so
+// that a file is not read again after a restart.
+func (in evtxInput) Run(
+	ctx input.Context,
+	src cursor.Source,
+	cursor cursor.Cursor,
+	pub cursor.Publisher,
+) error {
+	log := ctx.Logger
+	cancelCtx := ctxtool.FromCanceller(ctx.Cancelation)
+	publisher := &publisher{cursorPub: pub, checkpoints: initCheckpoints(log, cursor)}
+
+	// read holds the size of the files that have been read to the end. A
+	// file is read again if its size changes.
+	read := map[string]int64{}
+	for {
+		paths, err := expandPaths(src.(source).patterns)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			log.Debugf("No evtx files match the paths %v", src.(source).patterns)
+		}
+
+		matched := make(map[string]struct{}, len(paths))
+		for _, path := range paths {
+			matched[path] = struct{}{}
+		}
+		for path := range read {
+			if _, ok := matched[path]; !ok {
+				delete(read, path)
+			}
+		}
+		if err := publisher.prune(matched); err != nil {
+			return nil //nolint:nilerr // the input is stopping
+		}
+
+		for _, path := range paths {
+			if ctx.Cancelation.Err() != nil {
+				return nil
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Warnw("Failed to stat evtx file.", "file", path, "error", err)
+				continue
+			}
+			if size, ok := read[path]; ok && size == info.Size() {
+				continue
+			}
+
+			fileLog := log.With("file", path)
+			api := eventlog.NewEvtxFile(path, in.config.EvtxConfig, fileLog)
+			err = eventlog.Run(cancelCtx, api, publisher.checkpoints[path], publisher, fileLog)
+			if ctx.Cancelation.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				fileLog.Errorw("Failed to read evtx file.", "error", err)
+			}
+			read[path] = info.Size()
+		}
+
+		if err := timed.Wait(cancelCtx, in.config.ScanFrequency); err != nil {
+			return nil //nolint:nilerr // the input is stopping
+		}
+	}
+}
+
+func initCheckpoints(log *logp.Logger, cursor cursor.Cursor) checkpoints {
+	cp := checkpoints{}
+	if cursor.IsNew() {
+		return cp
+	}
+
+	if err := cursor.Unpack(&cp); err != nil {
+		log.Errorf("Reset evtx positions. Failed to read checkpoints from registry: %v", err)
+		return checkpoints{}
+	}
+
+	return cp
+}
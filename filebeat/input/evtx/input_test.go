@@ -0,0 +1,238 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package evtx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	pubtest "github.com/elastic/beats/v7/libbeat/publisher/testing"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/storetest"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+
+	"github.com/elastic/beats/v7/winlogbeat/checkpoint"
+	"github.com/elastic/beats/v7/winlogbeat/eventlog"
+)
+
+const testdata = "../../../winlogbeat/sys/wineventlog/testdata/"
+
+func TestExpandPaths(t *testing.T) {
+	paths, err := expandPaths([]string{
+		testdata + "ec*.evtx",
+		testdata + "ec1.evtx",
+		testdata + "sysmon-9.01.evtx",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		testdata + "ec1.evtx",
+		testdata + "ec2.evtx",
+		testdata + "ec3.evtx",
+		testdata + "ec3and4.evtx",
+		testdata + "ec4.evtx",
+		testdata + "sysmon-9.01.evtx",
+	}, paths)
+
+	paths, err = expandPaths([]string{testdata + "*.missing"})
+	require.NoError(t, err, "no matching file is not an error")
+	assert.Empty(t, paths)
+}
+
+func TestConfigure(t *testing.T) {
+	cfg := conf.MustNewConfigFrom(map[string]interface{}{
+		"paths": []string{testdata + "*.missing"},
+	})
+
+	sources, inp, err := configure(cfg)
+	require.NoError(t, err, "files can be added after the input is started")
+	assert.Equal(t, pluginName, inp.Name())
+	require.Len(t, sources, 1)
+	assert.Equal(t, testdata+"*.missing", sources[0].Name())
+}
+
+func TestConfigureErrors(t *testing.T) {
+	for name, settings := range map[string]map[string]interface{}{
+		"no paths":            {},
+		"bad pattern":         {"paths": []string{testdata + "[.evtx"}},
+		"zero batch size":     {"paths": []string{testdata + "ec1.evtx"}, "batch_read_size": 0},
+		"zero scan frequency": {"paths": []string{testdata + "ec1.evtx"}, "scan_frequency": 0},
+	} {
+		_, _, err := configure(conf.MustNewConfigFrom(settings))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestRunScansPaths(t *testing.T) {
+	dir := t.TempDir()
+	log := logp.NewLogger("test")
+	store := newTestStateStore(t)
+	cfg := conf.MustNewConfigFrom(map[string]interface{}{
+		"paths":          []string{filepath.Join(dir, "*.evtx")},
+		"scan_frequency": "10ms",
+	})
+
+	var mu sync.Mutex
+	var published int
+	pipeline := &pubtest.FakeConnector{
+		ConnectFunc: func(cfg beat.ClientConfig) (beat.Client, error) {
+			listener := cfg.EventListener
+			return &pubtest.FakeClient{
+				PublishFunc: func(event beat.Event) {
+					mu.Lock()
+					// Events without fields only update the cursor.
+					if event.Fields != nil {
+						published++
+					}
+					mu.Unlock()
+					listener.AddEvent(event, true)
+					listener.ACKEvents(1)
+				},
+			}, nil
+		},
+	}
+	publishedEvents := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return published
+	}
+
+	run := func(t *testing.T, files ...string) {
+		manager := Plugin(log, store).Manager
+		inp, err := manager.Create(cfg)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = inp.Run(input.Context{Logger: log, Cancelation: ctx}, pipeline)
+		}()
+		defer wg.Wait()
+		defer cancel()
+
+		want := publishedEvents()
+		for _, name := range files {
+			copyFile(t, testdata+name, filepath.Join(dir, name))
+			want += countRecords(t, testdata+name)
+			require.Eventually(t, func() bool { return publishedEvents() == want }, 10*time.Second, 10*time.Millisecond,
+				"the records of %s are published", name)
+		}
+		// No record is published twice.
+		time.Sleep(50 * time.Millisecond)
+		require.Equal(t, want, publishedEvents())
+	}
+
+	// The input starts without any matching file.
+	run(t, "ec1.evtx", "ec2.evtx")
+
+	// The files are not read again after a restart.
+	run(t)
+}
+
+func TestPublisher(t *testing.T) {
+	cursorPub := &testCursorPublisher{}
+	pub := &publisher{cursorPub: cursorPub, checkpoints: checkpoints{}}
+
+	record := func(name string, n uint64) eventlog.Record {
+		return eventlog.Record{Offset: checkpoint.EventLogState{Name: name, RecordNumber: n}}
+	}
+	require.NoError(t, pub.Publish([]eventlog.Record{record("a.evtx", 1), record("b.evtx", 1), record("a.evtx", 2)}))
+
+	// Only the last record of a batch updates the cursor.
+	want := checkpoints{
+		"a.evtx": {Name: "a.evtx", RecordNumber: 2},
+		"b.evtx": {Name: "b.evtx", RecordNumber: 1},
+	}
+	assert.Equal(t, []interface{}{nil, nil, want}, cursorPub.cursors)
+
+	// The checkpoints of the files that no longer match are removed.
+	require.NoError(t, pub.prune(map[string]struct{}{"a.evtx": {}}))
+	require.Len(t, cursorPub.cursors, 4)
+	assert.Nil(t, cursorPub.events[3].Fields)
+	assert.Equal(t, checkpoints{"a.evtx": {Name: "a.evtx", RecordNumber: 2}}, cursorPub.cursors[3])
+
+	// The cursor is not updated when no checkpoint is removed.
+	require.NoError(t, pub.prune(map[string]struct{}{"a.evtx": {}}))
+	assert.Len(t, cursorPub.cursors, 4)
+}
+
+type testCursorPublisher struct {
+	events  []beat.Event
+	cursors []interface{}
+}
+
+func (p *testCursorPublisher) Publish(event beat.Event, cursor interface{}) error {
+	p.events = append(p.events, event)
+	p.cursors = append(p.cursors, cursor)
+	return nil
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dst, data, 0o600))
+}
+
+func countRecords(t *testing.T, path string) int {
+	t.Helper()
+	api := eventlog.NewEvtxFile(path, eventlog.DefaultEvtxConfig(), logp.NewLogger("test"))
+	require.NoError(t, api.Open(checkpoint.EventLogState{}))
+	defer api.Close()
+
+	var n int
+	for {
+		records, err := api.Read()
+		if errors.Is(err, io.EOF) {
+			return n
+		}
+		require.NoError(t, err)
+		n += len(records)
+	}
+}
+
+type testStateStore struct {
+	store *statestore.Store
+}
+
+func newTestStateStore(t *testing.T) testStateStore {
+	reg := statestore.NewRegistry(storetest.NewMemoryStoreBackend())
+	store, err := reg.Get("test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		store.Close()
+		reg.Close()
+	})
+	return testStateStore{store: store}
+}
+
+func (s testStateStore) Access(string) (*statestore.Store, error) { return s.store, nil }
+func (s testStateStore) CleanupInterval() time.Duration           { return time.Hour }
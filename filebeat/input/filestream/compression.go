@@ -0,0 +1,188 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionMode configures how filestream handles compressed files.
+type compressionMode string
+
+const (
+	// compressionAuto detects gzip and zstd files by their magic bytes
+	// and decompresses them while reading.
+	compressionAuto compressionMode = "auto"
+	// compressionNone reads every file as is.
+	compressionNone compressionMode = "none"
+)
+
+const (
+	compressionFormatGzip = "gzip"
+	compressionFormatZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+func (m *compressionMode) Unpack(v string) error {
+	switch mode := compressionMode(v); mode {
+	case compressionAuto, compressionNone:
+		*m = mode
+		return nil
+	case "":
+		*m = compressionNone
+		return nil
+	default:
+		return fmt.Errorf("unknown compression mode '%s', supported modes: %s, %s", v, compressionAuto, compressionNone)
+	}
+}
+
+// detectCompression returns the compression format of the file based on
+// its magic bytes. An empty string is returned for uncompressed files.
+// The read position of the file is not modified.
+func detectCompression(f *os.File) (string, error) {
+	var magic [4]byte
+	n, err := f.ReadAt(magic[:], 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read magic bytes of %s: %w", f.Name(), err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic[:n], gzipMagic):
+		return compressionFormatGzip, nil
+	case bytes.HasPrefix(magic[:n], zstdMagic):
+		return compressionFormatZstd, nil
+	default:
+		return "", nil
+	}
+}
+
+// detectFileCompression returns the compression format of the file at path.
+func detectFileCompression(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return detectCompression(f)
+}
+
+// decompressor reads the uncompressed content of a compressed file.
+// The offset is counted in uncompressed bytes, so the registry state
+// of compressed files always points into the uncompressed stream.
+type decompressor struct {
+	format string
+	offset int64
+
+	// mu guards r and eof, as the reader can be closed while a read is in progress.
+	mu sync.Mutex
+	r  io.ReadCloser
+	// eof is set once the end of a complete compressed stream is reached.
+	eof bool
+}
+
+// newDecompressor creates a decompressor reading f from the beginning and
+// skips the first offset bytes of the uncompressed stream.
+func newDecompressor(f *os.File, format string, offset int64) (*decompressor, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var r io.ReadCloser
+	switch format {
+	case compressionFormatGzip:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader for %s: %w", f.Name(), err)
+		}
+		r = gz
+	case compressionFormatZstd:
+		zr, err := zstd.NewReader(f, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader for %s: %w", f.Name(), err)
+		}
+		r = zr.IOReadCloser()
+	default:
+		return nil, fmt.Errorf("unsupported compression format '%s'", format)
+	}
+
+	d := &decompressor{format: format, r: r}
+	if offset > 0 {
+		// Compressed streams cannot be seeked, the data before the
+		// offset has to be decompressed and discarded.
+		_, err := io.CopyN(io.Discard, d, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			d.Close()
+			return nil, fmt.Errorf("failed to skip to offset %d of %s: %w", offset, f.Name(), err)
+		}
+	}
+
+	return d, nil
+}
+
+// Read reads decompressed data. An incomplete stream, for example a file
+// that is still being compressed, is reported as io.EOF.
+func (d *decompressor) Read(buf []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.r == nil {
+		return 0, ErrClosed
+	}
+
+	n, err := d.r.Read(buf)
+	d.offset += int64(n)
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		err = io.EOF
+	case errors.Is(err, io.EOF):
+		d.eof = true
+	}
+	return n, err
+}
+
+// complete reports whether the whole compressed stream has been read.
+func (d *decompressor) complete() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.eof
+}
+
+// Close releases the decompressor. The underlying file is not closed.
+func (d *decompressor) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.r == nil {
+		return nil
+	}
+	err := d.r.Close()
+	d.r = nil
+	return err
+}
@@ -0,0 +1,213 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/reader/readfile/encoding"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const compressionTestContent = "first line\nsecond line\nthird line\n"
+
+func TestDetectCompression(t *testing.T) {
+	testCases := map[string]struct {
+		content  []byte
+		expected string
+	}{
+		"plain":      {content: []byte(compressionTestContent), expected: ""},
+		"empty":      {content: []byte{}, expected: ""},
+		"short":      {content: []byte{0x1f}, expected: ""},
+		"gzip":       {content: compressTestContent(t, compressionFormatGzip, compressionTestContent), expected: compressionFormatGzip},
+		"zstd":       {content: compressTestContent(t, compressionFormatZstd, compressionTestContent), expected: compressionFormatZstd},
+		"zstd magic": {content: zstdMagic, expected: compressionFormatZstd},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f := writeCompressionTestFile(t, tc.content)
+
+			format, err := detectCompression(f)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, format)
+
+			pos, err := f.Seek(0, io.SeekCurrent)
+			require.NoError(t, err)
+			require.Zero(t, pos, "detection must not move the read position")
+		})
+	}
+}
+
+func TestDecompressor(t *testing.T) {
+	for _, format := range []string{compressionFormatGzip, compressionFormatZstd} {
+		t.Run(format, func(t *testing.T) {
+			f := writeCompressionTestFile(t, compressTestContent(t, format, compressionTestContent))
+
+			t.Run("from start", func(t *testing.T) {
+				dec, err := newDecompressor(f, format, 0)
+				require.NoError(t, err)
+				defer dec.Close()
+
+				content, err := io.ReadAll(dec)
+				require.NoError(t, err)
+				require.Equal(t, compressionTestContent, string(content))
+				require.Equal(t, int64(len(compressionTestContent)), dec.offset)
+				require.True(t, dec.complete(), "the whole stream has been read")
+			})
+
+			t.Run("from uncompressed offset", func(t *testing.T) {
+				offset := int64(len("first line\n"))
+				dec, err := newDecompressor(f, format, offset)
+				require.NoError(t, err)
+				defer dec.Close()
+				require.Equal(t, offset, dec.offset)
+
+				content, err := io.ReadAll(dec)
+				require.NoError(t, err)
+				require.Equal(t, "second line\nthird line\n", string(content))
+			})
+
+			t.Run("offset past the end", func(t *testing.T) {
+				dec, err := newDecompressor(f, format, 1024)
+				require.NoError(t, err)
+				defer dec.Close()
+
+				content, err := io.ReadAll(dec)
+				require.NoError(t, err)
+				require.Empty(t, content)
+			})
+		})
+	}
+}
+
+func TestDecompressorIncompleteStream(t *testing.T) {
+	compressed := compressTestContent(t, compressionFormatGzip, strings.Repeat(compressionTestContent, 100))
+	f := writeCompressionTestFile(t, compressed[:len(compressed)/2])
+
+	dec, err := newDecompressor(f, compressionFormatGzip, 0)
+	require.NoError(t, err)
+	defer dec.Close()
+
+	// a file which is still being compressed is read until its current end
+	_, err = io.Copy(io.Discard, dec)
+	require.NoError(t, err)
+	require.False(t, dec.complete(), "an incomplete stream is not complete")
+}
+
+func TestCompressionDefault(t *testing.T) {
+	cfg := defaultConfig()
+	require.NoError(t, conf.MustNewConfigFrom(map[string]interface{}{"paths": []string{"/var/log/*"}}).Unpack(&cfg))
+	require.Equal(t, compressionNone, cfg.Reader.Compression, "compression must be opt-in")
+
+	var mode compressionMode
+	require.NoError(t, mode.Unpack(""))
+	require.Equal(t, compressionNone, mode)
+}
+
+func TestCompressedFileReaderClosesOnEOF(t *testing.T) {
+	f := writeCompressionTestFile(t, compressTestContent(t, compressionFormatGzip, compressionTestContent))
+
+	cfg := defaultReaderConfig()
+	cfg.Compression = compressionAuto
+	inp := &filestream{
+		readerConfig:    cfg,
+		encodingFactory: encoding.Plain,
+	}
+	f, dec, _, truncated, err := inp.openFile(logp.L(), f.Name(), 6)
+	require.NoError(t, err)
+	require.NotNil(t, dec)
+	require.False(t, truncated, "compressed files are never truncated")
+
+	reader := newCompressedFileReader(logp.L(), context.TODO(), f, dec, inp.readerConfig, defaultCloserConfig())
+	defer reader.Close()
+
+	var content bytes.Buffer
+	buf := make([]byte, 1024)
+	for {
+		n, err := reader.Read(buf)
+		content.Write(buf[:n])
+		if err != nil {
+			require.ErrorIs(t, err, io.EOF)
+			break
+		}
+	}
+	require.Equal(t, compressionTestContent[6:], content.String())
+	require.Equal(t, int64(len(compressionTestContent)), reader.offset)
+}
+
+func TestCompressionNone(t *testing.T) {
+	compressed := compressTestContent(t, compressionFormatGzip, compressionTestContent)
+	f := writeCompressionTestFile(t, compressed)
+
+	cfg := defaultReaderConfig()
+	cfg.Compression = compressionNone
+	inp := &filestream{
+		readerConfig:    cfg,
+		encodingFactory: encoding.Plain,
+	}
+	f, dec, _, _, err := inp.openFile(logp.L(), f.Name(), 0)
+	require.NoError(t, err)
+	defer f.Close()
+	require.Nil(t, dec)
+}
+
+func compressTestContent(t *testing.T, format string, content string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch format {
+	case compressionFormatGzip:
+		w = gzip.NewWriter(&buf)
+	case compressionFormatZstd:
+		zw, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		w = zw
+	default:
+		t.Fatalf("unknown compression format %s", format)
+	}
+
+	_, err := w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func writeCompressionTestFile(t *testing.T, content []byte) *os.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.log.gz")
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	return f
+}
@@ -47,6 +47,13 @@ type config struct {
 	IgnoreInactive ignoreInactiveType `config:"ignore_inactive"`
 	Rotation       *conf.Namespace    `config:"rotation"`
 	TakeOver       bool               `config:"take_over"`
+
+	// StartFrom defines where new files are started to be read: beginning or timestamp.
+	StartFrom      string               `config:"start_from"`
+	StartTimestamp startTimestampConfig `config:"start_timestamp"`
+
+	// ContentDedup skips the content of new files that has already been read.
+	ContentDedup contentDedupConfig `config:"content_dedup"`
 }
 
 type closerConfig struct {
@@ -69,6 +76,7 @@ type stateChangeCloserConfig struct {
 type readerConfig struct {
 	Backoff        backoffConfig           `config:"backoff"`
 	BufferSize     int                     `config:"buffer_size"`
+	Compression    compressionMode         `config:"compression"`
 	Encoding       string                  `config:"encoding"`
 	ExcludeLines   []match.Matcher         `config:"exclude_lines"`
 	IncludeLines   []match.Matcher         `config:"include_lines"`
@@ -104,6 +112,9 @@ func defaultConfig() config {
 		CleanRemoved:   true,
 		HarvesterLimit: 0,
 		IgnoreOlder:    0,
+		StartFrom:      startFromBeginning,
+		StartTimestamp: defaultStartTimestampConfig(),
+		ContentDedup:   defaultContentDedupConfig(),
 	}
 }
 
@@ -129,6 +140,7 @@ func defaultReaderConfig() readerConfig {
 			Max:  10 * time.Second,
 		},
 		BufferSize:     16 * humanize.KiByte,
+		Compression:    compressionNone,
 		LineTerminator: readfile.AutoLineTerminator,
 		MaxBytes:       10 * humanize.MiByte,
 		Tail:           false,
@@ -140,5 +152,15 @@ func (c *config) Validate() error {
 		return fmt.Errorf("no path is configured")
 	}
 
+	switch c.StartFrom {
+	case startFromBeginning:
+	case startFromTimestamp:
+		if c.StartTimestamp.Time == "" && c.StartTimestamp.Since == 0 {
+			return fmt.Errorf("start_timestamp.time or start_timestamp.since must be set when start_from is %s", startFromTimestamp)
+		}
+	default:
+		return fmt.Errorf("unknown start_from value '%s', supported values: %s, %s", c.StartFrom, startFromBeginning, startFromTimestamp)
+	}
+
 	return nil
 }
@@ -0,0 +1,325 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// rollingHashBase is the multiplier of the polynomial rolling hash over the
+// hashes of consecutive lines.
+const rollingHashBase = 1099511628211
+
+// contentDedupConfig configures the detection of files whose content has
+// already been read by the input, e.g. the copies of copytruncate rotation.
+type contentDedupConfig struct {
+	Enabled bool `config:"enabled"`
+	// Window is the number of recently read lines the input keeps track of.
+	Window int `config:"window" validate:"min=1"`
+	// MatchLines is the number of lines at the beginning of a new file that
+	// must match already read lines in order to skip them.
+	MatchLines int `config:"match_lines" validate:"min=1"`
+}
+
+func defaultContentDedupConfig() contentDedupConfig {
+	return contentDedupConfig{
+		Enabled:    false,
+		Window:     10000,
+		MatchLines: 3,
+	}
+}
+
+func (c *contentDedupConfig) Validate() error {
+	if c.MatchLines > c.Window {
+		return errors.New("content_dedup.match_lines must not be greater than content_dedup.window")
+	}
+	return nil
+}
+
+// contentIndex keeps the hashes of the lines recently read by the harvesters
+// of an input, so a new file starting with content that has already been read
+// can be resumed after it.
+//
+// The lines read from a file, from the offset a harvester started at, form a
+// stream. Every range of matchLines consecutive lines of a stream is indexed
+// by its rolling hash. Once more than window lines are tracked, the oldest
+// ones are forgotten.
+//
+// The index is only kept in memory, it starts empty when the input starts.
+type contentIndex struct {
+	mu sync.Mutex
+
+	window     int
+	matchLines int
+	// basePow is rollingHashBase to the power of matchLines, used to remove
+	// the oldest line from a rolling hash.
+	basePow uint64
+
+	ranges  map[uint64][]lineRef
+	streams map[string]*contentStream
+
+	// tracked holds all tracked lines in the order they were read.
+	tracked []lineRef
+	oldest  int
+}
+
+// contentStream is the sequence of lines read by a harvester.
+type contentStream struct {
+	idx *contentIndex
+	key string
+
+	// first is the position in the stream of lines[0].
+	first int
+	lines []trackedLine
+	// end is the offset in the file after the last line.
+	end int64
+
+	// recent holds the hashes of the last matchLines lines.
+	recent  []uint64
+	rolling uint64
+}
+
+type trackedLine struct {
+	hash uint64
+	// rangeHash is the rolling hash of the range of lines ending with this
+	// line, it is only set if hasRange is true.
+	rangeHash uint64
+	hasRange  bool
+}
+
+// lineRef refers to the line at position pos of a stream.
+type lineRef struct {
+	stream *contentStream
+	pos    int
+}
+
+func (r lineRef) line() (trackedLine, bool) {
+	i := r.pos - r.stream.first
+	if i < 0 || i >= len(r.stream.lines) {
+		return trackedLine{}, false
+	}
+	return r.stream.lines[i], true
+}
+
+// newContentIndex returns nil if content deduplication is disabled.
+func newContentIndex(c contentDedupConfig) *contentIndex {
+	if !c.Enabled {
+		return nil
+	}
+
+	basePow := uint64(1)
+	for i := 0; i < c.MatchLines; i++ {
+		basePow *= rollingHashBase
+	}
+	return &contentIndex{
+		window:     c.Window,
+		matchLines: c.MatchLines,
+		basePow:    basePow,
+		ranges:     map[uint64][]lineRef{},
+		streams:    map[string]*contentStream{},
+		tracked:    make([]lineRef, 0, c.Window),
+	}
+}
+
+func hashContent(content []byte) uint64 {
+	return xxhash.Sum64(content)
+}
+
+// stream returns the stream the lines a harvester reads from offset on are
+// added to. If the previous stream of the source ends at offset it is
+// continued, otherwise a new stream is started.
+func (idx *contentIndex) stream(key string, offset int64) *contentStream {
+	if idx == nil {
+		return nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if s, ok := idx.streams[key]; ok && s.end == offset {
+		return s
+	}
+	s := &contentStream{idx: idx, key: key, end: offset}
+	idx.streams[key] = s
+	return s
+}
+
+// add tracks a line whose content has been read, end is the offset after
+// the line.
+func (s *contentStream) add(content []byte, end int64) {
+	if s == nil {
+		return
+	}
+	hash := hashContent(content)
+
+	idx := s.idx
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	line := trackedLine{hash: hash}
+	s.rolling = s.rolling*rollingHashBase + hash
+	if len(s.recent) == idx.matchLines {
+		s.rolling -= s.recent[0] * idx.basePow
+		s.recent = s.recent[1:]
+	}
+	s.recent = append(s.recent, hash)
+	if len(s.recent) == idx.matchLines {
+		line.rangeHash, line.hasRange = s.rolling, true
+	}
+
+	ref := lineRef{stream: s, pos: s.first + len(s.lines)}
+	s.lines = append(s.lines, line)
+	s.end = end
+	if line.hasRange {
+		idx.ranges[line.rangeHash] = append(idx.ranges[line.rangeHash], ref)
+	}
+
+	if len(idx.tracked) < idx.window {
+		idx.tracked = append(idx.tracked, ref)
+		return
+	}
+	idx.evict(idx.tracked[idx.oldest])
+	idx.tracked[idx.oldest] = ref
+	idx.oldest = (idx.oldest + 1) % idx.window
+}
+
+// evict forgets the oldest line of a stream.
+func (idx *contentIndex) evict(ref lineRef) {
+	s := ref.stream
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	s.first++
+
+	if line.hasRange {
+		refs := idx.ranges[line.rangeHash]
+		for i, r := range refs {
+			if r == ref {
+				refs = append(refs[:i], refs[i+1:]...)
+				break
+			}
+		}
+		if len(refs) == 0 {
+			delete(idx.ranges, line.rangeHash)
+		} else {
+			idx.ranges[line.rangeHash] = refs
+		}
+	}
+
+	if len(s.lines) == 0 && idx.streams[s.key] == s {
+		delete(idx.streams, s.key)
+	}
+}
+
+// match compares the lines returned by next, the beginning of a new file,
+// with the tracked lines. next returns the hash of a line and the offset
+// after it.
+//
+// If the first matchLines lines have been read before, the lines are
+// compared as long as they continue to match already read content and the
+// offset after the last matching line is returned. next returns io.EOF
+// at the end of the file.
+func (idx *contentIndex) match(next func() (uint64, int64, error)) (int64, bool, error) {
+	var hashes []uint64
+	var rolling uint64
+	var offset int64
+	for len(hashes) < idx.matchLines {
+		hash, end, err := next()
+		if errors.Is(err, io.EOF) {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		hashes = append(hashes, hash)
+		rolling = rolling*rollingHashBase + hash
+		offset = end
+	}
+
+	// Candidates are the tracked ranges with the same hash and lines.
+	idx.mu.Lock()
+	var candidates []lineRef
+	for _, ref := range idx.ranges[rolling] {
+		if idx.rangeMatches(ref, hashes) {
+			candidates = append(candidates, ref)
+		}
+	}
+	idx.mu.Unlock()
+	if len(candidates) == 0 {
+		return 0, false, nil
+	}
+
+	for {
+		// Stop once all content read from a stream has been matched.
+		if idx.anyAtEnd(candidates) {
+			return offset, true, nil
+		}
+
+		hash, end, err := next()
+		if errors.Is(err, io.EOF) {
+			// Everything up to the end of the file has been read before.
+			return offset, true, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+
+		idx.mu.Lock()
+		remaining := candidates[:0]
+		for _, ref := range candidates {
+			ref.pos++
+			if line, ok := ref.line(); ok && line.hash == hash {
+				remaining = append(remaining, ref)
+			}
+		}
+		idx.mu.Unlock()
+
+		if len(remaining) == 0 {
+			return offset, true, nil
+		}
+		candidates = remaining
+		offset = end
+	}
+}
+
+// rangeMatches verifies the line hashes of a range to rule out collisions of
+// the rolling hash.
+func (idx *contentIndex) rangeMatches(ref lineRef, hashes []uint64) bool {
+	for i := range hashes {
+		line, ok := lineRef{stream: ref.stream, pos: ref.pos - len(hashes) + 1 + i}.line()
+		if !ok || line.hash != hashes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *contentIndex) anyAtEnd(candidates []lineRef) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, ref := range candidates {
+		if ref.pos == ref.stream.first+len(ref.stream.lines)-1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/reader/parser"
+)
+
+func TestContentIndexMatch(t *testing.T) {
+	read := []string{"a", "b", "c", "d", "e"}
+
+	tests := map[string]struct {
+		window    int
+		file      []string
+		wantFound bool
+		// wantLines is the number of lines of the file that are skipped.
+		wantLines int
+	}{
+		"copy of read content": {
+			file:      []string{"a", "b", "c", "d", "e", "f", "g"},
+			wantFound: true,
+			wantLines: 5,
+		},
+		"copy of a part of the read content": {
+			file:      []string{"a", "b", "c", "d"},
+			wantFound: true,
+			wantLines: 4,
+		},
+		"starts in the middle of read content": {
+			file:      []string{"b", "c", "d", "e", "f"},
+			wantFound: true,
+			wantLines: 4,
+		},
+		"diverges from read content": {
+			file:      []string{"a", "b", "c", "x", "e"},
+			wantFound: true,
+			wantLines: 3,
+		},
+		"head does not match": {
+			file:      []string{"a", "b", "x", "d", "e"},
+			wantFound: false,
+		},
+		"file shorter than match lines": {
+			file:      []string{"a", "b"},
+			wantFound: false,
+		},
+		"head has been forgotten": {
+			window:    3,
+			file:      []string{"a", "b", "c", "d", "e"},
+			wantFound: false,
+		},
+		"tail is still tracked": {
+			window:    3,
+			file:      []string{"c", "d", "e", "f"},
+			wantFound: true,
+			wantLines: 3,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := defaultContentDedupConfig()
+			cfg.Enabled = true
+			if tc.window > 0 {
+				cfg.Window = tc.window
+			}
+			idx := newContentIndex(cfg)
+			addLines(idx.stream("read", 0), read)
+
+			offset, found, err := idx.match(linesOf(tc.file))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFound, found)
+			assert.EqualValues(t, 2*tc.wantLines, offset)
+		})
+	}
+}
+
+func TestContentIndexStreams(t *testing.T) {
+	cfg := defaultContentDedupConfig()
+	cfg.Enabled = true
+	idx := newContentIndex(cfg)
+
+	// A harvester resuming at the end of its previous lines continues the stream.
+	addLines(idx.stream("file", 0), []string{"a", "b"})
+	addLines(idx.stream("file", 4), []string{"c", "d"})
+
+	offset, found, err := idx.match(linesOf([]string{"a", "b", "c", "d", "e"}))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.EqualValues(t, 8, offset)
+
+	// After a truncation the new lines start a new stream, the previous one
+	// is still tracked.
+	addLines(idx.stream("file", 0), []string{"x", "y", "z"})
+
+	offset, found, err = idx.match(linesOf([]string{"a", "b", "c", "d", "e"}))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.EqualValues(t, 8, offset)
+
+	offset, found, err = idx.match(linesOf([]string{"x", "y", "z"}))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.EqualValues(t, 6, offset)
+}
+
+func TestContentIndexEviction(t *testing.T) {
+	cfg := defaultContentDedupConfig()
+	cfg.Enabled = true
+	cfg.Window = 4
+	idx := newContentIndex(cfg)
+
+	addLines(idx.stream("first", 0), []string{"a", "b", "c"})
+	addLines(idx.stream("second", 0), []string{"d", "e", "f", "g"})
+
+	assert.NotContains(t, idx.streams, "first", "all lines of the first stream are evicted")
+	assert.Len(t, idx.tracked, 4)
+	for _, refs := range idx.ranges {
+		for _, ref := range refs {
+			assert.Equal(t, "second", ref.stream.key)
+		}
+	}
+	assert.Len(t, idx.ranges, 2)
+}
+
+func TestScannedStates(t *testing.T) {
+	var states scannedStates
+	header := []string{"a", "b"}
+	states.add(4, parser.State{})
+	states.add(8, parser.State{CSVHeader: header})
+	header[0] = "changed"
+	states.add(12, parser.State{CSVHeader: []string{"c"}})
+
+	// The content matched up to the line before the last one read.
+	assert.Equal(t, parser.State{CSVHeader: []string{"a", "b"}}, states.at(8))
+	assert.Equal(t, parser.State{CSVHeader: []string{"c"}}, states.at(12))
+}
+
+func TestContentDedupDisabled(t *testing.T) {
+	idx := newContentIndex(defaultContentDedupConfig())
+	require.Nil(t, idx)
+
+	// Harvesters call the stream of a disabled index.
+	idx.stream("file", 0).add([]byte("line"), 5)
+}
+
+func addLines(s *contentStream, lines []string) {
+	for _, line := range lines {
+		s.add([]byte(line), s.end+int64(len(line))+1)
+	}
+}
+
+// linesOf returns the lines the way the harvester reads them, each line is
+// followed by a newline.
+func linesOf(lines []string) func() (uint64, int64, error) {
+	var offset int64
+	return func() (uint64, int64, error) {
+		if len(lines) == 0 {
+			return 0, 0, io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		offset += int64(len(line)) + 1
+		return hashContent([]byte(line)), offset, nil
+	}
+}
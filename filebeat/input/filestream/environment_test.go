@@ -60,7 +60,9 @@ type inputTestingEnvironment struct {
 
 type registryEntry struct {
 	Cursor struct {
-		Offset int `json:"offset"`
+		Offset    int      `json:"offset"`
+		CSVHeader []string `json:"csv_header" struct:"csv_header"`
+		Done      bool     `json:"done"`
 	} `json:"cursor"`
 	Meta interface{} `json:"meta,omitempty"`
 }
@@ -594,7 +596,13 @@ func (c *mockClient) PublishAll(events []beat.Event) {
 	}
 	c.ackHandler.ACKEvents(len(events))
 
-	c.published = append(c.published, events...)
+	// Like the publishing pipeline, empty events only update the state.
+	for _, event := range events {
+		if event.Fields == nil {
+			continue
+		}
+		c.published = append(c.published, event)
+	}
 }
 
 func (c *mockClient) waitUntilPublishingHasStarted() {
@@ -41,9 +41,11 @@ var (
 
 // logFile contains all log related data
 type logFile struct {
-	file      *os.File
-	log       *logp.Logger
-	readerCtx ctxtool.CancelContext
+	file         *os.File
+	reader       io.Reader
+	decompressor *decompressor
+	log          *logp.Logger
+	readerCtx    ctxtool.CancelContext
 
 	closeAfterInterval time.Duration
 	closeOnEOF         bool
@@ -72,11 +74,47 @@ func newFileReader(
 		return nil, err
 	}
 
+	l := newLogFile(log, canceler, f, f, offset, config, closerConfig)
+	l.startFileMonitoringIfNeeded()
+
+	return l, nil
+}
+
+// newCompressedFileReader creates a new log instance reading the decompressed
+// content of a compressed file. Compressed files are not appended to, so
+// the reader is always closed when EOF is reached.
+func newCompressedFileReader(
+	log *logp.Logger,
+	canceler input.Canceler,
+	f *os.File,
+	dec *decompressor,
+	config readerConfig,
+	closerConfig closerConfig,
+) *logFile {
+	closerConfig.Reader.OnEOF = true
+
+	l := newLogFile(log, canceler, f, dec, dec.offset, config, closerConfig)
+	l.decompressor = dec
+	l.startFileMonitoringIfNeeded()
+
+	return l
+}
+
+func newLogFile(
+	log *logp.Logger,
+	canceler input.Canceler,
+	f *os.File,
+	r io.Reader,
+	offset int64,
+	config readerConfig,
+	closerConfig closerConfig,
+) *logFile {
 	readerCtx := ctxtool.WithCancelContext(ctxtool.FromCanceller(canceler))
 	tg := unison.TaskGroupWithCancel(readerCtx)
 
-	l := &logFile{
+	return &logFile{
 		file:               f,
+		reader:             r,
 		log:                log,
 		closeAfterInterval: closerConfig.Reader.AfterInterval,
 		closeOnEOF:         closerConfig.Reader.OnEOF,
@@ -90,10 +128,6 @@ func newFileReader(
 		readerCtx:          readerCtx,
 		tg:                 tg,
 	}
-
-	l.startFileMonitoringIfNeeded()
-
-	return l, nil
 }
 
 // Read reads from the reader and updates the offset
@@ -102,7 +136,7 @@ func (f *logFile) Read(buf []byte) (int, error) {
 	totalN := 0
 
 	for f.readerCtx.Err() == nil {
-		n, err := f.file.Read(buf)
+		n, err := f.reader.Read(buf)
 		if n > 0 {
 			f.offset += int64(n)
 			f.lastTimeRead = time.Now()
@@ -267,6 +301,10 @@ func (f *logFile) handleEOF() error {
 func (f *logFile) Close() error {
 	f.readerCtx.Cancel()
 	err := f.file.Close()
+	if f.decompressor != nil {
+		// closed after the file, so a pending read is not blocked anymore
+		_ = f.decompressor.Close()
+	}
 	_ = f.tg.Stop() // Wait until all resources are released for sure.
 	return err
 }
@@ -44,6 +44,12 @@ const (
 	DefaultFingerprintSize int64 = 1024 // 1KB
 	scannerDebugKey              = "scanner"
 	watcherDebugKey              = "file_watcher"
+
+	// scanModeScan finds changes by scanning the file system every check_interval.
+	scanModeScan = "scan"
+	// scanModeNotify finds changes using file system notifications and
+	// scans the file system every check_interval as a safety net.
+	scanModeNotify = "notify"
 )
 
 var (
@@ -51,6 +57,8 @@ var (
 )
 
 type fileWatcherConfig struct {
+	// Mode is the way changes of the files are detected: scan or notify.
+	Mode string `config:"mode"`
 	// Interval is the time between two scans.
 	Interval time.Duration `config:"check_interval"`
 	// ResendOnModTime  if a file has been changed according to modtime but the size is the same
@@ -91,17 +99,27 @@ func newScannerWatcher(paths []string, c *conf.C) (loginp.FSWatcher, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &fileWatcher{
+	w := &fileWatcher{
 		log:     logp.NewLogger(watcherDebugKey),
 		cfg:     config,
 		prev:    make(map[string]loginp.FileDescriptor, 0),
 		scanner: scanner,
 		events:  make(chan loginp.FSEvent),
-	}, nil
+	}
+
+	switch config.Mode {
+	case scanModeScan:
+		return w, nil
+	case scanModeNotify:
+		return newNotifyWatcher(w, scanner), nil
+	default:
+		return nil, fmt.Errorf("unknown scanner mode '%s', supported modes: %s, %s", config.Mode, scanModeScan, scanModeNotify)
+	}
 }
 
 func defaultFileWatcherConfig() fileWatcherConfig {
 	return fileWatcherConfig{
+		Mode:            scanModeScan,
 		Interval:        10 * time.Second,
 		ResendOnModTime: false,
 		Scanner:         defaultFileScannerConfig(),
@@ -147,24 +165,11 @@ func (w *fileWatcher) watch(ctx unison.Canceler) {
 			continue
 		}
 
-		var e loginp.FSEvent
-		switch {
-
-		// the new size is smaller, the file was truncated
-		case prevDesc.Info.Size() > fd.Info.Size():
-			e = truncateEvent(path, fd)
+		e := w.changeEvent(path, prevDesc, fd)
+		switch e.Op {
+		case loginp.OpTruncate:
 			truncatedCount++
-
-		// the size is the same, timestamps are different, the file was touched
-		case prevDesc.Info.Size() == fd.Info.Size() && prevDesc.Info.ModTime() != fd.Info.ModTime():
-			if w.cfg.ResendOnModTime {
-				e = truncateEvent(path, fd)
-				truncatedCount++
-			}
-
-		// the new size is larger, something was written
-		case prevDesc.Info.Size() < fd.Info.Size():
-			e = writeEvent(path, fd)
+		case loginp.OpWrite:
 			writtenCount++
 		}
 
@@ -231,6 +236,30 @@ func (w *fileWatcher) watch(ctx unison.Canceler) {
 	w.prev = paths
 }
 
+// changeEvent compares two descriptors of the same file and returns the
+// event describing the change. If the file remained unchanged, the returned
+// event has the Op OpDone.
+func (w *fileWatcher) changeEvent(path string, prevDesc, fd loginp.FileDescriptor) loginp.FSEvent {
+	switch {
+
+	// the new size is smaller, the file was truncated
+	case prevDesc.Info.Size() > fd.Info.Size():
+		return truncateEvent(path, fd)
+
+	// the size is the same, timestamps are different, the file was touched
+	case prevDesc.Info.Size() == fd.Info.Size() && prevDesc.Info.ModTime() != fd.Info.ModTime():
+		if w.cfg.ResendOnModTime {
+			return truncateEvent(path, fd)
+		}
+
+	// the new size is larger, something was written
+	case prevDesc.Info.Size() < fd.Info.Size():
+		return writeEvent(path, fd)
+	}
+
+	return loginp.FSEvent{}
+}
+
 func createEvent(path string, fd loginp.FileDescriptor) loginp.FSEvent {
 	return loginp.FSEvent{Op: loginp.OpCreate, OldPath: "", NewPath: path, Descriptor: fd}
 }
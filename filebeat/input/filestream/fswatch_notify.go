@@ -0,0 +1,234 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/elastic/go-concert/unison"
+	"github.com/fsnotify/fsnotify"
+
+	loginp "github.com/elastic/beats/v7/filebeat/input/filestream/internal/input-logfile"
+)
+
+// notifyWatcher reacts on file system notifications (inotify on Linux)
+// instead of waiting for the next scan. The file system is still scanned
+// every check_interval, so changes that are not reported, for example
+// because of an overflowing notification queue or writes to the target
+// of a symlink, are picked up eventually.
+type notifyWatcher struct {
+	*fileWatcher
+	scanner *fileScanner
+	// watched contains the directories added to the notification watcher.
+	watched map[string]struct{}
+}
+
+func newNotifyWatcher(w *fileWatcher, scanner *fileScanner) *notifyWatcher {
+	return &notifyWatcher{
+		fileWatcher: w,
+		scanner:     scanner,
+		watched:     make(map[string]struct{}),
+	}
+}
+
+func (w *notifyWatcher) Run(ctx unison.Canceler) {
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.log.Warnf("Failed to create file system notification watcher, falling back to scanning every %s: %v", w.cfg.Interval, err)
+		w.fileWatcher.Run(ctx)
+		return
+	}
+	defer notifier.Close()
+	defer close(w.events)
+
+	w.rescan(ctx, notifier)
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			w.rescan(ctx, notifier)
+
+		case event, ok := <-notifier.Events:
+			if !ok {
+				return
+			}
+			w.onNotify(ctx, notifier, event)
+
+		case err, ok := <-notifier.Errors:
+			if !ok {
+				return
+			}
+			// notifications might have been lost, e.g. because the queue overflowed
+			w.log.Warnf("File system notification error, rescanning: %v", err)
+			w.rescan(ctx, notifier)
+		}
+	}
+}
+
+// rescan updates the watched directories and runs a full scan. The
+// directories are watched before scanning, so no change between the
+// scan and setting up the watches is missed.
+func (w *notifyWatcher) rescan(ctx unison.Canceler, notifier *fsnotify.Watcher) {
+	w.updateWatches(notifier)
+	w.watch(ctx)
+}
+
+// updateWatches watches the parent directories of all configured paths.
+// Directories are resolved from the glob patterns on every call, so new
+// directories matching a pattern are watched after the next scan.
+func (w *notifyWatcher) updateWatches(notifier *fsnotify.Watcher) {
+	dirs := make(map[string]struct{})
+	for _, path := range w.scanner.paths {
+		matches, err := filepath.Glob(filepath.Dir(path))
+		if err != nil {
+			w.log.Errorf("glob(%s) failed: %v", filepath.Dir(path), err)
+			continue
+		}
+		for _, dir := range matches {
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	for dir := range dirs {
+		if _, ok := w.watched[dir]; ok {
+			continue
+		}
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if err := notifier.Add(dir); err != nil {
+			w.log.Warnf("Failed to watch directory %s, changes are detected by scanning: %v", dir, err)
+			continue
+		}
+		w.log.Debugf("Watching directory %s", dir)
+		w.watched[dir] = struct{}{}
+	}
+
+	for dir := range w.watched {
+		if _, ok := dirs[dir]; ok {
+			continue
+		}
+		// the watch is removed automatically if the directory was deleted
+		_ = notifier.Remove(dir)
+		delete(w.watched, dir)
+	}
+}
+
+func (w *notifyWatcher) onNotify(ctx unison.Canceler, notifier *fsnotify.Watcher, event fsnotify.Event) {
+	w.log.Debugf("File system notification: %s", event)
+
+	// Renames and removals are resolved by a full scan, as the new path
+	// of a renamed file is only known after comparing all files.
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		w.rescan(ctx, notifier)
+		return
+	}
+
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			// a new directory might match one of the patterns
+			w.rescan(ctx, notifier)
+			return
+		}
+	}
+
+	if !w.matches(event.Name) {
+		return
+	}
+
+	it, err := w.scanner.getIngestTarget(event.Name)
+	if err != nil {
+		w.log.Debugf("cannot create an ingest target for file %q: %s", event.Name, err)
+		return
+	}
+
+	fd, err := w.scanner.toFileDescriptor(&it)
+	if errors.Is(err, errFileTooSmall) {
+		w.log.Debugf("cannot start ingesting from file %q: %s", event.Name, err)
+		return
+	}
+	if err != nil {
+		w.log.Warnf("cannot create a file descriptor for an ingest target %q: %s", event.Name, err)
+		return
+	}
+
+	var e loginp.FSEvent
+	prevDesc, known := w.prev[event.Name]
+	switch {
+	case known && !loginp.SameFile(&prevDesc, &fd):
+		// the path points to a different file now
+		w.rescan(ctx, notifier)
+		return
+
+	case known:
+		e = w.changeEvent(event.Name, prevDesc, fd)
+
+	default:
+		if w.isKnownFile(fd) {
+			// the file is known under another path, it was renamed or it is a symlink
+			w.rescan(ctx, notifier)
+			return
+		}
+		// no need to react on empty new files
+		if fd.Info.Size() == 0 {
+			w.log.Debugf("file %q has no content yet, skipping", fd.Filename)
+			return
+		}
+		e = createEvent(event.Name, fd)
+	}
+
+	w.prev[event.Name] = fd
+
+	if e.Op == loginp.OpDone {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case w.events <- e:
+	}
+}
+
+// matches returns true if the path matches one of the configured patterns.
+func (w *notifyWatcher) matches(path string) bool {
+	for _, pattern := range w.scanner.paths {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isKnownFile returns true if the file was found under any path by the last scan.
+func (w *notifyWatcher) isKnownFile(fd loginp.FileDescriptor) bool {
+	for _, prevDesc := range w.prev {
+		if loginp.SameFile(&prevDesc, &fd) {
+			return true
+		}
+	}
+	return false
+}
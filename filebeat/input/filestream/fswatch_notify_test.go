@@ -0,0 +1,156 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	loginp "github.com/elastic/beats/v7/filebeat/input/filestream/internal/input-logfile"
+	"github.com/elastic/beats/v7/libbeat/common/file"
+	conf "github.com/elastic/elastic-agent-libs/config"
+)
+
+func TestNotifyWatcher(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "*.log")}
+	// the scan interval is long enough to make sure that
+	// all events are created from file system notifications
+	cfgStr := `
+scanner:
+  mode: notify
+  check_interval: 1h
+  symlinks: false
+  fingerprint.enabled: false
+`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fw := createWatcherWithConfig(t, paths, cfgStr)
+	require.IsType(t, &notifyWatcher{}, fw)
+
+	go fw.Run(ctx)
+
+	basename := "created.log"
+	filename := filepath.Join(dir, basename)
+
+	// the file is either found by the initial scan or reported
+	// by a notification, both result in a single create event
+	t.Run("detects a new file", func(t *testing.T) {
+		err := os.WriteFile(filename, []byte("hello"), 0o600)
+		require.NoError(t, err)
+
+		e := fw.Event()
+		expEvent := loginp.FSEvent{
+			NewPath: filename,
+			Op:      loginp.OpCreate,
+			Descriptor: loginp.FileDescriptor{
+				Filename: filename,
+				Info:     file.ExtendFileInfo(&testFileInfo{name: basename, size: 5}),
+			},
+		}
+		requireEqualEvents(t, expEvent, e)
+	})
+
+	t.Run("detects a file write", func(t *testing.T) {
+		f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = f.WriteString("world")
+		require.NoError(t, err)
+		f.Close()
+
+		e := fw.Event()
+		expEvent := loginp.FSEvent{
+			NewPath: filename,
+			OldPath: filename,
+			Op:      loginp.OpWrite,
+			Descriptor: loginp.FileDescriptor{
+				Filename: filename,
+				Info:     file.ExtendFileInfo(&testFileInfo{name: basename, size: 10}),
+			},
+		}
+		requireEqualEvents(t, expEvent, e)
+	})
+
+	newBasename := "renamed.log"
+	newFilename := filepath.Join(dir, newBasename)
+
+	t.Run("detects a file rename", func(t *testing.T) {
+		err := os.Rename(filename, newFilename)
+		require.NoError(t, err)
+
+		e := fw.Event()
+		expEvent := loginp.FSEvent{
+			NewPath: newFilename,
+			OldPath: filename,
+			Op:      loginp.OpRename,
+			Descriptor: loginp.FileDescriptor{
+				Filename: newFilename,
+				Info:     file.ExtendFileInfo(&testFileInfo{name: newBasename, size: 10}),
+			},
+		}
+		requireEqualEvents(t, expEvent, e)
+	})
+
+	t.Run("ignores files not matching the patterns", func(t *testing.T) {
+		err := os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("ignored"), 0o600)
+		require.NoError(t, err)
+	})
+
+	t.Run("detects a file remove", func(t *testing.T) {
+		err := os.Remove(newFilename)
+		require.NoError(t, err)
+
+		e := fw.Event()
+		expEvent := loginp.FSEvent{
+			OldPath: newFilename,
+			Op:      loginp.OpDelete,
+			Descriptor: loginp.FileDescriptor{
+				Filename: newFilename,
+				Info:     file.ExtendFileInfo(&testFileInfo{name: newBasename, size: 10}),
+			},
+		}
+		requireEqualEvents(t, expEvent, e)
+	})
+}
+
+func TestFileWatcherMode(t *testing.T) {
+	paths := []string{filepath.Join(t.TempDir(), "*.log")}
+
+	t.Run("scan is the default mode", func(t *testing.T) {
+		fw := createWatcherWithConfig(t, paths, "scanner: {}")
+		require.IsType(t, &fileWatcher{}, fw)
+	})
+
+	t.Run("fails on unknown mode", func(t *testing.T) {
+		cfg, err := conf.NewConfigWithYAML([]byte("scanner.mode: poll"), "")
+		require.NoError(t, err)
+
+		ns := &conf.Namespace{}
+		require.NoError(t, ns.Unpack(cfg))
+
+		_, err = newFileWatcher(paths, ns)
+		require.ErrorContains(t, err, "unknown scanner mode 'poll'")
+	})
+}
@@ -31,6 +31,7 @@ import (
 
 	loginp "github.com/elastic/beats/v7/filebeat/input/filestream/internal/input-logfile"
 	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/common/cleanup"
 	"github.com/elastic/beats/v7/libbeat/common/file"
 	"github.com/elastic/beats/v7/libbeat/common/match"
@@ -49,6 +50,11 @@ const pluginName = "filestream"
 
 type state struct {
 	Offset int64 `json:"offset" struct:"offset"`
+	// State of the parsers at Offset, e.g. the header of CSV files.
+	parser.State `struct:",inline"`
+	// Done is set once a compressed file has been read completely. As
+	// compressed files are not appended to, they are not read again.
+	Done bool `json:"done,omitempty" struct:"done,omitempty"`
 }
 
 type fileMeta struct {
@@ -64,6 +70,11 @@ type filestream struct {
 	closerConfig    closerConfig
 	parsers         parser.Config
 	takeOver        bool
+	// startFrom is set if new files are started to be read at a timestamp.
+	startFrom *timestampSeeker
+	// contentIndex is set if new files are resumed after content that has
+	// already been read.
+	contentIndex *contentIndex
 }
 
 // Plugin creates a new filestream input plugin for creating a stateful input.
@@ -106,6 +117,14 @@ func configure(cfg *conf.C) (loginp.Prospector, loginp.Harvester, error) {
 		closerConfig:    config.Close,
 		parsers:         config.Reader.Parsers,
 		takeOver:        config.TakeOver,
+		contentIndex:    newContentIndex(config.ContentDedup),
+	}
+
+	if config.StartFrom == startFromTimestamp {
+		filestream.startFrom, err = newTimestampSeeker(config.StartTimestamp, time.Now())
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot configure start_from: %w", err)
+		}
 	}
 
 	return prospector, filestream, nil
@@ -119,7 +138,7 @@ func (inp *filestream) Test(src loginp.Source, ctx input.TestContext) error {
 		return fmt.Errorf("not file source")
 	}
 
-	reader, _, err := inp.open(ctx.Logger, ctx.Cancelation, fs, 0)
+	reader, _, _, err := inp.open(ctx.Logger, ctx.Cancelation, fs, 0, nil)
 	if err != nil {
 		return err
 	}
@@ -132,6 +151,7 @@ func (inp *filestream) Run(
 	cursor loginp.Cursor,
 	publisher loginp.Publisher,
 	metrics *loginp.Metrics,
+	throttle *loginp.Throttle,
 ) error {
 	fs, ok := src.(fileSource)
 	if !ok {
@@ -140,8 +160,32 @@ func (inp *filestream) Run(
 
 	log := ctx.Logger.With("path", fs.newPath).With("state-id", src.Name())
 	state := initState(log, cursor, fs)
+	if state.Done {
+		log.Debug("Compressed file has already been read completely, skipping it")
+		return nil
+	}
+	deduplicated := false
+	if cursor.IsNew() && inp.contentIndex != nil {
+		offset, parserState, found, err := inp.findReadContent(log, ctx.Cancelation, fs)
+		if err != nil {
+			log.Warnf("File could not be compared with already read content, reading it from the beginning: %v", err)
+		} else if found {
+			log.Infof("File starts with already read content, resuming at offset %d", offset)
+			state.Offset = offset
+			state.State = parserState
+			deduplicated = true
+		}
+	}
+	if cursor.IsNew() && inp.startFrom != nil && !deduplicated {
+		offset, err := inp.startFrom.Seek(log, fs.newPath)
+		if err != nil {
+			log.Errorf("File could not be searched for the start timestamp: %v", err)
+			return err
+		}
+		state.Offset = offset
+	}
 
-	r, truncated, err := inp.open(log, ctx.Cancelation, fs, state.Offset)
+	r, dec, truncated, err := inp.open(log, ctx.Cancelation, fs, state.Offset, &state.State)
 	if err != nil {
 		log.Errorf("File could not be opened for reading: %v", err)
 		return err
@@ -150,6 +194,7 @@ func (inp *filestream) Run(
 	if truncated {
 		state.Offset = 0
 	}
+	stream := inp.contentIndex.stream(src.Name(), state.Offset)
 
 	metrics.FilesActive.Inc()
 	metrics.HarvesterRunning.Inc()
@@ -167,7 +212,65 @@ func (inp *filestream) Run(
 
 	// The caller of Run already reports the error and filters out errors that
 	// must not be reported, like 'context cancelled'.
-	return inp.readFromSource(ctx, log, r, fs.newPath, state, publisher, metrics)
+	return inp.readFromSource(ctx, log, r, dec, fs.newPath, &state, publisher, metrics, throttle, stream)
+}
+
+// findReadContent compares the beginning of a new file with the content
+// already read by the input. If it matches, the offset after the matching
+// content and the state of the parsers at that offset are returned.
+func (inp *filestream) findReadContent(log *logp.Logger, canceler input.Canceler, fs fileSource) (int64, parser.State, bool, error) {
+	// The file is only read up to its current end.
+	scan := fs
+	scan.archived = true
+
+	var parserState parser.State
+	r, _, _, err := inp.open(log, canceler, scan, 0, &parserState)
+	if err != nil {
+		return 0, parser.State{}, false, err
+	}
+	defer r.Close()
+
+	// The scan reads one line past the matching content, so the parser
+	// states after the last two lines are kept.
+	var states scannedStates
+	var read int64
+	offset, found, err := inp.contentIndex.match(func() (uint64, int64, error) {
+		message, err := r.Next()
+		if err != nil {
+			if errors.Is(err, ErrClosed) {
+				return 0, 0, io.EOF
+			}
+			return 0, 0, err
+		}
+		read += int64(message.Bytes) + int64(message.Offset)
+		states.add(read, parserState)
+		return hashContent(message.Content), read, nil
+	})
+	if err != nil || !found {
+		return 0, parser.State{}, found, err
+	}
+	return offset, states.at(offset), true, nil
+}
+
+// scannedStates keeps the parser states after the last two lines read.
+type scannedStates struct {
+	offsets [2]int64
+	states  [2]parser.State
+}
+
+func (s *scannedStates) add(offset int64, state parser.State) {
+	s.offsets[0], s.states[0] = s.offsets[1], s.states[1]
+	// The parsers update the state they were created with.
+	state.CSVHeader = slices.Clone(state.CSVHeader)
+	s.offsets[1], s.states[1] = offset, state
+}
+
+// at returns the parser state after the line ending at offset.
+func (s *scannedStates) at(offset int64) parser.State {
+	if offset == s.offsets[0] {
+		return s.states[0]
+	}
+	return s.states[1]
 }
 
 func initState(log *logp.Logger, c loginp.Cursor, s fileSource) state {
@@ -189,15 +292,19 @@ func (inp *filestream) open(
 	canceler input.Canceler,
 	fs fileSource,
 	offset int64,
-) (reader.Reader, bool, error) {
+	parserState *parser.State,
+) (reader.Reader, *decompressor, bool, error) {
 
-	f, encoding, truncated, err := inp.openFile(log, fs.newPath, offset)
+	f, dec, encoding, truncated, err := inp.openFile(log, fs.newPath, offset)
 	if err != nil {
-		return nil, truncated, err
+		return nil, nil, truncated, err
 	}
 
 	if truncated {
 		offset = 0
+		if parserState != nil {
+			*parserState = parser.State{}
+		}
 	}
 
 	ok := false // used for cleanup
@@ -220,14 +327,20 @@ func (inp *filestream) open(
 	// NewLineReader uses additional buffering to deal with encoding and testing
 	// for new lines in input stream. Simple 8-bit based encodings, or plain
 	// don't require 'complicated' logic.
-	logReader, err := newFileReader(log, canceler, f, inp.readerConfig, closerCfg)
-	if err != nil {
-		return nil, truncated, err
+	var logReader *logFile
+	if dec != nil {
+		log.Debugf("Reading %s compressed file", dec.format)
+		logReader = newCompressedFileReader(log, canceler, f, dec, inp.readerConfig, closerCfg)
+	} else {
+		logReader, err = newFileReader(log, canceler, f, inp.readerConfig, closerCfg)
+		if err != nil {
+			return nil, nil, truncated, err
+		}
 	}
 
 	dbgReader, err := debug.AppendReaders(logReader)
 	if err != nil {
-		return nil, truncated, err
+		return nil, nil, truncated, err
 	}
 
 	// Configure MaxBytes limit for EncodeReader as multiplied by 4
@@ -244,19 +357,19 @@ func (inp *filestream) open(
 		MaxBytes:   encReaderMaxBytes,
 	})
 	if err != nil {
-		return nil, truncated, err
+		return nil, nil, truncated, err
 	}
 
 	r = readfile.NewStripNewline(r, inp.readerConfig.LineTerminator)
 
 	r = readfile.NewFilemeta(r, fs.newPath, fs.desc.Info, fs.desc.Fingerprint, offset)
 
-	r = inp.parsers.Create(r)
+	r = inp.parsers.CreateWithState(r, parserState)
 
 	r = readfile.NewLimitReader(r, inp.readerConfig.MaxBytes)
 
 	ok = true // no need to close the file
-	return r, truncated, nil
+	return r, dec, truncated, nil
 }
 
 // openFile opens a file and checks for the encoding. In case the encoding cannot be detected
@@ -264,39 +377,67 @@ func (inp *filestream) open(
 // is returned and the harvester is closed. The file will be picked up again the next time
 // the file system is scanned.
 //
+// If the file is compressed, a decompressor positioned at the offset of the
+// uncompressed stream is returned alongside the file and must be used for reading.
+//
 // openFile will also detect and hadle file truncation. If a file is truncated
-// then the 3rd return value is true.
+// then the 4th return value is true.
 func (inp *filestream) openFile(
 	log *logp.Logger,
 	path string,
 	offset int64,
-) (*os.File, encoding.Encoding, bool, error) {
+) (*os.File, *decompressor, encoding.Encoding, bool, error) {
 	fi, err := os.Stat(path)
 	if err != nil {
-		return nil, nil, false, fmt.Errorf("failed to stat source file %s: %w", path, err)
+		return nil, nil, nil, false, fmt.Errorf("failed to stat source file %s: %w", path, err)
 	}
 
 	// it must be checked if the file is not a named pipe before we try to open it
 	// if it is a named pipe os.OpenFile fails, so there is no need to try opening it.
 	if fi.Mode()&os.ModeNamedPipe != 0 {
-		return nil, nil, false, fmt.Errorf("failed to open file %s, named pipes are not supported", fi.Name())
+		return nil, nil, nil, false, fmt.Errorf("failed to open file %s, named pipes are not supported", fi.Name())
 	}
 
 	f, err := file.ReadOpen(path)
 	if err != nil {
-		return nil, nil, false, fmt.Errorf("failed opening %s: %w", path, err)
+		return nil, nil, nil, false, fmt.Errorf("failed opening %s: %w", path, err)
 	}
 	ok := false
 	defer cleanup.IfNot(&ok, cleanup.IgnoreError(f.Close))
 
 	fi, err = f.Stat()
 	if err != nil {
-		return nil, nil, false, fmt.Errorf("failed to stat source file %s: %w", path, err)
+		return nil, nil, nil, false, fmt.Errorf("failed to stat source file %s: %w", path, err)
 	}
 
 	err = checkFileBeforeOpening(fi)
 	if err != nil {
-		return nil, nil, false, err
+		return nil, nil, nil, false, err
+	}
+
+	var format string
+	if inp.readerConfig.Compression != compressionNone {
+		format, err = detectCompression(f)
+		if err != nil {
+			return nil, nil, nil, false, err
+		}
+	}
+	if format != "" {
+		// Offsets of compressed files are counted in uncompressed bytes,
+		// so they cannot be compared to the file size.
+		dec, err := newDecompressor(f, format, offset)
+		if err != nil {
+			return nil, nil, nil, false, err
+		}
+		defer cleanup.IfNot(&ok, cleanup.IgnoreError(dec.Close))
+
+		encoding, err := inp.newEncoding(dec)
+		if err != nil {
+			return nil, nil, nil, false, err
+		}
+
+		ok = true // no need to close the file
+		return f, dec, encoding, false, nil
 	}
 
 	truncated := false
@@ -309,19 +450,27 @@ func (inp *filestream) openFile(
 	}
 	err = inp.initFileOffset(f, offset)
 	if err != nil {
-		return nil, nil, truncated, err
+		return nil, nil, nil, truncated, err
 	}
 
-	encoding, err := inp.encodingFactory(f)
+	encoding, err := inp.newEncoding(f)
 	if err != nil {
-		if errors.Is(err, transform.ErrShortSrc) {
-			return nil, nil, truncated, fmt.Errorf("initialising encoding for '%v' failed due to file being too short", f)
-		}
-		return nil, nil, truncated, fmt.Errorf("initialising encoding for '%v' failed: %w", f, err)
+		return nil, nil, nil, truncated, err
 	}
 
 	ok = true // no need to close the file
-	return f, encoding, truncated, nil
+	return f, nil, encoding, truncated, nil
+}
+
+func (inp *filestream) newEncoding(r io.Reader) (encoding.Encoding, error) {
+	encoding, err := inp.encodingFactory(r)
+	if err != nil {
+		if errors.Is(err, transform.ErrShortSrc) {
+			return nil, fmt.Errorf("initialising encoding for '%v' failed due to file being too short", r)
+		}
+		return nil, fmt.Errorf("initialising encoding for '%v' failed: %w", r, err)
+	}
+	return encoding, nil
 }
 
 func checkFileBeforeOpening(fi os.FileInfo) error {
@@ -347,10 +496,13 @@ func (inp *filestream) readFromSource(
 	ctx input.Context,
 	log *logp.Logger,
 	r reader.Reader,
+	dec *decompressor,
 	path string,
-	s state,
+	s *state,
 	p loginp.Publisher,
 	metrics *loginp.Metrics,
+	throttle *loginp.Throttle,
+	stream *contentStream,
 ) error {
 	metrics.FilesOpened.Inc()
 	metrics.HarvesterOpenFiles.Inc()
@@ -368,6 +520,13 @@ func (inp *filestream) readFromSource(
 				log.Infof("Reader was closed. Closing. Path='%s'", path)
 			} else if errors.Is(err, io.EOF) {
 				log.Debugf("EOF has been reached. Closing. Path='%s'", path)
+				if dec != nil && dec.complete() {
+					// Only the state is updated, the empty event is not published.
+					s.Done = true
+					if err := p.Publish(beat.Event{}, *s); err != nil {
+						return err
+					}
+				}
 			} else {
 				log.Errorf("Read line error: %v", err)
 				metrics.ProcessingErrors.Inc()
@@ -389,6 +548,7 @@ func (inp *filestream) readFromSource(
 
 		metrics.MessagesRead.Inc()
 		if message.IsEmpty() || inp.isDroppedLine(log, string(message.Content)) {
+			stream.add(message.Content, s.Offset)
 			continue
 		}
 
@@ -399,10 +559,16 @@ func (inp *filestream) readFromSource(
 			_ = mapstr.AddTags(message.Fields, []string{"take_over"})
 		}
 
-		if err := p.Publish(message.ToEvent(), s); err != nil {
+		if err := throttle.Wait(ctxtool.FromCanceller(ctx.Cancelation), message.Bytes); err != nil {
+			// the input is stopping
+			return nil //nolint:nilerr // context cancellation is not an error
+		}
+
+		if err := p.Publish(message.ToEvent(), *s); err != nil {
 			metrics.ProcessingErrors.Inc()
 			return err
 		}
+		stream.add(message.Content, s.Offset)
 
 		metrics.EventsProcessed.Inc()
 		metrics.ProcessingTime.Update(time.Since(message.Ts).Nanoseconds())
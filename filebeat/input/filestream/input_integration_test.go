@@ -31,6 +31,7 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid/v5"
+	"github.com/klauspost/compress/gzip"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/unicode"
@@ -1161,3 +1162,188 @@ func TestRotatingCloseInactiveLowWriteRate(t *testing.T) {
 	cancelInput()
 	env.waitUntilInputStops()
 }
+
+func TestFilestreamCompressedFile(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	testlogName := "test.log.gz"
+	id := "fake-ID-" + uuid.Must(uuid.NewV4()).String()
+	inp := env.mustCreateInput(map[string]interface{}{
+		"id":                                     id,
+		"paths":                                  []string{env.abspath(testlogName)},
+		"prospector.scanner.check_interval":      "1ms",
+		"prospector.scanner.fingerprint.enabled": false,
+		"file_identity.native":                   map[string]any{},
+		"compression":                            "auto",
+	})
+
+	testlines := "first line\nsecond line\nthird line\n"
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write([]byte(testlines))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	env.mustWriteToFile(testlogName, compressed.Bytes())
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, inp)
+
+	env.waitUntilEventCount(3)
+	env.requireEventsReceived([]string{"first line", "second line", "third line"})
+
+	// compressed files are read until EOF and closed
+	env.waitUntilHarvesterIsDone()
+
+	cancelInput()
+	env.waitUntilInputStops()
+
+	// the offset is tracked in uncompressed bytes
+	env.requireOffsetInRegistry(testlogName, id, len(testlines))
+
+	// the file is marked as done, so it is not read again after a restart
+	fi, err := os.Stat(env.abspath(testlogName))
+	require.NoError(t, err)
+	entry, err := env.getRegistryState(getIDFromPath(env.abspath(testlogName), id, fi))
+	require.NoError(t, err)
+	require.True(t, entry.Cursor.Done, "compressed file must be marked as done")
+}
+
+func TestFilestreamStartFromTimestamp(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	testlogName := "test.log"
+	id := "fake-ID-" + uuid.Must(uuid.NewV4()).String()
+	inp := env.mustCreateInput(map[string]interface{}{
+		"id":                                     id,
+		"paths":                                  []string{env.abspath(testlogName)},
+		"prospector.scanner.check_interval":      "1ms",
+		"prospector.scanner.fingerprint.enabled": false,
+		"file_identity.native":                   map[string]any{},
+		"start_from":                             "timestamp",
+		"start_timestamp.since":                  "1h",
+	})
+
+	now := time.Now()
+	lines := []string{
+		now.Add(-3*time.Hour).Format(time.RFC3339) + " old line",
+		now.Add(-2*time.Hour).Format(time.RFC3339) + " old line",
+		now.Add(-30*time.Minute).Format(time.RFC3339) + " new line",
+		now.Add(-10*time.Minute).Format(time.RFC3339) + " new line",
+	}
+	testlines := []byte(strings.Join(lines, "\n") + "\n")
+	env.mustWriteToFile(testlogName, testlines)
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, inp)
+
+	env.waitUntilEventCount(2)
+	env.requireEventsReceived(lines[2:])
+
+	cancelInput()
+	env.waitUntilInputStops()
+
+	// the cursor contains the skipped lines as well
+	env.requireOffsetInRegistry(testlogName, id, len(testlines))
+}
+
+func TestFilestreamCSVHeaderInRegistry(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	testlogName := "test.csv"
+	id := "fake-ID-" + uuid.Must(uuid.NewV4()).String()
+	inp := env.mustCreateInput(map[string]interface{}{
+		"id":                                     id,
+		"paths":                                  []string{env.abspath(testlogName)},
+		"prospector.scanner.check_interval":      "1ms",
+		"prospector.scanner.fingerprint.enabled": false,
+		"file_identity.native":                   map[string]any{},
+		"parsers": []map[string]interface{}{
+			{"csv": map[string]interface{}{}},
+		},
+	})
+
+	testlines := []byte("host,status\nweb-1,ok\nweb-2,down\n")
+	env.mustWriteToFile(testlogName, testlines)
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, inp)
+
+	env.waitUntilEventCount(2)
+	env.requireEventContents(0, "csv.host", "web-1")
+	env.requireEventContents(1, "csv.host", "web-2")
+	env.requireEventContents(1, "csv.status", "down")
+
+	// the header is stored in the cursor, so reading can be resumed mid-file
+	env.requireOffsetInRegistry(testlogName, id, len(testlines))
+	fi, err := os.Stat(env.abspath(testlogName))
+	require.NoError(t, err)
+	entry, err := env.getRegistryState(getIDFromPath(env.abspath(testlogName), id, fi))
+	require.NoError(t, err)
+	require.Equal(t, []string{"host", "status"}, entry.Cursor.CSVHeader)
+
+	cancelInput()
+	env.waitUntilInputStops()
+}
+
+func TestFilestreamRateLimit(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	testlogName := "test.log"
+	inp := env.mustCreateInput(map[string]interface{}{
+		"id":                                     "fake-ID-" + uuid.Must(uuid.NewV4()).String(),
+		"paths":                                  []string{env.abspath(testlogName)},
+		"prospector.scanner.check_interval":      "1ms",
+		"prospector.scanner.fingerprint.enabled": false,
+		"file_identity.native":                   map[string]any{},
+		"rate_limit.events_per_second":           10,
+	})
+
+	lines := make([]string, 15)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+	env.mustWriteToFile(testlogName, []byte(strings.Join(lines, "\n")+"\n"))
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	start := time.Now()
+	env.startInput(ctx, inp)
+
+	// the first 10 events are published at once, the other 5 take half a second
+	env.waitUntilEventCount(15)
+	require.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	env.requireEventsReceived(lines)
+
+	cancelInput()
+	env.waitUntilInputStops()
+}
+
+func TestFilestreamContentDedup(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	id := "fake-ID-" + uuid.Must(uuid.NewV4()).String()
+	inp := env.mustCreateInput(map[string]interface{}{
+		"id":                                     id,
+		"paths":                                  []string{env.abspath("*.log")},
+		"prospector.scanner.check_interval":      "1ms",
+		"prospector.scanner.fingerprint.enabled": false,
+		"file_identity.native":                   map[string]any{},
+		"content_dedup.enabled":                  true,
+	})
+
+	lines := []string{"line 1", "line 2", "line 3", "line 4"}
+	env.mustWriteToFile("test.log", []byte(strings.Join(lines, "\n")+"\n"))
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, inp)
+	env.waitUntilEventCount(len(lines))
+
+	// A copy of the file with new lines only publishes the new lines.
+	copied := append(lines, "line 5", "line 6")
+	env.mustWriteToFile("copy.log", []byte(strings.Join(copied, "\n")+"\n"))
+	env.waitUntilEventCount(len(copied))
+	env.waitUntilOffsetInRegistry("copy.log", id, 42, time.Second)
+	env.requireEventsReceived(copied)
+
+	cancelInput()
+	env.waitUntilInputStops()
+}
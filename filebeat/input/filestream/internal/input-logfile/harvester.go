@@ -46,8 +46,9 @@ type Harvester interface {
 	// Test checks if the Harvester can be started with the given configuration.
 	Test(Source, inputv2.TestContext) error
 	// Run is the event loop which reads from the source
-	// and forwards it to the publisher.
-	Run(inputv2.Context, Source, Cursor, Publisher, *Metrics) error
+	// and forwards it to the publisher. The events must be throttled
+	// with the Throttle, which is nil if no rate limits are configured.
+	Run(inputv2.Context, Source, Cursor, Publisher, *Metrics, *Throttle) error
 }
 
 type readerGroup struct {
@@ -127,6 +128,7 @@ type defaultHarvesterGroup struct {
 	identifier   *sourceIdentifier
 	tg           *task.Group
 	metrics      *Metrics
+	rateLimits   *rateLimits
 }
 
 // Start starts the Harvester for a Source if no Harvester is running for the
@@ -234,7 +236,10 @@ func startHarvester(
 		cursor := makeCursor(resource)
 		publisher := &cursorPublisher{canceler: ctx.Cancelation, client: client, cursor: &cursor}
 
-		err = hg.harvester.Run(ctx, src, cursor, publisher, metrics)
+		throttle := hg.rateLimits.newThrottle(srcID)
+		defer throttle.Close()
+
+		err = hg.harvester.Run(ctx, src, cursor, publisher, metrics, throttle)
 		if err != nil && !errors.Is(err, context.Canceled) {
 			hg.readers.remove(srcID)
 			ctx.UpdateStatus(status.Degraded, fmt.Sprintf("error while running harvester: %v", err))
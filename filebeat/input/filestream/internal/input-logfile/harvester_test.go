@@ -409,7 +409,7 @@ type mockHarvester struct {
 	onRun func(input.Context, Source, Cursor, Publisher) error
 }
 
-func (m *mockHarvester) Run(ctx input.Context, s Source, c Cursor, p Publisher, metrics *Metrics) error {
+func (m *mockHarvester) Run(ctx input.Context, s Source, c Cursor, p Publisher, metrics *Metrics, _ *Throttle) error {
 	if m.wg != nil {
 		defer m.wg.Done()
 	}
@@ -39,6 +39,7 @@ type managedInput struct {
 	harvester        Harvester
 	cleanTimeout     time.Duration
 	harvesterLimit   uint64
+	rateLimit        rateLimitConfig
 }
 
 // Name is required to implement the v2.Input interface
@@ -80,7 +81,8 @@ func (inp *managedInput) Run(
 			time.Minute, // magic number
 			ctx.Logger,
 			"harvester:"),
-		metrics: metrics,
+		metrics:    metrics,
+		rateLimits: newRateLimits(inp.rateLimit, metrics),
 	}
 
 	prospectorStore := inp.manager.getRetainedStore()
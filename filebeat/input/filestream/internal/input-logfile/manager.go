@@ -155,9 +155,10 @@ func (cim *InputManager) Create(config *conf.C) (v2.Input, error) {
 	}
 
 	settings := struct {
-		ID             string        `config:"id"`
-		CleanInactive  time.Duration `config:"clean_inactive"`
-		HarvesterLimit uint64        `config:"harvester_limit"`
+		ID             string          `config:"id"`
+		CleanInactive  time.Duration   `config:"clean_inactive"`
+		HarvesterLimit uint64          `config:"harvester_limit"`
+		RateLimit      rateLimitConfig `config:"rate_limit"`
 	}{CleanInactive: cim.DefaultCleanTimeout}
 	if err := config.Unpack(&settings); err != nil {
 		return nil, err
@@ -223,6 +224,7 @@ func (cim *InputManager) Create(config *conf.C) (v2.Input, error) {
 		sourceIdentifier: sourceIdentifier,
 		cleanTimeout:     settings.CleanInactive,
 		harvesterLimit:   settings.HarvesterLimit,
+		rateLimit:        settings.RateLimit,
 	}, nil
 }
 
@@ -28,6 +28,7 @@ import (
 // Metrics defines a set of metrics for the filestream input.
 type Metrics struct {
 	unregister func()
+	registry   *monitoring.Registry
 
 	FilesOpened       *monitoring.Uint // Number of files that have been opened.
 	FilesClosed       *monitoring.Uint // Number of files closed.
@@ -67,6 +68,7 @@ func NewMetrics(id string) *Metrics {
 	reg, unreg := inputmon.NewInputRegistry("filestream", id, nil)
 	m := Metrics{
 		unregister:        unreg,
+		registry:          reg,
 		FilesOpened:       monitoring.NewUint(reg, "files_opened_total"),
 		FilesClosed:       monitoring.NewUint(reg, "files_closed_total"),
 		FilesActive:       monitoring.NewUint(reg, "files_active"),
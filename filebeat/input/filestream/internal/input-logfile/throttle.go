@@ -0,0 +1,254 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package input_logfile
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// rateLimitConfig configures the rate at which the harvesters of an input
+// publish events.
+type rateLimitConfig struct {
+	// EventsPerSecond is the maximum number of events published per second.
+	EventsPerSecond float64 `config:"events_per_second" validate:"min=0"`
+	// BytesPerSecond is the maximum number of bytes read per second.
+	BytesPerSecond cfgtype.ByteSize `config:"bytes_per_second"`
+	// FairShare applies the limits to the input as a whole. The limits are
+	// evenly split across the running harvesters. Otherwise the limits
+	// apply to each harvester.
+	FairShare bool `config:"fair_share"`
+}
+
+func (c rateLimitConfig) enabled() bool {
+	return c.EventsPerSecond > 0 || c.BytesPerSecond > 0
+}
+
+// rateLimits creates and keeps track of the Throttles of the harvesters of
+// an input. A nil rateLimits does not limit the harvesters.
+type rateLimits struct {
+	config rateLimitConfig
+	// registry holds the throttle metrics of each harvester.
+	registry *monitoring.Registry
+
+	mu        sync.Mutex
+	throttles map[*Throttle]struct{}
+	metrics   map[string]*throttleMetrics
+}
+
+// throttleMetrics are the metrics of the harvester of a source. They are
+// shared if a harvester is restarted before the previous one has stopped.
+type throttleMetrics struct {
+	refs int
+
+	EventsLimit     *monitoring.Float // Current events per second limit.
+	BytesLimit      *monitoring.Float // Current bytes per second limit.
+	EventsThrottled *monitoring.Uint  // Number of events delayed by the limits.
+	ThrottledTime   *monitoring.Uint  // Total time in nanoseconds events were delayed.
+}
+
+func newRateLimits(config rateLimitConfig, metrics *Metrics) *rateLimits {
+	if !config.enabled() {
+		return nil
+	}
+
+	var registry *monitoring.Registry
+	if metrics != nil && metrics.registry != nil {
+		registry = metrics.registry.NewRegistry("harvesters")
+	} else {
+		registry = monitoring.NewRegistry()
+	}
+
+	return &rateLimits{
+		config:    config,
+		registry:  registry,
+		throttles: map[*Throttle]struct{}{},
+		metrics:   map[string]*throttleMetrics{},
+	}
+}
+
+// newThrottle creates the Throttle of the harvester reading the source
+// with the given ID. The Throttle must be closed when the harvester stops.
+func (r *rateLimits) newThrottle(srcID string) *Throttle {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := strings.ReplaceAll(srcID, ".", "_")
+	m, ok := r.metrics[key]
+	if !ok {
+		reg := r.registry.NewRegistry(key)
+		monitoring.NewString(reg, "source").Set(srcID)
+		m = &throttleMetrics{
+			EventsLimit:     monitoring.NewFloat(reg, "events_per_second_limit"),
+			BytesLimit:      monitoring.NewFloat(reg, "bytes_per_second_limit"),
+			EventsThrottled: monitoring.NewUint(reg, "events_throttled_total"),
+			ThrottledTime:   monitoring.NewUint(reg, "throttled_time_ns_total"),
+		}
+		r.metrics[key] = m
+	}
+	m.refs++
+
+	t := &Throttle{
+		limits:     r,
+		metricsKey: key,
+		metrics:    m,
+	}
+	r.throttles[t] = struct{}{}
+	r.updateLimits()
+
+	return t
+}
+
+func (r *rateLimits) release(t *Throttle) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.throttles[t]; !ok {
+		return
+	}
+	delete(r.throttles, t)
+
+	t.metrics.refs--
+	if t.metrics.refs == 0 {
+		delete(r.metrics, t.metricsKey)
+		r.registry.Remove(t.metricsKey)
+	}
+
+	r.updateLimits()
+}
+
+// updateLimits sets the limits of all throttles, creating the limiters of
+// new throttles. It must be called with r.mu held whenever a throttle is
+// added or removed.
+func (r *rateLimits) updateLimits() {
+	share := 1.0
+	if r.config.FairShare && len(r.throttles) > 0 {
+		share = float64(len(r.throttles))
+	}
+
+	events := r.config.EventsPerSecond / share
+	bytes := float64(r.config.BytesPerSecond) / share
+	for t := range r.throttles {
+		if events > 0 {
+			if t.events == nil {
+				t.events = rate.NewLimiter(rate.Limit(events), burst(events))
+			} else {
+				setLimit(t.events, events)
+			}
+			t.metrics.EventsLimit.Set(events)
+		}
+		if bytes > 0 {
+			if t.bytes == nil {
+				t.bytes = rate.NewLimiter(rate.Limit(bytes), burst(bytes))
+			} else {
+				setLimit(t.bytes, bytes)
+			}
+			t.metrics.BytesLimit.Set(bytes)
+		}
+	}
+}
+
+func setLimit(l *rate.Limiter, limit float64) {
+	l.SetLimit(rate.Limit(limit))
+	l.SetBurst(burst(limit))
+}
+
+// burst allows bursts of up to a second worth of tokens.
+func burst(limit float64) int {
+	return int(math.Max(1, math.Ceil(limit)))
+}
+
+// Throttle limits the rate at which a harvester publishes events. A nil
+// Throttle does not limit the harvester.
+type Throttle struct {
+	limits     *rateLimits
+	metricsKey string
+	metrics    *throttleMetrics
+
+	events *rate.Limiter
+	bytes  *rate.Limiter
+}
+
+// Wait blocks until the harvester is allowed to publish an event of the
+// given size in bytes, or ctx is cancelled.
+func (t *Throttle) Wait(ctx context.Context, size int) error {
+	if t == nil {
+		return nil
+	}
+
+	now := time.Now()
+	var delay time.Duration
+	var reservations []*rate.Reservation
+	if t.events != nil {
+		reservations = append(reservations, t.events.ReserveN(now, 1))
+	}
+	if t.bytes != nil {
+		// Events larger than the burst are reserved in multiple chunks, so
+		// they still pass the limiter.
+		burst := t.bytes.Burst()
+		for n := size; n > 0; n -= burst {
+			reservations = append(reservations, t.bytes.ReserveN(now, min(n, burst)))
+		}
+	}
+	for _, r := range reservations {
+		// a reservation fails if the burst was lowered concurrently
+		if r.OK() {
+			delay = max(delay, r.DelayFrom(now))
+		}
+	}
+
+	if delay <= 0 {
+		return nil
+	}
+
+	t.metrics.EventsThrottled.Inc()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		for _, r := range reservations {
+			r.Cancel()
+		}
+		t.metrics.ThrottledTime.Add(uint64(time.Since(now)))
+		return ctx.Err()
+	case <-timer.C:
+		t.metrics.ThrottledTime.Add(uint64(delay))
+		return nil
+	}
+}
+
+// Close releases the Throttle. In fair share mode, the limits of the
+// remaining harvesters are increased.
+func (t *Throttle) Close() {
+	if t == nil {
+		return
+	}
+	t.limits.release(t)
+}
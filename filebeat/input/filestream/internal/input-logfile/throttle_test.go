@@ -0,0 +1,132 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package input_logfile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+func TestRateLimitsDisabled(t *testing.T) {
+	limits := newRateLimits(rateLimitConfig{FairShare: true}, nil)
+	require.Nil(t, limits)
+
+	throttle := limits.newThrottle("source")
+	require.Nil(t, throttle)
+	require.NoError(t, throttle.Wait(context.Background(), 1024))
+	throttle.Close()
+}
+
+func TestThrottleEventsPerSecond(t *testing.T) {
+	limits := newRateLimits(rateLimitConfig{EventsPerSecond: 20}, nil)
+	throttle := limits.newThrottle("source")
+	defer throttle.Close()
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, throttle.Wait(context.Background(), 1))
+	}
+	assert.Less(t, time.Since(start), 40*time.Millisecond, "burst of one second must not be throttled")
+	assert.Zero(t, throttle.metrics.EventsThrottled.Get())
+
+	require.NoError(t, throttle.Wait(context.Background(), 1))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	assert.Equal(t, uint64(1), throttle.metrics.EventsThrottled.Get())
+	assert.NotZero(t, throttle.metrics.ThrottledTime.Get())
+}
+
+func TestThrottleBytesPerSecond(t *testing.T) {
+	limits := newRateLimits(rateLimitConfig{BytesPerSecond: 100}, nil)
+	throttle := limits.newThrottle("source")
+	defer throttle.Close()
+
+	// an event larger than the burst is delayed, but still passes
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, throttle.Wait(ctx, 150))
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestThrottleCancel(t *testing.T) {
+	limits := newRateLimits(rateLimitConfig{EventsPerSecond: 0.1}, nil)
+	throttle := limits.newThrottle("source")
+	defer throttle.Close()
+
+	require.NoError(t, throttle.Wait(context.Background(), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, throttle.Wait(ctx, 1), context.DeadlineExceeded)
+}
+
+func TestRateLimitsFairShare(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	limits := newRateLimits(
+		rateLimitConfig{EventsPerSecond: 100, BytesPerSecond: 1000, FairShare: true},
+		&Metrics{registry: reg})
+
+	first := limits.newThrottle("source-1")
+	assert.Equal(t, 100.0, first.metrics.EventsLimit.Get())
+	assert.Equal(t, 1000.0, first.metrics.BytesLimit.Get())
+
+	second := limits.newThrottle("source-2")
+	assert.Equal(t, 50.0, first.metrics.EventsLimit.Get())
+	assert.Equal(t, 50.0, second.metrics.EventsLimit.Get())
+	assert.Equal(t, 500.0, second.metrics.BytesLimit.Get())
+	assert.NotNil(t, reg.GetRegistry("harvesters").GetRegistry("source-2"))
+
+	second.Close()
+	assert.Equal(t, 100.0, first.metrics.EventsLimit.Get())
+	assert.Nil(t, reg.GetRegistry("harvesters").GetRegistry("source-2"))
+
+	first.Close()
+}
+
+func TestRateLimitsPerHarvester(t *testing.T) {
+	limits := newRateLimits(rateLimitConfig{EventsPerSecond: 100}, nil)
+
+	first := limits.newThrottle("source-1")
+	defer first.Close()
+	second := limits.newThrottle("source-2")
+	defer second.Close()
+
+	assert.Equal(t, 100.0, first.metrics.EventsLimit.Get())
+	assert.Equal(t, 100.0, second.metrics.EventsLimit.Get())
+}
+
+func TestRateLimitsRestartedHarvesterSharesMetrics(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	limits := newRateLimits(rateLimitConfig{EventsPerSecond: 100}, &Metrics{registry: reg})
+
+	key := "filestream::id::native::1-2.log"
+	old := limits.newThrottle(key)
+	restarted := limits.newThrottle(key)
+	require.Same(t, old.metrics, restarted.metrics)
+
+	old.Close()
+	require.NotNil(t, reg.GetRegistry("harvesters").GetRegistry("filestream::id::native::1-2_log"))
+	restarted.Close()
+	require.Nil(t, reg.GetRegistry("harvesters").GetRegistry("filestream::id::native::1-2_log"))
+}
@@ -78,6 +78,7 @@ type fileProspector struct {
 	ignoreInactiveSince ignoreInactiveType
 	cleanRemoved        bool
 	stateChangeCloser   stateChangeCloserConfig
+	compression         compressionMode
 }
 
 func (p *fileProspector) Init(
@@ -263,7 +264,7 @@ func (p *fileProspector) onFSEvent(
 		}
 
 		if p.isFileIgnored(log, event, ignoreSince) {
-			err := updater.ResetCursor(src, state{Offset: event.Descriptor.Info.Size()})
+			err := updater.ResetCursor(src, p.ignoredFileState(log, event))
 			if err != nil {
 				log.Errorf("setting cursor for ignored file: %v", err)
 			}
@@ -311,6 +312,21 @@ func (p *fileProspector) isFileIgnored(log *logp.Logger, fe loginp.FSEvent, igno
 	return false
 }
 
+// ignoredFileState returns the state of an ignored file, so it is not read
+// when it is updated. The offsets of compressed files are counted in
+// uncompressed bytes, so these files are marked as done instead.
+func (p *fileProspector) ignoredFileState(log *logp.Logger, fe loginp.FSEvent) state {
+	if p.compression == compressionAuto {
+		format, err := detectFileCompression(fe.NewPath)
+		if err != nil {
+			log.Debugf("Cannot detect compression of ignored file %s: %v", fe.NewPath, err)
+		} else if format != "" {
+			return state{Done: true}
+		}
+	}
+	return state{Offset: fe.Descriptor.Info.Size()}
+}
+
 func (p *fileProspector) onRemove(log *logp.Logger, fe loginp.FSEvent, src loginp.Source, s loginp.StateMetadataUpdater, hg loginp.HarvesterGroup) {
 	if p.stateChangeCloser.Removed {
 		log.Debugf("Stopping harvester as file %s has been removed and close.on_state_change.removed is enabled.", src.Name())
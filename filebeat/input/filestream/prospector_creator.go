@@ -63,6 +63,7 @@ func newProspector(config config) (loginp.Prospector, error) {
 		ignoreInactiveSince: config.IgnoreInactive,
 		cleanRemoved:        config.CleanRemoved,
 		stateChangeCloser:   config.Close.OnStateChange,
+		compression:         config.Reader.Compression,
 		logger:              logger.Named("prospector"),
 	}
 	if config.Rotation == nil {
@@ -455,6 +455,52 @@ func TestProspectorHarvesterUpdateIgnoredFiles(t *testing.T) {
 	)
 }
 
+func TestProspectorIgnoredFileState(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "test.log")
+	require.NoError(t, os.WriteFile(plain, []byte(compressionTestContent), 0o600))
+	compressed := filepath.Join(dir, "test.log.gz")
+	content := compressTestContent(t, compressionFormatGzip, compressionTestContent)
+	require.NoError(t, os.WriteFile(compressed, content, 0o600))
+
+	event := func(path string, size int64) loginp.FSEvent {
+		return loginp.FSEvent{
+			Op:         loginp.OpCreate,
+			NewPath:    path,
+			Descriptor: createTestFileDescriptorWithInfo(&testFileInfo{path, size, time.Now(), nil}),
+		}
+	}
+
+	testCases := map[string]struct {
+		compression compressionMode
+		event       loginp.FSEvent
+		expected    state
+	}{
+		"plain file": {
+			compression: compressionAuto,
+			event:       event(plain, int64(len(compressionTestContent))),
+			expected:    state{Offset: int64(len(compressionTestContent))},
+		},
+		"compressed file": {
+			compression: compressionAuto,
+			event:       event(compressed, int64(len(content))),
+			expected:    state{Done: true},
+		},
+		"compressed file without compression": {
+			compression: compressionNone,
+			event:       event(compressed, int64(len(content))),
+			expected:    state{Offset: int64(len(content))},
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := fileProspector{compression: test.compression}
+			assert.Equal(t, test.expected, p.ignoredFileState(logp.L(), test.event))
+		})
+	}
+}
+
 func TestProspectorDeletedFile(t *testing.T) {
 	testCases := map[string]struct {
 		events       []loginp.FSEvent
@@ -0,0 +1,320 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/file"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	startFromBeginning = "beginning"
+	startFromTimestamp = "timestamp"
+
+	// timestampProbeMaxBytes limits how far a probe of the binary search
+	// reads to find a line with a parsable timestamp.
+	timestampProbeMaxBytes = 1024 * 1024
+)
+
+// startTimestampConfig configures where new files are started to be read
+// when start_from is set to timestamp.
+type startTimestampConfig struct {
+	// Time is the absolute time in RFC3339 format.
+	Time string `config:"time"`
+	// Since is the duration before the start of the input.
+	Since time.Duration `config:"since"`
+	// Layouts are the Go time layouts the timestamps of the lines are parsed with.
+	Layouts []string `config:"layouts"`
+	// Pattern is a regular expression with a capture group extracting the
+	// timestamp from a line. By default the timestamp is expected at the beginning.
+	Pattern string `config:"pattern"`
+	// Timezone is used for timestamps without time zone information.
+	Timezone string `config:"timezone"`
+}
+
+func defaultStartTimestampConfig() startTimestampConfig {
+	return startTimestampConfig{
+		Layouts:  []string{time.RFC3339},
+		Timezone: "Local",
+	}
+}
+
+func (c *startTimestampConfig) Validate() error {
+	if c.Time != "" && c.Since != 0 {
+		return errors.New("only one of start_timestamp.time and start_timestamp.since can be set")
+	}
+	if c.Time != "" {
+		if _, err := time.Parse(time.RFC3339, c.Time); err != nil {
+			return fmt.Errorf("invalid start_timestamp.time, RFC3339 format expected: %w", err)
+		}
+	}
+	if c.Since < 0 {
+		return errors.New("start_timestamp.since must be positive")
+	}
+	if len(c.Layouts) == 0 {
+		return errors.New("at least one layout is required in start_timestamp.layouts")
+	}
+	if c.Pattern != "" {
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid start_timestamp.pattern: %w", err)
+		}
+		if re.NumSubexp() < 1 {
+			return errors.New("start_timestamp.pattern must contain a capture group for the timestamp")
+		}
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("invalid start_timestamp.timezone: %w", err)
+	}
+	return nil
+}
+
+// timestampSeeker finds the offset of the first line of a file with a
+// timestamp at or after the start time.
+type timestampSeeker struct {
+	start    time.Time
+	layouts  []string
+	fields   []int
+	pattern  *regexp.Regexp
+	location *time.Location
+}
+
+func newTimestampSeeker(c startTimestampConfig, now time.Time) (*timestampSeeker, error) {
+	if c.Time == "" && c.Since == 0 {
+		return nil, errors.New("start_from is set to timestamp, but neither start_timestamp.time nor start_timestamp.since is set")
+	}
+
+	s := &timestampSeeker{
+		layouts: c.Layouts,
+		fields:  make([]int, len(c.Layouts)),
+	}
+
+	if c.Time != "" {
+		start, err := time.Parse(time.RFC3339, c.Time)
+		if err != nil {
+			return nil, err
+		}
+		s.start = start
+	} else {
+		s.start = now.Add(-c.Since)
+	}
+
+	// without a pattern the timestamp is expected at the beginning of the
+	// line, spanning as many whitespace separated fields as the layout
+	for i, layout := range c.Layouts {
+		s.fields[i] = len(strings.Fields(layout))
+	}
+
+	if c.Pattern != "" {
+		s.pattern = regexp.MustCompile(c.Pattern)
+	}
+
+	var err error
+	s.location, err = time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// parse returns the timestamp of a line.
+func (s *timestampSeeker) parse(line []byte) (time.Time, bool) {
+	if s.pattern != nil {
+		m := s.pattern.FindSubmatch(line)
+		if m == nil {
+			return time.Time{}, false
+		}
+		line = m[1]
+	}
+
+	fields := strings.Fields(string(line))
+	for i, layout := range s.layouts {
+		if len(fields) < s.fields[i] {
+			continue
+		}
+		value := strings.Join(fields[:s.fields[i]], " ")
+		if ts, err := time.ParseInLocation(layout, value, s.location); err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Seek returns the offset of the first line of the file at path with
+// a timestamp at or after the start time. The file is binary searched,
+// assuming the timestamps of the lines are in order. Lines without a
+// parsable timestamp, like continuation lines of multiline messages,
+// belong to the previous line.
+//
+// If no line with a timestamp is found in the beginning of the file,
+// 0 is returned so no data is skipped by mistake.
+func (s *timestampSeeker) Seek(log *logp.Logger, path string) (int64, error) {
+	f, err := file.ReadOpen(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	format, err := detectCompression(f)
+	if err != nil {
+		return 0, err
+	}
+	if format != "" {
+		log.Infof("Compressed file %s cannot be searched for the start timestamp, reading it from the beginning", path)
+		return 0, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	p := &timestampProbe{seeker: s, f: f}
+	_, _, found, err := p.probe(0)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		log.Warnf("No timestamp matching the configured layouts found at the beginning of %s, reading it from the beginning", path)
+		return 0, nil
+	}
+
+	// find the smallest position after which the first line with a
+	// timestamp is at or after the start time, positions after which no
+	// timestamp is found are handled like the end of the file.
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		_, ts, found, err := p.probe(mid)
+		if err != nil {
+			return 0, err
+		}
+		if !found || !ts.Before(s.start) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	offset, _, found, err := p.probe(lo)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		// the remaining lines have no timestamp, they belong to a message
+		// before the start time
+		offset, err = p.lastLineEnd(lo)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	log.Infof("Start reading %s from offset %d, the first line at or after %s", path, offset, s.start.Format(time.RFC3339))
+	return offset, nil
+}
+
+type timestampProbe struct {
+	seeker *timestampSeeker
+	f      *os.File
+}
+
+// lineStart returns the offset of the first line starting at or after pos.
+func (p *timestampProbe) lineStart(pos int64) (int64, error) {
+	if pos == 0 {
+		return 0, nil
+	}
+
+	// a line starts at pos, if the previous byte is a new line
+	r := bufio.NewReader(io.NewSectionReader(p.f, pos-1, math.MaxInt64-pos))
+	offset := pos - 1
+	for {
+		skipped, err := r.ReadSlice('\n')
+		offset += int64(len(skipped))
+		switch {
+		case err == nil, errors.Is(err, io.EOF):
+			return offset, nil
+		case !errors.Is(err, bufio.ErrBufferFull):
+			return 0, err
+		}
+	}
+}
+
+// lastLineEnd returns the offset after the last complete line following pos.
+func (p *timestampProbe) lastLineEnd(pos int64) (int64, error) {
+	offset, err := p.lineStart(pos)
+	if err != nil {
+		return 0, err
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(p.f, offset, math.MaxInt64-offset))
+	var pending int64
+	for {
+		line, err := r.ReadSlice('\n')
+		pending += int64(len(line))
+		switch {
+		case err == nil:
+			offset += pending
+			pending = 0
+		case errors.Is(err, bufio.ErrBufferFull):
+			// continue reading the long line
+		case errors.Is(err, io.EOF):
+			return offset, nil
+		default:
+			return 0, err
+		}
+	}
+}
+
+// probe returns the offset and timestamp of the first line with a
+// parsable timestamp starting at or after pos.
+func (p *timestampProbe) probe(pos int64) (int64, time.Time, bool, error) {
+	offset, err := p.lineStart(pos)
+	if err != nil {
+		return 0, time.Time{}, false, err
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(p.f, offset, timestampProbeMaxBytes))
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, time.Time{}, false, err
+		}
+		// an incomplete line at the end might still be written
+		if bytes.HasSuffix(line, []byte{'\n'}) {
+			if ts, ok := p.seeker.parse(line); ok {
+				return offset, ts, true, nil
+			}
+		}
+		if err != nil {
+			return 0, time.Time{}, false, nil
+		}
+		offset += int64(len(line))
+	}
+}
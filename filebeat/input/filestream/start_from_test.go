@@ -0,0 +1,258 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func TestTimestampSeeker(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	// writeLog writes one line per minute, every third message has a
+	// continuation line, and returns the offsets of the messages.
+	writeLog := func(t *testing.T, lines int, format func(time.Time, int) string) (string, []int64) {
+		var sb strings.Builder
+		offsets := make([]int64, lines)
+		for i := 0; i < lines; i++ {
+			offsets[i] = int64(sb.Len())
+			sb.WriteString(format(base.Add(time.Duration(i)*time.Minute), i))
+			if i%3 == 0 {
+				sb.WriteString("  continuation of message " + fmt.Sprint(i) + "\n")
+			}
+		}
+		path := filepath.Join(t.TempDir(), "test.log")
+		require.NoError(t, os.WriteFile(path, []byte(sb.String()), 0o600))
+		return path, offsets
+	}
+
+	rfc3339Line := func(ts time.Time, i int) string {
+		return fmt.Sprintf("%s message %d\n", ts.Format(time.RFC3339), i)
+	}
+
+	newSeeker := func(t *testing.T, cfg startTimestampConfig) *timestampSeeker {
+		require.NoError(t, cfg.Validate())
+		s, err := newTimestampSeeker(cfg, base.Add(24*time.Hour))
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("finds the first line at or after the start time", func(t *testing.T) {
+		path, offsets := writeLog(t, 1000, rfc3339Line)
+
+		for _, i := range []int{0, 1, 2, 3, 333, 500, 998, 999} {
+			cfg := defaultStartTimestampConfig()
+			cfg.Time = base.Add(time.Duration(i) * time.Minute).Format(time.RFC3339)
+			offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+			require.NoError(t, err)
+			require.Equalf(t, offsets[i], offset, "message %d", i)
+		}
+	})
+
+	t.Run("start time between lines", func(t *testing.T) {
+		path, offsets := writeLog(t, 100, rfc3339Line)
+
+		cfg := defaultStartTimestampConfig()
+		cfg.Time = base.Add(41*time.Minute + 30*time.Second).Format(time.RFC3339)
+		offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Equal(t, offsets[42], offset)
+	})
+
+	t.Run("start time before the first line", func(t *testing.T) {
+		path, _ := writeLog(t, 100, rfc3339Line)
+
+		cfg := defaultStartTimestampConfig()
+		cfg.Time = base.Add(-time.Hour).Format(time.RFC3339)
+		offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Zero(t, offset)
+	})
+
+	t.Run("start time after the last line", func(t *testing.T) {
+		path, _ := writeLog(t, 100, rfc3339Line)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+
+		cfg := defaultStartTimestampConfig()
+		cfg.Time = base.Add(time.Hour * 10).Format(time.RFC3339)
+		offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Equal(t, info.Size(), offset)
+	})
+
+	t.Run("lines longer than the read buffer", func(t *testing.T) {
+		long := strings.Repeat("x", 10000)
+		path, offsets := writeLog(t, 50, func(ts time.Time, i int) string {
+			return fmt.Sprintf("%s %s %d\n", ts.Format(time.RFC3339), long, i)
+		})
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+
+		cfg := defaultStartTimestampConfig()
+		cfg.Time = base.Add(17 * time.Minute).Format(time.RFC3339)
+		offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Equal(t, offsets[17], offset)
+
+		cfg.Time = base.Add(time.Hour).Format(time.RFC3339)
+		offset, err = newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Equal(t, info.Size(), offset)
+	})
+
+	t.Run("relative start time", func(t *testing.T) {
+		path, offsets := writeLog(t, 100, rfc3339Line)
+
+		cfg := defaultStartTimestampConfig()
+		// the seeker is created 24h after the first line
+		cfg.Since = 24*time.Hour - 10*time.Minute
+		offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Equal(t, offsets[10], offset)
+	})
+
+	t.Run("layout with spaces and timezone", func(t *testing.T) {
+		path, offsets := writeLog(t, 100, func(ts time.Time, i int) string {
+			return fmt.Sprintf("%s [info] message %d\n", ts.In(time.FixedZone("", 2*3600)).Format("2006-01-02 15:04:05"), i)
+		})
+
+		cfg := defaultStartTimestampConfig()
+		cfg.Layouts = []string{"2006-01-02 15:04:05"}
+		cfg.Timezone = "Europe/Berlin"
+		cfg.Time = base.Add(70 * time.Minute).Format(time.RFC3339)
+		offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Equal(t, offsets[70], offset)
+	})
+
+	t.Run("timestamp extracted by pattern", func(t *testing.T) {
+		path, offsets := writeLog(t, 100, func(ts time.Time, i int) string {
+			return fmt.Sprintf(`{"message":"message %d","time":"%s"}`+"\n", i, ts.Format(time.RFC3339))
+		})
+
+		cfg := defaultStartTimestampConfig()
+		cfg.Pattern = `"time":"([^"]+)"`
+		cfg.Time = base.Add(55 * time.Minute).Format(time.RFC3339)
+		offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Equal(t, offsets[55], offset)
+	})
+
+	t.Run("file without timestamps is read from the beginning", func(t *testing.T) {
+		path, _ := writeLog(t, 100, func(_ time.Time, i int) string {
+			return fmt.Sprintf("message %d\n", i)
+		})
+
+		cfg := defaultStartTimestampConfig()
+		cfg.Time = base.Add(50 * time.Minute).Format(time.RFC3339)
+		offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Zero(t, offset)
+	})
+
+	t.Run("compressed file is read from the beginning", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.log.gz")
+		content := compressTestContent(t, compressionFormatGzip, rfc3339Line(base, 0))
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+
+		cfg := defaultStartTimestampConfig()
+		cfg.Time = base.Add(50 * time.Minute).Format(time.RFC3339)
+		offset, err := newSeeker(t, cfg).Seek(logp.L(), path)
+		require.NoError(t, err)
+		require.Zero(t, offset)
+	})
+}
+
+func TestStartFromConfig(t *testing.T) {
+	testCases := map[string]struct {
+		cfg         map[string]interface{}
+		expectedErr string
+	}{
+		"beginning is the default": {
+			cfg: map[string]interface{}{},
+		},
+		"timestamp with time": {
+			cfg: map[string]interface{}{
+				"start_from":           "timestamp",
+				"start_timestamp.time": "2024-05-01T00:00:00Z",
+			},
+		},
+		"timestamp with since": {
+			cfg: map[string]interface{}{
+				"start_from":            "timestamp",
+				"start_timestamp.since": "24h",
+			},
+		},
+		"unknown start_from": {
+			cfg:         map[string]interface{}{"start_from": "end"},
+			expectedErr: "unknown start_from value 'end'",
+		},
+		"timestamp without time": {
+			cfg:         map[string]interface{}{"start_from": "timestamp"},
+			expectedErr: "start_timestamp.time or start_timestamp.since must be set",
+		},
+		"time and since": {
+			cfg: map[string]interface{}{
+				"start_from":            "timestamp",
+				"start_timestamp.time":  "2024-05-01T00:00:00Z",
+				"start_timestamp.since": "24h",
+			},
+			expectedErr: "only one of start_timestamp.time and start_timestamp.since",
+		},
+		"invalid time": {
+			cfg: map[string]interface{}{
+				"start_from":           "timestamp",
+				"start_timestamp.time": "yesterday",
+			},
+			expectedErr: "invalid start_timestamp.time",
+		},
+		"pattern without capture group": {
+			cfg: map[string]interface{}{
+				"start_from":              "timestamp",
+				"start_timestamp.since":   "1h",
+				"start_timestamp.pattern": "time=\\S+",
+			},
+			expectedErr: "must contain a capture group",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.cfg["paths"] = []string{"/var/log/*.log"}
+			c := conf.MustNewConfigFrom(tc.cfg)
+			config := defaultConfig()
+			err := c.Unpack(&config)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
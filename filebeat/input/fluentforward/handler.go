@@ -0,0 +1,272 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fluentforward
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/elastic/beats/v7/filebeat/input/netmetrics"
+	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/streaming"
+	"github.com/elastic/beats/v7/filebeat/inputsource/tcp"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// handler serves the Forward protocol on the connections of the TCP server.
+type handler struct {
+	config  config
+	log     *logp.Logger
+	publish func(beat.Event)
+	metrics *netmetrics.TCP
+	h       *codec.MsgpackHandle
+}
+
+func (h *handler) factory(lc streaming.ListenerConfig) streaming.ConnectionHandler {
+	return func(ctx context.Context, conn net.Conn) error {
+		c := &connection{
+			handler:  h,
+			conn:     conn,
+			timeout:  lc.Timeout,
+			maxSize:  uint64(lc.MaxMessageSize),
+			metadata: tcp.MetadataCallback(conn),
+			log:      h.log.With("remote_address", conn.RemoteAddr().String()),
+		}
+		return c.serve(ctx)
+	}
+}
+
+type connection struct {
+	*handler
+	conn     net.Conn
+	timeout  time.Duration
+	maxSize  uint64
+	metadata inputsource.NetworkMetadata
+	log      *logp.Logger
+
+	// mu synchronizes the writes of handshake and ack responses.
+	mu  sync.Mutex
+	enc *codec.Encoder
+}
+
+func (c *connection) serve(ctx context.Context) error {
+	c.enc = codec.NewEncoder(c.conn, c.h)
+
+	// The limit is enforced above the buffered reader, so that only the bytes
+	// consumed by the decoder for the current message are counted.
+	r := &messageReader{
+		r:       bufio.NewReader(streaming.NewDeadlineReader(c.conn, c.timeout)),
+		maxSize: c.maxSize,
+	}
+	dec := codec.NewDecoder(r, c.h)
+
+	if c.config.SharedKey != "" {
+		if err := c.handshake(dec); err != nil {
+			return err
+		}
+	}
+
+	for ctx.Err() == nil {
+		r.reset()
+
+		var v []interface{}
+		err := dec.Decode(&v)
+		if err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			if r.exceeded {
+				// The decoder does not wrap the errors of the reader.
+				c.log.Errorw("fluent_forward message exceeds max_message_size", "error", err)
+				return fmt.Errorf("failed to decode forward message: %w", streaming.ErrMaxReadBuffer)
+			}
+			return fmt.Errorf("failed to decode forward message: %w", err)
+		}
+		received := time.Now()
+
+		msg, err := decodeMessage(c.h, v, int64(c.maxSize))
+		if err != nil {
+			return err
+		}
+		c.publishMessage(msg)
+		c.metrics.LogSize(int(r.n), received)
+	}
+	return nil
+}
+
+// publishMessage publishes the entries of a message. If the client expects
+// an acknowledgement, it is sent once all events have been acknowledged by
+// the pipeline.
+func (c *connection) publishMessage(msg message) {
+	var tracker *acker.BatchTracker
+	if msg.chunk != "" {
+		chunk := msg.chunk
+		tracker = acker.NewBatchTracker(func() {
+			if err := c.write(map[string]interface{}{"ack": chunk}); err != nil {
+				c.log.Debugw("Failed to acknowledge chunk", "error", err)
+			}
+		})
+	}
+
+	for _, e := range msg.entries {
+		event := beat.Event{
+			Timestamp: e.time,
+			Fields: mapstr.M{
+				"fluent": mapstr.M{
+					"tag":    msg.tag,
+					"record": e.record,
+				},
+			},
+		}
+		if c.metadata.RemoteAddr != nil {
+			event.Fields["log"] = mapstr.M{
+				"source": mapstr.M{
+					"address": c.metadata.RemoteAddr.String(),
+				},
+			}
+		}
+		if tracker != nil {
+			tracker.Add()
+			event.Private = tracker
+		}
+		c.publish(event)
+	}
+
+	if tracker != nil {
+		// Mark the chunk as "ready" after all events have been published.
+		tracker.Ready()
+	}
+}
+
+// handshake authenticates the client with the shared key. The server sends
+// HELO with a nonce, the client answers with PING and a digest of the shared
+// key and the server concludes with PONG.
+func (c *connection) handshake(dec *codec.Decoder) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	helo := []interface{}{"HELO", map[string]interface{}{
+		"nonce":     nonce,
+		"auth":      []byte{},
+		"keepalive": true,
+	}}
+	if err := c.write(helo); err != nil {
+		return fmt.Errorf("failed to send HELO: %w", err)
+	}
+
+	var ping []interface{}
+	if err := dec.Decode(&ping); err != nil {
+		return fmt.Errorf("failed to read PING: %w", err)
+	}
+	if len(ping) < 4 || ping[0] != "PING" {
+		return errors.New("invalid PING message")
+	}
+	clientHostname, _ := ping[1].(string)
+	salt := toBytes(ping[2])
+	digest := toBytes(ping[3])
+
+	want := sharedKeyDigest(salt, clientHostname, nonce, c.config.SharedKey)
+	if subtle.ConstantTimeCompare(digest, []byte(want)) != 1 {
+		reason := "shared_key mismatch"
+		_ = c.write([]interface{}{"PONG", false, reason, c.config.SelfHostname, ""})
+		return fmt.Errorf("handshake of %s failed: %s", clientHostname, reason)
+	}
+
+	pong := []interface{}{
+		"PONG", true, "", c.config.SelfHostname,
+		sharedKeyDigest(salt, c.config.SelfHostname, nonce, c.config.SharedKey),
+	}
+	if err := c.write(pong); err != nil {
+		return fmt.Errorf("failed to send PONG: %w", err)
+	}
+	return nil
+}
+
+func (c *connection) write(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timeout > 0 {
+		if err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+			return err
+		}
+	}
+	return c.enc.Encode(v)
+}
+
+// messageReader limits the number of bytes read by the decoder for a single
+// message to maxSize and counts them for the metrics. It implements
+// io.ByteScanner, so the decoder reads from it without buffering on its own.
+type messageReader struct {
+	r        *bufio.Reader
+	maxSize  uint64
+	n        uint64
+	exceeded bool
+}
+
+func (r *messageReader) Read(p []byte) (int, error) {
+	if r.n >= r.maxSize {
+		r.exceeded = true
+		return 0, streaming.ErrMaxReadBuffer
+	}
+	if remaining := r.maxSize - r.n; uint64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.r.Read(p)
+	r.n += uint64(n)
+	return n, err
+}
+
+func (r *messageReader) ReadByte() (byte, error) {
+	if r.n >= r.maxSize {
+		r.exceeded = true
+		return 0, streaming.ErrMaxReadBuffer
+	}
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.n++
+	}
+	return b, err
+}
+
+func (r *messageReader) UnreadByte() error {
+	err := r.r.UnreadByte()
+	if err == nil {
+		r.n--
+	}
+	return err
+}
+
+// reset starts counting the bytes of the next message.
+func (r *messageReader) reset() {
+	r.n = 0
+}
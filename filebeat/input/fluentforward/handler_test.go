@@ -0,0 +1,213 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fluentforward
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/streaming"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// testClient is the client side of a connection served by the handler.
+type testClient struct {
+	conn   net.Conn
+	enc    *codec.Encoder
+	dec    *codec.Decoder
+	events chan beat.Event
+	done   chan error
+}
+
+func newTestClient(t *testing.T, cfg config) *testClient {
+	t.Helper()
+	return newLimitedTestClient(t, cfg, 1<<20)
+}
+
+func newLimitedTestClient(t *testing.T, cfg config, maxMessageSize cfgtype.ByteSize) *testClient {
+	t.Helper()
+
+	server, client := net.Pipe()
+	events := make(chan beat.Event, 10)
+	h := &handler{
+		config:  cfg,
+		log:     logp.NewLogger(inputName),
+		publish: func(e beat.Event) { events <- e },
+		h:       newMsgpackHandle(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- h.factory(streaming.ListenerConfig{Timeout: time.Minute, MaxMessageSize: maxMessageSize})(ctx, server)
+		server.Close()
+	}()
+	t.Cleanup(func() {
+		cancel()
+		client.Close()
+	})
+
+	return &testClient{
+		conn:   client,
+		enc:    codec.NewEncoder(client, h.h),
+		dec:    codec.NewDecoder(client, h.h),
+		events: events,
+		done:   done,
+	}
+}
+
+func (c *testClient) nextEvent(t *testing.T) beat.Event {
+	t.Helper()
+
+	select {
+	case e := <-c.events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	return beat.Event{}
+}
+
+func TestHandlerAcksAfterPublishing(t *testing.T) {
+	c := newTestClient(t, defaultConfig())
+
+	record := map[string]interface{}{"log": "hello"}
+	require.NoError(t, c.enc.Encode([]interface{}{"app", []interface{}{
+		[]interface{}{eventTime(testTime), record},
+		[]interface{}{eventTime(testTime), record},
+	}, map[string]interface{}{"chunk": "chunk-1"}}))
+
+	first := c.nextEvent(t)
+	second := c.nextEvent(t)
+	assert.True(t, testTime.Equal(first.Timestamp))
+	tag, _ := first.Fields.GetValue("fluent.tag")
+	assert.Equal(t, "app", tag)
+	got, _ := first.Fields.GetValue("fluent.record")
+	assert.Equal(t, record, got)
+
+	acks := make(chan map[string]interface{}, 1)
+	go func() {
+		var ack map[string]interface{}
+		if err := c.dec.Decode(&ack); err == nil {
+			acks <- ack
+		}
+	}()
+
+	// The chunk is only acknowledged once all its events are acknowledged.
+	first.Private.(*acker.BatchTracker).ACK()
+	select {
+	case ack := <-acks:
+		t.Fatalf("chunk acknowledged before all events: %v", ack)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	second.Private.(*acker.BatchTracker).ACK()
+	select {
+	case ack := <-acks:
+		assert.Equal(t, map[string]interface{}{"ack": "chunk-1"}, ack)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for ack")
+	}
+}
+
+func TestHandlerWithoutChunk(t *testing.T) {
+	c := newTestClient(t, defaultConfig())
+
+	require.NoError(t, c.enc.Encode([]interface{}{"app", testTime.Unix(), map[string]interface{}{"log": "hello"}}))
+	e := c.nextEvent(t)
+	assert.Nil(t, e.Private, "events without chunk are not tracked")
+
+	c.conn.Close()
+	assert.NoError(t, <-c.done)
+}
+
+func TestHandlerMaxMessageSize(t *testing.T) {
+	c := newLimitedTestClient(t, defaultConfig(), 32)
+
+	// Both messages are sent in a single write, so they are buffered together.
+	// The limit applies to each message on its own.
+	small := []interface{}{"app", testTime.Unix(), map[string]interface{}{"log": "hello"}}
+	var buf []byte
+	enc := codec.NewEncoderBytes(&buf, newMsgpackHandle())
+	require.NoError(t, enc.Encode(small))
+	require.NoError(t, enc.Encode(small))
+	require.Less(t, len(buf)/2, 32)
+	require.Greater(t, len(buf), 32)
+	_, err := c.conn.Write(buf)
+	require.NoError(t, err)
+	c.nextEvent(t)
+	c.nextEvent(t)
+
+	large := []interface{}{"app", testTime.Unix(), map[string]interface{}{"log": strings.Repeat("x", 100)}}
+	go c.enc.Encode(large) //nolint:errcheck // The server closes the connection.
+	err = <-c.done
+	assert.ErrorIs(t, err, streaming.ErrMaxReadBuffer)
+}
+
+func TestHandlerSharedKey(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.SharedKey = "secret"
+	cfg.SelfHostname = "filebeat"
+
+	handshake := func(t *testing.T, c *testClient, key string) []interface{} {
+		var helo []interface{}
+		require.NoError(t, c.dec.Decode(&helo))
+		require.Equal(t, "HELO", helo[0])
+		nonce := toBytes(helo[1].(map[string]interface{})["nonce"])
+		require.Len(t, nonce, 16)
+
+		salt := []byte("salt")
+		require.NoError(t, c.enc.Encode([]interface{}{
+			"PING", "fluent-bit", salt, sharedKeyDigest(salt, "fluent-bit", nonce, key), "", "",
+		}))
+
+		var pong []interface{}
+		require.NoError(t, c.dec.Decode(&pong))
+		require.Equal(t, "PONG", pong[0])
+		if pong[1] == true {
+			assert.Equal(t, sharedKeyDigest(salt, "filebeat", nonce, key), pong[4], "server proves knowledge of the key")
+		}
+		return pong
+	}
+
+	t.Run("valid key", func(t *testing.T) {
+		c := newTestClient(t, cfg)
+		pong := handshake(t, c, "secret")
+		assert.Equal(t, true, pong[1])
+
+		require.NoError(t, c.enc.Encode([]interface{}{"app", testTime.Unix(), map[string]interface{}{"log": "hello"}}))
+		c.nextEvent(t)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		c := newTestClient(t, cfg)
+		pong := handshake(t, c, "wrong")
+		assert.Equal(t, false, pong[1])
+		assert.ErrorContains(t, <-c.done, "shared_key mismatch")
+	})
+}
@@ -0,0 +1,117 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fluentforward
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/elastic/beats/v7/filebeat/input/netmetrics"
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/filebeat/inputsource/tcp"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/go-concert/ctxtool"
+)
+
+const inputName = "fluent_forward"
+
+func Plugin() input.Plugin {
+	return input.Plugin{
+		Name:       inputName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "Receives events sent with the Fluent Forward protocol.",
+		Manager:    input.ConfigureWith(configure),
+	}
+}
+
+func configure(cfg *conf.C) (input.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	if config.SelfHostname == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname for self_hostname: %w", err)
+		}
+		config.SelfHostname = hostname
+	}
+
+	return &forwardInput{config: config}, nil
+}
+
+// forwardInput implements the Filebeat input V2 interface. The input is stateless.
+type forwardInput struct {
+	config config
+}
+
+func (i *forwardInput) Name() string { return inputName }
+
+func (i *forwardInput) Test(_ input.TestContext) error {
+	l, err := net.Listen("tcp", i.config.Host)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+func (i *forwardInput) Run(ctx input.Context, pipeline beat.Pipeline) error {
+	log := ctx.Logger.With("host", i.config.Host)
+
+	log.Info("Starting " + inputName + " input")
+	defer log.Info(inputName + " input stopped")
+
+	// Create client for publishing events and receive notification of their ACKs.
+	client, err := pipeline.ConnectWith(beat.ClientConfig{
+		EventListener: acker.BatchTrackerReporter(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline client: %w", err)
+	}
+	defer client.Close()
+
+	const pollInterval = time.Minute
+	metrics := netmetrics.NewTCP(inputName, ctx.ID, i.config.Host, pollInterval, log)
+	defer metrics.Close()
+
+	h := &handler{
+		config:  i.config,
+		log:     log,
+		publish: client.Publish,
+		metrics: metrics,
+		h:       newMsgpackHandle(),
+	}
+	server, err := tcp.New(&i.config.Config, h.factory)
+	if err != nil {
+		return err
+	}
+
+	err = server.Run(ctxtool.FromCanceller(ctx.Cancelation))
+	// Ignore error from 'Run' in case shutdown was signaled.
+	if ctxerr := ctx.Cancelation.Err(); ctxerr != nil {
+		err = ctxerr
+	}
+	return err
+}
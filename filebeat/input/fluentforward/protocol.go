@@ -0,0 +1,230 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fluentforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+// The Forward protocol v1 is documented at
+// https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1.
+
+// eventTimeExt is the msgpack extension type of EventTime values.
+const eventTimeExt = 0
+
+func newMsgpackHandle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	h.WriteExt = true
+	return h
+}
+
+// entry is an event of a forward message.
+type entry struct {
+	time   time.Time
+	record map[string]interface{}
+}
+
+// message is a message in one of the event modes of the protocol: Message,
+// Forward, PackedForward or CompressedPackedForward.
+type message struct {
+	tag     string
+	entries []entry
+	// chunk is the chunk option, if set the client expects the message to
+	// be acknowledged.
+	chunk string
+}
+
+// decodeMessage decodes a message from its msgpack array. maxSize limits the
+// size of decompressed entries.
+func decodeMessage(h *codec.MsgpackHandle, v []interface{}, maxSize int64) (message, error) {
+	if len(v) < 2 {
+		return message{}, fmt.Errorf("invalid message with %d elements", len(v))
+	}
+	tag, ok := v[0].(string)
+	if !ok {
+		return message{}, fmt.Errorf("invalid message tag of type %T", v[0])
+	}
+	msg := message{tag: tag}
+
+	var options map[string]interface{}
+	var err error
+	switch events := v[1].(type) {
+	case []interface{}:
+		// Forward mode: [tag, [[time, record], ...], option]
+		options, err = messageOptions(v, 2)
+		if err != nil {
+			return message{}, err
+		}
+		for _, e := range events {
+			pair, ok := e.([]interface{})
+			if !ok {
+				return message{}, fmt.Errorf("invalid entry of type %T", e)
+			}
+			ent, err := decodeEntry(pair)
+			if err != nil {
+				return message{}, err
+			}
+			msg.entries = append(msg.entries, ent)
+		}
+
+	case string, []byte:
+		// PackedForward mode: [tag, msgpack stream of [time, record], option]
+		options, err = messageOptions(v, 2)
+		if err != nil {
+			return message{}, err
+		}
+		var packed io.Reader = bytes.NewReader(toBytes(events))
+		if compressed, _ := options["compressed"].(string); compressed != "" {
+			if compressed != "gzip" {
+				return message{}, fmt.Errorf("unsupported compression %q", compressed)
+			}
+			gz, err := gzip.NewReader(packed)
+			if err != nil {
+				return message{}, fmt.Errorf("failed to decompress entries: %w", err)
+			}
+			defer gz.Close()
+			packed = io.LimitReader(gz, maxSize)
+		}
+		msg.entries, err = decodePackedEntries(h, packed)
+		if err != nil {
+			return message{}, err
+		}
+
+	default:
+		// Message mode: [tag, time, record, option]
+		if len(v) < 3 {
+			return message{}, errors.New("invalid message without record")
+		}
+		options, err = messageOptions(v, 3)
+		if err != nil {
+			return message{}, err
+		}
+		ent, err := decodeEntry(v[1:3])
+		if err != nil {
+			return message{}, err
+		}
+		msg.entries = []entry{ent}
+	}
+
+	if chunk, ok := options["chunk"]; ok {
+		msg.chunk = string(toBytes(chunk))
+	}
+	return msg, nil
+}
+
+func messageOptions(v []interface{}, i int) (map[string]interface{}, error) {
+	if len(v) <= i || v[i] == nil {
+		return nil, nil
+	}
+	options, ok := v[i].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid message option of type %T", v[i])
+	}
+	return options, nil
+}
+
+func decodePackedEntries(h *codec.MsgpackHandle, r io.Reader) ([]entry, error) {
+	var entries []entry
+	dec := codec.NewDecoder(r, h)
+	for {
+		var pair []interface{}
+		err := dec.Decode(&pair)
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode packed entries: %w", err)
+		}
+		ent, err := decodeEntry(pair)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, ent)
+	}
+}
+
+func decodeEntry(pair []interface{}) (entry, error) {
+	if len(pair) != 2 {
+		return entry{}, fmt.Errorf("invalid entry with %d elements", len(pair))
+	}
+	ts, err := decodeTime(pair[0])
+	if err != nil {
+		return entry{}, err
+	}
+	record, ok := pair[1].(map[string]interface{})
+	if !ok {
+		return entry{}, fmt.Errorf("invalid record of type %T", pair[1])
+	}
+	return entry{time: ts, record: record}, nil
+}
+
+// decodeTime decodes the time of an entry, either an EventTime with
+// nanosecond precision or the seconds since the epoch.
+func decodeTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case codec.RawExt:
+		if t.Tag != eventTimeExt || len(t.Data) != 8 {
+			return time.Time{}, fmt.Errorf("invalid event time extension type %d", t.Tag)
+		}
+		sec := binary.BigEndian.Uint32(t.Data)
+		nsec := binary.BigEndian.Uint32(t.Data[4:])
+		return time.Unix(int64(sec), int64(nsec)).UTC(), nil
+	case uint64:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case int64:
+		return time.Unix(t, 0).UTC(), nil
+	case float64:
+		sec := int64(t)
+		return time.Unix(sec, int64((t-float64(sec))*1e9)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid event time of type %T", v)
+}
+
+func toBytes(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	}
+	return nil
+}
+
+// sharedKeyDigest returns the digest that proves the knowledge of the shared
+// key during the handshake.
+func sharedKeyDigest(salt []byte, hostname string, nonce []byte, sharedKey string) string {
+	h := sha512.New()
+	h.Write(salt)
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(sharedKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,146 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fluentforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+var testTime = time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+
+func eventTime(t time.Time) codec.RawExt {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data, uint32(t.Unix()))
+	binary.BigEndian.PutUint32(data[4:], uint32(t.Nanosecond()))
+	return codec.RawExt{Tag: eventTimeExt, Data: data}
+}
+
+func encode(t *testing.T, h *codec.MsgpackHandle, v ...interface{}) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc := codec.NewEncoder(&buf, h)
+	for _, item := range v {
+		require.NoError(t, enc.Encode(item))
+	}
+	return buf.Bytes()
+}
+
+// roundTrip encodes and decodes a message, like it is received from a client.
+func roundTrip(t *testing.T, h *codec.MsgpackHandle, v []interface{}) []interface{} {
+	t.Helper()
+
+	var out []interface{}
+	require.NoError(t, codec.NewDecoderBytes(encode(t, h, v), h).Decode(&out))
+	return out
+}
+
+func TestDecodeMessage(t *testing.T) {
+	h := newMsgpackHandle()
+	record := map[string]interface{}{"log": "hello"}
+	packed := encode(t, h,
+		[]interface{}{eventTime(testTime), record},
+		[]interface{}{testTime.Unix(), record},
+	)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(packed)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	tests := map[string]struct {
+		message   []interface{}
+		wantTimes []time.Time
+		wantChunk string
+	}{
+		"message": {
+			message:   []interface{}{"app", eventTime(testTime), record},
+			wantTimes: []time.Time{testTime},
+		},
+		"message with seconds": {
+			message:   []interface{}{"app", testTime.Unix(), record, map[string]interface{}{"chunk": "c1"}},
+			wantTimes: []time.Time{testTime.Truncate(time.Second)},
+			wantChunk: "c1",
+		},
+		"forward": {
+			message: []interface{}{"app", []interface{}{
+				[]interface{}{eventTime(testTime), record},
+				[]interface{}{eventTime(testTime.Add(time.Second)), record},
+			}, map[string]interface{}{"chunk": "c2"}},
+			wantTimes: []time.Time{testTime, testTime.Add(time.Second)},
+			wantChunk: "c2",
+		},
+		"packed forward": {
+			message:   []interface{}{"app", packed},
+			wantTimes: []time.Time{testTime, testTime.Truncate(time.Second)},
+		},
+		"compressed packed forward": {
+			message: []interface{}{"app", compressed.Bytes(), map[string]interface{}{
+				"compressed": "gzip",
+				"chunk":      "c3",
+			}},
+			wantTimes: []time.Time{testTime, testTime.Truncate(time.Second)},
+			wantChunk: "c3",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg, err := decodeMessage(h, roundTrip(t, h, tc.message), 1<<20)
+			require.NoError(t, err)
+
+			assert.Equal(t, "app", msg.tag)
+			assert.Equal(t, tc.wantChunk, msg.chunk)
+			require.Len(t, msg.entries, len(tc.wantTimes))
+			for i, e := range msg.entries {
+				assert.True(t, tc.wantTimes[i].Equal(e.time), "got time %v, want %v", e.time, tc.wantTimes[i])
+				assert.Equal(t, record, e.record)
+			}
+		})
+	}
+}
+
+func TestDecodeMessageErrors(t *testing.T) {
+	h := newMsgpackHandle()
+
+	tests := map[string][]interface{}{
+		"no entries":           {"app"},
+		"invalid tag":          {42, []interface{}{}},
+		"message no record":    {"app", testTime.Unix()},
+		"invalid record":       {"app", testTime.Unix(), "record"},
+		"invalid time":         {"app", "now", map[string]interface{}{}},
+		"invalid option":       {"app", []interface{}{}, "option"},
+		"unknown compression":  {"app", []byte{1}, map[string]interface{}{"compressed": "zstd"}},
+		"invalid packed entry": {"app", []byte{0x91, 0x01}},
+	}
+
+	for name, message := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeMessage(h, roundTrip(t, h, message), 1<<20)
+			assert.Error(t, err)
+		})
+	}
+}
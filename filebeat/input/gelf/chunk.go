@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gelf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	chunkHeaderSize = 12
+	maxChunks       = 128
+)
+
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// isChunked returns true if the datagram is a chunk of a larger message.
+func isChunked(data []byte) bool {
+	return len(data) >= chunkHeaderSize && bytes.Equal(data[:2], chunkMagic)
+}
+
+// chunkedMessage collects the chunks of a message.
+type chunkedMessage struct {
+	id       uint64
+	start    time.Time
+	chunks   [][]byte
+	received int
+	size     uint64
+	done     bool
+}
+
+// reassembler reassembles messages sent in chunks over UDP. Messages are
+// dropped when all their chunks don't arrive within the timeout, or when
+// the chunks of newer messages need the memory they are using.
+type reassembler struct {
+	log     *logp.Logger
+	timeout time.Duration
+	maxSize uint64
+
+	size    uint64
+	pending map[uint64]*chunkedMessage
+	// order keeps the pending messages in the order their first chunk
+	// arrived in, which is also the order they expire in.
+	order []*chunkedMessage
+}
+
+func newReassembler(log *logp.Logger, timeout time.Duration, maxSize uint64) *reassembler {
+	return &reassembler{
+		log:     log,
+		timeout: timeout,
+		maxSize: maxSize,
+		pending: make(map[uint64]*chunkedMessage),
+	}
+}
+
+// add adds a chunk and returns the payload of its message once all chunks
+// have been received, nil otherwise.
+func (r *reassembler) add(data []byte, now time.Time) ([]byte, error) {
+	id := binary.BigEndian.Uint64(data[2:10])
+	seq, count := int(data[10]), int(data[11])
+	if count == 0 || count > maxChunks || seq >= count {
+		return nil, fmt.Errorf("invalid chunk %d of %d for message %x", seq, count, id)
+	}
+
+	r.expire(now)
+
+	msg, ok := r.pending[id]
+	if !ok {
+		msg = &chunkedMessage{id: id, start: now, chunks: make([][]byte, count)}
+		r.pending[id] = msg
+		r.order = append(r.order, msg)
+	}
+	if len(msg.chunks) != count {
+		err := fmt.Errorf("chunk count of message %x changed from %d to %d", id, len(msg.chunks), count)
+		r.drop(msg)
+		return nil, err
+	}
+	if msg.chunks[seq] != nil {
+		return nil, nil
+	}
+
+	chunk := data[chunkHeaderSize:]
+	if !r.reserve(uint64(len(chunk)), msg) {
+		r.drop(msg)
+		return nil, fmt.Errorf("message %x exceeds chunk_buffer_size", id)
+	}
+	// The buffer the chunk was read into can be reused.
+	msg.chunks[seq] = append([]byte(nil), chunk...)
+	msg.size += uint64(len(chunk))
+	r.size += uint64(len(chunk))
+	msg.received++
+	if msg.received < count {
+		return nil, nil
+	}
+
+	payload := bytes.Join(msg.chunks, nil)
+	r.drop(msg)
+	return payload, nil
+}
+
+// expire drops the messages that didn't receive all chunks in time.
+func (r *reassembler) expire(now time.Time) {
+	for len(r.order) != 0 {
+		msg := r.order[0]
+		if !msg.done {
+			if now.Sub(msg.start) < r.timeout {
+				return
+			}
+			r.log.Debugf("dropping incomplete message %x: received %d of %d chunks", msg.id, msg.received, len(msg.chunks))
+			r.drop(msg)
+		}
+		r.order[0] = nil
+		r.order = r.order[1:]
+	}
+}
+
+// reserve makes room for size bytes by dropping the oldest pending
+// messages other than msg. It returns false if there is not enough room.
+func (r *reassembler) reserve(size uint64, msg *chunkedMessage) bool {
+	if msg.size+size > r.maxSize {
+		return false
+	}
+	for _, old := range r.order {
+		if r.size+size <= r.maxSize {
+			break
+		}
+		if old.done || old == msg {
+			continue
+		}
+		r.log.Debugf("dropping incomplete message %x: chunk_buffer_size reached", old.id)
+		r.drop(old)
+	}
+	return true
+}
+
+// drop removes msg from the pending messages and releases its chunks. The
+// message stays in order until it expires, so only its chunks count
+// towards chunk_buffer_size.
+func (r *reassembler) drop(msg *chunkedMessage) {
+	if msg.done {
+		return
+	}
+	msg.done = true
+	msg.chunks = nil
+	r.size -= msg.size
+	delete(r.pending, msg.id)
+}
@@ -0,0 +1,132 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gelf
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func chunk(id uint64, seq, count int, data string) []byte {
+	b := make([]byte, chunkHeaderSize, chunkHeaderSize+len(data))
+	copy(b, chunkMagic)
+	binary.BigEndian.PutUint64(b[2:], id)
+	b[10], b[11] = byte(seq), byte(count)
+	return append(b, data...)
+}
+
+func TestReassembler(t *testing.T) {
+	now := time.Now()
+	log := logp.NewLogger("test")
+
+	t.Run("out of order chunks", func(t *testing.T) {
+		r := newReassembler(log, time.Second, 1024)
+		assert.True(t, isChunked(chunk(1, 0, 3, "a")))
+
+		for _, c := range [][]byte{chunk(1, 2, 3, "c"), chunk(1, 0, 3, "a"), chunk(1, 0, 3, "a")} {
+			payload, err := r.add(c, now)
+			require.NoError(t, err)
+			assert.Nil(t, payload)
+		}
+		payload, err := r.add(chunk(1, 1, 3, "b"), now)
+		require.NoError(t, err)
+		assert.Equal(t, "abc", string(payload))
+		assert.Empty(t, r.pending)
+		assert.Zero(t, r.size)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		r := newReassembler(log, time.Second, 1024)
+
+		_, err := r.add(chunk(1, 0, 2, "a"), now)
+		require.NoError(t, err)
+		_, err = r.add(chunk(2, 0, 2, "x"), now.Add(500*time.Millisecond))
+		require.NoError(t, err)
+
+		payload, err := r.add(chunk(1, 1, 2, "b"), now.Add(time.Second))
+		require.NoError(t, err)
+		assert.Nil(t, payload, "message 1 expired")
+		assert.Equal(t, 1, r.pending[1].received, "late chunk starts a new message")
+
+		payload, err = r.add(chunk(2, 1, 2, "y"), now.Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, "xy", string(payload))
+	})
+
+	t.Run("buffer size", func(t *testing.T) {
+		r := newReassembler(log, time.Second, 4)
+
+		_, err := r.add(chunk(1, 0, 2, "aa"), now)
+		require.NoError(t, err)
+		_, err = r.add(chunk(2, 0, 2, "xx"), now)
+		require.NoError(t, err)
+		_, err = r.add(chunk(2, 1, 2, "y"), now)
+		require.NoError(t, err, "oldest message is dropped to make room")
+		assert.NotContains(t, r.pending, uint64(1))
+
+		_, err = r.add(chunk(3, 0, 2, "12345"), now)
+		assert.Error(t, err, "message larger than the buffer")
+		assert.Zero(t, r.size)
+	})
+
+	t.Run("dropped messages release their chunks", func(t *testing.T) {
+		r := newReassembler(log, time.Second, 1024)
+
+		_, err := r.add(chunk(1, 0, 2, "a"), now)
+		require.NoError(t, err)
+		_, err = r.add(chunk(2, 0, 2, "b"), now)
+		require.NoError(t, err)
+		_, err = r.add(chunk(2, 1, 3, "c"), now)
+		assert.Error(t, err)
+		_, err = r.add(chunk(3, 0, 2, "x"), now)
+		require.NoError(t, err)
+		payload, err := r.add(chunk(3, 1, 2, "y"), now)
+		require.NoError(t, err)
+		assert.Equal(t, "xy", string(payload))
+
+		// The dropped and the completed message wait behind the pending
+		// message until they expire, without holding on to their chunks.
+		require.Len(t, r.order, 3)
+		for _, msg := range r.order[1:] {
+			assert.True(t, msg.done)
+			assert.Nil(t, msg.chunks, "chunks of message %x are released", msg.id)
+		}
+		assert.Equal(t, uint64(1), r.size)
+	})
+
+	t.Run("invalid chunks", func(t *testing.T) {
+		r := newReassembler(log, time.Second, 1024)
+
+		_, err := r.add(chunk(1, 2, 2, "a"), now)
+		assert.Error(t, err)
+		_, err = r.add(chunk(1, 0, 129, "a"), now)
+		assert.Error(t, err)
+
+		_, err = r.add(chunk(1, 0, 2, "a"), now)
+		require.NoError(t, err)
+		_, err = r.add(chunk(1, 1, 3, "b"), now)
+		assert.Error(t, err)
+		assert.Empty(t, r.pending)
+	})
+}
@@ -0,0 +1,113 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gelf
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elastic/beats/v7/filebeat/inputsource/tcp"
+	"github.com/elastic/beats/v7/filebeat/inputsource/udp"
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+const httpName = "http"
+
+type config struct {
+	Protocol conf.Namespace `config:"protocol"`
+
+	// MaxDecompressedSize limits the size of compressed messages once
+	// decompressed.
+	MaxDecompressedSize cfgtype.ByteSize `config:"max_decompressed_size" validate:"positive"`
+}
+
+type udpConfig struct {
+	udp.Config `config:",inline"`
+
+	// ChunkTimeout is the time all chunks of a message must arrive in,
+	// incomplete messages are dropped after it.
+	ChunkTimeout time.Duration `config:"chunk_timeout" validate:"positive"`
+	// ChunkBufferSize limits the total size of the chunks kept while
+	// waiting for messages to be complete.
+	ChunkBufferSize cfgtype.ByteSize `config:"chunk_buffer_size" validate:"positive"`
+}
+
+type tcpConfig struct {
+	tcp.Config `config:",inline"`
+}
+
+type httpConfig struct {
+	Host           string                  `config:"host"`
+	Path           string                  `config:"path"`
+	Timeout        time.Duration           `config:"timeout" validate:"positive"`
+	MaxMessageSize cfgtype.ByteSize        `config:"max_message_size" validate:"nonzero,positive"`
+	TLS            *tlscommon.ServerConfig `config:"ssl"`
+}
+
+func (c *httpConfig) Validate() error {
+	if c.Host == "" {
+		return errors.New("need to specify the host using the `host:port` syntax")
+	}
+	if len(c.Path) == 0 || c.Path[0] != '/' {
+		return fmt.Errorf("path must start with /: %q", c.Path)
+	}
+	return nil
+}
+
+func defaultConfig() config {
+	return config{
+		MaxDecompressedSize: 10 * humanize.MiByte,
+	}
+}
+
+func defaultUDP() udpConfig {
+	return udpConfig{
+		Config: udp.Config{
+			Host: "localhost:12201",
+			// Non chunked messages can use the whole datagram.
+			MaxMessageSize: 64 * humanize.KiByte,
+			Timeout:        time.Minute * 5,
+		},
+		ChunkTimeout:    5 * time.Second,
+		ChunkBufferSize: 10 * humanize.MiByte,
+	}
+}
+
+func defaultTCP() tcpConfig {
+	return tcpConfig{
+		Config: tcp.Config{
+			Host:           "localhost:12201",
+			Timeout:        time.Minute * 5,
+			MaxMessageSize: 20 * humanize.MiByte,
+		},
+	}
+}
+
+func defaultHTTP() httpConfig {
+	return httpConfig{
+		Host:           "localhost:12202",
+		Path:           "/gelf",
+		Timeout:        time.Minute,
+		MaxMessageSize: 20 * humanize.MiByte,
+	}
+}
@@ -0,0 +1,61 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gelf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	var got []string
+	h := &httpHandler{
+		log:     logp.NewLogger("test"),
+		maxSize: 64,
+		handle: func(data []byte, _ string) error {
+			_, err := parseMessage(data, 1024, time.Now())
+			if err == nil {
+				got = append(got, string(data))
+			}
+			return err
+		},
+	}
+
+	for _, tc := range []struct {
+		method string
+		body   string
+		status int
+	}{
+		{http.MethodPost, `{"version":"1.1","host":"h","short_message":"hello"}`, http.StatusAccepted},
+		{http.MethodPost, `{"version":"1.1","host":"h"}`, http.StatusBadRequest},
+		{http.MethodPost, `{"version":"1.1","host":"h","short_message":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge},
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.method, "/gelf", strings.NewReader(tc.body)))
+		assert.Equal(t, tc.status, w.Code, tc.body)
+	}
+	assert.Len(t, got, 1)
+}
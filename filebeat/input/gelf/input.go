@@ -0,0 +1,211 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gelf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/elastic/beats/v7/filebeat/input/netmetrics"
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/streaming"
+	"github.com/elastic/beats/v7/filebeat/inputsource/tcp"
+	"github.com/elastic/beats/v7/filebeat/inputsource/udp"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/go-concert/ctxtool"
+)
+
+const inputName = "gelf"
+
+func Plugin() input.Plugin {
+	return input.Plugin{
+		Name:       inputName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "GELF server over UDP, TCP and HTTP",
+		Manager:    stateless.NewInputManager(configure),
+	}
+}
+
+func configure(cfg *conf.C) (stateless.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+	if !config.Protocol.IsSet() {
+		return nil, errors.New("protocol must be set to one of udp, tcp or http")
+	}
+
+	s := &server{
+		protocol:            config.Protocol.Name(),
+		maxDecompressedSize: uint64(config.MaxDecompressedSize),
+	}
+	protoCfg := config.Protocol.Config()
+	var err error
+	switch s.protocol {
+	case udp.Name:
+		s.udp = defaultUDP()
+		err = protoCfg.Unpack(&s.udp)
+		s.host = s.udp.Host
+	case tcp.Name:
+		s.tcp = defaultTCP()
+		err = protoCfg.Unpack(&s.tcp)
+		s.host = s.tcp.Host
+	case httpName:
+		s.http = defaultHTTP()
+		err = protoCfg.Unpack(&s.http)
+		s.host = s.http.Host
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q, must be one of udp, tcp or http", s.protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type server struct {
+	protocol            string
+	host                string
+	maxDecompressedSize uint64
+
+	udp  udpConfig
+	tcp  tcpConfig
+	http httpConfig
+}
+
+func (s *server) Name() string { return inputName }
+
+func (s *server) Test(_ input.TestContext) error {
+	if s.protocol == udp.Name {
+		l, err := net.ListenPacket("udp", s.host)
+		if err != nil {
+			return err
+		}
+		return l.Close()
+	}
+	l, err := net.Listen("tcp", s.host)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+func (s *server) Run(ctx input.Context, publisher stateless.Publisher) error {
+	log := ctx.Logger.With("host", s.host, "protocol", s.protocol)
+
+	log.Info("starting gelf input")
+	defer log.Info("gelf input stopped")
+
+	// publish publishes a message, data must not be retained as the
+	// buffer can be reused.
+	publish := func(data []byte, remoteAddr string) error {
+		evt, err := parseMessage(data, s.maxDecompressedSize, time.Now())
+		if err != nil {
+			return err
+		}
+		if remoteAddr != "" {
+			evt.Fields.Put("log.source.address", remoteAddr)
+		}
+		publisher.Publish(evt)
+		return nil
+	}
+
+	const pollInterval = time.Minute
+	var err error
+	switch s.protocol {
+	case udp.Name:
+		metrics := netmetrics.NewUDP(inputName, ctx.ID, s.host, uint64(s.udp.ReadBuffer), pollInterval, log)
+		defer metrics.Close()
+
+		chunks := newReassembler(log, s.udp.ChunkTimeout, uint64(s.udp.ChunkBufferSize))
+		server := udp.New(&s.udp.Config, func(data []byte, metadata inputsource.NetworkMetadata) {
+			start := time.Now()
+			payload := data
+			if isChunked(data) {
+				var err error
+				payload, err = chunks.add(data, start)
+				if err != nil {
+					log.Warnw("dropping chunk", "error", err)
+					return
+				}
+				if payload == nil {
+					return
+				}
+			}
+			if err := publish(payload, remoteAddr(metadata)); err != nil {
+				log.Warnw("dropping message", "error", err)
+				return
+			}
+			// This must be called after publish to measure the
+			// processing time metric.
+			metrics.Log(payload, start)
+		})
+		err = server.Run(ctxtool.FromCanceller(ctx.Cancelation))
+
+	case tcp.Name:
+		metrics := netmetrics.NewTCP(inputName, ctx.ID, s.host, pollInterval, log)
+		defer metrics.Close()
+
+		var server *tcp.Server
+		server, err = tcp.New(&s.tcp.Config, streaming.SplitHandlerFactory(
+			inputsource.FamilyTCP, log, tcp.MetadataCallback, func(data []byte, metadata inputsource.NetworkMetadata) {
+				if len(data) == 0 {
+					return
+				}
+				start := time.Now()
+				if err := publish(data, remoteAddr(metadata)); err != nil {
+					log.Warnw("dropping message", "error", err)
+					return
+				}
+				metrics.Log(data, start)
+			},
+			// Messages are delimited by null bytes.
+			streaming.FactoryDelimiter([]byte{0}),
+		))
+		if err != nil {
+			return err
+		}
+		err = server.Run(ctxtool.FromCanceller(ctx.Cancelation))
+
+	case httpName:
+		err = runHTTP(ctxtool.FromCanceller(ctx.Cancelation), s.http, &httpHandler{
+			log:     log,
+			maxSize: int64(s.http.MaxMessageSize),
+			handle:  publish,
+		})
+	}
+
+	// Ignore error from 'Run' in case shutdown was signaled.
+	if ctxerr := ctx.Cancelation.Err(); ctxerr != nil {
+		err = ctxerr
+	}
+	return err
+}
+
+func remoteAddr(metadata inputsource.NetworkMetadata) string {
+	if metadata.RemoteAddr == nil {
+		return ""
+	}
+	return metadata.RemoteAddr.String()
+}
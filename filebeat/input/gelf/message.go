@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// defaultLevel is the level of messages without level as defined by the
+// GELF specification.
+const defaultLevel = 1
+
+var severityNames = []string{
+	"Emergency",
+	"Alert",
+	"Critical",
+	"Error",
+	"Warning",
+	"Notice",
+	"Informational",
+	"Debug",
+}
+
+// additionalFields maps the additional fields set by well known clients,
+// like the Docker gelf log driver, to ECS fields. Other additional fields
+// are kept under gelf.
+var additionalFields = map[string]string{
+	"_container_id":   "container.id",
+	"_container_name": "container.name",
+	"_image_name":     "container.image.name",
+	"_image_id":       "container.image.hash.all",
+}
+
+var errMessageTooLarge = errors.New("decompressed message exceeds max_decompressed_size")
+
+// decompress returns the payload of a message, decompressing it when it
+// starts with a gzip or a zlib header.
+func decompress(data []byte, maxSize uint64) ([]byte, error) {
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case len(data) >= 2 && data[0]&0x0f == 8 && binary.BigEndian.Uint16(data)%31 == 0:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed message: %w", err)
+	}
+	defer r.Close()
+
+	payload, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed message: %w", err)
+	}
+	if uint64(len(payload)) > maxSize {
+		return nil, errMessageTooLarge
+	}
+	return payload, nil
+}
+
+// parseMessage decodes a GELF message payload into an event.
+func parseMessage(data []byte, maxSize uint64, now time.Time) (beat.Event, error) {
+	payload, err := decompress(data, maxSize)
+	if err != nil {
+		return beat.Event{}, err
+	}
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return beat.Event{}, fmt.Errorf("invalid GELF message: %w", err)
+	}
+	return toEvent(msg, now)
+}
+
+// toEvent maps the fields of a GELF message to ECS.
+func toEvent(msg map[string]interface{}, now time.Time) (beat.Event, error) {
+	short, ok := msg["short_message"].(string)
+	if !ok || short == "" {
+		return beat.Event{}, errors.New("invalid GELF message: missing short_message")
+	}
+
+	evt := beat.Event{
+		Timestamp: now,
+		Fields: mapstr.M{
+			"message": short,
+		},
+	}
+	gelf := mapstr.M{}
+	level := defaultLevel
+	for k, v := range msg {
+		switch k {
+		case "short_message":
+		case "full_message":
+			gelf["full_message"] = v
+		case "version":
+			gelf["version"] = v
+		case "host":
+			if s, ok := v.(string); ok && s != "" {
+				evt.Fields.Put("host.name", s)
+			}
+		case "timestamp":
+			if ts, ok := v.(float64); ok {
+				evt.Timestamp = time.UnixMicro(int64(math.Round(ts * 1e6))).UTC()
+			}
+		case "level":
+			if l, ok := v.(float64); ok && l >= 0 && int(l) < len(severityNames) {
+				level = int(l)
+			}
+		case "facility":
+			evt.Fields.Put("log.syslog.facility.name", v)
+		case "file":
+			evt.Fields.Put("log.origin.file.name", v)
+		case "line":
+			evt.Fields.Put("log.origin.file.line", v)
+		case "_id":
+			// Reserved by the specification.
+		default:
+			if field, ok := additionalFields[k]; ok {
+				evt.Fields.Put(field, v)
+				continue
+			}
+			gelf[strings.TrimPrefix(k, "_")] = v
+		}
+	}
+
+	evt.Fields.Put("log.level", strings.ToLower(severityNames[level]))
+	evt.Fields.Put("log.syslog.severity.code", level)
+	evt.Fields.Put("log.syslog.severity.name", severityNames[level])
+	if len(gelf) != 0 {
+		evt.Fields["gelf"] = gelf
+	}
+	return evt, nil
+}
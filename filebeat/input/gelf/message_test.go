@@ -0,0 +1,127 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const dockerMessage = `{
+	"version": "1.1",
+	"host": "docker-host",
+	"short_message": "GET /index.html 200",
+	"full_message": "GET /index.html 200\nwith details",
+	"timestamp": 1700000000.123,
+	"level": 3,
+	"facility": "nginx",
+	"file": "main.go",
+	"line": 42,
+	"_container_id": "abc123",
+	"_container_name": "web",
+	"_image_name": "nginx:latest",
+	"_tag": "web-tag",
+	"_id": "reserved"
+}`
+
+func TestParseMessage(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(dockerMessage))
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte(dockerMessage))
+	zw.Close()
+
+	want := mapstr.M{
+		"message": "GET /index.html 200",
+		"host":    mapstr.M{"name": "docker-host"},
+		"container": mapstr.M{
+			"id":    "abc123",
+			"name":  "web",
+			"image": mapstr.M{"name": "nginx:latest"},
+		},
+		"log": mapstr.M{
+			"level": "error",
+			"syslog": mapstr.M{
+				"severity": mapstr.M{"code": 3, "name": "Error"},
+				"facility": mapstr.M{"name": "nginx"},
+			},
+			"origin": mapstr.M{"file": mapstr.M{"name": "main.go", "line": float64(42)}},
+		},
+		"gelf": mapstr.M{
+			"version":      "1.1",
+			"full_message": "GET /index.html 200\nwith details",
+			"tag":          "web-tag",
+		},
+	}
+
+	for name, data := range map[string][]byte{
+		"plain": []byte(dockerMessage),
+		"gzip":  gz.Bytes(),
+		"zlib":  zl.Bytes(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			evt, err := parseMessage(data, 1<<20, now)
+			require.NoError(t, err)
+			assert.Equal(t, want, evt.Fields)
+			assert.Equal(t, time.UnixMilli(1700000000123).UTC(), evt.Timestamp)
+		})
+	}
+}
+
+func TestParseMessageDefaults(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	evt, err := parseMessage([]byte(`{"version":"1.1","host":"h","short_message":"hello"}`), 1<<20, now)
+	require.NoError(t, err)
+	assert.Equal(t, now, evt.Timestamp)
+	level, _ := evt.Fields.GetValue("log.level")
+	assert.Equal(t, "alert", level, "level defaults to 1")
+}
+
+func TestParseMessageErrors(t *testing.T) {
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(bytes.Repeat([]byte(" "), 2048))
+	gw.Close()
+
+	for name, data := range map[string][]byte{
+		"not json":          []byte("hello"),
+		"no short_message":  []byte(`{"version":"1.1","host":"h"}`),
+		"empty message":     []byte(`{"version":"1.1","host":"h","short_message":""}`),
+		"too large":         gz.Bytes(),
+		"corrupted gzip":    gz.Bytes()[:12],
+		"invalid zlib data": {0x78, 0x9c, 0xff, 0xff},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseMessage(data, 1024, time.Now())
+			assert.Error(t, err)
+		})
+	}
+}
@@ -20,6 +20,8 @@
 package journald
 
 import (
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -71,6 +73,31 @@ type config struct {
 
 	// Parsers configuration
 	Parsers parser.Config `config:",inline"`
+
+	// Backend selects how the journal is read, either by running journalctl
+	// or with the native reader for journal files and export streams.
+	Backend string `config:"backend"`
+}
+
+const (
+	backendJournalctl = "journalctl"
+	backendNative     = "native"
+)
+
+func (c *config) Validate() error {
+	switch c.Backend {
+	case backendJournalctl:
+	case backendNative:
+		if len(c.Paths) == 0 {
+			return errors.New("the native backend requires paths to be set")
+		}
+		if c.Seek == journalctl.SeekTail {
+			return errors.New("the native backend does not support seek tail")
+		}
+	default:
+		return fmt.Errorf("unknown backend %q", c.Backend)
+	}
+	return nil
 }
 
 // bwcIncludeMatches is a wrapper that accepts include_matches configuration
@@ -101,5 +128,6 @@ func defaultConfig() config {
 	return config{
 		Seek:               journalctl.SeekHead,
 		SaveRemoteHostname: false,
+		Backend:            backendJournalctl,
 	}
 }
@@ -61,3 +61,31 @@ include_matches:
 		verify(t, yaml)
 	})
 }
+
+func TestConfigBackend(t *testing.T) {
+	tests := map[string]struct {
+		yaml    string
+		wantErr string
+	}{
+		"default":          {yaml: `id: test`},
+		"native":           {yaml: `{backend: native, paths: [/tmp/system.journal]}`},
+		"native no paths":  {yaml: `backend: native`, wantErr: "requires paths"},
+		"native seek tail": {yaml: `{backend: native, paths: [/tmp/system.journal], seek: tail}`, wantErr: "does not support seek tail"},
+		"unknown":          {yaml: `backend: sdjournal`, wantErr: "unknown backend"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := conf.NewConfigWithYAML([]byte(tc.yaml), "source")
+			require.NoError(t, err)
+
+			config := defaultConfig()
+			err = c.Unpack(&config)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
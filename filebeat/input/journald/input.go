@@ -22,11 +22,13 @@ package journald
 import (
 	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"time"
 
 	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalctl"
 	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalfield"
+	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalfile"
 	input "github.com/elastic/beats/v7/filebeat/input/v2"
 	cursor "github.com/elastic/beats/v7/filebeat/input/v2/input-cursor"
 	"github.com/elastic/beats/v7/libbeat/feature"
@@ -57,6 +59,7 @@ type journald struct {
 	SaveRemoteHostname bool
 	Parsers            parser.Config
 	Journalctl         bool
+	Backend            string
 }
 
 type checkpoint struct {
@@ -121,25 +124,19 @@ func Configure(cfg *conf.C) ([]cursor.Source, cursor.Input, error) {
 		Facilities:         config.Facilities,
 		SaveRemoteHostname: config.SaveRemoteHostname,
 		Parsers:            config.Parsers,
+		Backend:            config.Backend,
 	}, nil
 }
 
 func (inp *journald) Name() string { return pluginName }
 
 func (inp *journald) Test(src cursor.Source, ctx input.TestContext) error {
-	reader, err := journalctl.New(
+	reader, err := inp.newReader(
 		ctx.Logger.With("input_id", inp.ID),
 		ctx.Cancelation,
-		inp.Units,
-		inp.Identifiers,
-		inp.Transports,
-		inp.Matches,
-		inp.Facilities,
 		journalctl.SeekHead,
 		"",
-		inp.Since,
 		src.Name(),
-		journalctl.Factory,
 	)
 	if err != nil {
 		return err
@@ -147,6 +144,44 @@ func (inp *journald) Test(src cursor.Source, ctx input.TestContext) error {
 	return reader.Close()
 }
 
+// newReader returns the reader for the configured backend.
+func (inp *journald) newReader(
+	logger *logp.Logger,
+	canceler input.Canceler,
+	mode journalctl.SeekMode,
+	pos string,
+	path string,
+) (journalReader, error) {
+	if inp.Backend == backendNative {
+		return journalfile.New(
+			logger,
+			inp.Units,
+			inp.Identifiers,
+			inp.Transports,
+			inp.Matches,
+			inp.Facilities,
+			mode,
+			pos,
+			inp.Since,
+			path,
+		)
+	}
+	return journalctl.New(
+		logger,
+		canceler,
+		inp.Units,
+		inp.Identifiers,
+		inp.Transports,
+		inp.Matches,
+		inp.Facilities,
+		mode,
+		pos,
+		inp.Since,
+		path,
+		journalctl.Factory,
+	)
+}
+
 func (inp *journald) Run(
 	ctx input.Context,
 	src cursor.Source,
@@ -162,20 +197,7 @@ func (inp *journald) Run(
 
 	mode := inp.Seek
 	pos := currentCheckpoint.Position
-	reader, err := journalctl.New(
-		logger,
-		ctx.Cancelation,
-		inp.Units,
-		inp.Identifiers,
-		inp.Transports,
-		inp.Matches,
-		inp.Facilities,
-		mode,
-		pos,
-		inp.Since,
-		src.Name(),
-		journalctl.Factory,
-	)
+	reader, err := inp.newReader(logger, ctx.Cancelation, mode, pos, src.Name())
 	if err != nil {
 		wrappedErr := fmt.Errorf("could not start journal reader: %w", err)
 		ctx.UpdateStatus(status.Failed, wrappedErr.Error())
@@ -203,6 +225,11 @@ func (inp *journald) Run(
 				// Journalctl is restarting, do ignore the empty event
 			case errors.Is(err, journalctl.ErrRestarting):
 				continue
+				// The native backend has read the whole file
+			case errors.Is(err, io.EOF):
+				logger.Info("finished reading journal file")
+				ctx.UpdateStatus(status.Stopped, "Finished reading")
+				return nil
 			default:
 				msg := fmt.Sprintf("could not read event: %s", err)
 				ctx.UpdateStatus(status.Failed, msg)
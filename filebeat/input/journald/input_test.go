@@ -130,11 +130,20 @@ func TestInputFieldsTranslation(t *testing.T) {
 // __CURSOR - it is added to the registry and there are other tests for it
 // __MONOTONIC_TIMESTAMP - it is part of the cursor
 func TestCompareGoSystemdWithJournalctl(t *testing.T) {
+	for _, backend := range []string{backendJournalctl, backendNative} {
+		t.Run(backend, func(t *testing.T) {
+			testCompareWithGoldenFile(t, backend)
+		})
+	}
+}
+
+func testCompareWithGoldenFile(t *testing.T, backend string) {
 	out := decompress(t, filepath.Join("testdata", "input-multiline-parser.journal.gz"))
 	env := newInputTestingEnvironment(t)
 	inp := env.mustCreateInput(mapstr.M{
-		"paths": []string{out},
-		"seek":  "head",
+		"paths":   []string{out},
+		"seek":    "head",
+		"backend": backend,
 	})
 
 	ctx, cancelInput := context.WithCancel(context.Background())
@@ -284,22 +293,25 @@ func TestMatchers(t *testing.T) {
 		},
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			env := newInputTestingEnvironment(t)
-			cfg := mapstr.M{
-				"paths":           []string{out},
-				"include_matches": tc.matchers,
-			}
-			cfg.Update(mapstr.M(tc.confiFields))
-			inp := env.mustCreateInput(cfg)
+	for _, backend := range []string{backendJournalctl, backendNative} {
+		for _, tc := range testCases {
+			t.Run(backend+"/"+tc.name, func(t *testing.T) {
+				env := newInputTestingEnvironment(t)
+				cfg := mapstr.M{
+					"paths":           []string{out},
+					"include_matches": tc.matchers,
+					"backend":         backend,
+				}
+				cfg.Update(mapstr.M(tc.confiFields))
+				inp := env.mustCreateInput(cfg)
 
-			ctx, cancelInput := context.WithCancel(context.Background())
-			defer cancelInput()
+				ctx, cancelInput := context.WithCancel(context.Background())
+				defer cancelInput()
 
-			env.startInput(ctx, inp)
-			env.waitUntilEventCount(tc.expectedEvents)
-		})
+				env.startInput(ctx, inp)
+				env.waitUntilEventCount(tc.expectedEvents)
+			})
+		}
 	}
 }
 
@@ -0,0 +1,108 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package journalfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalctl"
+)
+
+// exportReader reads entries in the journal export format, as written by
+// `journalctl -o export`. The format is documented at
+// https://systemd.io/JOURNAL_EXPORT_FORMATS/.
+type exportReader struct {
+	r *bufio.Reader
+}
+
+func newExportReader(r io.Reader) *exportReader {
+	return &exportReader{r: bufio.NewReader(r)}
+}
+
+// next returns the next entry of the stream or io.EOF.
+func (e *exportReader) next() (journalctl.JournalEntry, error) {
+	b := newEntryBuilder()
+	for {
+		line, err := e.r.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(line) == 0 && !b.empty() {
+				// The last entry is not followed by an empty line.
+				return b.entry()
+			}
+			if errors.Is(err, io.EOF) && len(line) != 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return journalctl.JournalEntry{}, err
+		}
+		line = line[:len(line)-1]
+
+		if len(line) == 0 {
+			if b.empty() {
+				continue
+			}
+			return b.entry()
+		}
+
+		if key, value, ok := bytes.Cut(line, []byte("=")); ok {
+			b.add(string(key), value)
+			continue
+		}
+
+		// Fields that are not printable are serialized as the field name
+		// followed by the little endian 64 bit size of the value, the value
+		// and a newline.
+		value, err := e.readBinary()
+		if err != nil {
+			return journalctl.JournalEntry{}, fmt.Errorf("failed to read field %q: %w", line, err)
+		}
+		b.add(string(line), value)
+	}
+}
+
+func (e *exportReader) readBinary() ([]byte, error) {
+	var size [8]byte
+	if _, err := io.ReadFull(e.r, size[:]); err != nil {
+		return nil, noEOF(err)
+	}
+	n := binary.LittleEndian.Uint64(size[:])
+	if n > maxObjectSize {
+		return nil, fmt.Errorf("invalid field size %d", n)
+	}
+	value := make([]byte, n+1)
+	if _, err := io.ReadFull(e.r, value); err != nil {
+		return nil, noEOF(err)
+	}
+	if value[n] != '\n' {
+		return nil, errors.New("field is not terminated by a newline")
+	}
+	return value[:n], nil
+}
+
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
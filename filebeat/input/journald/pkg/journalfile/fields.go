@@ -0,0 +1,211 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package journalfile
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalctl"
+)
+
+// Cursor is a journal cursor. Its string representation is the one used by
+// journalctl, so cursors stored by either reader can be used by the other.
+type Cursor struct {
+	SeqnumID  string
+	Seqnum    uint64
+	BootID    string
+	Monotonic uint64
+	Realtime  uint64
+	XorHash   uint64
+}
+
+func (c Cursor) String() string {
+	return fmt.Sprintf("s=%s;i=%x;b=%s;m=%x;t=%x;x=%x",
+		c.SeqnumID, c.Seqnum, c.BootID, c.Monotonic, c.Realtime, c.XorHash)
+}
+
+// ParseCursor parses the string representation of a cursor.
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	var found bool
+	for _, part := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+		}
+
+		var err error
+		switch k {
+		case "s":
+			c.SeqnumID, found = v, true
+		case "b":
+			c.BootID = v
+		case "i":
+			c.Seqnum, err = strconv.ParseUint(v, 16, 64)
+		case "m":
+			c.Monotonic, err = strconv.ParseUint(v, 16, 64)
+		case "t":
+			c.Realtime, err = strconv.ParseUint(v, 16, 64)
+			found = true
+		case "x":
+			c.XorHash, err = strconv.ParseUint(v, 16, 64)
+		}
+		if err != nil {
+			return Cursor{}, fmt.Errorf("invalid cursor %q: %w", s, err)
+		}
+	}
+	if !found {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return c, nil
+}
+
+// after reports whether the entry with cursor c comes after the entry with
+// cursor other. Sequence numbers are only comparable within the same
+// sequence number ID, otherwise the wallclock time is used.
+func (c Cursor) after(other Cursor) bool {
+	if c.SeqnumID != "" && c.SeqnumID == other.SeqnumID {
+		return c.Seqnum > other.Seqnum
+	}
+	return c.Realtime > other.Realtime
+}
+
+// entryBuilder collects the fields of an entry with the same value types as
+// journalctl's JSON output decoded into a map: printable values are strings,
+// other values are arrays of byte values and fields that occur more than once
+// hold an array of their values.
+type entryBuilder struct {
+	fields map[string][]any
+}
+
+func newEntryBuilder() *entryBuilder {
+	return &entryBuilder{fields: map[string][]any{}}
+}
+
+func (b *entryBuilder) add(key string, value []byte) {
+	b.fields[key] = append(b.fields[key], fieldValue(value))
+}
+
+func (b *entryBuilder) addString(key, value string) {
+	b.fields[key] = append(b.fields[key], value)
+}
+
+func (b *entryBuilder) empty() bool { return len(b.fields) == 0 }
+
+// entry returns the journal entry. The cursor and timestamps are taken from
+// the __CURSOR, __REALTIME_TIMESTAMP and __MONOTONIC_TIMESTAMP fields.
+func (b *entryBuilder) entry() (journalctl.JournalEntry, error) {
+	fields := make(map[string]any, len(b.fields))
+	for k, v := range b.fields {
+		if len(v) == 1 {
+			fields[k] = v[0]
+		} else {
+			fields[k] = v
+		}
+	}
+	b.fields = map[string][]any{}
+
+	cursor, ok := fields["__CURSOR"].(string)
+	if !ok {
+		return journalctl.JournalEntry{}, errors.New("journal entry has no __CURSOR")
+	}
+	realtime, err := uintField(fields, "__REALTIME_TIMESTAMP")
+	if err != nil {
+		return journalctl.JournalEntry{}, err
+	}
+	monotonic, err := uintField(fields, "__MONOTONIC_TIMESTAMP")
+	if err != nil {
+		return journalctl.JournalEntry{}, err
+	}
+
+	return journalctl.JournalEntry{
+		Fields:             fields,
+		Cursor:             cursor,
+		RealtimeTimestamp:  realtime,
+		MonotonicTimestamp: monotonic,
+	}, nil
+}
+
+func uintField(fields map[string]any, key string) (uint64, error) {
+	s, ok := fields[key].(string)
+	if !ok {
+		return 0, fmt.Errorf("journal entry has no %s", key)
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not convert '%s' to uint64: %w", key, err)
+	}
+	return v, nil
+}
+
+// fieldValue converts a field value like journalctl does for its JSON
+// output. Values that are not valid UTF-8 or contain control characters
+// other than newline and tab are represented as an array of their bytes.
+func fieldValue(value []byte) any {
+	if isPrintable(value) {
+		return string(value)
+	}
+	bytes := make([]any, len(value))
+	for i, b := range value {
+		// Numbers are decoded as float64 from journalctl's JSON output.
+		bytes[i] = float64(b)
+	}
+	return bytes
+}
+
+func isPrintable(b []byte) bool {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size <= 1 {
+			return false
+		}
+		if (r < ' ' && r != '\t' && r != '\n') || (0x7f <= r && r <= 0x9f) {
+			return false
+		}
+		b = b[size:]
+	}
+	return true
+}
+
+// fieldStrings returns the string values of a field.
+func fieldStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func id128String(b []byte) string {
+	return hex.EncodeToString(b[:16])
+}
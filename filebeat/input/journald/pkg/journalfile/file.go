@@ -0,0 +1,295 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package journalfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+
+	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalctl"
+)
+
+// The journal file format is documented at
+// https://systemd.io/JOURNAL_FILE_FORMAT/.
+
+var journalSignature = []byte("LPKSHHRH")
+
+// Incompatible header flags.
+const (
+	flagCompressedXZ   = 1 << 0
+	flagCompressedLZ4  = 1 << 1
+	flagKeyedHash      = 1 << 2
+	flagCompressedZSTD = 1 << 3
+	flagCompact        = 1 << 4
+
+	supportedFlags = flagCompressedXZ | flagCompressedLZ4 | flagKeyedHash | flagCompressedZSTD | flagCompact
+)
+
+// Object types.
+const (
+	objectData       = 1
+	objectEntry      = 3
+	objectEntryArray = 6
+)
+
+// Object flags.
+const (
+	objectCompressedXZ   = 1 << 0
+	objectCompressedLZ4  = 1 << 1
+	objectCompressedZSTD = 1 << 2
+)
+
+const (
+	headerMinSize    = 208
+	objectHeaderSize = 16
+
+	// maxObjectSize protects against corrupt object sizes.
+	maxObjectSize = 64 << 20
+)
+
+// journalFile reads the entries of a journal file in the order of its entry
+// arrays, which is the order in which they were written.
+type journalFile struct {
+	r       io.ReaderAt
+	compact bool
+
+	seqnumID string
+	nEntries uint64
+	read     uint64
+
+	nextArray uint64   // Offset of the next entry array object.
+	items     []uint64 // Entry offsets of the current entry array.
+
+	zstd *zstd.Decoder
+}
+
+func newJournalFile(r io.ReaderAt) (*journalFile, error) {
+	header := make([]byte, headerMinSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read journal file header: %w", err)
+	}
+	if !bytes.Equal(header[:8], journalSignature) {
+		return nil, errors.New("not a journal file")
+	}
+
+	flags := binary.LittleEndian.Uint32(header[12:])
+	if flags&^supportedFlags != 0 {
+		return nil, fmt.Errorf("unsupported journal file features 0x%x", flags&^supportedFlags)
+	}
+
+	f := &journalFile{
+		r:         r,
+		compact:   flags&flagCompact != 0,
+		seqnumID:  id128String(header[72:]),
+		nEntries:  binary.LittleEndian.Uint64(header[152:]),
+		nextArray: binary.LittleEndian.Uint64(header[176:]),
+	}
+	if flags&flagCompressedZSTD != 0 {
+		d, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		f.zstd = d
+	}
+	return f, nil
+}
+
+func (f *journalFile) close() {
+	if f.zstd != nil {
+		f.zstd.Close()
+	}
+}
+
+// next returns the next entry of the file or io.EOF.
+func (f *journalFile) next() (journalctl.JournalEntry, error) {
+	for f.read < f.nEntries {
+		if len(f.items) == 0 {
+			if f.nextArray == 0 {
+				break
+			}
+			if err := f.loadEntryArray(); err != nil {
+				return journalctl.JournalEntry{}, err
+			}
+			continue
+		}
+
+		offset := f.items[0]
+		f.items = f.items[1:]
+		if offset == 0 {
+			// The rest of the array has not been used yet.
+			f.items = nil
+			continue
+		}
+		f.read++
+		return f.readEntry(offset)
+	}
+	return journalctl.JournalEntry{}, io.EOF
+}
+
+func (f *journalFile) loadEntryArray() error {
+	obj, err := f.readObject(f.nextArray, objectEntryArray)
+	if err != nil {
+		return err
+	}
+	if len(obj) < 24 {
+		return fmt.Errorf("invalid entry array at offset %d", f.nextArray)
+	}
+	f.nextArray = binary.LittleEndian.Uint64(obj[16:])
+	f.items = f.offsets(obj[24:])
+	return nil
+}
+
+// offsets decodes a list of object offsets, which are 32 bits wide in compact
+// journal files.
+func (f *journalFile) offsets(b []byte) []uint64 {
+	if f.compact {
+		offsets := make([]uint64, len(b)/4)
+		for i := range offsets {
+			offsets[i] = uint64(binary.LittleEndian.Uint32(b[4*i:]))
+		}
+		return offsets
+	}
+	offsets := make([]uint64, len(b)/8)
+	for i := range offsets {
+		offsets[i] = binary.LittleEndian.Uint64(b[8*i:])
+	}
+	return offsets
+}
+
+func (f *journalFile) readEntry(offset uint64) (journalctl.JournalEntry, error) {
+	obj, err := f.readObject(offset, objectEntry)
+	if err != nil {
+		return journalctl.JournalEntry{}, err
+	}
+	if len(obj) < 64 {
+		return journalctl.JournalEntry{}, fmt.Errorf("invalid entry at offset %d", offset)
+	}
+
+	le := binary.LittleEndian
+	cursor := Cursor{
+		SeqnumID:  f.seqnumID,
+		Seqnum:    le.Uint64(obj[16:]),
+		Realtime:  le.Uint64(obj[24:]),
+		Monotonic: le.Uint64(obj[32:]),
+		BootID:    id128String(obj[40:]),
+		XorHash:   le.Uint64(obj[56:]),
+	}
+
+	// Like journalctl, the boot ID is taken from the entry rather than from
+	// its data.
+	b := newEntryBuilder()
+	b.addString("__CURSOR", cursor.String())
+	b.addString("__REALTIME_TIMESTAMP", strconv.FormatUint(cursor.Realtime, 10))
+	b.addString("__MONOTONIC_TIMESTAMP", strconv.FormatUint(cursor.Monotonic, 10))
+	b.addString("_BOOT_ID", cursor.BootID)
+
+	offsets := f.offsets(obj[64:])
+	if !f.compact {
+		// Regular entry items hold the data object offset followed by
+		// its hash.
+		for i := 0; 2*i < len(offsets); i++ {
+			offsets[i] = offsets[2*i]
+		}
+		offsets = offsets[:(len(offsets)+1)/2]
+	}
+	for _, dataOffset := range offsets {
+		payload, err := f.readData(dataOffset)
+		if err != nil {
+			return journalctl.JournalEntry{}, err
+		}
+		key, value, ok := bytes.Cut(payload, []byte("="))
+		if !ok || string(key) == "_BOOT_ID" {
+			continue
+		}
+		b.add(string(key), value)
+	}
+	return b.entry()
+}
+
+// readData returns the decompressed payload of a data object.
+func (f *journalFile) readData(offset uint64) ([]byte, error) {
+	obj, err := f.readObject(offset, objectData)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadOffset := 64
+	if f.compact {
+		payloadOffset = 72
+	}
+	if len(obj) < payloadOffset {
+		return nil, fmt.Errorf("invalid data object at offset %d", offset)
+	}
+	payload := obj[payloadOffset:]
+
+	switch flags := obj[1]; {
+	case flags&objectCompressedZSTD != 0:
+		if f.zstd == nil {
+			return nil, fmt.Errorf("unexpected zstd compressed data object at offset %d", offset)
+		}
+		return f.zstd.DecodeAll(payload, nil)
+	case flags&objectCompressedLZ4 != 0:
+		// The uncompressed size is stored before the compressed block.
+		if len(payload) < 8 {
+			return nil, fmt.Errorf("invalid lz4 compressed data object at offset %d", offset)
+		}
+		size := binary.LittleEndian.Uint64(payload)
+		if size > maxObjectSize {
+			return nil, fmt.Errorf("invalid lz4 compressed data object at offset %d", offset)
+		}
+		out := make([]byte, size)
+		n, err := lz4.UncompressBlock(payload[8:], out)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress data object at offset %d: %w", offset, err)
+		}
+		return out[:n], nil
+	case flags&objectCompressedXZ != 0:
+		return nil, fmt.Errorf("xz compressed data object at offset %d is not supported", offset)
+	}
+	return payload, nil
+}
+
+// readObject reads the object at offset and verifies its type.
+func (f *journalFile) readObject(offset uint64, typ byte) ([]byte, error) {
+	var header [objectHeaderSize]byte
+	if _, err := f.r.ReadAt(header[:], int64(offset)); err != nil {
+		return nil, fmt.Errorf("failed to read object at offset %d: %w", offset, err)
+	}
+	if header[0] != typ {
+		return nil, fmt.Errorf("object at offset %d has type %d, expected %d", offset, header[0], typ)
+	}
+	size := binary.LittleEndian.Uint64(header[8:])
+	if size < objectHeaderSize || size > maxObjectSize {
+		return nil, fmt.Errorf("object at offset %d has invalid size %d", offset, size)
+	}
+
+	obj := make([]byte, size)
+	if _, err := f.r.ReadAt(obj, int64(offset)); err != nil {
+		return nil, fmt.Errorf("failed to read object at offset %d: %w", offset, err)
+	}
+	return obj, nil
+}
@@ -0,0 +1,170 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package journalfile
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalfield"
+)
+
+// unitSuffixes are the systemd unit types, names without one of them are
+// service names.
+var unitSuffixes = []string{
+	".service", ".socket", ".target", ".device", ".mount", ".automount",
+	".swap", ".timer", ".path", ".slice", ".scope",
+}
+
+// match is a single field match, the value may be a glob pattern.
+type match struct {
+	key, value string
+	glob       bool
+}
+
+func (m match) matches(fields map[string]any) bool {
+	for _, v := range fieldStrings(fields[m.key]) {
+		if m.glob {
+			if ok, _ := path.Match(m.value, v); ok {
+				return true
+			}
+		} else if v == m.value {
+			return true
+		}
+	}
+	return false
+}
+
+// term is a conjunction of matches.
+type term []match
+
+func (t term) matches(fields map[string]any) bool {
+	for _, m := range t {
+		if !m.matches(fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// filter selects entries the same way journalctl does for the input's
+// options: an entry must match every group and a group matches if any of its
+// terms match. Like journalctl, include matches for the same field are
+// alternatives while matches for different fields must all match.
+type filter [][]term
+
+func newFilter(
+	units []string,
+	syslogIdentifiers []string,
+	transports []string,
+	matchers journalfield.IncludeMatches,
+	facilities []int,
+) filter {
+	var f filter
+
+	if len(units) > 0 {
+		var group []term
+		for _, u := range units {
+			u = mangleUnit(u)
+			glob := isGlob(u)
+			group = append(group,
+				term{{key: "_SYSTEMD_UNIT", value: u, glob: glob}},
+				term{{key: "COREDUMP_UNIT", value: u, glob: glob}},
+				term{{key: "_PID", value: "1"}, {key: "UNIT", value: u, glob: glob}},
+				term{{key: "_UID", value: "0"}, {key: "OBJECT_SYSTEMD_UNIT", value: u, glob: glob}},
+			)
+		}
+		f = append(f, group)
+	}
+
+	if len(syslogIdentifiers) > 0 {
+		var group []term
+		for _, id := range syslogIdentifiers {
+			group = append(group, term{{key: "SYSLOG_IDENTIFIER", value: id}})
+		}
+		f = append(f, group)
+	}
+
+	if len(facilities) > 0 {
+		var group []term
+		for _, facility := range facilities {
+			group = append(group, term{{key: "SYSLOG_FACILITY", value: strconv.Itoa(facility)}})
+		}
+		f = append(f, group)
+	}
+
+	// Group the include matches and transports by field.
+	var keys []string
+	byKey := map[string][]term{}
+	addMatch := func(s string) {
+		key, value, _ := strings.Cut(s, "=")
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], term{{key: key, value: value}})
+	}
+	for _, m := range matchers.Matches {
+		addMatch(m.String())
+	}
+	for _, t := range transports {
+		addMatch("_TRANSPORT=" + t)
+	}
+	for _, key := range keys {
+		f = append(f, byKey[key])
+	}
+
+	return f
+}
+
+func (f filter) matches(fields map[string]any) bool {
+	for _, group := range f {
+		found := false
+		for _, t := range group {
+			if t.matches(fields) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// mangleUnit appends the .service suffix to unit names without a unit type
+// like systemd does for the --unit flag.
+func mangleUnit(name string) string {
+	if isGlob(name) {
+		// Globs are used as is.
+		return name
+	}
+	for _, suffix := range unitSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return name
+		}
+	}
+	return name + ".service"
+}
+
+func isGlob(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
@@ -0,0 +1,163 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package journalfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalctl"
+	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalfield"
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// entryReader is implemented by the journal file and export format readers.
+type entryReader interface {
+	next() (journalctl.JournalEntry, error)
+}
+
+// Reader reads a journal file or a journal export stream without using
+// journalctl or the systemd libraries. The entries it returns are the same
+// journalctl returns for the file, including their cursors.
+//
+// Unlike the journalctl reader, the Reader does not follow the file: once
+// all entries have been read Next returns io.EOF.
+type Reader struct {
+	logger *logp.Logger
+	file   *os.File
+
+	entries entryReader
+	journal *journalFile // Set when reading a journal file.
+
+	filter filter
+	cursor Cursor
+	resume bool
+	since  time.Time
+}
+
+// New opens a journal file or a file in the journal export format and
+// returns a Reader for it. The format is detected from the file content.
+//
+// Entries are filtered like the journalctl reader does for the same options.
+// If cursor is not empty only entries after the cursor are returned,
+// otherwise mode defines where reading starts. SeekTail is not supported as
+// the file is not followed.
+func New(
+	logger *logp.Logger,
+	units []string,
+	syslogIdentifiers []string,
+	transports []string,
+	matchers journalfield.IncludeMatches,
+	facilities []int,
+	mode journalctl.SeekMode,
+	cursor string,
+	since time.Duration,
+	file string,
+) (*Reader, error) {
+	r := &Reader{
+		logger: logger.Named("reader").With("path", file),
+		filter: newFilter(units, syslogIdentifiers, transports, matchers, facilities),
+	}
+
+	switch {
+	case cursor != "":
+		c, err := ParseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		r.cursor, r.resume = c, true
+	case mode == journalctl.SeekSince:
+		r.since = time.Now().Add(since)
+	case mode == journalctl.SeekTail:
+		return nil, errors.New("seek tail is not supported when reading journal files")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal file: %w", err)
+	}
+	r.file = f
+
+	signature := make([]byte, len(journalSignature))
+	if _, err := io.ReadFull(f, signature); err == nil && bytes.Equal(signature, journalSignature) {
+		r.journal, err = newJournalFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read journal file %s: %w", file, err)
+		}
+		r.entries = r.journal
+		return r, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.entries = newExportReader(f)
+	return r, nil
+}
+
+// Close closes the file.
+func (r *Reader) Close() error {
+	if r.journal != nil {
+		r.journal.close()
+	}
+	return r.file.Close()
+}
+
+// Next returns the next entry matching the filters. It returns io.EOF once
+// all entries have been read and journalctl.ErrCancelled if cancel is done.
+func (r *Reader) Next(cancel input.Canceler) (journalctl.JournalEntry, error) {
+	for {
+		select {
+		case <-cancel.Done():
+			return journalctl.JournalEntry{}, journalctl.ErrCancelled
+		default:
+		}
+
+		entry, err := r.entries.next()
+		if err != nil {
+			return journalctl.JournalEntry{}, err
+		}
+
+		if r.resume {
+			c, err := ParseCursor(entry.Cursor)
+			if err != nil {
+				return journalctl.JournalEntry{}, err
+			}
+			if !c.after(r.cursor) {
+				continue
+			}
+			r.resume = false
+		}
+		if !r.since.IsZero() && time.UnixMicro(int64(entry.RealtimeTimestamp)).Before(r.since) {
+			continue
+		}
+		if !r.filter.matches(entry.Fields) {
+			continue
+		}
+		return entry, nil
+	}
+}
@@ -0,0 +1,207 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package journalfile
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalctl"
+	"github.com/elastic/beats/v7/filebeat/input/journald/pkg/journalfield"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func TestReaderJournalFile(t *testing.T) {
+	path := decompress(t, filepath.Join("..", "..", "testdata", "multiple-boots.journal.gz"))
+
+	entries := readAll(t, path, "", journalfield.IncludeMatches{})
+	require.Len(t, entries, 6)
+
+	entry := entries[2]
+	assert.Equal(t, "s=60a7b51a742a488090839d81fbaa137a;i=3;b=457105b2d84547a4b4549f0eaa700b61;m=35bc29;t=6227ecec5b11f;x=a46eaad8c3930985", entry.Cursor)
+	assert.Equal(t, uint64(0x6227ecec5b11f), entry.RealtimeTimestamp)
+	assert.Equal(t, uint64(0x35bc29), entry.MonotonicTimestamp)
+	assert.Equal(t, "457105b2d84547a4b4549f0eaa700b61", entry.Fields["_BOOT_ID"])
+	assert.Equal(t, "kernel", entry.Fields["_TRANSPORT"])
+
+	t.Run("resume after cursor", func(t *testing.T) {
+		resumed := readAll(t, path, entry.Cursor, journalfield.IncludeMatches{})
+		assert.Equal(t, entries[3:], resumed)
+	})
+}
+
+func TestReaderExport(t *testing.T) {
+	entries := readAll(t, filepath.Join("..", "..", "testdata", "ndjson-parser.export"), "", journalfield.IncludeMatches{})
+	require.Len(t, entries, 1)
+
+	entry := entries[0]
+	assert.Equal(t, "s=51026f4bac4a4e5ea350d8364b72335a;i=554d;b=e2fca45429e54522bb2927112eb8e0b5;m=1c0674fa38;t=624c61632bbe9;x=1232dd1fdebaa692", entry.Cursor)
+	assert.Equal(t, uint64(1729283054812137), entry.RealtimeTimestamp)
+	assert.Equal(t, `{"foo": "bar", "answer":42}`, entry.Fields["MESSAGE"])
+	assert.Equal(t, "unconfined\n", entry.Fields["_SELINUX_CONTEXT"], "fields serialized as binary")
+}
+
+func TestExportReader(t *testing.T) {
+	stream := "__CURSOR=s=1;i=1;b=2;m=3;t=4;x=5\n" +
+		"__REALTIME_TIMESTAMP=4\n" +
+		"__MONOTONIC_TIMESTAMP=3\n" +
+		"MESSAGE=first\n" +
+		"TAG=a\n" +
+		"TAG=b\n" +
+		"\n" +
+		"__CURSOR=s=1;i=2;b=2;m=6;t=7;x=8\n" +
+		"__REALTIME_TIMESTAMP=7\n" +
+		"__MONOTONIC_TIMESTAMP=6\n" +
+		"MESSAGE\n\x06\x00\x00\x00\x00\x00\x00\x00two\nxx\n"
+
+	r := newExportReader(strings.NewReader(stream))
+
+	entry, err := r.next()
+	require.NoError(t, err)
+	assert.Equal(t, "s=1;i=1;b=2;m=3;t=4;x=5", entry.Cursor)
+	assert.Equal(t, "first", entry.Fields["MESSAGE"])
+	assert.Equal(t, []any{"a", "b"}, entry.Fields["TAG"])
+
+	// The last entry does not need to be terminated by an empty line.
+	entry, err = r.next()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), entry.RealtimeTimestamp)
+	assert.Equal(t, "two\nxx", entry.Fields["MESSAGE"])
+
+	_, err = r.next()
+	assert.ErrorIs(t, err, io.EOF)
+
+	r = newExportReader(strings.NewReader("MESSAGE\n\x06\x00\x00\x00\x00\x00\x00\x00tw"))
+	_, err = r.next()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestCursor(t *testing.T) {
+	s := "s=60a7b51a742a488090839d81fbaa137a;i=3;b=457105b2d84547a4b4549f0eaa700b61;m=35bc29;t=6227ecec5b11f;x=a46eaad8c3930985"
+	c, err := ParseCursor(s)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), c.Seqnum)
+	assert.Equal(t, s, c.String())
+
+	next := c
+	next.Seqnum++
+	next.Realtime = 0
+	assert.True(t, next.after(c), "sequence numbers are compared within the same sequence")
+	next.SeqnumID = "other"
+	assert.False(t, next.after(c), "the wallclock is compared for other sequences")
+
+	_, err = ParseCursor("invalid")
+	assert.Error(t, err)
+}
+
+func TestFilter(t *testing.T) {
+	fields := map[string]any{
+		"_SYSTEMD_UNIT":     "nginx.service",
+		"SYSLOG_IDENTIFIER": "nginx",
+		"SYSLOG_FACILITY":   "3",
+		"_TRANSPORT":        "stdout",
+		"TAG":               []any{"a", "b"},
+	}
+
+	tests := map[string]struct {
+		units       []string
+		identifiers []string
+		transports  []string
+		matches     []string
+		facilities  []int
+		want        bool
+	}{
+		"no filter":            {want: true},
+		"unit name":            {units: []string{"nginx"}, want: true},
+		"unit glob":            {units: []string{"ngin*"}, want: true},
+		"other unit":           {units: []string{"nginx.socket"}, want: false},
+		"any unit":             {units: []string{"sshd", "nginx.service"}, want: true},
+		"identifier":           {identifiers: []string{"nginx"}, want: true},
+		"facility":             {facilities: []int{1}, want: false},
+		"transport":            {transports: []string{"syslog", "stdout"}, want: true},
+		"same key is OR":       {matches: []string{"TAG=c", "TAG=b"}, want: true},
+		"different key is AND": {matches: []string{"TAG=a", "_TRANSPORT=kernel"}, want: false},
+		"groups are AND":       {units: []string{"nginx"}, identifiers: []string{"sshd"}, want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var matchers journalfield.IncludeMatches
+			for _, m := range tc.matches {
+				matcher, err := journalfield.BuildMatcher(m)
+				require.NoError(t, err)
+				matchers.Matches = append(matchers.Matches, matcher)
+			}
+
+			f := newFilter(tc.units, tc.identifiers, tc.transports, matchers, tc.facilities)
+			assert.Equal(t, tc.want, f.matches(fields))
+		})
+	}
+}
+
+func decompress(t *testing.T, namegz string) string {
+	t.Helper()
+
+	in, err := os.Open(namegz)
+	require.NoError(t, err)
+	defer in.Close()
+
+	gr, err := gzip.NewReader(in)
+	require.NoError(t, err)
+	defer gr.Close()
+
+	out := filepath.Join(t.TempDir(), strings.TrimSuffix(filepath.Base(namegz), ".gz"))
+	dst, err := os.Create(out)
+	require.NoError(t, err)
+	defer dst.Close()
+
+	//nolint:gosec // this is used in tests
+	_, err = io.Copy(dst, gr)
+	require.NoError(t, err)
+
+	return out
+}
+
+func readAll(t *testing.T, path, cursor string, matchers journalfield.IncludeMatches) []journalctl.JournalEntry {
+	t.Helper()
+
+	r, err := New(logp.NewLogger("test"), nil, nil, nil, matchers, nil, journalctl.SeekHead, cursor, 0, path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var entries []journalctl.JournalEntry
+	for {
+		entry, err := r.Next(context.Background())
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+}
@@ -0,0 +1,76 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// pendingMsgs tracks the JetStream messages whose events were published but
+// not acknowledged yet.
+type pendingMsgs struct {
+	log *logp.Logger
+
+	mu   sync.Mutex
+	msgs map[jetstream.Msg]struct{}
+}
+
+func newPendingMsgs(log *logp.Logger) *pendingMsgs {
+	return &pendingMsgs{log: log, msgs: make(map[jetstream.Msg]struct{})}
+}
+
+func (p *pendingMsgs) add(msg jetstream.Msg) {
+	p.mu.Lock()
+	p.msgs[msg] = struct{}{}
+	p.mu.Unlock()
+}
+
+// ack acknowledges a message whose event was acknowledged.
+func (p *pendingMsgs) ack(msg jetstream.Msg) {
+	p.mu.Lock()
+	delete(p.msgs, msg)
+	p.mu.Unlock()
+	if err := msg.Ack(); err != nil {
+		p.log.Warnw("Error acknowledging message", "error", err)
+	}
+}
+
+// nakAll rejects the pending messages so that they are redelivered after
+// delay.
+func (p *pendingMsgs) nakAll(delay time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for msg := range p.msgs {
+		if err := msg.NakWithDelay(delay); err != nil {
+			p.log.Warnw("Error rejecting message", "error", err)
+		}
+		delete(p.msgs, msg)
+	}
+}
+
+// len returns the number of pending messages.
+func (p *pendingMsgs) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.msgs)
+}
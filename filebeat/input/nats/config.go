@@ -0,0 +1,111 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nats
+
+import (
+	"errors"
+	"time"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+type config struct {
+	// Hosts are the URLs of the NATS servers, e.g. "nats://localhost:4222".
+	Hosts []string `config:"hosts" validate:"required"`
+	// Subjects are the core NATS subjects to subscribe to.
+	Subjects []string `config:"subjects"`
+	// QueueGroup is the queue group of the subscriptions, the messages of
+	// a subject are distributed between the members of the group.
+	QueueGroup string `config:"queue_group"`
+	// JetStream binds the input to a durable JetStream consumer instead of
+	// subscribing to subjects.
+	JetStream *jetStreamConfig `config:"jetstream"`
+
+	Name            string            `config:"name"`
+	Username        string            `config:"username"`
+	Password        string            `config:"password"`
+	Token           string            `config:"token"`
+	CredentialsFile string            `config:"credentials_file"`
+	TLS             *tlscommon.Config `config:"ssl"`
+
+	ConnectBackoff time.Duration `config:"connect_backoff" validate:"min=0"`
+	ReconnectWait  time.Duration `config:"reconnect_wait" validate:"min=0"`
+	WaitClose      time.Duration `config:"wait_close" validate:"min=0"`
+}
+
+type jetStreamConfig struct {
+	Stream   string `config:"stream" validate:"required"`
+	Consumer string `config:"consumer" validate:"required"`
+	// BatchSize is the maximum number of messages pulled at once.
+	BatchSize int `config:"batch_size" validate:"min=1"`
+	// NakDelay is the delay after which the messages that could not be
+	// published are redelivered.
+	NakDelay time.Duration `config:"nak_delay" validate:"min=0"`
+}
+
+func defaultConfig() config {
+	return config{
+		Name:           "filebeat",
+		ConnectBackoff: 5 * time.Second,
+		ReconnectWait:  2 * time.Second,
+		WaitClose:      2 * time.Second,
+	}
+}
+
+func defaultJetStreamConfig() jetStreamConfig {
+	return jetStreamConfig{
+		BatchSize: 500,
+		NakDelay:  5 * time.Second,
+	}
+}
+
+// Unpack applies the JetStream defaults, which can not be set in
+// defaultConfig as the JetStream settings are optional.
+func (c *jetStreamConfig) Unpack(from *conf.C) error {
+	type plain jetStreamConfig
+	p := plain(defaultJetStreamConfig())
+	if err := from.Unpack(&p); err != nil {
+		return err
+	}
+	*c = jetStreamConfig(p)
+	return nil
+}
+
+func (c *config) Validate() error {
+	if c.JetStream == nil && len(c.Subjects) == 0 {
+		return errors.New("either subjects or jetstream must be configured")
+	}
+	if c.JetStream != nil && (len(c.Subjects) != 0 || c.QueueGroup != "") {
+		return errors.New("subjects and queue_group can not be used with jetstream")
+	}
+	auth := 0
+	if c.Username != "" || c.Password != "" {
+		auth++
+	}
+	if c.Token != "" {
+		auth++
+	}
+	if c.CredentialsFile != "" {
+		auth++
+	}
+	if auth > 1 {
+		return errors.New("only one of username and password, token or credentials_file can be set")
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/beats/v7/libbeat/common/backoff"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+	"github.com/elastic/go-concert/ctxtool"
+)
+
+const inputName = "nats"
+
+func Plugin() input.Plugin {
+	return input.Plugin{
+		Name:       inputName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "NATS input",
+		Doc:        "The NATS input subscribes to NATS subjects or consumes from a JetStream consumer",
+		Manager:    input.ConfigureWith(configure),
+	}
+}
+
+func configure(cfg *conf.C) (input.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+	in := &natsInput{config: config}
+	if config.TLS != nil {
+		tlsConfig, err := tlscommon.LoadTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		in.tlsConfig = tlsConfig
+	}
+	return in, nil
+}
+
+type natsInput struct {
+	config    config
+	tlsConfig *tlscommon.TLSConfig
+}
+
+func (in *natsInput) Name() string { return inputName }
+
+func (in *natsInput) Test(_ input.TestContext) error {
+	nc, err := nats.Connect(in.url(), in.options(nil)...)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+	if js := in.config.JetStream; js != nil {
+		s, err := jetstream.New(nc)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err = s.Consumer(ctx, js.Stream, js.Consumer)
+		return err
+	}
+	return nil
+}
+
+func (in *natsInput) Run(ctx input.Context, pipeline beat.Pipeline) error {
+	log := ctx.Logger.With("hosts", in.config.Hosts)
+
+	log.Info("Starting NATS input")
+	defer log.Info("NATS input stopped")
+
+	opts := append(in.options(log), nats.RetryOnFailedConnect(true), nats.MaxReconnects(-1))
+	nc, err := nats.Connect(in.url(), opts...)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	pending := newPendingMsgs(log)
+	client, err := pipeline.ConnectWith(beat.ClientConfig{
+		EventListener: acker.ConnectionOnly(
+			acker.EventPrivateReporter(func(_ int, privates []interface{}) {
+				for _, p := range privates {
+					if msg, ok := p.(jetstream.Msg); ok {
+						pending.ack(msg)
+					}
+				}
+			}),
+		),
+		WaitClose: in.config.WaitClose,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		client.Close()
+		// Redeliver the messages that were not acknowledged by the
+		// outputs before the pipeline client was closed.
+		if js := in.config.JetStream; js != nil {
+			pending.nakAll(js.NakDelay)
+			if err := nc.FlushTimeout(in.config.WaitClose); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+				log.Warnw("Error flushing message rejections", "error", err)
+			}
+		}
+	}()
+
+	goContext := ctxtool.FromCanceller(ctx.Cancelation)
+	if in.config.JetStream != nil {
+		err = in.runJetStream(goContext, log, nc, client, pending)
+	} else {
+		err = in.runCore(goContext, log, nc, client)
+	}
+	if goContext.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// runCore publishes the messages of the subject subscriptions.
+func (in *natsInput) runCore(ctx context.Context, log *logp.Logger, nc *nats.Conn, client beat.Client) error {
+	msgs := make(chan *nats.Msg, nats.DefaultSubPendingMsgsLimit)
+	for _, subject := range in.config.Subjects {
+		var (
+			sub *nats.Subscription
+			err error
+		)
+		if in.config.QueueGroup != "" {
+			sub, err = nc.ChanQueueSubscribe(subject, in.config.QueueGroup, msgs)
+		} else {
+			sub, err = nc.ChanSubscribe(subject, msgs)
+		}
+		if err != nil {
+			return fmt.Errorf("subscribing to %s: %w", subject, err)
+		}
+		defer func() {
+			if err := sub.Unsubscribe(); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+				log.Warnw("Error unsubscribing", "subject", subject, "error", err)
+			}
+		}()
+	}
+	log.Infow("Subscribed to NATS subjects", "subjects", in.config.Subjects, "queue_group", in.config.QueueGroup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-msgs:
+			client.Publish(newEvent(msg.Subject, msg.Data, msg.Header, time.Now()))
+		}
+	}
+}
+
+// runJetStream publishes the messages of the durable JetStream consumer. The
+// messages are acknowledged once their events are acknowledged.
+func (in *natsInput) runJetStream(ctx context.Context, log *logp.Logger, nc *nats.Conn, client beat.Client, pending *pendingMsgs) error {
+	cfg := in.config.JetStream
+	log = log.With("stream", cfg.Stream, "consumer", cfg.Consumer)
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return err
+	}
+
+	// The consumer is looked up until it exists and the servers can be
+	// reached, using exponential backoff with jitter.
+	connectDelay := backoff.NewEqualJitterBackoff(ctx.Done(), in.config.ConnectBackoff, 8*in.config.ConnectBackoff)
+	var consumer jetstream.Consumer
+	for {
+		consumer, err = js.Consumer(ctx, cfg.Stream, cfg.Consumer)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Errorw("Error binding to JetStream consumer", "error", err)
+		connectDelay.Wait()
+	}
+
+	iter, err := consumer.Messages(jetstream.PullMaxMessages(cfg.BatchSize))
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, iter.Stop)
+	defer stop()
+	log.Info("Consuming from JetStream consumer")
+
+	for {
+		msg, err := iter.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err != nil {
+			log.Warnw("Error pulling JetStream messages", "error", err)
+			continue
+		}
+
+		meta, err := msg.Metadata()
+		if err != nil {
+			log.Warnw("Invalid JetStream message metadata", "error", err)
+			if err := msg.NakWithDelay(cfg.NakDelay); err != nil {
+				log.Warnw("Error rejecting message", "error", err)
+			}
+			continue
+		}
+		evt := newEvent(msg.Subject(), msg.Data(), msg.Headers(), meta.Timestamp)
+		evt.Fields.Put("nats.jetstream", mapstr.M{
+			"stream":   meta.Stream,
+			"consumer": meta.Consumer,
+			"sequence": mapstr.M{
+				"stream":   meta.Sequence.Stream,
+				"consumer": meta.Sequence.Consumer,
+			},
+			"num_delivered": meta.NumDelivered,
+			"num_pending":   meta.NumPending,
+		})
+		evt.Private = msg
+		pending.add(msg)
+		client.Publish(evt)
+	}
+}
+
+// newEvent returns the event of a message, headers are stored under
+// nats.headers.
+func newEvent(subject string, data []byte, header nats.Header, ts time.Time) beat.Event {
+	fields := mapstr.M{
+		"message": string(data),
+		"nats": mapstr.M{
+			"subject": subject,
+		},
+	}
+	if len(header) != 0 {
+		headers := make(mapstr.M, len(header))
+		for k, v := range header {
+			if len(v) == 1 {
+				headers[k] = v[0]
+			} else {
+				headers[k] = v
+			}
+		}
+		fields.Put("nats.headers", headers)
+	}
+	return beat.Event{
+		Timestamp: ts,
+		Fields:    fields,
+	}
+}
+
+func (in *natsInput) url() string {
+	return strings.Join(in.config.Hosts, ",")
+}
+
+// options returns the connection options, the connection events are logged
+// when log is set.
+func (in *natsInput) options(log *logp.Logger) []nats.Option {
+	c := in.config
+	opts := []nats.Option{
+		nats.Name(c.Name),
+		nats.ReconnectWait(c.ReconnectWait),
+	}
+	switch {
+	case c.Username != "" || c.Password != "":
+		opts = append(opts, nats.UserInfo(c.Username, c.Password))
+	case c.Token != "":
+		opts = append(opts, nats.Token(c.Token))
+	case c.CredentialsFile != "":
+		opts = append(opts, nats.UserCredentials(c.CredentialsFile))
+	}
+	if in.tlsConfig != nil {
+		opts = append(opts, nats.Secure(in.tlsConfig.BuildModuleClientConfig(hostname(c.Hosts[0]))))
+	}
+	if log != nil {
+		opts = append(opts,
+			nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+				if err != nil {
+					log.Warnw("Disconnected from NATS", "error", err)
+				}
+			}),
+			nats.ReconnectHandler(func(nc *nats.Conn) {
+				log.Infow("Reconnected to NATS", "url", nc.ConnectedUrlRedacted())
+			}),
+			nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+				if sub != nil {
+					log.Errorw("NATS subscription error", "subject", sub.Subject, "error", err)
+					return
+				}
+				log.Errorw("NATS error", "error", err)
+			}),
+		)
+	}
+	return opts
+}
+
+// hostname returns the host name of a server URL, used to verify the server
+// certificate.
+func hostname(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "nats://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
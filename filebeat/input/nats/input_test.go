@@ -0,0 +1,301 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package nats
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	pubtest "github.com/elastic/beats/v7/libbeat/publisher/testing"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:   "subjects",
+			config: map[string]interface{}{"hosts": []string{"localhost"}, "subjects": []string{"logs.>"}, "queue_group": "filebeat"},
+		},
+		{
+			name:   "jetstream",
+			config: map[string]interface{}{"hosts": []string{"localhost"}, "jetstream": map[string]interface{}{"stream": "LOGS", "consumer": "filebeat"}},
+		},
+		{
+			name:    "nothing to consume",
+			config:  map[string]interface{}{"hosts": []string{"localhost"}},
+			wantErr: "either subjects or jetstream must be configured",
+		},
+		{
+			name:    "subjects and jetstream",
+			config:  map[string]interface{}{"hosts": []string{"localhost"}, "subjects": []string{"logs"}, "jetstream": map[string]interface{}{"stream": "LOGS", "consumer": "filebeat"}},
+			wantErr: "subjects and queue_group can not be used with jetstream",
+		},
+		{
+			name:    "jetstream without consumer",
+			config:  map[string]interface{}{"hosts": []string{"localhost"}, "jetstream": map[string]interface{}{"stream": "LOGS"}},
+			wantErr: "string value is not set accessing 'jetstream.consumer'",
+		},
+		{
+			name:    "several authentication methods",
+			config:  map[string]interface{}{"hosts": []string{"localhost"}, "subjects": []string{"logs"}, "token": "secret", "username": "user"},
+			wantErr: "only one of username and password, token or credentials_file can be set",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := defaultConfig()
+			err := conf.MustNewConfigFrom(tc.config).Unpack(&c)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	c := defaultConfig()
+	require.NoError(t, conf.MustNewConfigFrom(testCases[1].config).Unpack(&c))
+	assert.Equal(t, &jetStreamConfig{Stream: "LOGS", Consumer: "filebeat", BatchSize: 500, NakDelay: 5 * time.Second}, c.JetStream)
+}
+
+func TestNewEvent(t *testing.T) {
+	ts := time.Now()
+	evt := newEvent("logs.app", []byte("hello"), nats.Header{"Trace-Id": {"abc"}, "Tag": {"a", "b"}}, ts)
+	assert.Equal(t, beat.Event{
+		Timestamp: ts,
+		Fields: mapstr.M{
+			"message": "hello",
+			"nats": mapstr.M{
+				"subject": "logs.app",
+				"headers": mapstr.M{"Trace-Id": "abc", "Tag": []string{"a", "b"}},
+			},
+		},
+	}, evt)
+}
+
+func runTestServer(t *testing.T) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+	go s.Start()
+	if !s.ReadyForConnections(10 * time.Second) {
+		t.Fatal("NATS server is not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+// testPipeline records the published events, and acknowledges them on
+// request.
+type testPipeline struct {
+	mu       sync.Mutex
+	listener beat.EventListener
+	events   chan beat.Event
+}
+
+func newTestPipeline() *testPipeline {
+	return &testPipeline{events: make(chan beat.Event, 100)}
+}
+
+func (p *testPipeline) connector() beat.PipelineConnector {
+	return pubtest.FakeConnector{
+		ConnectFunc: func(cfg beat.ClientConfig) (beat.Client, error) {
+			p.mu.Lock()
+			p.listener = cfg.EventListener
+			p.mu.Unlock()
+			return &pubtest.FakeClient{
+				PublishFunc: func(evt beat.Event) {
+					p.mu.Lock()
+					p.listener.AddEvent(evt, true)
+					p.mu.Unlock()
+					p.events <- evt
+				},
+				CloseFunc: func() error {
+					p.mu.Lock()
+					p.listener.ClientClosed()
+					p.mu.Unlock()
+					return nil
+				},
+			}, nil
+		},
+	}
+}
+
+func (p *testPipeline) ack(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listener.ACKEvents(n)
+}
+
+func (p *testPipeline) next(t *testing.T) beat.Event {
+	t.Helper()
+	select {
+	case evt := <-p.events:
+		return evt
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for event")
+		return beat.Event{}
+	}
+}
+
+// runInput runs the input configured by cfg until the returned function is
+// called.
+func runInput(t *testing.T, cfg map[string]interface{}, pipeline *testPipeline) (stop func()) {
+	t.Helper()
+	in, err := configure(conf.MustNewConfigFrom(cfg))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- in.Run(v2.Context{
+			ID:          "nats-test",
+			Logger:      logp.NewLogger(inputName),
+			Cancelation: ctx,
+		}, pipeline.connector())
+	}()
+	return func() {
+		cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for the input to stop")
+		}
+	}
+}
+
+func TestCoreSubjects(t *testing.T) {
+	s := runTestServer(t)
+	pipeline := newTestPipeline()
+	stop := runInput(t, map[string]interface{}{
+		"hosts":       []string{s.ClientURL()},
+		"subjects":    []string{"logs.>"},
+		"queue_group": "filebeat",
+	}, pipeline)
+	defer stop()
+
+	nc, err := nats.Connect(s.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	// Publish until the input subscribed, core NATS messages without
+	// subscribers are dropped.
+	msg := &nats.Msg{Subject: "logs.app", Data: []byte("hello"), Header: nats.Header{"Trace-Id": {"abc"}}}
+	var evt beat.Event
+	require.Eventually(t, func() bool {
+		require.NoError(t, nc.PublishMsg(msg))
+		select {
+		case evt = <-pipeline.events:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, time.Millisecond)
+
+	assert.Equal(t, mapstr.M{
+		"message": "hello",
+		"nats": mapstr.M{
+			"subject": "logs.app",
+			"headers": mapstr.M{"Trace-Id": "abc"},
+		},
+	}, evt.Fields)
+}
+
+func TestJetStream(t *testing.T) {
+	s := runTestServer(t)
+
+	nc, err := nats.Connect(s.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = js.CreateStream(ctx, jetstream.StreamConfig{Name: "LOGS", Subjects: []string{"logs.>"}})
+	require.NoError(t, err)
+	consumer, err := js.CreateConsumer(ctx, "LOGS", jetstream.ConsumerConfig{Durable: "filebeat", AckPolicy: jetstream.AckExplicitPolicy})
+	require.NoError(t, err)
+	for _, m := range []string{"one", "two", "three"} {
+		_, err = js.PublishMsg(ctx, &nats.Msg{Subject: "logs.app", Data: []byte(m), Header: nats.Header{"Nats-Msg-Id": {m}}})
+		require.NoError(t, err)
+	}
+
+	cfg := map[string]interface{}{
+		"hosts": []string{s.ClientURL()},
+		"jetstream": map[string]interface{}{
+			"stream":    "LOGS",
+			"consumer":  "filebeat",
+			"nak_delay": "100ms",
+		},
+		"wait_close": "1s",
+	}
+	pipeline := newTestPipeline()
+	stop := runInput(t, cfg, pipeline)
+
+	for i, want := range []string{"one", "two", "three"} {
+		evt := pipeline.next(t)
+		assert.Equal(t, want, evt.Fields["message"])
+		seq, _ := evt.GetValue("nats.jetstream.sequence.stream")
+		assert.EqualValues(t, i+1, seq)
+		id, _ := evt.GetValue("nats.headers.Nats-Msg-Id")
+		assert.Equal(t, want, id)
+	}
+
+	// Only the acknowledged events are acknowledged to the server.
+	info, err := consumer.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, info.NumAckPending)
+	pipeline.ack(2)
+	require.Eventually(t, func() bool {
+		info, err := consumer.Info(ctx)
+		return err == nil && info.NumAckPending == 1
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// The pending message is rejected on shutdown and redelivered.
+	stop()
+	pipeline = newTestPipeline()
+	stop = runInput(t, cfg, pipeline)
+	defer stop()
+	evt := pipeline.next(t)
+	assert.Equal(t, "three", evt.Fields["message"])
+	delivered, _ := evt.GetValue("nats.jetstream.num_delivered")
+	assert.EqualValues(t, 2, delivered)
+}
@@ -89,12 +89,18 @@ func NewTCP(inputName string, id string, device string, poll time.Duration, log
 
 // Log logs metric for the given packet.
 func (m *TCP) Log(data []byte, timestamp time.Time) {
+	m.LogSize(len(data), timestamp)
+}
+
+// LogSize logs metric for a packet of the given size, for inputs that do not
+// keep the raw packet.
+func (m *TCP) LogSize(size int, timestamp time.Time) {
 	if m == nil {
 		return
 	}
 	m.processingTime.Update(time.Since(timestamp).Nanoseconds())
 	m.packets.Add(1)
-	m.bytes.Add(uint64(len(data)))
+	m.bytes.Add(uint64(size))
 	if !m.lastPacket.IsZero() {
 		m.arrivalPeriod.Update(timestamp.Sub(m.lastPacket).Nanoseconds())
 	}
@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+const (
+	signalLogs    = "logs"
+	signalTraces  = "traces"
+	signalMetrics = "metrics"
+)
+
+type config struct {
+	GRPC listenerConfig `config:"grpc"`
+	HTTP listenerConfig `config:"http"`
+
+	TLS *tlscommon.ServerConfig `config:"ssl"`
+	// MaxMessageSize limits the size of export requests, after
+	// decompression.
+	MaxMessageSize cfgtype.ByteSize `config:"max_message_size" validate:"nonzero,positive"`
+	// Signals lists the signals accepted, logs are always converted to
+	// events, traces and metrics only when enabled.
+	Signals []string `config:"signals"`
+}
+
+type listenerConfig struct {
+	Enabled bool   `config:"enabled"`
+	Host    string `config:"host"`
+}
+
+func defaultConfig() config {
+	return config{
+		GRPC: listenerConfig{
+			Enabled: true,
+			Host:    "localhost:4317",
+		},
+		HTTP: listenerConfig{
+			Enabled: true,
+			Host:    "localhost:4318",
+		},
+		MaxMessageSize: 20 * humanize.MiByte,
+		Signals:        []string{signalLogs},
+	}
+}
+
+func (c *config) Validate() error {
+	if !c.GRPC.Enabled && !c.HTTP.Enabled {
+		return errors.New("at least one of grpc or http must be enabled")
+	}
+	if c.GRPC.Enabled && c.GRPC.Host == "" {
+		return errors.New("grpc.host must be set")
+	}
+	if c.HTTP.Enabled && c.HTTP.Host == "" {
+		return errors.New("http.host must be set")
+	}
+	if len(c.Signals) == 0 {
+		return errors.New("at least one signal must be enabled")
+	}
+	for _, s := range c.Signals {
+		switch s {
+		case signalLogs, signalTraces, signalMetrics:
+		default:
+			return fmt.Errorf("unknown signal %q, must be one of %s, %s or %s", s, signalLogs, signalTraces, signalMetrics)
+		}
+	}
+	return nil
+}
+
+func (c *config) signalEnabled(signal string) bool {
+	for _, s := range c.Signals {
+		if s == signal {
+			return true
+		}
+	}
+	return false
+}
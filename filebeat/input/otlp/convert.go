@@ -0,0 +1,339 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// logsToEvents converts log records to events. The resource and scope
+// of the records are kept under otlp.resource and otlp.scope.
+func logsToEvents(ld plog.Logs, now time.Time) []beat.Event {
+	events := make([]beat.Event, 0, ld.LogRecordCount())
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		rl := rls.At(i)
+		sls := rl.ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			sl := sls.At(j)
+			lrs := sl.LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				lr := lrs.At(k)
+
+				evt, otlp := newEvent(rl.Resource(), sl.Scope())
+				evt.Timestamp = timestamp(now, lr.Timestamp(), lr.ObservedTimestamp())
+
+				body := lr.Body()
+				switch body.Type() {
+				case pcommon.ValueTypeEmpty:
+				case pcommon.ValueTypeStr:
+					evt.Fields["message"] = body.Str()
+				default:
+					otlp["body"] = body.AsRaw()
+				}
+				if lr.SeverityText() != "" {
+					evt.Fields.Put("log.level", lr.SeverityText())
+				}
+				if lr.SeverityNumber() != plog.SeverityNumberUnspecified {
+					evt.Fields.Put("event.severity", int(lr.SeverityNumber()))
+				}
+				putIDs(evt.Fields, lr.TraceID(), lr.SpanID())
+				putAttributes(otlp, lr.Attributes())
+				if lr.Flags() != 0 {
+					otlp["flags"] = uint32(lr.Flags())
+				}
+
+				events = append(events, evt)
+			}
+		}
+	}
+	return events
+}
+
+// tracesToEvents converts spans to events, with the span details under
+// otlp.span.
+func tracesToEvents(td ptrace.Traces, now time.Time) []beat.Event {
+	events := make([]beat.Event, 0, td.SpanCount())
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		sss := rs.ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			ss := sss.At(j)
+			spans := ss.Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+
+				evt, otlp := newEvent(rs.Resource(), ss.Scope())
+				evt.Timestamp = timestamp(now, span.StartTimestamp())
+				putIDs(evt.Fields, span.TraceID(), span.SpanID())
+				if end := span.EndTimestamp(); end >= span.StartTimestamp() && span.StartTimestamp() != 0 {
+					evt.Fields.Put("event.duration", int64(end-span.StartTimestamp()))
+				}
+
+				s := mapstr.M{
+					"name": span.Name(),
+					"kind": span.Kind().String(),
+					"status": mapstr.M{
+						"code": span.Status().Code().String(),
+					},
+				}
+				if msg := span.Status().Message(); msg != "" {
+					s.Put("status.message", msg)
+				}
+				if !span.ParentSpanID().IsEmpty() {
+					s["parent_span_id"] = span.ParentSpanID().String()
+				}
+				if ts := span.TraceState().AsRaw(); ts != "" {
+					s["trace_state"] = ts
+				}
+				putAttributes(s, span.Attributes())
+
+				if span.Events().Len() != 0 {
+					spanEvents := make([]mapstr.M, 0, span.Events().Len())
+					for l := 0; l < span.Events().Len(); l++ {
+						e := span.Events().At(l)
+						m := mapstr.M{
+							"name":      e.Name(),
+							"timestamp": e.Timestamp().AsTime(),
+						}
+						putAttributes(m, e.Attributes())
+						spanEvents = append(spanEvents, m)
+					}
+					s["events"] = spanEvents
+				}
+				if span.Links().Len() != 0 {
+					links := make([]mapstr.M, 0, span.Links().Len())
+					for l := 0; l < span.Links().Len(); l++ {
+						link := span.Links().At(l)
+						m := mapstr.M{
+							"trace_id": link.TraceID().String(),
+							"span_id":  link.SpanID().String(),
+						}
+						putAttributes(m, link.Attributes())
+						links = append(links, m)
+					}
+					s["links"] = links
+				}
+				otlp["span"] = s
+
+				events = append(events, evt)
+			}
+		}
+	}
+	return events
+}
+
+// metricsToEvents converts metric data points to events, with the metric
+// and the values of the data point under otlp.metric.
+func metricsToEvents(md pmetric.Metrics, now time.Time) []beat.Event {
+	events := make([]beat.Event, 0, md.DataPointCount())
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		sms := rm.ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			sm := sms.At(j)
+			metrics := sm.Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+
+				add := func(ts pcommon.Timestamp, attrs pcommon.Map, values mapstr.M) {
+					evt, otlp := newEvent(rm.Resource(), sm.Scope())
+					evt.Timestamp = timestamp(now, ts)
+
+					m := mapstr.M{
+						"name": metric.Name(),
+						"type": metric.Type().String(),
+					}
+					if metric.Description() != "" {
+						m["description"] = metric.Description()
+					}
+					if metric.Unit() != "" {
+						m["unit"] = metric.Unit()
+					}
+					putAttributes(m, attrs)
+					m.Update(values)
+					otlp["metric"] = m
+
+					events = append(events, evt)
+				}
+
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					dps := metric.Gauge().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						add(dp.Timestamp(), dp.Attributes(), mapstr.M{"value": numberValue(dp)})
+					}
+				case pmetric.MetricTypeSum:
+					sum := metric.Sum()
+					dps := sum.DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						add(dp.Timestamp(), dp.Attributes(), mapstr.M{
+							"value":                   numberValue(dp),
+							"is_monotonic":            sum.IsMonotonic(),
+							"aggregation_temporality": sum.AggregationTemporality().String(),
+						})
+					}
+				case pmetric.MetricTypeHistogram:
+					hist := metric.Histogram()
+					dps := hist.DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						values := mapstr.M{
+							"count":                   dp.Count(),
+							"bucket_counts":           dp.BucketCounts().AsRaw(),
+							"explicit_bounds":         dp.ExplicitBounds().AsRaw(),
+							"aggregation_temporality": hist.AggregationTemporality().String(),
+						}
+						putOptional(values, "sum", dp.Sum(), dp.HasSum())
+						putOptional(values, "min", dp.Min(), dp.HasMin())
+						putOptional(values, "max", dp.Max(), dp.HasMax())
+						add(dp.Timestamp(), dp.Attributes(), values)
+					}
+				case pmetric.MetricTypeExponentialHistogram:
+					hist := metric.ExponentialHistogram()
+					dps := hist.DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						values := mapstr.M{
+							"count":      dp.Count(),
+							"scale":      dp.Scale(),
+							"zero_count": dp.ZeroCount(),
+							"positive": mapstr.M{
+								"offset":        dp.Positive().Offset(),
+								"bucket_counts": dp.Positive().BucketCounts().AsRaw(),
+							},
+							"negative": mapstr.M{
+								"offset":        dp.Negative().Offset(),
+								"bucket_counts": dp.Negative().BucketCounts().AsRaw(),
+							},
+							"aggregation_temporality": hist.AggregationTemporality().String(),
+						}
+						putOptional(values, "sum", dp.Sum(), dp.HasSum())
+						putOptional(values, "min", dp.Min(), dp.HasMin())
+						putOptional(values, "max", dp.Max(), dp.HasMax())
+						add(dp.Timestamp(), dp.Attributes(), values)
+					}
+				case pmetric.MetricTypeSummary:
+					dps := metric.Summary().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						quantiles := make([]mapstr.M, 0, dp.QuantileValues().Len())
+						for q := 0; q < dp.QuantileValues().Len(); q++ {
+							qv := dp.QuantileValues().At(q)
+							quantiles = append(quantiles, mapstr.M{
+								"quantile": qv.Quantile(),
+								"value":    qv.Value(),
+							})
+						}
+						add(dp.Timestamp(), dp.Attributes(), mapstr.M{
+							"count":     dp.Count(),
+							"sum":       dp.Sum(),
+							"quantiles": quantiles,
+						})
+					}
+				}
+			}
+		}
+	}
+	return events
+}
+
+// newEvent returns an event with the resource and scope fields set, and
+// the otlp object of the event.
+func newEvent(res pcommon.Resource, scope pcommon.InstrumentationScope) (beat.Event, mapstr.M) {
+	otlp := mapstr.M{}
+	if res.Attributes().Len() != 0 {
+		resource := mapstr.M{}
+		putAttributes(resource, res.Attributes())
+		otlp["resource"] = resource
+	}
+
+	s := mapstr.M{}
+	if scope.Name() != "" {
+		s["name"] = scope.Name()
+	}
+	if scope.Version() != "" {
+		s["version"] = scope.Version()
+	}
+	putAttributes(s, scope.Attributes())
+	if len(s) != 0 {
+		otlp["scope"] = s
+	}
+
+	evt := beat.Event{
+		Fields: mapstr.M{
+			"otlp": otlp,
+		},
+	}
+	if name, ok := res.Attributes().Get("service.name"); ok {
+		evt.Fields.Put("service.name", name.AsString())
+	}
+	return evt, otlp
+}
+
+// putAttributes adds the attributes to m if there are any. Attribute
+// names are kept as is, including their dots.
+func putAttributes(m mapstr.M, attrs pcommon.Map) {
+	if attrs.Len() != 0 {
+		m["attributes"] = attrs.AsRaw()
+	}
+}
+
+func putOptional(m mapstr.M, key string, v float64, ok bool) {
+	if ok {
+		m[key] = v
+	}
+}
+
+func putIDs(fields mapstr.M, traceID pcommon.TraceID, spanID pcommon.SpanID) {
+	if !traceID.IsEmpty() {
+		fields.Put("trace.id", traceID.String())
+	}
+	if !spanID.IsEmpty() {
+		fields.Put("span.id", spanID.String())
+	}
+}
+
+func numberValue(dp pmetric.NumberDataPoint) interface{} {
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		return dp.IntValue()
+	}
+	return dp.DoubleValue()
+}
+
+// timestamp returns the first timestamp that is set, or now.
+func timestamp(now time.Time, timestamps ...pcommon.Timestamp) time.Time {
+	for _, ts := range timestamps {
+		if ts != 0 {
+			return ts.AsTime()
+		}
+	}
+	return now
+}
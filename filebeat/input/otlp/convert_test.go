@@ -0,0 +1,209 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var (
+	testTime = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	traceID  = pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	spanID   = pcommon.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+)
+
+func setResourceAndScope(res pcommon.Resource, scope pcommon.InstrumentationScope) {
+	res.Attributes().PutStr("service.name", "checkout")
+	res.Attributes().PutStr("host.name", "web-1")
+	scope.SetName("io.opentelemetry.test")
+	scope.SetVersion("1.0.0")
+	scope.Attributes().PutBool("scope.flag", true)
+}
+
+func wantResourceAndScope() mapstr.M {
+	return mapstr.M{
+		"resource": mapstr.M{
+			"attributes": map[string]interface{}{"service.name": "checkout", "host.name": "web-1"},
+		},
+		"scope": mapstr.M{
+			"name":       "io.opentelemetry.test",
+			"version":    "1.0.0",
+			"attributes": map[string]interface{}{"scope.flag": true},
+		},
+	}
+}
+
+func TestLogsToEvents(t *testing.T) {
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	sl := rl.ScopeLogs().AppendEmpty()
+	setResourceAndScope(rl.Resource(), sl.Scope())
+
+	lr := sl.LogRecords().AppendEmpty()
+	lr.SetTimestamp(pcommon.NewTimestampFromTime(testTime))
+	lr.Body().SetStr("payment failed")
+	lr.SetSeverityText("ERROR")
+	lr.SetSeverityNumber(plog.SeverityNumberError)
+	lr.SetTraceID(traceID)
+	lr.SetSpanID(spanID)
+	lr.Attributes().PutInt("http.status_code", 500)
+
+	structured := sl.LogRecords().AppendEmpty()
+	structured.SetObservedTimestamp(pcommon.NewTimestampFromTime(testTime.Add(time.Second)))
+	structured.Body().SetEmptyMap().PutStr("key", "value")
+
+	now := testTime.Add(time.Hour)
+	events := logsToEvents(ld, now)
+	require.Len(t, events, 2)
+
+	otlp := wantResourceAndScope()
+	otlp["attributes"] = map[string]interface{}{"http.status_code": int64(500)}
+	assert.Equal(t, testTime, events[0].Timestamp)
+	assert.Equal(t, mapstr.M{
+		"message": "payment failed",
+		"log":     mapstr.M{"level": "ERROR"},
+		"event":   mapstr.M{"severity": int(plog.SeverityNumberError)},
+		"trace":   mapstr.M{"id": traceID.String()},
+		"span":    mapstr.M{"id": spanID.String()},
+		"service": mapstr.M{"name": "checkout"},
+		"otlp":    otlp,
+	}, events[0].Fields)
+
+	assert.Equal(t, testTime.Add(time.Second), events[1].Timestamp, "falls back to the observed timestamp")
+	body, err := events[1].Fields.GetValue("otlp.body")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"key": "value"}, body)
+}
+
+func TestTracesToEvents(t *testing.T) {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	ss := rs.ScopeSpans().AppendEmpty()
+	setResourceAndScope(rs.Resource(), ss.Scope())
+
+	span := ss.Spans().AppendEmpty()
+	span.SetName("GET /cart")
+	span.SetKind(ptrace.SpanKindServer)
+	span.SetTraceID(traceID)
+	span.SetSpanID(spanID)
+	span.SetParentSpanID(pcommon.SpanID{8, 7, 6, 5, 4, 3, 2, 1})
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(testTime))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(testTime.Add(250 * time.Millisecond)))
+	span.Status().SetCode(ptrace.StatusCodeError)
+	span.Status().SetMessage("timeout")
+	e := span.Events().AppendEmpty()
+	e.SetName("retry")
+	e.SetTimestamp(pcommon.NewTimestampFromTime(testTime.Add(time.Millisecond)))
+
+	events := tracesToEvents(td, time.Now())
+	require.Len(t, events, 1)
+	assert.Equal(t, testTime, events[0].Timestamp)
+
+	fields := events[0].Fields
+	assert.Equal(t, int64(250*time.Millisecond), mustGet(t, fields, "event.duration"))
+	assert.Equal(t, traceID.String(), mustGet(t, fields, "trace.id"))
+	assert.Equal(t, mapstr.M{
+		"name":           "GET /cart",
+		"kind":           "Server",
+		"status":         mapstr.M{"code": "Error", "message": "timeout"},
+		"parent_span_id": "0807060504030201",
+		"events": []mapstr.M{
+			{"name": "retry", "timestamp": testTime.Add(time.Millisecond)},
+		},
+	}, mustGet(t, fields, "otlp.span"))
+	assert.Equal(t, wantResourceAndScope()["resource"], mustGet(t, fields, "otlp.resource"))
+}
+
+func TestMetricsToEvents(t *testing.T) {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	sm := rm.ScopeMetrics().AppendEmpty()
+	setResourceAndScope(rm.Resource(), sm.Scope())
+
+	gauge := sm.Metrics().AppendEmpty()
+	gauge.SetName("queue.size")
+	gauge.SetUnit("{items}")
+	dp := gauge.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(testTime))
+	dp.SetIntValue(42)
+	dp.Attributes().PutStr("queue", "orders")
+
+	sum := sm.Metrics().AppendEmpty()
+	sum.SetName("requests")
+	s := sum.SetEmptySum()
+	s.SetIsMonotonic(true)
+	s.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	s.DataPoints().AppendEmpty().SetDoubleValue(1.5)
+
+	hist := sm.Metrics().AppendEmpty()
+	hist.SetName("latency")
+	hdp := hist.SetEmptyHistogram().DataPoints().AppendEmpty()
+	hdp.SetCount(3)
+	hdp.SetSum(0.6)
+	hdp.BucketCounts().FromRaw([]uint64{1, 2})
+	hdp.ExplicitBounds().FromRaw([]float64{0.1})
+
+	now := testTime.Add(time.Hour)
+	events := metricsToEvents(md, now)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, testTime, events[0].Timestamp)
+	assert.Equal(t, mapstr.M{
+		"name":       "queue.size",
+		"type":       "Gauge",
+		"unit":       "{items}",
+		"value":      int64(42),
+		"attributes": map[string]interface{}{"queue": "orders"},
+	}, mustGet(t, events[0].Fields, "otlp.metric"))
+
+	assert.Equal(t, now, events[1].Timestamp)
+	assert.Equal(t, mapstr.M{
+		"name":                    "requests",
+		"type":                    "Sum",
+		"value":                   1.5,
+		"is_monotonic":            true,
+		"aggregation_temporality": "Cumulative",
+	}, mustGet(t, events[1].Fields, "otlp.metric"))
+
+	assert.Equal(t, mapstr.M{
+		"name":                    "latency",
+		"type":                    "Histogram",
+		"count":                   uint64(3),
+		"sum":                     0.6,
+		"bucket_counts":           []uint64{1, 2},
+		"explicit_bounds":         []float64{0.1},
+		"aggregation_temporality": "Unspecified",
+	}, mustGet(t, events[2].Fields, "otlp.metric"))
+}
+
+func mustGet(t *testing.T, m mapstr.M, key string) interface{} {
+	t.Helper()
+	v, err := m.GetValue(key)
+	require.NoError(t, err, key)
+	return v
+}
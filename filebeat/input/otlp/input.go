@@ -0,0 +1,199 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // Register the gzip compressor.
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+	"github.com/elastic/go-concert/ctxtool"
+)
+
+const inputName = "otlp"
+
+func Plugin() input.Plugin {
+	return input.Plugin{
+		Name:       inputName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "Receives OpenTelemetry logs, traces and metrics over OTLP/gRPC and OTLP/HTTP.",
+		Manager:    input.ConfigureWith(configure),
+	}
+}
+
+func configure(cfg *conf.C) (input.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+	return &otlpInput{config: config}, nil
+}
+
+// otlpInput implements the Filebeat input V2 interface. The input is stateless.
+type otlpInput struct {
+	config config
+}
+
+func (i *otlpInput) Name() string { return inputName }
+
+func (i *otlpInput) Test(_ input.TestContext) error {
+	for _, l := range []listenerConfig{i.config.GRPC, i.config.HTTP} {
+		if !l.Enabled {
+			continue
+		}
+		l, err := net.Listen("tcp", l.Host)
+		if err != nil {
+			return err
+		}
+		l.Close()
+	}
+	return nil
+}
+
+func (i *otlpInput) Run(ctx input.Context, pipeline beat.Pipeline) error {
+	log := ctx.Logger
+
+	log.Info("Starting " + inputName + " input")
+	defer log.Info(inputName + " input stopped")
+
+	tlsConfig, err := tlscommon.LoadTLSServerConfig(i.config.TLS)
+	if err != nil {
+		return err
+	}
+
+	// Create client for publishing events and receive notification of their ACKs.
+	client, err := pipeline.ConnectWith(beat.ClientConfig{
+		EventListener: acker.BatchTrackerReporter(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline client: %w", err)
+	}
+	defer client.Close()
+
+	r := &receiver{log: log, publish: client.PublishAll}
+
+	// Listen on all addresses before serving, so that no server is left
+	// running when one of them can't listen.
+	var grpcListener, httpListener net.Listener
+	if i.config.GRPC.Enabled {
+		grpcListener, err = net.Listen("tcp", i.config.GRPC.Host)
+		if err != nil {
+			return err
+		}
+	}
+	if i.config.HTTP.Enabled {
+		httpListener, err = net.Listen("tcp", i.config.HTTP.Host)
+		if err != nil {
+			if grpcListener != nil {
+				grpcListener.Close()
+			}
+			return err
+		}
+	}
+
+	g, gctx := errgroup.WithContext(ctxtool.FromCanceller(ctx.Cancelation))
+	if grpcListener != nil {
+		srv := i.grpcServer(r, tlsConfig)
+		log.Infof("Listening for OTLP/gRPC on %s", grpcListener.Addr())
+		g.Go(func() error {
+			<-gctx.Done()
+			// Pending requests are cancelled, clients retry them.
+			srv.Stop()
+			return nil
+		})
+		g.Go(func() error { return srv.Serve(grpcListener) })
+	}
+	if httpListener != nil {
+		l := httpListener
+		if tlsConfig != nil {
+			l = tls.NewListener(l, tlsConfig.BuildServerConfig(i.config.HTTP.Host))
+		}
+		srv := &http.Server{Handler: i.httpMux(r), ReadHeaderTimeout: time.Minute}
+		log.Infof("Listening for OTLP/HTTP on %s", l.Addr())
+		g.Go(func() error {
+			<-gctx.Done()
+			return srv.Close()
+		})
+		g.Go(func() error {
+			err := srv.Serve(l)
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+		})
+	}
+
+	err = g.Wait()
+	// Ignore error from the servers in case shutdown was signaled.
+	if ctxerr := ctx.Cancelation.Err(); ctxerr != nil {
+		err = ctxerr
+	}
+	return err
+}
+
+func (i *otlpInput) grpcServer(r *receiver, tlsConfig *tlscommon.TLSConfig) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(int(i.config.MaxMessageSize)),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig.BuildServerConfig(i.config.GRPC.Host))))
+	}
+
+	// Services of disabled signals are not registered, clients get an
+	// Unimplemented error for them.
+	srv := grpc.NewServer(opts...)
+	if i.config.signalEnabled(signalLogs) {
+		plogotlp.RegisterGRPCServer(srv, &logsServer{receiver: r})
+	}
+	if i.config.signalEnabled(signalTraces) {
+		ptraceotlp.RegisterGRPCServer(srv, &tracesServer{receiver: r})
+	}
+	if i.config.signalEnabled(signalMetrics) {
+		pmetricotlp.RegisterGRPCServer(srv, &metricsServer{receiver: r})
+	}
+	return srv
+}
+
+func (i *otlpInput) httpMux(r *receiver) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, s := range signals {
+		if i.config.signalEnabled(s.name) {
+			mux.Handle(s.path, &httpHandler{receiver: r, signal: s, maxSize: int64(i.config.MaxMessageSize)})
+		}
+	}
+	return mux
+}
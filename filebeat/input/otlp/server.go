@@ -0,0 +1,279 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// receiver publishes the events of export requests.
+type receiver struct {
+	log     *logp.Logger
+	publish func([]beat.Event)
+}
+
+// publishAndWait publishes the events and waits until they have all been
+// acknowledged, so that the export request is only successful once the
+// events are safe.
+func (r *receiver) publishAndWait(ctx context.Context, events []beat.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	done := make(chan struct{})
+	tracker := acker.NewBatchTracker(func() { close(done) })
+	for i := range events {
+		tracker.Add()
+		events[i].Private = tracker
+	}
+	r.publish(events)
+	tracker.Ready()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// signal describes how the export requests of a signal are converted to
+// events.
+type signal struct {
+	name string
+	path string
+	// decode decodes an export request and converts it to events.
+	decode func(data []byte, isJSON bool, now time.Time) ([]beat.Event, error)
+	// response returns the encoded response to a successful export.
+	response func(isJSON bool) ([]byte, error)
+}
+
+type protoMessage interface {
+	UnmarshalProto([]byte) error
+	UnmarshalJSON([]byte) error
+	MarshalProto() ([]byte, error)
+	MarshalJSON() ([]byte, error)
+}
+
+func unmarshal(msg protoMessage, data []byte, isJSON bool) error {
+	if isJSON {
+		return msg.UnmarshalJSON(data)
+	}
+	return msg.UnmarshalProto(data)
+}
+
+func marshal(msg protoMessage, isJSON bool) ([]byte, error) {
+	if isJSON {
+		return msg.MarshalJSON()
+	}
+	return msg.MarshalProto()
+}
+
+var signals = []signal{
+	{
+		name: signalLogs,
+		path: "/v1/logs",
+		decode: func(data []byte, isJSON bool, now time.Time) ([]beat.Event, error) {
+			req := plogotlp.NewExportRequest()
+			if err := unmarshal(req, data, isJSON); err != nil {
+				return nil, err
+			}
+			return logsToEvents(req.Logs(), now), nil
+		},
+		response: func(isJSON bool) ([]byte, error) {
+			return marshal(plogotlp.NewExportResponse(), isJSON)
+		},
+	},
+	{
+		name: signalTraces,
+		path: "/v1/traces",
+		decode: func(data []byte, isJSON bool, now time.Time) ([]beat.Event, error) {
+			req := ptraceotlp.NewExportRequest()
+			if err := unmarshal(req, data, isJSON); err != nil {
+				return nil, err
+			}
+			return tracesToEvents(req.Traces(), now), nil
+		},
+		response: func(isJSON bool) ([]byte, error) {
+			return marshal(ptraceotlp.NewExportResponse(), isJSON)
+		},
+	},
+	{
+		name: signalMetrics,
+		path: "/v1/metrics",
+		decode: func(data []byte, isJSON bool, now time.Time) ([]beat.Event, error) {
+			req := pmetricotlp.NewExportRequest()
+			if err := unmarshal(req, data, isJSON); err != nil {
+				return nil, err
+			}
+			return metricsToEvents(req.Metrics(), now), nil
+		},
+		response: func(isJSON bool) ([]byte, error) {
+			return marshal(pmetricotlp.NewExportResponse(), isJSON)
+		},
+	},
+}
+
+// httpHandler serves OTLP/HTTP export requests of a signal, encoded in
+// protobuf or JSON.
+type httpHandler struct {
+	receiver *receiver
+	signal   signal
+	maxSize  int64
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var isJSON bool
+	switch contentType {
+	case contentTypeProtobuf:
+	case contentTypeJSON:
+		isJSON = true
+	default:
+		http.Error(w, fmt.Sprintf("unsupported content type %q", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := h.readBody(w, r)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) || errors.Is(err, errRequestTooLarge) {
+			http.Error(w, "request exceeds max_message_size", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.signal.decode(data, isJSON, time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid %s export request: %v", h.signal.name, err), http.StatusBadRequest)
+		return
+	}
+	if err := h.receiver.publishAndWait(r.Context(), events); err != nil {
+		http.Error(w, fmt.Sprintf("events were not acknowledged: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	resp, err := h.signal.response(isJSON)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp)
+}
+
+var errRequestTooLarge = errors.New("request too large")
+
+// readBody reads the body of a request, decompressing it if it is gzip
+// encoded.
+func (h *httpHandler) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	var body io.Reader = http.MaxBytesReader(w, r.Body, h.maxSize)
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", r.Header.Get("Content-Encoding"))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, h.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > h.maxSize {
+		return nil, errRequestTooLarge
+	}
+	return data, nil
+}
+
+// The gRPC servers receive the decoded export requests.
+
+type logsServer struct {
+	plogotlp.UnimplementedGRPCServer
+	receiver *receiver
+}
+
+func (s *logsServer) Export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	err := s.receiver.publishAndWait(ctx, logsToEvents(req.Logs(), time.Now()))
+	return plogotlp.NewExportResponse(), grpcError(err)
+}
+
+type tracesServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+	receiver *receiver
+}
+
+func (s *tracesServer) Export(ctx context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	err := s.receiver.publishAndWait(ctx, tracesToEvents(req.Traces(), time.Now()))
+	return ptraceotlp.NewExportResponse(), grpcError(err)
+}
+
+type metricsServer struct {
+	pmetricotlp.UnimplementedGRPCServer
+	receiver *receiver
+}
+
+func (s *metricsServer) Export(ctx context.Context, req pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	err := s.receiver.publishAndWait(ctx, metricsToEvents(req.Metrics(), time.Now()))
+	return pmetricotlp.NewExportResponse(), grpcError(err)
+}
+
+// grpcError returns an error the client can retry on when the events
+// were not acknowledged.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return status.Errorf(codes.Unavailable, "events were not acknowledged: %v", err)
+}
@@ -0,0 +1,151 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// testReceiver returns a receiver and the channel of the events it
+// publishes. Events are only acknowledged by calling ack.
+func testReceiver() (*receiver, chan []beat.Event) {
+	published := make(chan []beat.Event, 10)
+	return &receiver{
+		log:     logp.NewLogger("test"),
+		publish: func(events []beat.Event) { published <- events },
+	}, published
+}
+
+func ack(events []beat.Event) {
+	for _, e := range events {
+		e.Private.(*acker.BatchTracker).ACK()
+	}
+}
+
+func testLogsRequest() plogotlp.ExportRequest {
+	ld := plog.NewLogs()
+	lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	lrs.AppendEmpty().Body().SetStr("first")
+	lrs.AppendEmpty().Body().SetStr("second")
+	return plogotlp.NewExportRequestFromLogs(ld)
+}
+
+func TestGRPCExport(t *testing.T) {
+	r, published := testReceiver()
+	in := &otlpInput{config: defaultConfig()}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := in.grpcServer(r, nil)
+	go srv.Serve(l) //nolint:errcheck // Stopped by the test.
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := plogotlp.NewGRPCClient(conn).Export(context.Background(), testLogsRequest())
+		done <- err
+	}()
+
+	events := <-published
+	require.Len(t, events, 2)
+	assert.Equal(t, "first", events[0].Fields["message"])
+	select {
+	case err := <-done:
+		t.Fatalf("export returned before events were acknowledged: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ack(events)
+	require.NoError(t, <-done)
+
+	// Traces are not enabled by default.
+	_, err = ptraceotlp.NewGRPCClient(conn).Export(context.Background(), ptraceotlp.NewExportRequest())
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestHTTPExport(t *testing.T) {
+	r, published := testReceiver()
+	cfg := defaultConfig()
+	cfg.MaxMessageSize = 1024
+	in := &otlpInput{config: cfg}
+	srv := httptest.NewServer(in.httpMux(r))
+	defer srv.Close()
+
+	req := testLogsRequest()
+	protoBody, err := req.MarshalProto()
+	require.NoError(t, err)
+	jsonBody, err := req.MarshalJSON()
+	require.NoError(t, err)
+
+	for contentType, body := range map[string][]byte{
+		contentTypeProtobuf: protoBody,
+		contentTypeJSON:     jsonBody,
+	} {
+		t.Run(contentType, func(t *testing.T) {
+			go func() { ack(<-published) }()
+
+			resp, err := http.Post(srv.URL+"/v1/logs", contentType, bytes.NewReader(body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, contentType, resp.Header.Get("Content-Type"))
+		})
+	}
+
+	for name, tc := range map[string]struct {
+		path, contentType string
+		body              []byte
+		status            int
+	}{
+		"disabled signal":  {"/v1/traces", contentTypeProtobuf, nil, http.StatusNotFound},
+		"bad content type": {"/v1/logs", "text/plain", protoBody, http.StatusUnsupportedMediaType},
+		"invalid body":     {"/v1/logs", contentTypeJSON, []byte("{"), http.StatusBadRequest},
+		"too large":        {"/v1/logs", contentTypeJSON, bytes.Repeat([]byte(" "), 2048), http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+tc.path, tc.contentType, bytes.NewReader(tc.body))
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package relp
+
+import (
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elastic/beats/v7/filebeat/inputsource/tcp"
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+)
+
+type config struct {
+	tcp.Config `config:",inline"`
+
+	// Format is the syslog format of the messages, one of rfc3164,
+	// rfc5424 or auto.
+	Format   string            `config:"format"`
+	Timezone *cfgtype.Timezone `config:"timezone"`
+}
+
+func defaultConfig() config {
+	return config{
+		Config: tcp.Config{
+			Host:           "localhost:20514",
+			Timeout:        time.Minute * 5,
+			MaxMessageSize: 128 * humanize.KiByte,
+		},
+		Format:   "auto",
+		Timezone: cfgtype.MustNewTimezone("Local"),
+	}
+}
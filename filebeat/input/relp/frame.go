@@ -0,0 +1,163 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package relp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// RELP commands.
+const (
+	cmdOpen        = "open"
+	cmdSyslog      = "syslog"
+	cmdClose       = "close"
+	cmdRsp         = "rsp"
+	cmdServerClose = "serverclose"
+)
+
+const (
+	maxTxnr       = 999999999
+	maxCommandLen = 32
+	// maxDataLenDigits is the maximum number of digits of the data length,
+	// larger frames are refused by max_message_size anyway.
+	maxDataLenDigits = 9
+)
+
+var errFrameTooLarge = errors.New("frame exceeds max_message_size")
+
+// frame is a RELP frame: TXNR SP COMMAND SP DATALEN [SP DATA] TRAILER.
+type frame struct {
+	txnr    int
+	command string
+	data    []byte
+}
+
+// readFrame reads the next frame. It returns io.EOF if the connection was
+// closed between frames.
+func readFrame(r *bufio.Reader, maxSize int) (frame, error) {
+	var f frame
+
+	txnr, err := readToken(r, ' ', maxDataLenDigits)
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(txnr) == 0 {
+			return f, io.EOF
+		}
+		return f, fmt.Errorf("invalid transaction number: %w", err)
+	}
+	f.txnr, err = strconv.Atoi(txnr)
+	if err != nil || f.txnr < 0 || f.txnr > maxTxnr {
+		return f, fmt.Errorf("invalid transaction number %q", txnr)
+	}
+
+	f.command, err = readToken(r, ' ', maxCommandLen)
+	if err != nil {
+		return f, fmt.Errorf("invalid command: %w", err)
+	}
+
+	// The data length is followed by a space if there is data, or the
+	// trailer if there is none.
+	var digits []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return f, fmt.Errorf("invalid data length: %w", unexpectedEOF(err))
+		}
+		if b == ' ' || b == '\n' {
+			if len(digits) == 0 {
+				return f, errors.New("missing data length")
+			}
+			n, _ := strconv.Atoi(string(digits))
+			if n > maxSize {
+				return f, errFrameTooLarge
+			}
+			if b == '\n' {
+				if n != 0 {
+					return f, fmt.Errorf("missing data of length %d", n)
+				}
+				return f, nil
+			}
+			f.data = make([]byte, n)
+			if _, err := io.ReadFull(r, f.data); err != nil {
+				return f, fmt.Errorf("invalid data: %w", unexpectedEOF(err))
+			}
+			break
+		}
+		if b < '0' || b > '9' || len(digits) == maxDataLenDigits {
+			return f, fmt.Errorf("invalid data length %q", append(digits, b))
+		}
+		digits = append(digits, b)
+	}
+
+	trailer, err := r.ReadByte()
+	if err != nil {
+		return f, fmt.Errorf("missing trailer: %w", unexpectedEOF(err))
+	}
+	if trailer != '\n' {
+		return f, fmt.Errorf("invalid trailer %q", trailer)
+	}
+	return f, nil
+}
+
+// readToken reads up to the delimiter, which is not returned.
+func readToken(r *bufio.Reader, delim byte, maxLen int) (string, error) {
+	var token []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if len(token) != 0 {
+				err = unexpectedEOF(err)
+			}
+			return string(token), err
+		}
+		if b == delim {
+			if len(token) == 0 {
+				return "", errors.New("empty token")
+			}
+			return string(token), nil
+		}
+		if len(token) == maxLen {
+			return "", fmt.Errorf("token longer than %d bytes", maxLen)
+		}
+		token = append(token, b)
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// appendFrame appends the encoding of a frame to buf.
+func appendFrame(buf []byte, txnr int, command string, data []byte) []byte {
+	buf = strconv.AppendInt(buf, int64(txnr), 10)
+	buf = append(buf, ' ')
+	buf = append(buf, command...)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(len(data)), 10)
+	if len(data) != 0 {
+		buf = append(buf, ' ')
+		buf = append(buf, data...)
+	}
+	return append(buf, '\n')
+}
@@ -0,0 +1,75 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package relp
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader(
+		"1 open 14 relp_version=0\n" +
+			"2 syslog 5 hello\n" +
+			"3 syslog 7 multi\nl\n" +
+			"4 close 0\n"))
+
+	for _, want := range []frame{
+		{txnr: 1, command: cmdOpen, data: []byte("relp_version=0")},
+		{txnr: 2, command: cmdSyslog, data: []byte("hello")},
+		{txnr: 3, command: cmdSyslog, data: []byte("multi\nl")},
+		{txnr: 4, command: cmdClose},
+	} {
+		f, err := readFrame(r, 1024)
+		require.NoError(t, err)
+		assert.Equal(t, want, f)
+	}
+	_, err := readFrame(r, 1024)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"invalid txnr":        "x open 0\n",
+		"txnr too large":      "1000000000 open 0\n",
+		"missing data length": "1 syslog \n",
+		"invalid data length": "1 syslog 1x hello\n",
+		"missing data":        "1 syslog 5\n",
+		"short data":          "1 syslog 5 hel",
+		"missing trailer":     "1 syslog 5 hello",
+		"invalid trailer":     "1 syslog 5 hellox",
+		"too large":           "1 syslog 2000 hello\n",
+		"truncated":           "1 sys",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := readFrame(bufio.NewReader(strings.NewReader(data)), 1024)
+			require.Error(t, err)
+			assert.NotEqual(t, io.EOF, err)
+		})
+	}
+}
+
+func TestAppendFrame(t *testing.T) {
+	assert.Equal(t, "2 rsp 6 200 OK\n", string(appendFrame(nil, 2, cmdRsp, []byte("200 OK"))))
+	assert.Equal(t, "0 serverclose 0\n", string(appendFrame(nil, 0, cmdServerClose, nil)))
+}
@@ -0,0 +1,175 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package relp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/filebeat/input/netmetrics"
+	"github.com/elastic/beats/v7/filebeat/input/syslog"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/streaming"
+	"github.com/elastic/beats/v7/filebeat/inputsource/tcp"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// offers are sent in response to the open command.
+const offers = "relp_version=0\nrelp_software=filebeat\ncommands=" + cmdSyslog
+
+type handler struct {
+	log     *logp.Logger
+	parse   syslog.EventParserFunc
+	publish func(beat.Event)
+	metrics *netmetrics.TCP
+}
+
+func (h *handler) factory(lc streaming.ListenerConfig) streaming.ConnectionHandler {
+	return func(ctx context.Context, conn net.Conn) error {
+		c := &connection{
+			handler: h,
+			conn:    conn,
+			config:  lc,
+			log:     h.log.With("remote_address", conn.RemoteAddr()),
+		}
+		return c.serve(ctx)
+	}
+}
+
+// connection is a RELP session.
+type connection struct {
+	*handler
+	conn   net.Conn
+	config streaming.ListenerConfig
+	log    *logp.Logger
+
+	writeMu sync.Mutex
+	// pending counts the syslog commands waiting for their events to be
+	// acknowledged.
+	pending sync.WaitGroup
+}
+
+func (c *connection) serve(ctx context.Context) error {
+	r := bufio.NewReader(streaming.NewDeadlineReader(c.conn, c.config.Timeout))
+	metadata := tcp.MetadataCallback(c.conn)
+
+	open := false
+	for ctx.Err() == nil {
+		f, err := readFrame(r, int(c.config.MaxMessageSize))
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			c.serverClose()
+			return fmt.Errorf("failed to read RELP frame: %w", err)
+		}
+
+		switch f.command {
+		case cmdOpen:
+			if open {
+				c.respond(f.txnr, "500 session already open")
+				continue
+			}
+			open = true
+			c.respond(f.txnr, "200 OK\n"+offers)
+
+		case cmdSyslog:
+			if !open {
+				c.respond(f.txnr, "500 session not open")
+				c.serverClose()
+				return errors.New("syslog command received before open")
+			}
+
+			start := time.Now()
+			evt := c.parse(f.data, metadata)
+			txnr := f.txnr
+			c.pending.Add(1)
+			tracker := acker.NewBatchTracker(func() {
+				c.respond(txnr, "200 OK")
+				c.pending.Done()
+			})
+			tracker.Add()
+			evt.Private = tracker
+			c.publish(evt)
+			tracker.Ready()
+
+			// This must be called after publish to measure the
+			// processing time metric.
+			c.metrics.LogSize(len(f.data), start)
+
+		case cmdClose:
+			// Messages are only safe once acknowledged, the client must
+			// not consider them sent before receiving their responses.
+			if err := c.waitPending(ctx); err != nil {
+				return err
+			}
+			c.respond(f.txnr, "")
+			c.serverClose()
+			return nil
+
+		default:
+			c.respond(f.txnr, "500 command not supported")
+		}
+	}
+	return nil
+}
+
+// waitPending waits until all events of the session have been acknowledged.
+func (c *connection) waitPending(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// respond sends a response to the command with the given transaction number.
+func (c *connection) respond(txnr int, rsp string) {
+	c.write(appendFrame(nil, txnr, cmdRsp, []byte(rsp)))
+}
+
+// serverClose notifies the client that the session is closed by the server.
+func (c *connection) serverClose() {
+	c.write(appendFrame(nil, 0, cmdServerClose, nil))
+}
+
+func (c *connection) write(b []byte) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.config.Timeout)); err != nil {
+		c.log.Debugw("failed to set write deadline", "error", err)
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		// The client resends the messages it did not get responses for.
+		c.log.Debugw("failed to write RELP response", "error", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package relp
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/filebeat/input/syslog"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/streaming"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+type testClient struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	events chan beat.Event
+	done   chan error
+}
+
+func newTestClient(t *testing.T) *testClient {
+	t.Helper()
+
+	log := logp.NewLogger(inputName)
+	parse, err := syslog.NewEventParser("auto", time.UTC, log)
+	require.NoError(t, err)
+
+	server, client := net.Pipe()
+	events := make(chan beat.Event, 10)
+	h := &handler{
+		log:     log,
+		parse:   parse,
+		publish: func(e beat.Event) { events <- e },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- h.factory(streaming.ListenerConfig{Timeout: time.Minute, MaxMessageSize: 1024})(ctx, server)
+		server.Close()
+	}()
+	t.Cleanup(func() {
+		cancel()
+		client.Close()
+	})
+
+	return &testClient{conn: client, r: bufio.NewReader(client), events: events, done: done}
+}
+
+func (c *testClient) send(t *testing.T, txnr int, command, data string) {
+	t.Helper()
+	_, err := c.conn.Write(appendFrame(nil, txnr, command, []byte(data)))
+	require.NoError(t, err)
+}
+
+func (c *testClient) read(t *testing.T) frame {
+	t.Helper()
+	f, err := readFrame(c.r, 1024)
+	require.NoError(t, err)
+	return f
+}
+
+func TestSession(t *testing.T) {
+	c := newTestClient(t)
+
+	c.send(t, 1, cmdOpen, "relp_version=0\nrelp_software=librelp\ncommands=syslog")
+	assert.Equal(t, frame{txnr: 1, command: cmdRsp, data: []byte("200 OK\n" + offers)}, c.read(t))
+
+	c.send(t, 2, cmdSyslog, "<34>Oct 11 22:14:15 mymachine su: 'su root' failed")
+	c.send(t, 3, cmdSyslog, "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event")
+
+	first := <-c.events
+	second := <-c.events
+	assert.Equal(t, "'su root' failed", first.Fields["message"])
+	assert.Equal(t, "An application event", second.Fields["message"])
+	assert.Equal(t, time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC), second.Timestamp)
+
+	frames := make(chan frame, 3)
+	go func() {
+		for {
+			f, err := readFrame(c.r, 1024)
+			if err != nil {
+				return
+			}
+			frames <- f
+		}
+	}()
+
+	// Responses are only sent once events are acknowledged.
+	select {
+	case f := <-frames:
+		t.Fatalf("response sent before acknowledgement: %+v", f)
+	case <-time.After(50 * time.Millisecond):
+	}
+	second.Private.(*acker.BatchTracker).ACK()
+	assert.Equal(t, frame{txnr: 3, command: cmdRsp, data: []byte("200 OK")}, <-frames)
+
+	// The session is only closed once all events are acknowledged.
+	c.send(t, 4, cmdClose, "")
+	select {
+	case f := <-frames:
+		t.Fatalf("close answered with pending events: %+v", f)
+	case <-time.After(50 * time.Millisecond):
+	}
+	first.Private.(*acker.BatchTracker).ACK()
+	assert.Equal(t, frame{txnr: 2, command: cmdRsp, data: []byte("200 OK")}, <-frames)
+	assert.Equal(t, frame{txnr: 4, command: cmdRsp}, <-frames)
+	assert.Equal(t, frame{txnr: 0, command: cmdServerClose}, <-frames)
+	assert.NoError(t, <-c.done)
+}
+
+func TestSessionNotOpen(t *testing.T) {
+	c := newTestClient(t)
+
+	c.send(t, 1, cmdSyslog, "<34>Oct 11 22:14:15 mymachine su: 'su root' failed")
+	assert.Equal(t, frame{txnr: 1, command: cmdRsp, data: []byte("500 session not open")}, c.read(t))
+	assert.Equal(t, frame{txnr: 0, command: cmdServerClose}, c.read(t))
+	assert.Error(t, <-c.done)
+	assert.Empty(t, c.events)
+}
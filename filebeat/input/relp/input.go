@@ -0,0 +1,119 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package relp
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/elastic/beats/v7/filebeat/input/netmetrics"
+	"github.com/elastic/beats/v7/filebeat/input/syslog"
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/filebeat/inputsource/tcp"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/go-concert/ctxtool"
+)
+
+const inputName = "relp"
+
+func Plugin() input.Plugin {
+	return input.Plugin{
+		Name:       inputName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "Receives syslog messages sent with the Reliable Event Logging Protocol.",
+		Manager:    input.ConfigureWith(configure),
+	}
+}
+
+func configure(cfg *conf.C) (input.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	// Check the format now to fail on invalid configurations.
+	if _, err := syslog.NewEventParser(config.Format, config.Timezone.Location(), logp.NewLogger(inputName)); err != nil {
+		return nil, err
+	}
+
+	return &relpInput{config: config}, nil
+}
+
+// relpInput implements the Filebeat input V2 interface. The input is stateless.
+type relpInput struct {
+	config config
+}
+
+func (i *relpInput) Name() string { return inputName }
+
+func (i *relpInput) Test(_ input.TestContext) error {
+	l, err := net.Listen("tcp", i.config.Host)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+func (i *relpInput) Run(ctx input.Context, pipeline beat.Pipeline) error {
+	log := ctx.Logger.With("host", i.config.Host)
+
+	log.Info("Starting " + inputName + " input")
+	defer log.Info(inputName + " input stopped")
+
+	parse, err := syslog.NewEventParser(i.config.Format, i.config.Timezone.Location(), log)
+	if err != nil {
+		return err
+	}
+
+	// Create client for publishing events and receive notification of their ACKs.
+	client, err := pipeline.ConnectWith(beat.ClientConfig{
+		EventListener: acker.BatchTrackerReporter(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline client: %w", err)
+	}
+	defer client.Close()
+
+	const pollInterval = time.Minute
+	metrics := netmetrics.NewTCP(inputName, ctx.ID, i.config.Host, pollInterval, log)
+	defer metrics.Close()
+
+	h := &handler{
+		log:     log,
+		parse:   parse,
+		publish: client.Publish,
+		metrics: metrics,
+	}
+	server, err := tcp.New(&i.config.Config, h.factory)
+	if err != nil {
+		return err
+	}
+
+	err = server.Run(ctxtool.FromCanceller(ctx.Cancelation))
+	// Ignore error from 'Run' in case shutdown was signaled.
+	if ctxerr := ctx.Cancelation.Err(); ctxerr != nil {
+		err = ctxerr
+	}
+	return err
+}
@@ -0,0 +1,268 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BER tags of the SNMP types.
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagOID         = 0x06
+	tagSequence    = 0x30
+
+	tagIPAddress  = 0x40
+	tagCounter32  = 0x41
+	tagGauge32    = 0x42
+	tagTimeTicks  = 0x43
+	tagOpaque     = 0x44
+	tagCounter64  = 0x46
+	tagNoSuchObj  = 0x80
+	tagNoSuchInst = 0x81
+	tagEndOfView  = 0x82
+)
+
+var errTruncated = errors.New("truncated BER element")
+
+// readTLV reads the BER element at the start of b. The content is a sub slice
+// of b, which allows finding its offset in the decoded buffer.
+func readTLV(b []byte) (tag byte, content, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errTruncated
+	}
+	tag = b[0]
+	if tag&0x1f == 0x1f {
+		return 0, nil, nil, fmt.Errorf("unsupported multi-byte BER tag 0x%x", tag)
+	}
+	length, i := int(b[1]), 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 {
+			return 0, nil, nil, errors.New("unsupported BER length encoding")
+		}
+		if len(b) < i+n {
+			return 0, nil, nil, errTruncated
+		}
+		length = 0
+		for _, c := range b[i : i+n] {
+			length = length<<8 | int(c)
+		}
+		i += n
+	}
+	if length < 0 || length > len(b)-i {
+		return 0, nil, nil, errTruncated
+	}
+	return tag, b[i : i+length], b[i+length:], nil
+}
+
+// readExpect reads a BER element of the given tag.
+func readExpect(b []byte, want byte) (content, rest []byte, err error) {
+	tag, content, rest, err := readTLV(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tag != want {
+		return nil, nil, fmt.Errorf("unexpected BER tag 0x%x, want 0x%x", tag, want)
+	}
+	return content, rest, nil
+}
+
+// readInt reads a BER integer.
+func readInt(b []byte) (int64, []byte, error) {
+	c, rest, err := readExpect(b, tagInteger)
+	if err != nil {
+		return 0, nil, err
+	}
+	v, err := parseInt(c)
+	return v, rest, err
+}
+
+// readOctets reads a BER octet string.
+func readOctets(b []byte) ([]byte, []byte, error) {
+	return readExpect(b, tagOctetString)
+}
+
+func parseInt(c []byte) (int64, error) {
+	if len(c) == 0 || len(c) > 8 {
+		return 0, fmt.Errorf("invalid integer length %d", len(c))
+	}
+	v := int64(int8(c[0]))
+	for _, b := range c[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+func parseUint(c []byte) (uint64, error) {
+	if len(c) > 0 && c[0] == 0 {
+		c = c[1:]
+	}
+	if len(c) > 8 {
+		return 0, fmt.Errorf("invalid unsigned integer length %d", len(c))
+	}
+	var v uint64
+	for _, b := range c {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+// oid is an object identifier.
+type oid []uint32
+
+func parseOID(c []byte) (oid, error) {
+	if len(c) == 0 {
+		return nil, errors.New("empty object identifier")
+	}
+	var (
+		o oid
+		v uint64
+	)
+	for i, b := range c {
+		v = v<<7 | uint64(b&0x7f)
+		if v > 1<<32-1 {
+			return nil, errors.New("object identifier sub-identifier overflows")
+		}
+		if b&0x80 != 0 {
+			if i == len(c)-1 {
+				return nil, errTruncated
+			}
+			continue
+		}
+		if len(o) == 0 {
+			// The first two sub-identifiers are encoded together.
+			first := v / 40
+			if first > 2 {
+				first = 2
+			}
+			o = append(o, uint32(first), uint32(v-first*40))
+		} else {
+			o = append(o, uint32(v))
+		}
+		v = 0
+	}
+	return o, nil
+}
+
+func parseOIDString(s string) (oid, error) {
+	s = strings.TrimPrefix(s, ".")
+	parts := strings.Split(s, ".")
+	o := make(oid, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object identifier %q", s)
+		}
+		o[i] = uint32(v)
+	}
+	return o, nil
+}
+
+func (o oid) String() string {
+	var sb strings.Builder
+	for i, v := range o {
+		if i != 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(strconv.FormatUint(uint64(v), 10))
+	}
+	return sb.String()
+}
+
+func (o oid) equal(other oid) bool {
+	if len(o) != len(other) {
+		return false
+	}
+	for i := range o {
+		if o[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendTLV appends a BER element to dst.
+func appendTLV(dst []byte, tag byte, content []byte) []byte {
+	dst = append(dst, tag)
+	switch n := len(content); {
+	case n < 0x80:
+		dst = append(dst, byte(n))
+	case n <= 0xff:
+		dst = append(dst, 0x81, byte(n))
+	case n <= 0xffff:
+		dst = append(dst, 0x82, byte(n>>8), byte(n))
+	default:
+		dst = append(dst, 0x84, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(dst, content...)
+}
+
+func appendInt(dst []byte, tag byte, v int64) []byte {
+	n := 1
+	for x := v; x > 127 || x < -128; x >>= 8 {
+		n++
+	}
+	c := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		c[i] = byte(v)
+		v >>= 8
+	}
+	return appendTLV(dst, tag, c)
+}
+
+func appendUint(dst []byte, tag byte, v uint64) []byte {
+	c := []byte{byte(v)}
+	for v >>= 8; v != 0; v >>= 8 {
+		c = append([]byte{byte(v)}, c...)
+	}
+	if c[0]&0x80 != 0 {
+		c = append([]byte{0}, c...)
+	}
+	return appendTLV(dst, tag, c)
+}
+
+func appendOID(dst []byte, o oid) []byte {
+	var c []byte
+	appendSub := func(v uint32) {
+		start := len(c)
+		c = append(c, byte(v&0x7f))
+		for v >>= 7; v != 0; v >>= 7 {
+			c = append(c, 0)
+			copy(c[start+1:], c[start:])
+			c[start] = byte(v&0x7f) | 0x80
+		}
+	}
+	switch len(o) {
+	case 0:
+		appendSub(0)
+	case 1:
+		appendSub(o[0] * 40)
+	default:
+		appendSub(o[0]*40 + o[1])
+		for _, v := range o[2:] {
+			appendSub(v)
+		}
+	}
+	return appendTLV(dst, tagOID, c)
+}
@@ -0,0 +1,78 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"1.3.6.1.6.3.1.1.5.3",
+		"1.3.6.1.4.1.2636.4.1.1",
+		"2.999.4294967295",
+		"0.39",
+	} {
+		t.Run(s, func(t *testing.T) {
+			o, err := parseOIDString(s)
+			require.NoError(t, err)
+			c, rest, err := readExpect(appendOID(nil, o), tagOID)
+			require.NoError(t, err)
+			assert.Empty(t, rest)
+			got, err := parseOID(c)
+			require.NoError(t, err)
+			assert.Equal(t, s, got.String())
+		})
+	}
+}
+
+func TestIntegerRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, -1, 127, 128, -128, -129, 256, math.MaxInt32, math.MinInt64, math.MaxInt64} {
+		c, _, err := readExpect(appendInt(nil, tagInteger, v), tagInteger)
+		require.NoError(t, err)
+		got, err := parseInt(c)
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+	for _, v := range []uint64{0, 127, 128, 255, math.MaxUint32, math.MaxUint64} {
+		c, _, err := readExpect(appendUint(nil, tagCounter64, v), tagCounter64)
+		require.NoError(t, err)
+		got, err := parseUint(c)
+		require.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestReadTLV(t *testing.T) {
+	long := make([]byte, 300)
+	b := appendTLV(nil, tagOctetString, long)
+	assert.Equal(t, []byte{tagOctetString, 0x82, 0x01, 0x2c}, b[:4])
+	c, rest, err := readOctets(append(b, 0x05, 0x00))
+	require.NoError(t, err)
+	assert.Len(t, c, 300)
+	assert.Equal(t, []byte{0x05, 0x00}, rest)
+
+	_, _, err = readOctets(b[:100])
+	assert.ErrorIs(t, err, errTruncated)
+	_, _, err = readOctets([]byte{tagOctetString, 0x80})
+	assert.Error(t, err, "indefinite length")
+}
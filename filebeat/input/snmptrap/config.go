@@ -0,0 +1,112 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elastic/beats/v7/filebeat/inputsource/udp"
+)
+
+type config struct {
+	udp.Config `config:",inline"`
+
+	// Communities are the accepted communities of SNMPv1 and SNMPv2c
+	// messages, all communities are accepted when empty.
+	Communities []string `config:"communities"`
+	// Users are the SNMPv3 users.
+	Users []userConfig `config:"users"`
+	// EngineID is the hex encoded engine ID of the input, SNMPv3 informs
+	// are sent to it. A random engine ID is used when empty.
+	EngineID string `config:"engine_id"`
+	// MIBs are the MIB files, or directories of MIB files, used to resolve
+	// object identifiers to names.
+	MIBs []string `config:"mibs"`
+}
+
+type userConfig struct {
+	Name         string `config:"name" validate:"required"`
+	AuthProtocol string `config:"auth_protocol"`
+	AuthPassword string `config:"auth_password"`
+	PrivProtocol string `config:"priv_protocol"`
+	PrivPassword string `config:"priv_password"`
+}
+
+// minPasswordLen is the minimum length of SNMPv3 passwords.
+const minPasswordLen = 8
+
+func (c *userConfig) Validate() error {
+	if c.AuthProtocol == "" {
+		if c.PrivProtocol != "" {
+			return fmt.Errorf("user %s: priv_protocol requires auth_protocol", c.Name)
+		}
+		return nil
+	}
+	if _, ok := authProtocols[strings.ToUpper(c.AuthProtocol)]; !ok {
+		return fmt.Errorf("user %s: unsupported auth_protocol %q, must be one of MD5, SHA, SHA224, SHA256, SHA384 or SHA512", c.Name, c.AuthProtocol)
+	}
+	if len(c.AuthPassword) < minPasswordLen {
+		return fmt.Errorf("user %s: auth_password must have at least %d characters", c.Name, minPasswordLen)
+	}
+	if c.PrivProtocol == "" {
+		return nil
+	}
+	if _, ok := privKeyLen[strings.ToUpper(c.PrivProtocol)]; !ok {
+		return fmt.Errorf("user %s: unsupported priv_protocol %q, must be one of DES, AES, AES192 or AES256", c.Name, c.PrivProtocol)
+	}
+	if len(c.PrivPassword) < minPasswordLen {
+		return fmt.Errorf("user %s: priv_password must have at least %d characters", c.Name, minPasswordLen)
+	}
+	return nil
+}
+
+func (c *config) Validate() error {
+	if c.EngineID != "" {
+		id, err := hex.DecodeString(c.EngineID)
+		if err != nil {
+			return fmt.Errorf("engine_id must be hex encoded: %w", err)
+		}
+		if len(id) < 5 || len(id) > 32 {
+			return errors.New("engine_id must be 5 to 32 bytes long")
+		}
+	}
+	names := make(map[string]bool, len(c.Users))
+	for _, u := range c.Users {
+		if names[u.Name] {
+			return fmt.Errorf("duplicate user %s", u.Name)
+		}
+		names[u.Name] = true
+	}
+	return nil
+}
+
+func defaultConfig() config {
+	return config{
+		Config: udp.Config{
+			Host:           "localhost:1162",
+			MaxMessageSize: 64 * humanize.KiByte,
+			Timeout:        time.Minute * 5,
+		},
+	}
+}
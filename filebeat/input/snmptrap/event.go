@@ -0,0 +1,197 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var (
+	oidSysUpTime   = oid{1, 3, 6, 1, 2, 1, 1, 3, 0}
+	oidSNMPTrapOID = oid{1, 3, 6, 1, 6, 3, 1, 1, 4, 1, 0}
+	oidSNMPTraps   = oid{1, 3, 6, 1, 6, 3, 1, 1, 5}
+)
+
+var valueTypes = map[byte]string{
+	tagInteger:     "integer",
+	tagOctetString: "octet_string",
+	tagNull:        "null",
+	tagOID:         "object_identifier",
+	tagIPAddress:   "ip_address",
+	tagCounter32:   "counter32",
+	tagGauge32:     "gauge32",
+	tagTimeTicks:   "timeticks",
+	tagOpaque:      "opaque",
+	tagCounter64:   "counter64",
+	tagNoSuchObj:   "no_such_object",
+	tagNoSuchInst:  "no_such_instance",
+	tagEndOfView:   "end_of_mib_view",
+}
+
+// newEvent returns the event of a received notification.
+func newEvent(msg *message, remoteAddr net.Addr, mibs *mibTree, now time.Time) beat.Event {
+	p := msg.pdu
+	snmp := mapstr.M{}
+	switch msg.version {
+	case version1:
+		snmp["version"] = "1"
+	case version2c:
+		snmp["version"] = "2c"
+	case version3:
+		snmp["version"] = "3"
+	}
+
+	varbinds := p.varbinds
+	switch p.typ {
+	case pduTrapV1:
+		snmp["pdu_type"] = "trap"
+		snmp["uptime"] = p.timestamp
+		snmp["trap"] = oidFields(v1TrapOID(p), mibs)
+		snmp["enterprise"] = oidFields(p.enterprise, mibs)
+		snmp["agent_address"] = p.agentAddr.String()
+		snmp["generic_trap"] = p.genericTrap
+		snmp["specific_trap"] = p.specificTrap
+	default:
+		snmp["pdu_type"] = "trap"
+		if p.typ == pduInform {
+			snmp["pdu_type"] = "inform"
+		}
+		snmp["request_id"] = p.requestID
+		// SNMPv2 notifications start with the sysUpTime.0 and
+		// snmpTrapOID.0 variable bindings.
+		if len(varbinds) != 0 && varbinds[0].oid.equal(oidSysUpTime) {
+			snmp["uptime"] = varbinds[0].value
+			varbinds = varbinds[1:]
+		}
+		if len(varbinds) != 0 && varbinds[0].oid.equal(oidSNMPTrapOID) {
+			if o, ok := varbinds[0].value.(oid); ok {
+				snmp["trap"] = oidFields(o, mibs)
+			}
+			varbinds = varbinds[1:]
+		}
+	}
+
+	if msg.version == version3 {
+		level := "noAuthNoPriv"
+		switch {
+		case msg.flags&flagPriv != 0:
+			level = "authPriv"
+		case msg.flags&flagAuth != 0:
+			level = "authNoPriv"
+		}
+		snmp["security"] = mapstr.M{
+			"user_name": msg.usm.userName,
+			"level":     level,
+			"engine_id": hex.EncodeToString(msg.usm.engineID),
+		}
+		ctx := mapstr.M{}
+		if len(msg.contextEngineID) != 0 {
+			ctx["engine_id"] = hex.EncodeToString(msg.contextEngineID)
+		}
+		if msg.contextName != "" {
+			ctx["name"] = msg.contextName
+		}
+		if len(ctx) != 0 {
+			snmp["context"] = ctx
+		}
+	}
+
+	if len(varbinds) != 0 {
+		list := make([]mapstr.M, 0, len(varbinds))
+		for _, v := range varbinds {
+			list = append(list, varbindFields(v, mibs))
+		}
+		snmp["varbinds"] = list
+	}
+
+	fields := mapstr.M{"snmp": snmp}
+	if remoteAddr != nil {
+		fields["log"] = mapstr.M{"source": mapstr.M{"address": remoteAddr.String()}}
+	}
+	return beat.Event{
+		Timestamp: now,
+		Fields:    fields,
+	}
+}
+
+// v1TrapOID returns the SNMPv2 notification identifier of an SNMPv1 trap,
+// as described in RFC 3584 section 3.1.
+func v1TrapOID(p pdu) oid {
+	if p.genericTrap >= 0 && p.genericTrap < 6 {
+		return append(append(oid(nil), oidSNMPTraps...), uint32(p.genericTrap+1))
+	}
+	return append(append(oid(nil), p.enterprise...), 0, uint32(p.specificTrap))
+}
+
+func oidFields(o oid, mibs *mibTree) mapstr.M {
+	m := mapstr.M{"oid": o.String()}
+	if name := mibs.name(o); name != "" {
+		m["name"] = name
+	}
+	return m
+}
+
+func varbindFields(v varbind, mibs *mibTree) mapstr.M {
+	m := oidFields(v.oid, mibs)
+	m["type"] = valueTypes[v.tag]
+	// Values are strings so that the varbinds of all types can be
+	// indexed in the same field.
+	switch val := v.value.(type) {
+	case int64:
+		m["value"] = strconv.FormatInt(val, 10)
+	case uint64:
+		m["value"] = strconv.FormatUint(val, 10)
+	case []byte:
+		m["value"] = octetString(val)
+	case oid:
+		m["value"] = val.String()
+		if name := mibs.name(val); name != "" {
+			m["value_name"] = name
+		}
+	case net.IP:
+		m["value"] = val.String()
+	}
+	return m
+}
+
+// octetString returns printable octet strings as is, and other octet strings
+// as colon separated hex bytes, like MAC addresses.
+func octetString(b []byte) string {
+	if utf8.Valid(b) && strings.IndexFunc(string(b), func(r rune) bool {
+		return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+	}) < 0 {
+		return string(b)
+	}
+	var sb strings.Builder
+	for i, c := range b {
+		if i != 0 {
+			sb.WriteByte(':')
+		}
+		sb.WriteString(hex.EncodeToString([]byte{c}))
+	}
+	return sb.String()
+}
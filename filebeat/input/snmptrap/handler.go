@@ -0,0 +1,225 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// maxMessageSize is the maximum size of the messages sent to the input.
+const maxMessageSize = 65507
+
+// errReported is returned when a message is not processed further after a
+// report was sent to the sender.
+var errReported = errors.New("report sent")
+
+// handler handles the messages received by the input.
+type handler struct {
+	log *logp.Logger
+	// communities are the accepted communities, all communities are
+	// accepted when empty.
+	communities map[string]bool
+	usm         *usm
+	mibs        *mibTree
+	publish     func(beat.Event)
+	// reply sends a message to the sender of a received message.
+	reply func(b []byte, addr net.Addr) error
+}
+
+// handle publishes the notification of a message and answers informs.
+func (h *handler) handle(data []byte, addr net.Addr, now time.Time) error {
+	msg, err := decodeMessage(data)
+	if err != nil {
+		return fmt.Errorf("invalid SNMP message: %w", err)
+	}
+
+	var (
+		user *usmUser
+		keys localKeys
+	)
+	if msg.version == version3 {
+		user, keys, err = h.processUSM(msg, data, addr, now)
+		if errors.Is(err, errReported) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	} else if len(h.communities) != 0 && !h.communities[msg.community] {
+		return errors.New("unknown community")
+	}
+
+	switch msg.pdu.typ {
+	case pduTrapV1:
+		if msg.version != version1 {
+			return errors.New("SNMPv1 trap in a message that is not SNMPv1")
+		}
+	case pduTrapV2, pduInform:
+		if msg.version == version1 {
+			return errors.New("SNMPv2 notification in an SNMPv1 message")
+		}
+	default:
+		return fmt.Errorf("unsupported PDU type 0x%x", msg.pdu.typ)
+	}
+
+	h.publish(newEvent(msg, addr, h.mibs, now))
+
+	if msg.pdu.typ != pduInform {
+		return nil
+	}
+	resp := pdu{typ: pduResponse, requestID: msg.pdu.requestID, varbinds: msg.pdu.varbinds}
+	if msg.version != version3 {
+		return h.reply(encodeCommunityMessage(msg.version, msg.community, resp), addr)
+	}
+	b, err := h.encodeV3(msg, msg.flags&^flagReportable, user, keys, msg.contextEngineID, resp, now)
+	if err != nil {
+		return err
+	}
+	return h.reply(b, addr)
+}
+
+// processUSM authenticates and decrypts an SNMPv3 message, following
+// RFC 3414 section 3.2. It returns the user and keys of the message.
+func (h *handler) processUSM(msg *message, raw []byte, addr net.Addr, now time.Time) (*usmUser, localKeys, error) {
+	if msg.securityModel != securityModelUSM {
+		return nil, localKeys{}, fmt.Errorf("unsupported security model %d", msg.securityModel)
+	}
+	params := msg.usm
+	reportable := msg.flags&flagReportable != 0
+
+	if len(params.engineID) == 0 {
+		// Engine ID discovery by the senders of informs.
+		if reportable && msg.encrypted == nil {
+			err := h.report(msg, addr, oidUSMStatsUnknownEngineIDs, h.usm.unknownEngineIDs.Add(1), nil, localKeys{}, now)
+			if err != nil {
+				return nil, localKeys{}, err
+			}
+		}
+		return nil, localKeys{}, errReported
+	}
+
+	user, ok := h.usm.users[params.userName]
+	if !ok {
+		return nil, localKeys{}, fmt.Errorf("unknown user %q", params.userName)
+	}
+	authenticated := msg.flags&flagAuth != 0
+	private := msg.flags&flagPriv != 0
+	if authenticated != (user.auth != nil) || private != (user.priv != "") {
+		return nil, localKeys{}, fmt.Errorf("security level of message does not match user %q", user.name)
+	}
+	keys := h.usm.keys(user, params.engineID)
+	if authenticated && !verify(user, keys, raw, params) {
+		return nil, localKeys{}, fmt.Errorf("authentication failed for user %q", user.name)
+	}
+	if private {
+		plain, err := decrypt(user, keys, params, msg.encrypted)
+		if err != nil {
+			return nil, localKeys{}, err
+		}
+		if err = decodeScopedPDU(msg, plain); err != nil {
+			return nil, localKeys{}, fmt.Errorf("invalid scoped PDU, check the privacy password of user %q: %w", user.name, err)
+		}
+	}
+
+	local := bytes.Equal(params.engineID, h.usm.engineID)
+	if msg.pdu.typ == pduInform && !local {
+		// Informs are sent to the local engine.
+		if reportable {
+			err := h.report(msg, addr, oidUSMStatsUnknownEngineIDs, h.usm.unknownEngineIDs.Add(1), nil, localKeys{}, now)
+			if err != nil {
+				return nil, localKeys{}, err
+			}
+		}
+		return nil, localKeys{}, errReported
+	}
+	if local && authenticated {
+		// Authenticated messages sent to the local engine must be
+		// in its time window.
+		diff := params.time - h.usm.engineTime(now)
+		if params.boots != h.usm.boots || diff > timeWindow || diff < -timeWindow {
+			if reportable {
+				err := h.report(msg, addr, oidUSMStatsNotInTimeWindows, h.usm.notInTimeWindows.Add(1), user, keys, now)
+				if err != nil {
+					return nil, localKeys{}, err
+				}
+			}
+			return nil, localKeys{}, errReported
+		}
+	}
+	return user, keys, nil
+}
+
+// report sends a report of the local engine with the value of a USM
+// statistic. The report is authenticated when user is set.
+func (h *handler) report(msg *message, addr net.Addr, stat oid, count uint64, user *usmUser, keys localKeys, now time.Time) error {
+	var flags byte
+	if user != nil {
+		flags = flagAuth
+	}
+	p := pdu{
+		typ:       pduReport,
+		requestID: msg.pdu.requestID,
+		varbinds:  []varbind{{oid: stat, tag: tagCounter32, value: count}},
+	}
+	b, err := h.encodeV3(msg, flags, user, keys, h.usm.engineID, p, now)
+	if err != nil {
+		return err
+	}
+	return h.reply(b, addr)
+}
+
+// encodeV3 encodes an SNMPv3 message of the local engine answering msg.
+func (h *handler) encodeV3(msg *message, flags byte, user *usmUser, keys localKeys, contextEngineID []byte, p pdu, now time.Time) ([]byte, error) {
+	resp := &message{
+		msgID:         msg.msgID,
+		maxSize:       maxMessageSize,
+		flags:         flags,
+		securityModel: securityModelUSM,
+		usm: usmParams{
+			engineID: h.usm.engineID,
+			boots:    h.usm.boots,
+			time:     h.usm.engineTime(now),
+			userName: msg.usm.userName,
+		},
+	}
+	if flags&flagAuth != 0 {
+		resp.usm.authParams = make([]byte, user.auth.macLen)
+	}
+	scoped := encodeScopedPDU(contextEngineID, msg.contextName, p)
+	if flags&flagPriv != 0 {
+		var err error
+		scoped, err = h.usm.encrypt(user, keys, &resp.usm, scoped)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b := encodeV3Message(resp, scoped)
+	if flags&flagAuth != 0 {
+		if err := sign(user, keys, b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,318 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var (
+	testAddr           = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 50162}
+	testLocalEngineID  = mustHex("80000000050102030405060708")
+	testSenderEngineID = mustHex("800000090300aabbccddeeff")
+
+	oidLinkDown = oid{1, 3, 6, 1, 6, 3, 1, 1, 5, 3}
+	oidIfIndex2 = oid{1, 3, 6, 1, 2, 1, 2, 2, 1, 1, 2}
+	oidIfDescr2 = oid{1, 3, 6, 1, 2, 1, 2, 2, 1, 2, 2}
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func newTestUSM(t *testing.T, users []*usmUser, engineID []byte) *usm {
+	t.Helper()
+	u, err := newUSM(users, engineID, time.Now())
+	require.NoError(t, err)
+	return u
+}
+
+type testHandler struct {
+	*handler
+	events  []beat.Event
+	replies [][]byte
+}
+
+func newTestHandler(t *testing.T, communities []string, users ...*usmUser) *testHandler {
+	t.Helper()
+	mibs, err := loadMIBs(nil, nil)
+	require.NoError(t, err)
+	th := &testHandler{}
+	th.handler = &handler{
+		log:     logp.NewLogger(inputName),
+		usm:     newTestUSM(t, users, testLocalEngineID),
+		mibs:    mibs,
+		publish: func(e beat.Event) { th.events = append(th.events, e) },
+		reply: func(b []byte, addr net.Addr) error {
+			assert.Equal(t, testAddr, addr)
+			th.replies = append(th.replies, b)
+			return nil
+		},
+	}
+	if len(communities) != 0 {
+		th.communities = make(map[string]bool)
+		for _, c := range communities {
+			th.communities[c] = true
+		}
+	}
+	return th
+}
+
+func linkDownPDU(typ byte) pdu {
+	return pdu{
+		typ:       typ,
+		requestID: 42,
+		varbinds: []varbind{
+			{oid: oidSysUpTime, tag: tagTimeTicks, value: uint64(123456)},
+			{oid: oidSNMPTrapOID, tag: tagOID, value: oidLinkDown},
+			{oid: oidIfIndex2, tag: tagInteger, value: int64(2)},
+			{oid: oidIfDescr2, tag: tagOctetString, value: []byte("eth0")},
+			{oid: oid{1, 3, 6, 1, 4, 1, 9, 1}, tag: tagOctetString, value: []byte{0x00, 0x1b, 0xff}},
+			{oid: oid{1, 3, 6, 1, 4, 1, 9, 2}, tag: tagIPAddress, value: net.IPv4(10, 0, 0, 1).To4()},
+		},
+	}
+}
+
+var linkDownVarbinds = []mapstr.M{
+	{"oid": "1.3.6.1.2.1.2.2.1.1.2", "name": "ifIndex.2", "type": "integer", "value": "2"},
+	{"oid": "1.3.6.1.2.1.2.2.1.2.2", "name": "ifDescr.2", "type": "octet_string", "value": "eth0"},
+	{"oid": "1.3.6.1.4.1.9.1", "name": "enterprises.9.1", "type": "octet_string", "value": "00:1b:ff"},
+	{"oid": "1.3.6.1.4.1.9.2", "name": "enterprises.9.2", "type": "ip_address", "value": "10.0.0.1"},
+}
+
+func TestHandleCommunityTrap(t *testing.T) {
+	h := newTestHandler(t, []string{"public"})
+	now := time.Now()
+
+	err := h.handle(encodeCommunityMessage(version2c, "public", linkDownPDU(pduTrapV2)), testAddr, now)
+	require.NoError(t, err)
+	require.Len(t, h.events, 1)
+	assert.Empty(t, h.replies)
+	assert.Equal(t, beat.Event{
+		Timestamp: now,
+		Fields: mapstr.M{
+			"snmp": mapstr.M{
+				"version":    "2c",
+				"pdu_type":   "trap",
+				"request_id": int64(42),
+				"uptime":     uint64(123456),
+				"trap":       mapstr.M{"oid": "1.3.6.1.6.3.1.1.5.3", "name": "linkDown"},
+				"varbinds":   linkDownVarbinds,
+			},
+			"log": mapstr.M{"source": mapstr.M{"address": "192.0.2.1:50162"}},
+		},
+	}, h.events[0])
+
+	err = h.handle(encodeCommunityMessage(version2c, "private", linkDownPDU(pduTrapV2)), testAddr, now)
+	assert.EqualError(t, err, "unknown community")
+	assert.Len(t, h.events, 1)
+}
+
+func TestHandleV1Trap(t *testing.T) {
+	h := newTestHandler(t, nil)
+
+	var body []byte
+	body = appendOID(body, oid{1, 3, 6, 1, 4, 1, 99999})
+	body = appendTLV(body, tagIPAddress, []byte{10, 1, 2, 3})
+	body = appendInt(body, tagInteger, 6)
+	body = appendInt(body, tagInteger, 17)
+	body = appendUint(body, tagTimeTicks, 500)
+	body = appendTLV(body, tagSequence, appendTLV(nil, tagSequence, appendVarbind(nil, varbind{oid: oidIfIndex2, tag: tagInteger, value: int64(2)})))
+	var msg []byte
+	msg = appendInt(msg, tagInteger, version1)
+	msg = appendTLV(msg, tagOctetString, []byte("any"))
+	msg = appendTLV(msg, pduTrapV1, body)
+
+	require.NoError(t, h.handle(appendTLV(nil, tagSequence, msg), testAddr, time.Now()))
+	require.Len(t, h.events, 1)
+	assert.Equal(t, mapstr.M{
+		"version":       "1",
+		"pdu_type":      "trap",
+		"uptime":        uint64(500),
+		"trap":          mapstr.M{"oid": "1.3.6.1.4.1.99999.0.17", "name": "enterprises.99999.0.17"},
+		"enterprise":    mapstr.M{"oid": "1.3.6.1.4.1.99999", "name": "enterprises.99999"},
+		"agent_address": "10.1.2.3",
+		"generic_trap":  int64(6),
+		"specific_trap": int64(17),
+		"varbinds":      []mapstr.M{{"oid": "1.3.6.1.2.1.2.2.1.1.2", "name": "ifIndex.2", "type": "integer", "value": "2"}},
+	}, h.events[0].Fields["snmp"])
+}
+
+func TestHandleCommunityInform(t *testing.T) {
+	h := newTestHandler(t, nil)
+	inform := linkDownPDU(pduInform)
+
+	require.NoError(t, h.handle(encodeCommunityMessage(version2c, "public", inform), testAddr, time.Now()))
+	require.Len(t, h.events, 1)
+	assert.Equal(t, "inform", h.events[0].Fields["snmp"].(mapstr.M)["pdu_type"])
+
+	require.Len(t, h.replies, 1)
+	resp, err := decodeMessage(h.replies[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(version2c), resp.version)
+	assert.Equal(t, "public", resp.community)
+	assert.Equal(t, byte(pduResponse), resp.pdu.typ)
+	assert.Equal(t, inform.requestID, resp.pdu.requestID)
+	assert.Equal(t, inform.varbinds, resp.pdu.varbinds)
+}
+
+// encodeTestV3 encodes an SNMPv3 message sent by a client with the keys of
+// user localized to engineID.
+func encodeTestV3(t *testing.T, client *usm, user *usmUser, flags byte, engineID []byte, boots, engineTime int64, p pdu) []byte {
+	t.Helper()
+	msg := &message{
+		msgID:         7,
+		maxSize:       maxMessageSize,
+		flags:         flags,
+		securityModel: securityModelUSM,
+		usm:           usmParams{engineID: engineID, boots: boots, time: engineTime, userName: user.name},
+	}
+	keys := client.keys(user, engineID)
+	if flags&flagAuth != 0 {
+		msg.usm.authParams = make([]byte, user.auth.macLen)
+	}
+	scoped := encodeScopedPDU(engineID, "", p)
+	if flags&flagPriv != 0 {
+		var err error
+		scoped, err = client.encrypt(user, keys, &msg.usm, scoped)
+		require.NoError(t, err)
+	}
+	b := encodeV3Message(msg, scoped)
+	if flags&flagAuth != 0 {
+		require.NoError(t, sign(user, keys, b))
+	}
+	return b
+}
+
+func newTestUser(t *testing.T, name, auth, authPassword, priv, privPassword string) *usmUser {
+	t.Helper()
+	u, err := newUSMUser(userConfig{Name: name, AuthProtocol: auth, AuthPassword: authPassword, PrivProtocol: priv, PrivPassword: privPassword})
+	require.NoError(t, err)
+	return u
+}
+
+func TestHandleV3Trap(t *testing.T) {
+	for _, tc := range []struct {
+		auth, priv string
+		flags      byte
+		level      string
+	}{
+		{"", "", 0, "noAuthNoPriv"},
+		{"MD5", "", flagAuth, "authNoPriv"},
+		{"SHA", "DES", flagAuth | flagPriv, "authPriv"},
+		{"SHA256", "AES", flagAuth | flagPriv, "authPriv"},
+		{"SHA512", "AES256", flagAuth | flagPriv, "authPriv"},
+	} {
+		t.Run(tc.level+tc.auth+tc.priv, func(t *testing.T) {
+			user := newTestUser(t, "traps", tc.auth, "authpassword", tc.priv, "privpassword")
+			h := newTestHandler(t, nil, user)
+			client := newTestUSM(t, []*usmUser{user}, testSenderEngineID)
+
+			b := encodeTestV3(t, client, user, tc.flags, testSenderEngineID, 1, 10, linkDownPDU(pduTrapV2))
+			require.NoError(t, h.handle(b, testAddr, time.Now()))
+			require.Len(t, h.events, 1)
+			assert.Empty(t, h.replies)
+
+			snmp := h.events[0].Fields["snmp"].(mapstr.M)
+			assert.Equal(t, "3", snmp["version"])
+			assert.Equal(t, mapstr.M{"user_name": "traps", "level": tc.level, "engine_id": hex.EncodeToString(testSenderEngineID)}, snmp["security"])
+			assert.Equal(t, mapstr.M{"engine_id": hex.EncodeToString(testSenderEngineID)}, snmp["context"])
+			assert.Equal(t, mapstr.M{"oid": "1.3.6.1.6.3.1.1.5.3", "name": "linkDown"}, snmp["trap"])
+			assert.Equal(t, linkDownVarbinds, snmp["varbinds"])
+		})
+	}
+}
+
+func TestHandleV3TrapRejected(t *testing.T) {
+	user := newTestUser(t, "traps", "SHA", "authpassword", "AES", "privpassword")
+	h := newTestHandler(t, nil, user)
+
+	wrongAuth := newTestUser(t, "traps", "SHA", "wrongpassword", "AES", "privpassword")
+	b := encodeTestV3(t, newTestUSM(t, nil, nil), wrongAuth, flagAuth|flagPriv, testSenderEngineID, 1, 10, linkDownPDU(pduTrapV2))
+	assert.EqualError(t, h.handle(b, testAddr, time.Now()), `authentication failed for user "traps"`)
+
+	noPriv := newTestUser(t, "traps", "SHA", "authpassword", "", "")
+	b = encodeTestV3(t, newTestUSM(t, nil, nil), noPriv, flagAuth, testSenderEngineID, 1, 10, linkDownPDU(pduTrapV2))
+	assert.EqualError(t, h.handle(b, testAddr, time.Now()), `security level of message does not match user "traps"`)
+
+	unknown := newTestUser(t, "other", "", "", "", "")
+	b = encodeTestV3(t, newTestUSM(t, nil, nil), unknown, 0, testSenderEngineID, 1, 10, linkDownPDU(pduTrapV2))
+	assert.EqualError(t, h.handle(b, testAddr, time.Now()), `unknown user "other"`)
+
+	assert.Empty(t, h.events)
+}
+
+func TestHandleV3Inform(t *testing.T) {
+	user := newTestUser(t, "informs", "SHA", "authpassword", "AES", "privpassword")
+	h := newTestHandler(t, nil, user)
+	client := newTestUSM(t, []*usmUser{user}, nil)
+	now := time.Now()
+
+	// Engine ID discovery.
+	discovery := encodeTestV3(t, client, &usmUser{}, flagReportable, nil, 0, 0, pdu{typ: 0xa0, requestID: 1})
+	require.NoError(t, h.handle(discovery, testAddr, now))
+	require.Len(t, h.replies, 1)
+	report, err := decodeMessage(h.replies[0])
+	require.NoError(t, err)
+	assert.Equal(t, testLocalEngineID, report.usm.engineID)
+	assert.Equal(t, byte(pduReport), report.pdu.typ)
+	assert.Equal(t, oidUSMStatsUnknownEngineIDs, report.pdu.varbinds[0].oid)
+	boots, engineTime := report.usm.boots, report.usm.time
+
+	// Messages out of the time window are reported.
+	b := encodeTestV3(t, client, user, flagAuth|flagPriv|flagReportable, testLocalEngineID, boots, engineTime+1000, linkDownPDU(pduInform))
+	require.NoError(t, h.handle(b, testAddr, now))
+	require.Len(t, h.replies, 2)
+	report, err = decodeMessage(h.replies[1])
+	require.NoError(t, err)
+	assert.Equal(t, byte(flagAuth), report.flags)
+	assert.True(t, verify(user, client.keys(user, testLocalEngineID), h.replies[1], report.usm))
+	assert.Equal(t, oidUSMStatsNotInTimeWindows, report.pdu.varbinds[0].oid)
+	assert.Empty(t, h.events)
+
+	b = encodeTestV3(t, client, user, flagAuth|flagPriv|flagReportable, testLocalEngineID, boots, engineTime, linkDownPDU(pduInform))
+	require.NoError(t, h.handle(b, testAddr, now))
+	require.Len(t, h.events, 1)
+	require.Len(t, h.replies, 3)
+
+	keys := client.keys(user, testLocalEngineID)
+	resp, err := decodeMessage(h.replies[2])
+	require.NoError(t, err)
+	assert.Equal(t, byte(flagAuth|flagPriv), resp.flags)
+	assert.True(t, verify(user, keys, h.replies[2], resp.usm))
+	plain, err := decrypt(user, keys, resp.usm, resp.encrypted)
+	require.NoError(t, err)
+	require.NoError(t, decodeScopedPDU(resp, plain))
+	assert.Equal(t, byte(pduResponse), resp.pdu.typ)
+	assert.Equal(t, int64(42), resp.pdu.requestID)
+	assert.Equal(t, linkDownPDU(pduInform).varbinds, resp.pdu.varbinds)
+}
@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"time"
+
+	"github.com/elastic/beats/v7/filebeat/input/netmetrics"
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/udp"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/go-concert/ctxtool"
+)
+
+const inputName = "snmptrap"
+
+func Plugin() input.Plugin {
+	return input.Plugin{
+		Name:       inputName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "SNMP trap and inform receiver",
+		Manager:    stateless.NewInputManager(configure),
+	}
+}
+
+func configure(cfg *conf.C) (stateless.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	users := make([]*usmUser, 0, len(config.Users))
+	for _, c := range config.Users {
+		u, err := newUSMUser(c)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	engineID, err := engineID(config.EngineID)
+	if err != nil {
+		return nil, err
+	}
+	return &server{config: config, users: users, engineID: engineID}, nil
+}
+
+// engineID returns the configured engine ID, or a random engine ID with the
+// administratively assigned format of RFC 3411.
+func engineID(s string) ([]byte, error) {
+	if s != "" {
+		return hex.DecodeString(s)
+	}
+	id := make([]byte, 13)
+	copy(id, []byte{0x80, 0, 0, 0, 5})
+	_, err := rand.Read(id[5:])
+	return id, err
+}
+
+type server struct {
+	config   config
+	users    []*usmUser
+	engineID []byte
+}
+
+func (s *server) Name() string { return inputName }
+
+func (s *server) Test(_ input.TestContext) error {
+	l, err := net.ListenPacket("udp", s.config.Host)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+func (s *server) Run(ctx input.Context, publisher stateless.Publisher) error {
+	log := ctx.Logger.With("host", s.config.Host)
+
+	log.Info("starting snmptrap input")
+	defer log.Info("snmptrap input stopped")
+
+	mibs, err := loadMIBs(s.config.MIBs, log)
+	if err != nil {
+		return err
+	}
+	usm, err := newUSM(s.users, s.engineID, time.Now())
+	if err != nil {
+		return err
+	}
+	log.Infow("SNMP engine started", "engine_id", hex.EncodeToString(s.engineID))
+
+	const pollInterval = time.Minute
+	metrics := netmetrics.NewUDP(inputName, ctx.ID, s.config.Host, uint64(s.config.ReadBuffer), pollInterval, log)
+	defer metrics.Close()
+
+	var server *udp.Server
+	h := &handler{
+		log:     log,
+		usm:     usm,
+		mibs:    mibs,
+		publish: func(evt beat.Event) { publisher.Publish(evt) },
+		reply: func(b []byte, addr net.Addr) error {
+			_, err := server.WriteTo(b, addr)
+			return err
+		},
+	}
+	if len(s.config.Communities) != 0 {
+		h.communities = make(map[string]bool, len(s.config.Communities))
+		for _, c := range s.config.Communities {
+			h.communities[c] = true
+		}
+	}
+
+	server = udp.New(&s.config.Config, func(data []byte, metadata inputsource.NetworkMetadata) {
+		start := time.Now()
+		if err := h.handle(data, metadata.RemoteAddr, start); err != nil {
+			log.Warnw("dropping SNMP message", "error", err, "address", remoteAddr(metadata))
+			return
+		}
+		// This must be called after publish to measure the processing
+		// time metric.
+		metrics.Log(data, start)
+	})
+	err = server.Run(ctxtool.FromCanceller(ctx.Cancelation))
+
+	// Ignore error from 'Run' in case shutdown was signaled.
+	if ctxerr := ctx.Cancelation.Err(); ctxerr != nil {
+		err = ctxerr
+	}
+	return err
+}
+
+func remoteAddr(metadata inputsource.NetworkMetadata) string {
+	if metadata.RemoteAddr == nil {
+		return ""
+	}
+	return metadata.RemoteAddr.String()
+}
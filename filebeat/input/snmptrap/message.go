@@ -0,0 +1,397 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// SNMP message versions.
+const (
+	version1  = 0
+	version2c = 1
+	version3  = 3
+)
+
+// PDU types.
+const (
+	pduResponse = 0xa2
+	pduTrapV1   = 0xa4
+	pduInform   = 0xa6
+	pduTrapV2   = 0xa7
+	pduReport   = 0xa8
+)
+
+// SNMPv3 message flags.
+const (
+	flagAuth       = 0x01
+	flagPriv       = 0x02
+	flagReportable = 0x04
+)
+
+const securityModelUSM = 3
+
+// message is a decoded SNMP message.
+type message struct {
+	version int64
+
+	// community is the community of SNMPv1 and SNMPv2c messages.
+	community string
+
+	// SNMPv3 fields.
+	msgID           int64
+	maxSize         int64
+	flags           byte
+	securityModel   int64
+	usm             usmParams
+	contextEngineID []byte
+	contextName     string
+	// encrypted holds the encrypted scoped PDU until it is decrypted.
+	encrypted []byte
+
+	pdu pdu
+}
+
+// usmParams are the User-based Security Model parameters of a message.
+type usmParams struct {
+	engineID   []byte
+	boots      int64
+	time       int64
+	userName   string
+	authParams []byte
+	privParams []byte
+}
+
+type pdu struct {
+	typ byte
+
+	requestID   int64
+	errorStatus int64
+	errorIndex  int64
+
+	// SNMPv1 trap fields.
+	enterprise   oid
+	agentAddr    net.IP
+	genericTrap  int64
+	specificTrap int64
+	timestamp    uint64
+
+	varbinds []varbind
+}
+
+type varbind struct {
+	oid   oid
+	tag   byte
+	value interface{}
+}
+
+// decodeMessage decodes an SNMP message. The scoped PDU of encrypted SNMPv3
+// messages is left in encrypted to be decrypted with decodeScopedPDU.
+func decodeMessage(b []byte) (*message, error) {
+	body, _, err := readExpect(b, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	msg := &message{}
+	msg.version, body, err = readInt(body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch msg.version {
+	case version1, version2c:
+		var community []byte
+		community, body, err = readOctets(body)
+		if err != nil {
+			return nil, err
+		}
+		msg.community = string(community)
+		msg.pdu, err = decodePDU(body)
+		return msg, err
+	case version3:
+		return msg, decodeV3(msg, body)
+	default:
+		return nil, fmt.Errorf("unsupported SNMP version %d", msg.version)
+	}
+}
+
+func decodeV3(msg *message, body []byte) error {
+	global, body, err := readExpect(body, tagSequence)
+	if err != nil {
+		return err
+	}
+	if msg.msgID, global, err = readInt(global); err != nil {
+		return err
+	}
+	if msg.maxSize, global, err = readInt(global); err != nil {
+		return err
+	}
+	flags, global, err := readOctets(global)
+	if err != nil {
+		return err
+	}
+	if len(flags) != 1 {
+		return errors.New("invalid message flags")
+	}
+	msg.flags = flags[0]
+	if msg.flags&flagPriv != 0 && msg.flags&flagAuth == 0 {
+		return errors.New("invalid message flags: privacy without authentication")
+	}
+	if msg.securityModel, _, err = readInt(global); err != nil {
+		return err
+	}
+
+	secParams, body, err := readOctets(body)
+	if err != nil {
+		return err
+	}
+	if msg.securityModel == securityModelUSM {
+		if msg.usm, err = decodeUSMParams(secParams); err != nil {
+			return fmt.Errorf("invalid security parameters: %w", err)
+		}
+	}
+
+	if msg.flags&flagPriv != 0 {
+		msg.encrypted, _, err = readOctets(body)
+		return err
+	}
+	return decodeScopedPDU(msg, body)
+}
+
+func decodeUSMParams(b []byte) (usmParams, error) {
+	var p usmParams
+	body, _, err := readExpect(b, tagSequence)
+	if err != nil {
+		return p, err
+	}
+	if p.engineID, body, err = readOctets(body); err != nil {
+		return p, err
+	}
+	if p.boots, body, err = readInt(body); err != nil {
+		return p, err
+	}
+	if p.time, body, err = readInt(body); err != nil {
+		return p, err
+	}
+	user, body, err := readOctets(body)
+	if err != nil {
+		return p, err
+	}
+	p.userName = string(user)
+	if p.authParams, body, err = readOctets(body); err != nil {
+		return p, err
+	}
+	p.privParams, _, err = readOctets(body)
+	return p, err
+}
+
+// decodeScopedPDU decodes the plaintext scoped PDU of an SNMPv3 message.
+// Trailing bytes, like the padding of decrypted data, are ignored.
+func decodeScopedPDU(msg *message, b []byte) error {
+	body, _, err := readExpect(b, tagSequence)
+	if err != nil {
+		return err
+	}
+	if msg.contextEngineID, body, err = readOctets(body); err != nil {
+		return err
+	}
+	name, body, err := readOctets(body)
+	if err != nil {
+		return err
+	}
+	msg.contextName = string(name)
+	msg.pdu, err = decodePDU(body)
+	return err
+}
+
+func decodePDU(b []byte) (pdu, error) {
+	typ, body, _, err := readTLV(b)
+	if err != nil {
+		return pdu{}, err
+	}
+	p := pdu{typ: typ}
+	if typ == pduTrapV1 {
+		var c []byte
+		if c, body, err = readExpect(body, tagOID); err != nil {
+			return p, err
+		}
+		if p.enterprise, err = parseOID(c); err != nil {
+			return p, err
+		}
+		if c, body, err = readExpect(body, tagIPAddress); err != nil {
+			return p, err
+		}
+		if len(c) != net.IPv4len {
+			return p, errors.New("invalid agent address")
+		}
+		p.agentAddr = net.IP(append([]byte(nil), c...))
+		if p.genericTrap, body, err = readInt(body); err != nil {
+			return p, err
+		}
+		if p.specificTrap, body, err = readInt(body); err != nil {
+			return p, err
+		}
+		if c, body, err = readExpect(body, tagTimeTicks); err != nil {
+			return p, err
+		}
+		if p.timestamp, err = parseUint(c); err != nil {
+			return p, err
+		}
+	} else {
+		if p.requestID, body, err = readInt(body); err != nil {
+			return p, err
+		}
+		if p.errorStatus, body, err = readInt(body); err != nil {
+			return p, err
+		}
+		if p.errorIndex, body, err = readInt(body); err != nil {
+			return p, err
+		}
+	}
+
+	list, _, err := readExpect(body, tagSequence)
+	if err != nil {
+		return p, err
+	}
+	for len(list) != 0 {
+		var vb []byte
+		if vb, list, err = readExpect(list, tagSequence); err != nil {
+			return p, err
+		}
+		v, err := decodeVarbind(vb)
+		if err != nil {
+			return p, err
+		}
+		p.varbinds = append(p.varbinds, v)
+	}
+	return p, nil
+}
+
+func decodeVarbind(b []byte) (varbind, error) {
+	var v varbind
+	c, b, err := readExpect(b, tagOID)
+	if err != nil {
+		return v, err
+	}
+	if v.oid, err = parseOID(c); err != nil {
+		return v, err
+	}
+	v.tag, c, _, err = readTLV(b)
+	if err != nil {
+		return v, err
+	}
+	switch v.tag {
+	case tagInteger:
+		v.value, err = parseInt(c)
+	case tagOctetString, tagOpaque:
+		v.value = append([]byte(nil), c...)
+	case tagNull, tagNoSuchObj, tagNoSuchInst, tagEndOfView:
+	case tagOID:
+		v.value, err = parseOID(c)
+	case tagIPAddress:
+		if len(c) != net.IPv4len {
+			return v, errors.New("invalid IP address value")
+		}
+		v.value = net.IP(append([]byte(nil), c...))
+	case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+		v.value, err = parseUint(c)
+	default:
+		return v, fmt.Errorf("unsupported value type 0x%x of %s", v.tag, v.oid)
+	}
+	return v, err
+}
+
+// encodeCommunityMessage encodes an SNMPv1 or SNMPv2c message.
+func encodeCommunityMessage(version int64, community string, p pdu) []byte {
+	var body []byte
+	body = appendInt(body, tagInteger, version)
+	body = appendTLV(body, tagOctetString, []byte(community))
+	body = appendPDU(body, p)
+	return appendTLV(nil, tagSequence, body)
+}
+
+// encodeScopedPDU encodes the scoped PDU of an SNMPv3 message.
+func encodeScopedPDU(contextEngineID []byte, contextName string, p pdu) []byte {
+	var body []byte
+	body = appendTLV(body, tagOctetString, contextEngineID)
+	body = appendTLV(body, tagOctetString, []byte(contextName))
+	body = appendPDU(body, p)
+	return appendTLV(nil, tagSequence, body)
+}
+
+// encodeV3Message encodes an SNMPv3 message with the given scoped PDU,
+// which is encrypted when msg has the privacy flag.
+func encodeV3Message(msg *message, scopedPDU []byte) []byte {
+	var global []byte
+	global = appendInt(global, tagInteger, msg.msgID)
+	global = appendInt(global, tagInteger, msg.maxSize)
+	global = appendTLV(global, tagOctetString, []byte{msg.flags})
+	global = appendInt(global, tagInteger, msg.securityModel)
+
+	var sec []byte
+	sec = appendTLV(sec, tagOctetString, msg.usm.engineID)
+	sec = appendInt(sec, tagInteger, msg.usm.boots)
+	sec = appendInt(sec, tagInteger, msg.usm.time)
+	sec = appendTLV(sec, tagOctetString, []byte(msg.usm.userName))
+	sec = appendTLV(sec, tagOctetString, msg.usm.authParams)
+	sec = appendTLV(sec, tagOctetString, msg.usm.privParams)
+
+	var body []byte
+	body = appendInt(body, tagInteger, version3)
+	body = appendTLV(body, tagSequence, global)
+	body = appendTLV(body, tagOctetString, appendTLV(nil, tagSequence, sec))
+	if msg.flags&flagPriv != 0 {
+		body = appendTLV(body, tagOctetString, scopedPDU)
+	} else {
+		body = append(body, scopedPDU...)
+	}
+	return appendTLV(nil, tagSequence, body)
+}
+
+func appendPDU(dst []byte, p pdu) []byte {
+	var body []byte
+	body = appendInt(body, tagInteger, p.requestID)
+	body = appendInt(body, tagInteger, p.errorStatus)
+	body = appendInt(body, tagInteger, p.errorIndex)
+	var list []byte
+	for _, v := range p.varbinds {
+		list = appendTLV(list, tagSequence, appendVarbind(nil, v))
+	}
+	body = appendTLV(body, tagSequence, list)
+	return appendTLV(dst, p.typ, body)
+}
+
+func appendVarbind(dst []byte, v varbind) []byte {
+	dst = appendOID(dst, v.oid)
+	switch val := v.value.(type) {
+	case int64:
+		return appendInt(dst, v.tag, val)
+	case uint64:
+		return appendUint(dst, v.tag, val)
+	case []byte:
+		return appendTLV(dst, v.tag, val)
+	case oid:
+		return appendOID(dst, val)
+	case net.IP:
+		return appendTLV(dst, v.tag, val.To4())
+	default:
+		return appendTLV(dst, v.tag, nil)
+	}
+}
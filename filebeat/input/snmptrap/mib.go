@@ -0,0 +1,337 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// builtinMIB defines the object identifiers of the standard traps and of the
+// objects the MIB files commonly build on, so that they can be resolved
+// without loading the SNMPv2-SMI and SNMPv2-MIB files.
+const builtinMIB = `
+internet OBJECT IDENTIFIER ::= { iso org(3) dod(6) 1 }
+directory OBJECT IDENTIFIER ::= { internet 1 }
+mgmt OBJECT IDENTIFIER ::= { internet 2 }
+mib-2 OBJECT IDENTIFIER ::= { mgmt 1 }
+transmission OBJECT IDENTIFIER ::= { mib-2 10 }
+experimental OBJECT IDENTIFIER ::= { internet 3 }
+private OBJECT IDENTIFIER ::= { internet 4 }
+enterprises OBJECT IDENTIFIER ::= { private 1 }
+security OBJECT IDENTIFIER ::= { internet 5 }
+snmpV2 OBJECT IDENTIFIER ::= { internet 6 }
+snmpDomains OBJECT IDENTIFIER ::= { snmpV2 1 }
+snmpProxys OBJECT IDENTIFIER ::= { snmpV2 2 }
+snmpModules OBJECT IDENTIFIER ::= { snmpV2 3 }
+system OBJECT IDENTIFIER ::= { mib-2 1 }
+sysDescr OBJECT-TYPE ::= { system 1 }
+sysObjectID OBJECT-TYPE ::= { system 2 }
+sysUpTime OBJECT-TYPE ::= { system 3 }
+sysContact OBJECT-TYPE ::= { system 4 }
+sysName OBJECT-TYPE ::= { system 5 }
+sysLocation OBJECT-TYPE ::= { system 6 }
+interfaces OBJECT IDENTIFIER ::= { mib-2 2 }
+ifTable OBJECT-TYPE ::= { interfaces 2 }
+ifEntry OBJECT-TYPE ::= { ifTable 1 }
+ifIndex OBJECT-TYPE ::= { ifEntry 1 }
+ifDescr OBJECT-TYPE ::= { ifEntry 2 }
+ifType OBJECT-TYPE ::= { ifEntry 3 }
+ifAdminStatus OBJECT-TYPE ::= { ifEntry 7 }
+ifOperStatus OBJECT-TYPE ::= { ifEntry 8 }
+snmpMIB OBJECT IDENTIFIER ::= { snmpModules 1 }
+snmpMIBObjects OBJECT IDENTIFIER ::= { snmpMIB 1 }
+snmpTrap OBJECT IDENTIFIER ::= { snmpMIBObjects 4 }
+snmpTrapOID OBJECT-TYPE ::= { snmpTrap 1 }
+snmpTrapEnterprise OBJECT-TYPE ::= { snmpTrap 3 }
+snmpTraps OBJECT IDENTIFIER ::= { snmpMIBObjects 5 }
+coldStart NOTIFICATION-TYPE ::= { snmpTraps 1 }
+warmStart NOTIFICATION-TYPE ::= { snmpTraps 2 }
+linkDown NOTIFICATION-TYPE ::= { snmpTraps 3 }
+linkUp NOTIFICATION-TYPE ::= { snmpTraps 4 }
+authenticationFailure NOTIFICATION-TYPE ::= { snmpTraps 5 }
+egpNeighborLoss NOTIFICATION-TYPE ::= { snmpTraps 6 }
+snmpTrapAddress OBJECT-TYPE ::= { snmpCommunityMIBObjects 3 }
+snmpTrapCommunity OBJECT-TYPE ::= { snmpCommunityMIBObjects 4 }
+snmpCommunityMIBObjects OBJECT IDENTIFIER ::= { snmpCommunityMIB 1 }
+snmpCommunityMIB OBJECT IDENTIFIER ::= { snmpModules 18 }
+`
+
+// mibMacros are the SMI macros whose values are object identifiers.
+var mibMacros = map[string]bool{
+	"OBJECT-TYPE":        true,
+	"OBJECT-IDENTITY":    true,
+	"MODULE-IDENTITY":    true,
+	"NOTIFICATION-TYPE":  true,
+	"OBJECT-GROUP":       true,
+	"NOTIFICATION-GROUP": true,
+	"MODULE-COMPLIANCE":  true,
+	"AGENT-CAPABILITIES": true,
+}
+
+// mibTree resolves object identifiers to the names defined by MIB files.
+type mibTree struct {
+	// defs are the definitions of the names, relative to another name.
+	defs map[string]mibDef
+	// names maps dotted object identifiers to names.
+	names map[string]string
+}
+
+type mibDef struct {
+	parent string
+	subIDs []uint32
+}
+
+func newMIBTree() *mibTree {
+	t := &mibTree{defs: make(map[string]mibDef)}
+	t.parse(builtinMIB)
+	return t
+}
+
+// loadMIBs loads the MIB files of paths, which are files or directories of
+// MIB files, and resolves the object identifiers of their definitions.
+func loadMIBs(paths []string, log *logp.Logger) (*mibTree, error) {
+	t := newMIBTree()
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{p}
+		if info.IsDir() {
+			entries, err := os.ReadDir(p)
+			if err != nil {
+				return nil, err
+			}
+			files = files[:0]
+			for _, e := range entries {
+				if e.Type().IsRegular() {
+					files = append(files, filepath.Join(p, e.Name()))
+				}
+			}
+		}
+		for _, f := range files {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			t.parse(string(b))
+		}
+	}
+	t.resolve(log)
+	return t, nil
+}
+
+// parse reads the object identifier definitions of a MIB module.
+func (t *mibTree) parse(text string) {
+	toks := tokenizeMIB(text)
+	for i := 0; i < len(toks)-1; i++ {
+		name := toks[i]
+		if !isMIBValueName(name) {
+			continue
+		}
+		isOID := mibMacros[toks[i+1]] ||
+			toks[i+1] == "OBJECT" && i+2 < len(toks) && toks[i+2] == "IDENTIFIER"
+		isTrap := toks[i+1] == "TRAP-TYPE"
+		if !isOID && !isTrap {
+			continue
+		}
+
+		// Find the value of the definition.
+		j := i + 1
+		enterprise := ""
+		for ; j < len(toks) && toks[j] != "::="; j++ {
+			if toks[j] == "ENTERPRISE" && j+1 < len(toks) {
+				enterprise = toks[j+1]
+			}
+		}
+		if j+1 >= len(toks) {
+			return
+		}
+		if isTrap {
+			// SNMPv1 traps are identified by the enterprise and the
+			// specific trap number, the SNMPv2 notification
+			// identifier is enterprise.0.specific.
+			// Notifications of the same name take precedence.
+			n, err := strconv.ParseUint(toks[j+1], 10, 32)
+			if _, ok := t.defs[name]; !ok && err == nil && enterprise != "" {
+				t.defs[name] = mibDef{parent: enterprise, subIDs: []uint32{0, uint32(n)}}
+			}
+			i = j + 1
+			continue
+		}
+		if toks[j+1] != "{" {
+			i = j
+			continue
+		}
+		end := j + 2
+		for end < len(toks) && toks[end] != "}" {
+			end++
+		}
+		t.define(name, toks[j+2:end])
+		i = end
+	}
+}
+
+// define defines name from the components of its value, like
+// "iso org(3) dod(6) 1". Named components are defined too.
+func (t *mibTree) define(name string, comps []string) {
+	if len(comps) == 0 {
+		return
+	}
+	def := mibDef{}
+	for k := 0; k < len(comps); k++ {
+		c := comps[k]
+		if n, err := strconv.ParseUint(c, 10, 32); err == nil {
+			def.subIDs = append(def.subIDs, uint32(n))
+			continue
+		}
+		if k+3 < len(comps) && comps[k+1] == "(" && comps[k+3] == ")" {
+			n, err := strconv.ParseUint(comps[k+2], 10, 32)
+			if err != nil {
+				return
+			}
+			def.subIDs = append(def.subIDs, uint32(n))
+			t.defs[c] = mibDef{parent: def.parent, subIDs: append([]uint32(nil), def.subIDs...)}
+			k += 3
+			continue
+		}
+		if k != 0 {
+			return
+		}
+		def.parent = c
+	}
+	t.defs[name] = def
+}
+
+// resolve computes the object identifiers of all definitions.
+func (t *mibTree) resolve(log *logp.Logger) {
+	oids := map[string]oid{
+		"ccitt":           {0},
+		"iso":             {1},
+		"joint-iso-ccitt": {2},
+		"joint-iso-itu-t": {2},
+	}
+	var lookup func(name string, depth int) (oid, bool)
+	lookup = func(name string, depth int) (oid, bool) {
+		if o, ok := oids[name]; ok {
+			return o, true
+		}
+		def, ok := t.defs[name]
+		if !ok || depth > 128 {
+			return nil, false
+		}
+		var o oid
+		if def.parent != "" {
+			parent, ok := lookup(def.parent, depth+1)
+			if !ok {
+				return nil, false
+			}
+			o = append(o, parent...)
+		}
+		o = append(o, def.subIDs...)
+		oids[name] = o
+		return o, true
+	}
+
+	t.names = make(map[string]string, len(t.defs))
+	var unresolved []string
+	for name := range t.defs {
+		o, ok := lookup(name, 0)
+		if !ok {
+			unresolved = append(unresolved, name)
+			continue
+		}
+		t.names[o.String()] = name
+	}
+	if len(unresolved) != 0 && log != nil {
+		log.Debugw("MIB object identifiers could not be resolved", "count", len(unresolved), "names", unresolved)
+	}
+}
+
+// name returns the name of an object identifier, with the sub-identifiers
+// following the longest defined prefix, like ifOperStatus.3. It returns an
+// empty string if no prefix is defined.
+func (t *mibTree) name(o oid) string {
+	for n := len(o); n > 0; n-- {
+		name, ok := t.names[o[:n].String()]
+		if !ok {
+			continue
+		}
+		if n == len(o) {
+			return name
+		}
+		return name + "." + o[n:].String()
+	}
+	return ""
+}
+
+func isMIBValueName(s string) bool {
+	return s != "" && unicode.IsLower(rune(s[0]))
+}
+
+// tokenizeMIB splits a MIB module into tokens, skipping comments and quoted
+// strings.
+func tokenizeMIB(text string) []string {
+	var toks []string
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '-' && strings.HasPrefix(text[i:], "--"):
+			// Comments end at the end of the line or at the next "--".
+			i += 2
+			for i < len(text) && text[i] != '\n' {
+				if strings.HasPrefix(text[i:], "--") {
+					i += 2
+					break
+				}
+				i++
+			}
+		case c == '"':
+			end := strings.IndexByte(text[i+1:], '"')
+			if end < 0 {
+				return toks
+			}
+			i += end + 2
+		case strings.HasPrefix(text[i:], "::="):
+			toks = append(toks, "::=")
+			i += 3
+		case isMIBIdentChar(c):
+			start := i
+			for i < len(text) && isMIBIdentChar(text[i]) && !strings.HasPrefix(text[i:], "--") {
+				i++
+			}
+			toks = append(toks, text[start:i])
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		default:
+			toks = append(toks, string(c))
+			i++
+		}
+	}
+	return toks
+}
+
+func isMIBIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMIB = `
+ACME-MIB DEFINITIONS ::= BEGIN
+
+IMPORTS
+    MODULE-IDENTITY, OBJECT-TYPE, NOTIFICATION-TYPE, enterprises
+        FROM SNMPv2-SMI;
+
+acme MODULE-IDENTITY
+    LAST-UPDATED "202401010000Z"
+    ORGANIZATION "ACME"
+    DESCRIPTION  "Test module, a definition in a string
+                  acmeFake OBJECT IDENTIFIER ::= { acme 99 } is ignored."
+    ::= { enterprises 99999 }
+
+acmeObjects OBJECT IDENTIFIER ::= { acme 1 } -- comment ::= { acme 98 }
+acmeTemperature OBJECT-TYPE
+    SYNTAX      Integer32
+    MAX-ACCESS  accessible-for-notify
+    STATUS      current
+    DESCRIPTION "Temperature."
+    ::= { acmeObjects 1 }
+
+acmeNotifications OBJECT IDENTIFIER ::= { acme 0 }
+acmeOverheat NOTIFICATION-TYPE
+    OBJECTS { acmeTemperature }
+    STATUS  current
+    DESCRIPTION "Overheat."
+    ::= { acmeNotifications 1 }
+
+acmeLegacyTrap TRAP-TYPE
+    ENTERPRISE acme
+    VARIABLES { acmeTemperature }
+    ::= 2
+
+END
+`
+
+func TestMIBTree(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ACME-MIB.txt"), []byte(testMIB), 0o600))
+
+	mibs, err := loadMIBs([]string{dir}, nil)
+	require.NoError(t, err)
+
+	for oid, want := range map[string]string{
+		"1.3.6.1.4.1.99999":           "acme",
+		"1.3.6.1.4.1.99999.1.1":       "acmeTemperature",
+		"1.3.6.1.4.1.99999.1.1.0":     "acmeTemperature.0",
+		"1.3.6.1.4.1.99999.0.1":       "acmeOverheat",
+		"1.3.6.1.4.1.99999.0.2":       "acmeLegacyTrap",
+		"1.3.6.1.4.1.99999.99":        "acme.99",
+		"1.3.6.1.4.1.99999.98":        "acme.98",
+		"1.3.6.1.6.3.1.1.5.3":         "linkDown",
+		"1.3.6.1.2.1.2.2.1.8.12":      "ifOperStatus.12",
+		"1.3.6.1.4.1.12345.1":         "enterprises.12345.1",
+		"1.3.6.1.6.3.18.1.3.0":        "snmpTrapAddress.0",
+		"1.3.6.1.6.3.1.1.4.1.0":       "snmpTrapOID.0",
+		"2.25.1":                      "",
+		"1.3.6.1.4.1.99999.1.1.0.1.2": "acmeTemperature.0.1.2",
+	} {
+		o, err := parseOIDString(oid)
+		require.NoError(t, err)
+		assert.Equal(t, want, mibs.name(o), oid)
+	}
+
+	_, err = loadMIBs([]string{filepath.Join(dir, "missing")}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,319 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des" //nolint:gosec // DES is required by the SNMPv3 USM privacy protocol.
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec // MD5 is required by the SNMPv3 USM authentication protocol.
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // SHA-1 is required by the SNMPv3 USM authentication protocol.
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// authProtocol is an SNMPv3 USM authentication protocol.
+type authProtocol struct {
+	hash func() hash.Hash
+	// macLen is the length of the truncated HMAC sent in messages.
+	macLen int
+}
+
+var authProtocols = map[string]authProtocol{
+	"MD5":    {md5.New, 12},
+	"SHA":    {sha1.New, 12},
+	"SHA224": {sha256.New224, 16},
+	"SHA256": {sha256.New, 24},
+	"SHA384": {sha512.New384, 32},
+	"SHA512": {sha512.New, 48},
+}
+
+// privKeyLen is the length of the localized privacy keys of the SNMPv3 USM
+// privacy protocols. DES keys hold the key and the pre-IV.
+var privKeyLen = map[string]int{
+	"DES":    16,
+	"AES":    16,
+	"AES192": 24,
+	"AES256": 32,
+}
+
+// USM statistics reported to senders, from the SNMP-USER-BASED-SM-MIB.
+var (
+	oidUSMStatsNotInTimeWindows = oid{1, 3, 6, 1, 6, 3, 15, 1, 1, 2, 0}
+	oidUSMStatsUnknownEngineIDs = oid{1, 3, 6, 1, 6, 3, 15, 1, 1, 4, 0}
+)
+
+// timeWindow is the maximum difference between the time of a message sent
+// to the local engine and the local engine time.
+const timeWindow = 150
+
+// usmUser is an SNMPv3 user, its keys are not localized.
+type usmUser struct {
+	name    string
+	auth    *authProtocol
+	priv    string
+	authKey []byte
+	privKey []byte
+}
+
+func newUSMUser(c userConfig) (*usmUser, error) {
+	u := &usmUser{name: c.Name}
+	if c.AuthProtocol == "" {
+		return u, nil
+	}
+	auth, ok := authProtocols[strings.ToUpper(c.AuthProtocol)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported auth_protocol %q", c.AuthProtocol)
+	}
+	u.auth = &auth
+	u.authKey = passwordToKey(auth.hash, c.AuthPassword)
+	if c.PrivProtocol != "" {
+		u.priv = strings.ToUpper(c.PrivProtocol)
+		if _, ok := privKeyLen[u.priv]; !ok {
+			return nil, fmt.Errorf("unsupported priv_protocol %q", c.PrivProtocol)
+		}
+		u.privKey = passwordToKey(auth.hash, c.PrivPassword)
+	}
+	return u, nil
+}
+
+// passwordToKey derives a key from a password as described in RFC 3414
+// section A.2.
+func passwordToKey(h func() hash.Hash, password string) []byte {
+	const expandedLen = 1 << 20
+	d := h()
+	buf := make([]byte, 64)
+	for i := 0; i < expandedLen; i += len(buf) {
+		for j := range buf {
+			buf[j] = password[(i+j)%len(password)]
+		}
+		d.Write(buf)
+	}
+	return d.Sum(nil)
+}
+
+// localizeKey localizes a key to an SNMP engine.
+func localizeKey(h func() hash.Hash, key, engineID []byte) []byte {
+	d := h()
+	d.Write(key)
+	d.Write(engineID)
+	d.Write(key)
+	return d.Sum(nil)
+}
+
+// localKeys are the keys of a user localized to an engine.
+type localKeys struct {
+	auth []byte
+	priv []byte
+}
+
+// usm is the User-based Security Model of the local SNMP engine.
+type usm struct {
+	users    map[string]*usmUser
+	engineID []byte
+	// boots is the number of times the local engine was started, the start
+	// time in seconds is used as it increases without persisting it.
+	boots int64
+	start time.Time
+
+	salt             atomic.Uint64
+	unknownEngineIDs atomic.Uint64
+	notInTimeWindows atomic.Uint64
+
+	mu        sync.Mutex
+	localized map[string]localKeys
+}
+
+// maxLocalizedKeys bounds the number of cached localized keys, as senders
+// may use any engine ID.
+const maxLocalizedKeys = 10000
+
+func newUSM(users []*usmUser, engineID []byte, now time.Time) (*usm, error) {
+	u := &usm{
+		users:     make(map[string]*usmUser, len(users)),
+		engineID:  engineID,
+		boots:     now.Unix() & 0x7fffffff,
+		start:     now,
+		localized: make(map[string]localKeys),
+	}
+	for _, user := range users {
+		u.users[user.name] = user
+	}
+	var salt [8]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return nil, err
+	}
+	u.salt.Store(binary.BigEndian.Uint64(salt[:]))
+	return u, nil
+}
+
+// engineTime returns the number of seconds since the local engine started.
+func (u *usm) engineTime(now time.Time) int64 {
+	return int64(now.Sub(u.start) / time.Second)
+}
+
+// keys returns the keys of user localized to engineID.
+func (u *usm) keys(user *usmUser, engineID []byte) localKeys {
+	if user.auth == nil {
+		return localKeys{}
+	}
+	id := user.name + "\x00" + string(engineID)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if k, ok := u.localized[id]; ok {
+		return k
+	}
+	k := localKeys{auth: localizeKey(user.auth.hash, user.authKey, engineID)}
+	if user.privKey != nil {
+		k.priv = localizeKey(user.auth.hash, user.privKey, engineID)
+		// Extend the key for the AES key lengths that are longer than the
+		// hash, following draft-blumenthal-aes-usm.
+		for len(k.priv) < privKeyLen[user.priv] {
+			d := user.auth.hash()
+			d.Write(k.priv)
+			k.priv = append(k.priv, d.Sum(nil)...)
+		}
+	}
+	if len(u.localized) >= maxLocalizedKeys {
+		u.localized = make(map[string]localKeys)
+	}
+	u.localized[id] = k
+	return k
+}
+
+// verify checks the authentication parameters of the raw message b, which
+// were decoded in params.
+func verify(user *usmUser, keys localKeys, b []byte, params usmParams) bool {
+	if len(params.authParams) != user.auth.macLen {
+		return false
+	}
+	// The authentication parameters are a sub slice of b, they are
+	// zeroed to compute the HMAC.
+	off := cap(b) - cap(params.authParams)
+	if off < 0 || off+len(params.authParams) > len(b) {
+		return false
+	}
+	msg := append([]byte(nil), b...)
+	clear(msg[off : off+len(params.authParams)])
+	return hmac.Equal(params.authParams, mac(user, keys, msg))
+}
+
+// sign sets the authentication parameters of the encoded message b.
+func sign(user *usmUser, keys localKeys, b []byte) error {
+	msg, err := decodeMessage(b)
+	if err != nil {
+		return err
+	}
+	authParams := msg.usm.authParams
+	if len(authParams) != user.auth.macLen {
+		return errors.New("invalid authentication parameters placeholder")
+	}
+	copy(authParams, mac(user, keys, b))
+	return nil
+}
+
+func mac(user *usmUser, keys localKeys, msg []byte) []byte {
+	h := hmac.New(user.auth.hash, keys.auth)
+	h.Write(msg)
+	return h.Sum(nil)[:user.auth.macLen]
+}
+
+// decrypt decrypts the scoped PDU of a message.
+func decrypt(user *usmUser, keys localKeys, params usmParams, data []byte) ([]byte, error) {
+	if len(params.privParams) != 8 {
+		return nil, errors.New("invalid privacy parameters")
+	}
+	switch user.priv {
+	case "DES":
+		if len(data)%des.BlockSize != 0 {
+			return nil, errors.New("encrypted data is not a multiple of the block size")
+		}
+		block, err := des.NewCipher(keys.priv[:8])
+		if err != nil {
+			return nil, err
+		}
+		plain := make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, desIV(keys, params.privParams)).CryptBlocks(plain, data)
+		return plain, nil
+	default:
+		block, err := aes.NewCipher(keys.priv[:privKeyLen[user.priv]])
+		if err != nil {
+			return nil, err
+		}
+		plain := make([]byte, len(data))
+		//nolint:staticcheck // CFB is required by RFC 3826.
+		cipher.NewCFBDecrypter(block, aesIV(params)).XORKeyStream(plain, data)
+		return plain, nil
+	}
+}
+
+// encrypt encrypts the scoped PDU of a message, and sets the privacy
+// parameters of params.
+func (u *usm) encrypt(user *usmUser, keys localKeys, params *usmParams, plain []byte) ([]byte, error) {
+	salt := u.salt.Add(1)
+	params.privParams = make([]byte, 8)
+	switch user.priv {
+	case "DES":
+		binary.BigEndian.PutUint32(params.privParams, uint32(params.boots))
+		binary.BigEndian.PutUint32(params.privParams[4:], uint32(salt))
+		block, err := des.NewCipher(keys.priv[:8])
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, (len(plain)+des.BlockSize-1)/des.BlockSize*des.BlockSize)
+		copy(data, plain)
+		cipher.NewCBCEncrypter(block, desIV(keys, params.privParams)).CryptBlocks(data, data)
+		return data, nil
+	default:
+		binary.BigEndian.PutUint64(params.privParams, salt)
+		block, err := aes.NewCipher(keys.priv[:privKeyLen[user.priv]])
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, len(plain))
+		//nolint:staticcheck // CFB is required by RFC 3826.
+		cipher.NewCFBEncrypter(block, aesIV(*params)).XORKeyStream(data, plain)
+		return data, nil
+	}
+}
+
+func desIV(keys localKeys, salt []byte) []byte {
+	iv := make([]byte, des.BlockSize)
+	for i := range iv {
+		iv[i] = keys.priv[8+i] ^ salt[i]
+	}
+	return iv
+}
+
+func aesIV(params usmParams) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint32(iv, uint32(params.boots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(params.time))
+	copy(iv[8:], params.privParams)
+	return iv
+}
@@ -0,0 +1,68 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package snmptrap
+
+import (
+	"crypto/md5"  //nolint:gosec // Test vectors of RFC 3414.
+	"crypto/sha1" //nolint:gosec // Test vectors of RFC 3414.
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLocalization(t *testing.T) {
+	// Test vectors of RFC 3414 section A.3.
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+
+	key := passwordToKey(md5.New, "maplesyrup")
+	assert.Equal(t, "9faf3283884e92834ebc9847d8edd963", hex.EncodeToString(key))
+	assert.Equal(t, "526f5eed9fcce26f8964c2930787d82b", hex.EncodeToString(localizeKey(md5.New, key, engineID)))
+
+	key = passwordToKey(sha1.New, "maplesyrup")
+	assert.Equal(t, "9fb5cc0381497b3793528939ff788d5d79145211", hex.EncodeToString(key))
+	assert.Equal(t, "6695febc9288e36282235fc7151f128497b38f3f", hex.EncodeToString(localizeKey(sha1.New, key, engineID)))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	engineID, _ := hex.DecodeString("80000000050102030405060708")
+	plain := encodeScopedPDU(engineID, "", pdu{typ: pduTrapV2, requestID: 1})
+	for _, priv := range []string{"DES", "AES", "AES192", "AES256"} {
+		t.Run(priv, func(t *testing.T) {
+			user, err := newUSMUser(userConfig{Name: "u", AuthProtocol: "SHA", AuthPassword: "authpassword", PrivProtocol: priv, PrivPassword: "privpassword"})
+			if !assert.NoError(t, err) {
+				return
+			}
+			u := newTestUSM(t, []*usmUser{user}, engineID)
+			keys := u.keys(user, engineID)
+			assert.GreaterOrEqual(t, len(keys.priv), privKeyLen[priv])
+
+			params := usmParams{engineID: engineID, boots: 3, time: 42}
+			data, err := u.encrypt(user, keys, &params, plain)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NotEqual(t, plain, data[:len(plain)])
+			got, err := decrypt(user, keys, params, data)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, plain, got[:len(plain)])
+		})
+	}
+}
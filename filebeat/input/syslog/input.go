@@ -182,31 +182,46 @@ func (p *Input) Wait() {
 }
 
 func GetCbByConfig(cfg config, forwarder *harvester.Forwarder, log *logp.Logger) inputsource.NetworkFunc {
-	switch cfg.Format {
+	parse := eventParser(cfg.Format, cfg.Timezone.Location(), log)
+	return func(data []byte, metadata inputsource.NetworkMetadata) {
+		_ = forwarder.Send(parse(data, metadata))
+	}
+}
 
+// EventParserFunc converts a syslog message to an event.
+type EventParserFunc func(data []byte, metadata inputsource.NetworkMetadata) beat.Event
+
+// NewEventParser returns a function converting syslog messages in the given
+// format, one of rfc3164, rfc5424 or auto, to events. Timestamps without a
+// timezone are in the given timezone.
+func NewEventParser(format string, timezone *time.Location, log *logp.Logger) (EventParserFunc, error) {
+	var f syslogFormat
+	if err := f.Unpack(format); err != nil {
+		return nil, err
+	}
+	return eventParser(f, timezone, log), nil
+}
+
+func eventParser(format syslogFormat, timezone *time.Location, log *logp.Logger) EventParserFunc {
+	switch format {
 	case syslogFormatRFC5424:
-		return func(data []byte, metadata inputsource.NetworkMetadata) {
-			ev := parseAndCreateEvent5424(data, metadata, cfg.Timezone.Location(), log)
-			_ = forwarder.Send(ev)
+		return func(data []byte, metadata inputsource.NetworkMetadata) beat.Event {
+			return parseAndCreateEvent5424(data, metadata, timezone, log)
 		}
 
 	case syslogFormatAuto:
-		return func(data []byte, metadata inputsource.NetworkMetadata) {
-			var ev beat.Event
+		return func(data []byte, metadata inputsource.NetworkMetadata) beat.Event {
 			if IsRFC5424Format(data) {
-				ev = parseAndCreateEvent5424(data, metadata, cfg.Timezone.Location(), log)
-			} else {
-				ev = parseAndCreateEvent3164(data, metadata, cfg.Timezone.Location(), log)
+				return parseAndCreateEvent5424(data, metadata, timezone, log)
 			}
-			_ = forwarder.Send(ev)
+			return parseAndCreateEvent3164(data, metadata, timezone, log)
 		}
 	case syslogFormatRFC3164:
 		break
 	}
 
-	return func(data []byte, metadata inputsource.NetworkMetadata) {
-		ev := parseAndCreateEvent3164(data, metadata, cfg.Timezone.Location(), log)
-		_ = forwarder.Send(ev)
+	return func(data []byte, metadata inputsource.NetworkMetadata) beat.Event {
+		return parseAndCreateEvent3164(data, metadata, timezone, log)
 	}
 }
 
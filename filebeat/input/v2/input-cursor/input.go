@@ -179,15 +179,20 @@ func newInputACKHandler(log *logp.Logger) beat.EventListener {
 	return acker.EventPrivateReporter(func(acked int, private []interface{}) {
 		var n uint
 		var last int
+		var callbacks []func()
 		for i := 0; i < len(private); i++ {
 			current := private[i]
 			if current == nil {
 				continue
 			}
 
-			if _, ok := current.(*updateOp); !ok {
+			op, ok := current.(*updateOp)
+			if !ok {
 				continue
 			}
+			if op.onACK != nil {
+				callbacks = append(callbacks, op.onACK)
+			}
 
 			n++
 			last = i
@@ -197,5 +202,8 @@ func newInputACKHandler(log *logp.Logger) beat.EventListener {
 			return
 		}
 		private[last].(*updateOp).Execute(n)
+		for _, f := range callbacks {
+			f()
+		}
 	})
 }
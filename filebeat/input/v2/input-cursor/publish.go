@@ -33,6 +33,14 @@ type Publisher interface {
 	Publish(event beat.Event, cursor interface{}) error
 }
 
+// UpdateWithACK can be passed as cursor state to Publish to be notified once
+// the event has been acknowledged and the cursor update written to the
+// persistent store. OnACK is called from the ACK handler and must not block.
+type UpdateWithACK struct {
+	Update interface{}
+	OnACK  func()
+}
+
 // cursorPublisher implements the Publisher interface and used internally by the managedInput.
 // When publishing an event with cursor state updates, the cursorPublisher
 // updates the in memory state and create an updateOp that is used to schedule
@@ -56,6 +64,9 @@ type updateOp struct {
 	// state updates to persist
 	timestamp time.Time
 	delta     interface{}
+
+	// onACK is called once the update has been persisted, if set.
+	onACK func()
 }
 
 // Publish publishes an event. Publish returns false if the inputs cancellation context has been marked as done.
@@ -68,10 +79,15 @@ func (c *cursorPublisher) Publish(event beat.Event, cursorUpdate interface{}) er
 		return c.forward(event)
 	}
 
+	var onACK func()
+	if u, ok := cursorUpdate.(UpdateWithACK); ok {
+		cursorUpdate, onACK = u.Update, u.OnACK
+	}
 	op, err := createUpdateOp(c.cursor.store, c.cursor.resource, cursorUpdate)
 	if err != nil {
 		return err
 	}
+	op.onACK = onACK
 
 	event.Private = op
 	return c.forward(event)
@@ -58,6 +58,37 @@ func TestPublish(t *testing.T) {
 		require.Nil(t, actual.Private)
 	})
 
+	t.Run("update with ACK callback is called once the update is persisted", func(t *testing.T) {
+		store := testOpenStore(t, "test", createSampleStore(t, nil))
+		defer store.Release()
+		res := store.Get("test::key")
+		cursor := makeCursor(store, res)
+
+		ackHandler := newInputACKHandler(nil)
+		client := &pubtest.FakeClient{
+			PublishFunc: func(event beat.Event) { ackHandler.AddEvent(event, true) },
+		}
+		publisher := cursorPublisher{nil, client, &cursor}
+
+		var acked []string
+		for _, s := range []string{"first", "second"} {
+			s := s
+			err := publisher.Publish(beat.Event{}, UpdateWithACK{
+				Update: s,
+				OnACK: func() {
+					acked = append(acked, s+":"+storeInSyncSnapshot(store)["test::key"].Cursor.(string))
+				},
+			})
+			require.NoError(t, err)
+		}
+		res.Release()
+		require.Empty(t, acked)
+
+		ackHandler.ACKEvents(2)
+		assert.Equal(t, []string{"first:second", "second:second"}, acked)
+		assert.True(t, res.Finished())
+	})
+
 	t.Run("publish returns error if context has been cancelled", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.TODO())
 		cancel()
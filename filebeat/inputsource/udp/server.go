@@ -18,7 +18,9 @@
 package udp
 
 import (
+	"errors"
 	"net"
+	"sync"
 
 	"github.com/elastic/beats/v7/filebeat/inputsource"
 	"github.com/elastic/beats/v7/filebeat/inputsource/common/dgram"
@@ -35,6 +37,9 @@ type Server struct {
 	config *Config
 
 	localaddress string
+
+	mu   sync.Mutex
+	conn net.PacketConn
 }
 
 // New returns a new UDPServer instance.
@@ -68,10 +73,25 @@ func (u *Server) createConn() (net.PacketConn, error) {
 	}
 
 	u.localaddress = listener.LocalAddr().String()
+	u.mu.Lock()
+	u.conn = listener
+	u.mu.Unlock()
 
 	return listener, err
 }
 
+// WriteTo writes a datagram to addr from the listening socket, it allows
+// answering the datagrams received by the callback.
+func (u *Server) WriteTo(b []byte, addr net.Addr) (int, error) {
+	u.mu.Lock()
+	conn := u.conn
+	u.mu.Unlock()
+	if conn == nil {
+		return 0, errors.New("udp server is not listening")
+	}
+	return conn.WriteTo(b, addr)
+}
+
 func (u *Server) network() string {
 	if u.config.Network != "" {
 		return u.config.Network
@@ -114,3 +114,42 @@ func testReceiveEventFromUDPWithNetwork(t *testing.T, network string) {
 		})
 	}
 }
+
+func TestReplyToUDP(t *testing.T) {
+	config := &Config{
+		Host:           "localhost:0",
+		MaxMessageSize: maxMessageSize,
+		Timeout:        timeout,
+		Network:        networkUDP4,
+	}
+	var s *Server
+	s = New(config, func(message []byte, metadata inputsource.NetworkMetadata) {
+		_, err := s.WriteTo(append([]byte("re: "), message...), metadata.RemoteAddr)
+		assert.NoError(t, err)
+	})
+	err := s.Start()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Stop()
+
+	conn, err := net.Dial(s.network(), s.localaddress)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("Hello"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, conn.SetReadDeadline(time.Now().Add(timeout))) {
+		return
+	}
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "re: Hello", string(buf[:n]))
+}
@@ -57,6 +57,7 @@ type Heartbeat struct {
 	monitorFactory     *monitors.RunnerFactory
 	autodiscover       *autodiscover.Autodiscover
 	replaceStateLoader func(sl monitorstate.StateLoader)
+	localState         *localStateStore
 	trace              tracer.Tracer
 }
 
@@ -85,7 +86,19 @@ func New(b *beat.Beat, rawConfig *conf.C) (beat.Beater, error) {
 
 	// Check if any of these can prevent using states client
 	stateLoader, replaceStateLoader := monitorstate.AtomicStateLoader(monitorstate.NilStateLoader)
-	if b.Config.Output.Name() == "elasticsearch" && !b.Manager.Enabled() {
+	var stateSaver monitorstate.StateSaver = monitorstate.NilStateSaver
+	var localState *localStateStore
+	if parsedConfig.LocalState.Enabled {
+		// The local store takes precedence, it works regardless of the output
+		var err error
+		localState, err = openLocalStateStore(b.Info, parsedConfig.LocalState)
+		if err != nil {
+			trace.Abort()
+			return nil, err
+		}
+		replaceStateLoader(localState.Load)
+		stateSaver = localState.Save
+	} else if b.Config.Output.Name() == "elasticsearch" && !b.Manager.Enabled() {
 		// Connect to ES and setup the State loader if the output is not managed by agent
 		// Note this, intentionally, blocks until connected or max attempts reached
 		esClient, err := makeESClient(context.TODO(), b.Config.Output.Config(), 3, 2*time.Second)
@@ -110,6 +123,9 @@ func New(b *beat.Beat, rawConfig *conf.C) (beat.Beater, error) {
 	}
 	location, err := time.LoadLocation(schedLocationName)
 	if err != nil {
+		if localState != nil {
+			localState.Close()
+		}
 		return nil, err
 	}
 	jobConfig := parsedConfig.Jobs
@@ -125,12 +141,14 @@ func New(b *beat.Beat, rawConfig *conf.C) (beat.Beater, error) {
 		config:             parsedConfig,
 		scheduler:          sched,
 		replaceStateLoader: replaceStateLoader,
+		localState:         localState,
 		// monitorFactory is the factory used for creating all monitor instances,
 		// wiring them up to everything needed to actually execute.
 		monitorFactory: monitors.NewFactory(monitors.FactoryParams{
 			BeatInfo:              b.Info,
 			AddTask:               sched.Add,
 			StateLoader:           stateLoader,
+			StateSaver:            stateSaver,
 			PluginsReg:            plugin.GlobalPluginsReg,
 			PipelineClientFactory: pipelineClientFactory,
 			BeatRunFrom:           parsedConfig.RunFrom,
@@ -150,6 +168,11 @@ func (bt *Heartbeat) Run(b *beat.Beat) error {
 	bt.trace.Start()
 	defer bt.trace.Close()
 
+	if bt.localState != nil {
+		// Deferred early so it runs after all monitors have stopped
+		defer bt.localState.Close()
+	}
+
 	// Adapt local pipeline to synchronized mode if run_once is enabled
 	pipeline := b.Publisher
 	var pipelineWrapper monitors.PipelineWrapper = &monitors.NoopPipelineWrapper{}
@@ -265,7 +288,8 @@ func (bt *Heartbeat) RunCentralMgmtMonitors(b *beat.Beat) {
 	// Register output reloader for managed outputs
 	b.OutputConfigReloader = reload.ReloadableFunc(func(r *reload.ConfigWithMeta) error {
 		// Do not return error here, it will prevent libbeat output from processing the same event
-		if r == nil {
+		// States are not loaded from ES if persisted locally
+		if r == nil || bt.localState != nil {
 			return nil
 		}
 		outCfg := conf.Namespace{}
@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package beater
+
+import (
+	"fmt"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+
+	"github.com/elastic/beats/v7/heartbeat/config"
+	"github.com/elastic/beats/v7/heartbeat/monitors/wrappers/monitorstate"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+)
+
+// localStateStore bundles the memlog registry backing monitor states with the
+// store handed out to monitors.
+type localStateStore struct {
+	registry *statestore.Registry
+	*monitorstate.LocalStore
+}
+
+// openLocalStateStore opens the memlog based monitor state store in the data path.
+func openLocalStateStore(info beat.Info, cfg config.LocalState) (*localStateStore, error) {
+	logger := logp.NewLogger("monitorstate")
+	root := paths.Resolve(paths.Data, cfg.Path)
+	reg, err := memlog.New(logger, memlog.Settings{
+		Root:     root,
+		FileMode: cfg.Permissions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not open local state registry at %s: %w", root, err)
+	}
+
+	registry := statestore.NewRegistry(reg)
+	store, err := registry.Get(info.Beat)
+	if err != nil {
+		registry.Close()
+		return nil, fmt.Errorf("could not access local state store: %w", err)
+	}
+
+	logger.Infof("persisting monitor states locally at %s", root)
+	return &localStateStore{
+		registry:   registry,
+		LocalStore: monitorstate.NewLocalStore(store),
+	}, nil
+}
+
+// Close releases the store and waits for the registry to be flushed.
+func (s *localStateStore) Close() error {
+	if err := s.LocalStore.Close(); err != nil {
+		return err
+	}
+	return s.registry.Close()
+}
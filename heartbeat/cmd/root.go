@@ -31,6 +31,7 @@ import (
 	// Import packages that need to register themselves.
 	_ "github.com/elastic/beats/v7/heartbeat/monitors/active/http"
 	_ "github.com/elastic/beats/v7/heartbeat/monitors/active/icmp"
+	_ "github.com/elastic/beats/v7/heartbeat/monitors/active/ssh"
 	_ "github.com/elastic/beats/v7/heartbeat/monitors/active/tcp"
 
 	// include all heartbeat specific autodiscovery builders
@@ -49,6 +49,7 @@ type Config struct {
 	Jobs           map[string]*JobLimit `config:"jobs"`
 	RunFrom        *LocationWithID      `config:"run_from"`
 	SocketTrace    *SocketTrace         `config:"socket_trace"`
+	LocalState     LocalState           `config:"local_state"`
 }
 
 type JobLimit struct {
@@ -70,7 +71,7 @@ func DefaultConfig() *Config {
 	// Read the env key SYNTHETICS_LIMIT_{TYPE} for each type of monitor to set scaling limits
 	// hard coded list of types to avoid cycles in current plugin system.
 	// TODO: refactor plugin system to DRY this up
-	for _, t := range []string{"http", "tcp", "icmp", "browser"} {
+	for _, t := range []string{"http", "tcp", "icmp", "ssh", "browser"} {
 		envKey := fmt.Sprintf("SYNTHETICS_LIMIT_%s", strings.ToUpper(t))
 		if limitStr := os.Getenv(envKey); limitStr != "" {
 			tLimitVal, err := strconv.ParseInt(limitStr, 10, 64)
@@ -85,6 +86,10 @@ func DefaultConfig() *Config {
 
 	return &Config{
 		Jobs: limits,
+		LocalState: LocalState{
+			Path:        "monitor_state",
+			Permissions: 0600,
+		},
 	}
 }
 
@@ -92,3 +97,11 @@ type SocketTrace struct {
 	Path string        `config:"path"`
 	Wait time.Duration `config:"wait"`
 }
+
+// LocalState configures persisting monitor states to the local data path, so that
+// they can be restored on restart without querying Elasticsearch.
+type LocalState struct {
+	Enabled     bool        `config:"enabled"`
+	Path        string      `config:"path"`
+	Permissions os.FileMode `config:"file_permissions"`
+}
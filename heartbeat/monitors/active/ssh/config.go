@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ssh
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/heartbeat/monitors"
+	"github.com/elastic/elastic-agent-libs/transport"
+)
+
+type config struct {
+	// check all ports if host does not contain port
+	Hosts []string `config:"hosts" validate:"required"`
+	Ports []uint16 `config:"ports"`
+
+	Mode monitors.IPSettings `config:",inline"`
+
+	Socks5 transport.ProxyConfig `config:",inline"`
+
+	Timeout time.Duration `config:"timeout"`
+
+	// HostKey pins the accepted host key fingerprints
+	HostKey hostKeyConfig `config:"host_key"`
+
+	Auth authConfig `config:"auth"`
+
+	Check checkConfig `config:"check"`
+}
+
+type hostKeyConfig struct {
+	// Fingerprints in the SHA256:base64 format printed by `ssh-keygen -l`.
+	Fingerprints []string `config:"fingerprints"`
+}
+
+type authConfig struct {
+	Username string `config:"username"`
+	// PrivateKey holds the PEM encoded key, usually referenced from the keystore.
+	PrivateKey string `config:"private_key"`
+	Passphrase string `config:"passphrase"`
+	Password   string `config:"password"`
+}
+
+type checkConfig struct {
+	// Banner is a substring expected in the server identification banner.
+	Banner   string `config:"banner"`
+	Command  string `config:"command"`
+	ExitCode int    `config:"exit_code"`
+}
+
+func defaultConfig() config {
+	return config{
+		Ports:   []uint16{22},
+		Timeout: 16 * time.Second,
+		Mode:    monitors.DefaultIPSettings,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.Socks5.URL != "" {
+		if c.Mode.Mode != monitors.PingAny && !c.Socks5.LocalResolve {
+			return errors.New("ping all ips only supported if proxy_use_local_resolver is enabled`")
+		}
+	}
+
+	for _, fp := range c.HostKey.Fingerprints {
+		if !strings.HasPrefix(fp, fingerprintPrefix) {
+			return errors.New("host_key.fingerprints must be SHA256 fingerprints, e.g. SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8")
+		}
+	}
+
+	if c.Auth.enabled() && c.Auth.Username == "" {
+		return errors.New("auth.username is required when authenticating")
+	}
+	if c.Check.Command != "" && !c.Auth.enabled() {
+		return errors.New("check.command requires auth.private_key or auth.password to be set")
+	}
+
+	return nil
+}
+
+func (a authConfig) enabled() bool {
+	return a.PrivateKey != "" || a.Password != ""
+}
@@ -0,0 +1,399 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/elastic/beats/v7/heartbeat/eventext"
+	"github.com/elastic/beats/v7/heartbeat/look"
+	"github.com/elastic/beats/v7/heartbeat/monitors"
+	"github.com/elastic/beats/v7/heartbeat/monitors/active/dialchain"
+	"github.com/elastic/beats/v7/heartbeat/monitors/jobs"
+	"github.com/elastic/beats/v7/heartbeat/monitors/plugin"
+	"github.com/elastic/beats/v7/heartbeat/monitors/wrappers/wraputil"
+	"github.com/elastic/beats/v7/heartbeat/reason"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func init() {
+	plugin.Register("ssh", create, "synthetics/ssh")
+}
+
+var debugf = logp.MakeDebug("ssh")
+
+const fingerprintPrefix = "SHA256:"
+
+var (
+	errHostKeyMismatch = errors.New("host key fingerprint does not match any pinned fingerprint")
+	errBannerMismatch  = errors.New("server banner mismatch")
+	errExitCode        = errors.New("unexpected command exit code")
+)
+
+func create(
+	name string,
+	cfg *conf.C,
+) (p plugin.Plugin, err error) {
+	return createWithResolver(cfg, monitors.NewStdResolver())
+}
+
+func createWithResolver(
+	cfg *conf.C,
+	resolver monitors.Resolver,
+) (p plugin.Plugin, err error) {
+	jf, err := newJobFactory(cfg, resolver)
+	if err != nil {
+		return plugin.Plugin{}, err
+	}
+
+	js, err := jf.makeJobs()
+	if err != nil {
+		return plugin.Plugin{}, err
+	}
+
+	return plugin.Plugin{Jobs: js, Endpoints: len(js)}, nil
+}
+
+// jobFactory holds the parsed configuration shared by all endpoint jobs.
+type jobFactory struct {
+	config      config
+	authMethods []ssh.AuthMethod
+	endpoints   []*url.URL
+	resolver    monitors.Resolver
+}
+
+func newJobFactory(commonCfg *conf.C, resolver monitors.Resolver) (*jobFactory, error) {
+	jf := &jobFactory{config: defaultConfig(), resolver: resolver}
+	if err := commonCfg.Unpack(&jf.config); err != nil {
+		return nil, err
+	}
+
+	var err error
+	jf.authMethods, err = makeAuthMethods(jf.config.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	jf.endpoints, err = makeEndpoints(jf.config.Hosts, jf.config.Ports)
+	if err != nil {
+		return nil, err
+	}
+
+	return jf, nil
+}
+
+func makeAuthMethods(cfg authConfig) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if cfg.PrivateKey != "" {
+		var (
+			signer ssh.Signer
+			err    error
+		)
+		if cfg.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(cfg.PrivateKey), []byte(cfg.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(cfg.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse auth.private_key: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		methods = append(methods, ssh.Password(cfg.Password))
+	}
+	return methods, nil
+}
+
+// makeEndpoints creates one ssh:// URL per host and port.
+func makeEndpoints(hosts []string, ports []uint16) ([]*url.URL, error) {
+	var endpoints []*url.URL
+	for _, h := range hosts {
+		h = strings.TrimPrefix(h, "ssh://")
+		if host, port, err := net.SplitHostPort(h); err == nil {
+			if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+				return nil, fmt.Errorf("invalid port in ssh host '%s': %w", h, err)
+			}
+			endpoints = append(endpoints, &url.URL{Scheme: "ssh", Host: net.JoinHostPort(host, port)})
+			continue
+		}
+		if h == "" {
+			return nil, errors.New("empty ssh host")
+		}
+		for _, port := range ports {
+			endpoints = append(endpoints, &url.URL{Scheme: "ssh", Host: net.JoinHostPort(h, strconv.Itoa(int(port)))})
+		}
+	}
+	return endpoints, nil
+}
+
+// makeJobs returns the actual schedulable jobs for this monitor.
+func (jf *jobFactory) makeJobs() ([]jobs.Job, error) {
+	var js []jobs.Job
+	for _, endpointURL := range jf.endpoints {
+		endpointURL := endpointURL
+		var job jobs.Job
+		if jf.config.Socks5.URL != "" && !jf.config.Socks5.LocalResolve {
+			// let the proxy resolve the hostname
+			job = jobs.MakeSimpleJob(func(event *beat.Event) error {
+				return jf.check(event, endpointURL.Host, endpointURL)
+			})
+		} else {
+			var err error
+			job, err = monitors.MakeByHostJob(
+				endpointURL.Hostname(),
+				jf.config.Mode,
+				jf.resolver,
+				monitors.MakePingIPFactory(
+					func(event *beat.Event, ip *net.IPAddr) error {
+						return jf.check(event, net.JoinHostPort(ip.String(), endpointURL.Port()), endpointURL)
+					}))
+			if err != nil {
+				return nil, err
+			}
+		}
+		js = append(js, wraputil.WithURLField(endpointURL, job))
+	}
+	return js, nil
+}
+
+// check connects to dialAddr and runs the SSH checks. canonicalURL is used
+// as the address reported to the host key callback.
+func (jf *jobFactory) check(event *beat.Event, dialAddr string, canonicalURL *url.URL) error {
+	dc := &dialchain.DialerChain{
+		Net: dialchain.CreateNetDialer(jf.config.Timeout),
+	}
+	if jf.config.Socks5.URL != "" {
+		dc.AddLayer(dialchain.SOCKS5Layer(&jf.config.Socks5))
+	}
+	dc.AddLayer(dialchain.ConstAddrLayer(dialAddr))
+
+	dialer, err := dc.Build(event)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dialer.Dial("tcp", dialAddr)
+	if err != nil {
+		debugf("dial failed with: %v", err)
+		return reason.IOFailed(err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(jf.config.Timeout)); err != nil {
+		return reason.IOFailed(err)
+	}
+
+	return jf.checkConn(event, &bannerConn{Conn: conn}, canonicalURL.Host)
+}
+
+// checkConn performs the handshake, authentication and command checks over an
+// established connection.
+func (jf *jobFactory) checkConn(event *beat.Event, conn *bannerConn, addr string) error {
+	var (
+		hostKeyMtx  sync.Mutex
+		hostKey     ssh.PublicKey
+		hostKeyErr  error
+		handshakeAt time.Time
+	)
+	clientCfg := &ssh.ClientConfig{
+		User: jf.config.Auth.Username,
+		Auth: jf.authMethods,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			hostKeyMtx.Lock()
+			defer hostKeyMtx.Unlock()
+			hostKey = key
+			handshakeAt = time.Now()
+			hostKeyErr = jf.verifyHostKey(key)
+			return hostKeyErr
+		},
+	}
+
+	start := time.Now()
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientCfg)
+	end := time.Now()
+
+	hostKeyMtx.Lock()
+	fields := mapstr.M{}
+	if banner := conn.Banner(); banner != "" {
+		_, _ = fields.Put("server.banner", banner)
+	}
+	if hostKey != nil {
+		_, _ = fields.Put("host_key.type", hostKey.Type())
+		_, _ = fields.Put("host_key.fingerprint", ssh.FingerprintSHA256(hostKey))
+		_, _ = fields.Put("rtt.handshake", look.RTT(handshakeAt.Sub(start)))
+	}
+	handshakeDone := hostKey != nil && hostKeyErr == nil
+	hostKeyMtx.Unlock()
+
+	defer func() {
+		eventext.MergeEventFields(event, mapstr.M{"ssh": fields})
+	}()
+
+	if err := jf.verifyBanner(conn.Banner()); err != nil {
+		if sshConn != nil {
+			sshConn.Close()
+		}
+		return reason.MakeValidateError(err)
+	}
+
+	if hostKeyErr != nil {
+		return reason.MakeValidateError(hostKeyErr)
+	}
+
+	if !jf.config.Auth.enabled() {
+		if sshConn != nil {
+			sshConn.Close()
+		}
+		// Without credentials the server is expected to reject the 'none' auth method,
+		// reaching that point is proof of a successful handshake.
+		if !handshakeDone {
+			debugf("ssh handshake failed with: %v", err)
+			return reason.IOFailed(err)
+		}
+		return nil
+	}
+
+	if err != nil {
+		if handshakeDone {
+			_, _ = fields.Put("rtt.auth", look.RTT(end.Sub(handshakeAt)))
+			return reason.MakeValidateError(fmt.Errorf("ssh authentication failed: %w", err))
+		}
+		debugf("ssh handshake failed with: %v", err)
+		return reason.IOFailed(err)
+	}
+	_, _ = fields.Put("rtt.auth", look.RTT(end.Sub(handshakeAt)))
+
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	if jf.config.Check.Command == "" {
+		return nil
+	}
+	return jf.runCommand(client, fields)
+}
+
+func (jf *jobFactory) runCommand(client *ssh.Client, fields mapstr.M) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return reason.IOFailed(fmt.Errorf("could not open ssh session: %w", err))
+	}
+	defer session.Close()
+
+	start := time.Now()
+	err = session.Run(jf.config.Check.Command)
+	_, _ = fields.Put("rtt.command", look.RTT(time.Since(start)))
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) {
+			return reason.IOFailed(fmt.Errorf("could not run command: %w", err))
+		}
+		exitCode = exitErr.ExitStatus()
+	}
+	_, _ = fields.Put("command.exit_code", exitCode)
+
+	if exitCode != jf.config.Check.ExitCode {
+		return reason.MakeValidateError(fmt.Errorf("%w: expected %d, got %d", errExitCode, jf.config.Check.ExitCode, exitCode))
+	}
+	return nil
+}
+
+func (jf *jobFactory) verifyHostKey(key ssh.PublicKey) error {
+	pinned := jf.config.HostKey.Fingerprints
+	if len(pinned) == 0 {
+		return nil
+	}
+	fingerprint := ssh.FingerprintSHA256(key)
+	for _, fp := range pinned {
+		if fp == fingerprint {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: got %s", errHostKeyMismatch, fingerprint)
+}
+
+func (jf *jobFactory) verifyBanner(banner string) error {
+	expected := jf.config.Check.Banner
+	if expected == "" || strings.Contains(banner, expected) {
+		return nil
+	}
+	return fmt.Errorf("%w: expected '%s' in '%s'", errBannerMismatch, expected, banner)
+}
+
+// maxBannerLen bounds the data kept while looking for the identification
+// string, servers may send other lines before it.
+const maxBannerLen = 8 * 1024
+
+// bannerConn records the SSH identification string sent by the server, which
+// x/crypto/ssh does not expose if the handshake fails.
+type bannerConn struct {
+	net.Conn
+	buf    []byte
+	banner string
+	done   bool
+}
+
+func (c *bannerConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if !c.done && n > 0 {
+		c.record(p[:n])
+	}
+	return n, err
+}
+
+func (c *bannerConn) record(data []byte) {
+	c.buf = append(c.buf, data...)
+	for {
+		idx := bytes.IndexByte(c.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := strings.TrimRight(string(c.buf[:idx]), "\r")
+		c.buf = c.buf[idx+1:]
+		if strings.HasPrefix(line, "SSH-") {
+			c.banner = line
+			c.done = true
+			c.buf = nil
+			return
+		}
+	}
+	if len(c.buf) > maxBannerLen {
+		c.done = true
+		c.buf = nil
+	}
+}
+
+// Banner returns the server identification string, if received.
+func (c *bannerConn) Banner() string {
+	return c.banner
+}
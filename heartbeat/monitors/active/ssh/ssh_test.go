@@ -0,0 +1,296 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+
+	"github.com/elastic/beats/v7/heartbeat/monitors/stdfields"
+	"github.com/elastic/beats/v7/heartbeat/monitors/wrappers"
+	"github.com/elastic/beats/v7/heartbeat/scheduler/schedule"
+	"github.com/elastic/beats/v7/libbeat/beat"
+)
+
+const testBanner = "SSH-2.0-heartbeat_test"
+
+// testServer is a minimal in-process SSH server accepting a single client key
+// and executing `exit N` commands.
+type testServer struct {
+	addr       string
+	hostKey    ssh.Signer
+	clientKey  string
+	listener   net.Listener
+	serverConf *ssh.ServerConfig
+}
+
+func newTestServer(t *testing.T) *testServer {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	require.NoError(t, err)
+
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	authorized, err := ssh.NewPublicKey(clientPub)
+	require.NoError(t, err)
+	pemBlock, err := ssh.MarshalPrivateKey(clientPriv, "")
+	require.NoError(t, err)
+
+	serverConf := &ssh.ServerConfig{
+		ServerVersion: testBanner,
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errUnauthorized
+		},
+	}
+	serverConf.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &testServer{
+		addr:       l.Addr().String(),
+		hostKey:    hostKey,
+		clientKey:  string(pem.EncodeToMemory(pemBlock)),
+		listener:   l,
+		serverConf: serverConf,
+	}
+	t.Cleanup(func() { l.Close() })
+	go srv.serve()
+	return srv
+}
+
+var errUnauthorized = errors.New("unauthorized")
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.serverConf)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			_ = newCh.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer ch.Close()
+			for req := range chReqs {
+				if req.Type != "exec" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				// payload is a length prefixed command string
+				cmd := string(req.Payload[4:])
+				code, _ := strconv.Atoi(strings.TrimPrefix(cmd, "exit "))
+				_ = req.Reply(true, nil)
+				status := make([]byte, 4)
+				binary.BigEndian.PutUint32(status, uint32(code))
+				_, _ = ch.SendRequest("exit-status", false, status)
+				return
+			}
+		}()
+	}
+}
+
+func runSSHCheck(t *testing.T, cfgMap mapstr.M) *beat.Event {
+	cfg, err := conf.NewConfigFrom(cfgMap)
+	require.NoError(t, err)
+
+	p, err := create("ssh", cfg)
+	require.NoError(t, err)
+	require.Equal(t, 1, p.Endpoints)
+
+	sched := schedule.MustParse("@every 1s")
+	job := wrappers.WrapCommon(p.Jobs, stdfields.StdMonitorFields{ID: "test", Type: "ssh", Schedule: sched, Timeout: 1}, nil, nil)[0]
+
+	event := &beat.Event{}
+	_, err = job(event)
+	require.NoError(t, err)
+
+	return event
+}
+
+func requireField(t *testing.T, event *beat.Event, key string, expected interface{}) {
+	t.Helper()
+	v, err := event.GetValue(key)
+	require.NoError(t, err, key)
+	require.Equal(t, expected, v, key)
+}
+
+func requireStatus(t *testing.T, event *beat.Event, status string) {
+	t.Helper()
+	requireField(t, event, "monitor.status", status)
+}
+
+func TestSSHHandshake(t *testing.T) {
+	srv := newTestServer(t)
+	fingerprint := ssh.FingerprintSHA256(srv.hostKey.PublicKey())
+
+	event := runSSHCheck(t, mapstr.M{
+		"hosts":                 srv.addr,
+		"host_key.fingerprints": []string{fingerprint},
+		"check.banner":          "heartbeat_test",
+	})
+
+	requireStatus(t, event, "up")
+	requireField(t, event, "ssh.server.banner", testBanner)
+	requireField(t, event, "ssh.host_key.fingerprint", fingerprint)
+	requireField(t, event, "ssh.host_key.type", ssh.KeyAlgoED25519)
+	requireField(t, event, "url.scheme", "ssh")
+	_, err := event.GetValue("ssh.rtt.handshake.us")
+	require.NoError(t, err)
+	_, err = event.GetValue("ssh.rtt.auth")
+	require.Error(t, err)
+}
+
+func TestSSHHostKeyMismatch(t *testing.T) {
+	srv := newTestServer(t)
+
+	event := runSSHCheck(t, mapstr.M{
+		"hosts":                 srv.addr,
+		"host_key.fingerprints": []string{"SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"},
+	})
+
+	requireStatus(t, event, "down")
+	requireField(t, event, "ssh.host_key.fingerprint", ssh.FingerprintSHA256(srv.hostKey.PublicKey()))
+	msg, err := event.GetValue("error.message")
+	require.NoError(t, err)
+	require.Contains(t, msg, errHostKeyMismatch.Error())
+}
+
+func TestSSHBannerMismatch(t *testing.T) {
+	srv := newTestServer(t)
+
+	event := runSSHCheck(t, mapstr.M{
+		"hosts":        srv.addr,
+		"check.banner": "OpenSSH",
+	})
+
+	requireStatus(t, event, "down")
+	requireField(t, event, "ssh.server.banner", testBanner)
+}
+
+func TestSSHAuthAndCommand(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, tc := range []struct {
+		name     string
+		command  string
+		exitCode int
+		status   string
+	}{
+		{"auth only", "", 0, "up"},
+		{"command succeeds", "exit 0", 0, "up"},
+		{"expected failure", "exit 3", 3, "up"},
+		{"unexpected exit code", "exit 1", 0, "down"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event := runSSHCheck(t, mapstr.M{
+				"hosts":            srv.addr,
+				"auth.username":    "heartbeat",
+				"auth.private_key": srv.clientKey,
+				"check.command":    tc.command,
+				"check.exit_code":  tc.exitCode,
+			})
+
+			requireStatus(t, event, tc.status)
+			_, err := event.GetValue("ssh.rtt.auth.us")
+			require.NoError(t, err)
+			if tc.command != "" {
+				code, _ := strconv.Atoi(strings.TrimPrefix(tc.command, "exit "))
+				requireField(t, event, "ssh.command.exit_code", code)
+				_, err = event.GetValue("ssh.rtt.command.us")
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSSHAuthFailure(t *testing.T) {
+	srv := newTestServer(t)
+	other := newTestServer(t)
+
+	event := runSSHCheck(t, mapstr.M{
+		"hosts":            srv.addr,
+		"auth.username":    "heartbeat",
+		"auth.private_key": other.clientKey,
+	})
+
+	requireStatus(t, event, "down")
+	msg, err := event.GetValue("error.message")
+	require.NoError(t, err)
+	require.Contains(t, msg, "authentication failed")
+}
+
+func TestSSHConfigValidation(t *testing.T) {
+	for name, cfgMap := range map[string]mapstr.M{
+		"command without auth":  {"hosts": "localhost", "check.command": "true"},
+		"auth without username": {"hosts": "localhost", "auth.password": "secret"},
+		"bad fingerprint":       {"hosts": "localhost", "host_key.fingerprints": []string{"MD5:aa:bb"}},
+		"bad private key":       {"hosts": "localhost", "auth.username": "a", "auth.private_key": "nope"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := conf.NewConfigFrom(cfgMap)
+			require.NoError(t, err)
+			_, err = create("ssh", cfg)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestMakeEndpoints(t *testing.T) {
+	endpoints, err := makeEndpoints([]string{"bastion", "ssh://other:2222", "[::1]:22"}, []uint16{22, 2200})
+	require.NoError(t, err)
+
+	var hosts []string
+	for _, e := range endpoints {
+		hosts = append(hosts, e.String())
+	}
+	require.Equal(t, []string{"ssh://bastion:22", "ssh://bastion:2200", "ssh://other:2222", "ssh://[::1]:22"}, hosts)
+}
@@ -47,6 +47,7 @@ type RunnerFactory struct {
 	info                  beat.Info
 	addTask               scheduler.AddTask
 	stateLoader           monitorstate.StateLoader
+	stateSaver            monitorstate.StateSaver
 	byId                  map[string]*Monitor
 	mtx                   *sync.Mutex
 	pluginsReg            *plugin.PluginsReg
@@ -80,6 +81,7 @@ type FactoryParams struct {
 	BeatInfo              beat.Info
 	AddTask               scheduler.AddTask
 	StateLoader           monitorstate.StateLoader
+	StateSaver            monitorstate.StateSaver
 	PluginsReg            *plugin.PluginsReg
 	PipelineClientFactory PipelineClientFactory
 	BeatRunFrom           *config.LocationWithID
@@ -97,6 +99,7 @@ func NewFactory(fp FactoryParams) *RunnerFactory {
 		pipelineClientFactory: fp.PipelineClientFactory,
 		beatLocation:          fp.BeatRunFrom,
 		stateLoader:           fp.StateLoader,
+		stateSaver:            fp.StateSaver,
 	}
 }
 
@@ -178,7 +181,7 @@ func (f *RunnerFactory) Create(p beat.Pipeline, c *conf.C) (cfgfile.Runner, erro
 		}
 	}
 
-	monitor, err := newMonitor(c, f.pluginsReg, pc, f.addTask, f.stateLoader, safeStop)
+	monitor, err := newMonitor(c, f.pluginsReg, pc, f.addTask, f.stateLoader, f.stateSaver, safeStop)
 	if err != nil {
 		return nil, fmt.Errorf("factory could not create monitor: %w", err)
 	}
@@ -33,6 +33,7 @@ import (
 	"github.com/elastic/beats/v7/heartbeat/monitors/stdfields"
 	"github.com/elastic/beats/v7/heartbeat/monitors/wrappers"
 	"github.com/elastic/beats/v7/heartbeat/scheduler"
+	"github.com/elastic/beats/v7/heartbeat/scheduler/schedule"
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/management/status"
 )
@@ -86,7 +87,7 @@ func (m *Monitor) String() string {
 }
 
 func checkMonitorConfig(config *conf.C, registrar *plugin.PluginsReg) error {
-	_, err := newMonitor(config, registrar, nil, nil, monitorstate.NilStateLoader, nil)
+	_, err := newMonitor(config, registrar, nil, nil, monitorstate.NilStateLoader, monitorstate.NilStateSaver, nil)
 
 	return err
 }
@@ -99,9 +100,10 @@ func newMonitor(
 	pubClient beat.Client,
 	taskAdder scheduler.AddTask,
 	stateLoader monitorstate.StateLoader,
+	stateSaver monitorstate.StateSaver,
 	onStop func(*Monitor),
 ) (*Monitor, error) {
-	m, err := newMonitorUnsafe(config, registrar, pubClient, taskAdder, stateLoader, onStop)
+	m, err := newMonitorUnsafe(config, registrar, pubClient, taskAdder, stateLoader, stateSaver, onStop)
 	if m != nil && err != nil {
 		m.Stop()
 	}
@@ -116,6 +118,7 @@ func newMonitorUnsafe(
 	pubClient beat.Client,
 	addTask scheduler.AddTask,
 	stateLoader monitorstate.StateLoader,
+	stateSaver monitorstate.StateSaver,
 	onStop func(*Monitor),
 ) (*Monitor, error) {
 	// Extract just the Id, Type, and Enabled fields from the config
@@ -141,7 +144,7 @@ func newMonitorUnsafe(
 		config:              config,
 		stats:               pluginFactory.Stats,
 		state:               MON_INIT,
-		monitorStateTracker: monitorstate.NewTracker(stateLoader, false),
+		monitorStateTracker: monitorstate.NewTracker(stateLoader, stateSaver, false),
 	}
 
 	if m.stdFields.ID == "" {
@@ -164,7 +167,7 @@ func newMonitorUnsafe(
 
 	var wrappedJobs []jobs.Job
 	if err == nil {
-		wrappedJobs = wrappers.WrapCommon(p.Jobs, m.stdFields, stateLoader)
+		wrappedJobs = wrappers.WrapCommon(p.Jobs, m.stdFields, stateLoader, stateSaver)
 	} else {
 		// If we've hit an error at this point, still run on schedule, but always return an error.
 		// This way the error is clearly communicated through to kibana.
@@ -193,7 +196,7 @@ func newMonitorUnsafe(
 		m.stdFields.BadConfig = true
 		// No need to retry bad configs
 		m.stdFields.MaxAttempts = 1
-		wrappedJobs = wrappers.WrapCommon(p.Jobs, m.stdFields, stateLoader)
+		wrappedJobs = wrappers.WrapCommon(p.Jobs, m.stdFields, stateLoader, stateSaver)
 	}
 
 	m.endpoints = p.Endpoints
@@ -226,9 +229,14 @@ func (m *Monitor) makeTasks(config *conf.C, jobs []jobs.Job) ([]*configuredJob,
 		return nil, fmt.Errorf("invalid config, could not unpack monitor config: %w", err)
 	}
 
+	maintenance, err := schedule.NewMaintenanceWindows(mtConf.MaintenanceWindows)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config, could not parse maintenance_windows: %w", err)
+	}
+
 	var mTasks = make([]*configuredJob, 0, len(jobs))
 	for _, job := range jobs {
-		t := newConfiguredJob(job, mtConf, m)
+		t := newConfiguredJob(job, mtConf, maintenance, m)
 		mTasks = append(mTasks, t)
 	}
 
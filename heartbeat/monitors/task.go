@@ -20,6 +20,7 @@ package monitors
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/elastic/beats/v7/heartbeat/eventext"
 	"github.com/elastic/beats/v7/heartbeat/monitors/jobs"
@@ -33,18 +34,20 @@ import (
 // configuredJob represents a job combined with its config and any
 // subsequent processors.
 type configuredJob struct {
-	job       jobs.Job
-	config    jobConfig
-	monitor   *Monitor
-	cancelFn  context.CancelFunc
-	pubClient beat.Client
+	job         jobs.Job
+	config      jobConfig
+	maintenance *schedule.MaintenanceWindows
+	monitor     *Monitor
+	cancelFn    context.CancelFunc
+	pubClient   beat.Client
 }
 
-func newConfiguredJob(job jobs.Job, config jobConfig, monitor *Monitor) *configuredJob {
+func newConfiguredJob(job jobs.Job, config jobConfig, maintenance *schedule.MaintenanceWindows, monitor *Monitor) *configuredJob {
 	return &configuredJob{
-		job:     job,
-		config:  config,
-		monitor: monitor,
+		job:         job,
+		config:      config,
+		maintenance: maintenance,
+		monitor:     monitor,
 	}
 }
 
@@ -53,6 +56,10 @@ type jobConfig struct {
 	Name     string             `config:"pluginName"`
 	Type     string             `config:"type"`
 	Schedule *schedule.Schedule `config:"schedule" validate:"required"`
+	// Jitter delays all runs of each job by a random offset below this value.
+	Jitter             time.Duration                      `config:"jitter" validate:"min=0"`
+	MaintenanceWindows []schedule.MaintenanceWindowConfig `config:"maintenance_windows"`
+	MaintenanceMode    schedule.MaintenanceMode           `config:"maintenance_mode"`
 }
 
 // ProcessorsError is used to indicate situations when processors could not be loaded.
@@ -65,10 +72,28 @@ func (e ProcessorsError) Error() string {
 
 func (t *configuredJob) prepareSchedulerJob(job jobs.Job) scheduler.TaskFunc {
 	return func(_ context.Context) []scheduler.TaskFunc {
+		if t.maintenance.Contains(time.Now()) {
+			if t.config.MaintenanceMode == schedule.MaintenanceTag {
+				return runPublishJob(jobs.Wrap(job, tagMaintenance), t.pubClient)
+			}
+			logp.L().Debugf("skipping check of monitor %s during maintenance window", t.monitor.stdFields.ID)
+			return nil
+		}
 		return runPublishJob(job, t.pubClient)
 	}
 }
 
+// tagMaintenance tags the events of jobs run during a maintenance window.
+func tagMaintenance(job jobs.Job) jobs.Job {
+	return func(event *beat.Event) ([]jobs.Job, error) {
+		conts, err := job(event)
+		if event.Fields != nil {
+			mapstr.AddTags(event.Fields, []string{schedule.MaintenanceTagName})
+		}
+		return conts, err
+	}
+}
+
 func (t *configuredJob) makeSchedulerTaskFunc() scheduler.TaskFunc {
 	return t.prepareSchedulerJob(t.job)
 }
@@ -84,7 +109,9 @@ func (t *configuredJob) Start(pubClient beat.Client) {
 		return
 	}
 
-	t.cancelFn, err = t.monitor.addTask(t.config.Schedule, t.monitor.stdFields.ID, t.makeSchedulerTaskFunc(), t.config.Type)
+	// Each job picks its own offset, spreading the endpoints of a monitor as well
+	sched := schedule.WithJitter(t.config.Schedule, t.config.Jitter)
+	t.cancelFn, err = t.monitor.addTask(sched, t.monitor.stdFields.ID, t.makeSchedulerTaskFunc(), t.config.Type)
 	if err != nil {
 		logp.L().Infof("could not start monitor: %v", err)
 	}
@@ -20,6 +20,7 @@ package monitors
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/elastic/elastic-agent-libs/mapstr"
 	"github.com/elastic/go-lookslike/validator"
@@ -27,10 +28,12 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/go-lookslike"
+	"github.com/elastic/go-lookslike/isdef"
 	"github.com/elastic/go-lookslike/testslike"
 
 	"github.com/elastic/beats/v7/heartbeat/eventext"
 	"github.com/elastic/beats/v7/heartbeat/monitors/jobs"
+	"github.com/elastic/beats/v7/heartbeat/scheduler/schedule"
 	"github.com/elastic/beats/v7/libbeat/beat"
 )
 
@@ -118,3 +121,59 @@ func Test_runPublishJob(t *testing.T) {
 		})
 	}
 }
+
+func TestConfiguredJobMaintenance(t *testing.T) {
+	job := func(event *beat.Event) (j []jobs.Job, e error) {
+		eventext.MergeEventFields(event, mapstr.M{"foo": "bar"})
+		return nil, nil
+	}
+	inWindow, err := schedule.NewMaintenanceWindows([]schedule.MaintenanceWindowConfig{{
+		Start: time.Now().Add(-time.Hour).Format(time.RFC3339),
+		End:   time.Now().Add(time.Hour).Format(time.RFC3339),
+	}})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		maintenance *schedule.MaintenanceWindows
+		mode        schedule.MaintenanceMode
+		validators  []validator.Validator
+	}{
+		{
+			"no window",
+			nil,
+			schedule.MaintenanceSkip,
+			[]validator.Validator{lookslike.MustCompile(map[string]interface{}{"foo": "bar", "tags": isdef.KeyMissing})},
+		},
+		{
+			"skip",
+			inWindow,
+			"",
+			nil,
+		},
+		{
+			"tag",
+			inWindow,
+			schedule.MaintenanceTag,
+			[]validator.Validator{lookslike.MustCompile(map[string]interface{}{"foo": "bar", "tags": []string{schedule.MaintenanceTagName}})},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pipel := &MockPipeline{}
+			client, err := pipel.Connect()
+			require.NoError(t, err)
+
+			cj := newConfiguredJob(job, jobConfig{MaintenanceMode: tc.mode}, tc.maintenance, &Monitor{})
+			cj.pubClient = client
+			require.Empty(t, cj.makeSchedulerTaskFunc()(context.Background()))
+			require.NoError(t, client.Close())
+
+			require.Len(t, pipel.PublishedEvents(), len(tc.validators))
+			for idx, event := range pipel.PublishedEvents() {
+				testslike.Test(t, tc.validators[idx], event.Fields)
+			}
+		})
+	}
+}
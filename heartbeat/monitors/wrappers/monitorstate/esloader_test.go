@@ -172,7 +172,7 @@ func newESTestContext(t *testing.T) *esTestContext {
 		location:  location,
 	}
 
-	etc.tracker = NewTracker(etc.loader, true)
+	etc.tracker = NewTracker(etc.loader, nil, true)
 
 	return etc
 }
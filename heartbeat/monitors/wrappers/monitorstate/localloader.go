@@ -0,0 +1,108 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package monitorstate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+
+	"github.com/elastic/beats/v7/heartbeat/monitors/stdfields"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+)
+
+// LocalStateMaxAge mirrors the ES loader lookback window, older states are
+// discarded rather than resumed.
+const LocalStateMaxAge = 6 * time.Hour
+
+const localStateKeyPrefix = "monitorstate::"
+
+// localStateEntry is the value persisted for each monitor in the local store.
+type localStateEntry struct {
+	Updated time.Time `struct:"updated"`
+	State   *State    `struct:"state"`
+}
+
+// LocalStore persists the latest state of each monitor to a statestore,
+// usually backed by memlog in the beat's data path. Unlike the ES loader it
+// works regardless of the configured output.
+type LocalStore struct {
+	store *statestore.Store
+	// now is overridden in tests
+	now func() time.Time
+}
+
+// NewLocalStore creates a LocalStore using the given store. The store is owned
+// by the LocalStore and released on Close.
+func NewLocalStore(store *statestore.Store) *LocalStore {
+	return &LocalStore{store: store, now: time.Now}
+}
+
+// Load implements StateLoader. It returns nil, nil if no state, or only an
+// expired state, is known for the monitor.
+func (ls *LocalStore) Load(sf stdfields.StdMonitorFields) (*State, error) {
+	key := LocalStoreKey(sf)
+
+	has, err := ls.store.Has(key)
+	if err != nil {
+		return nil, LoaderError{err: fmt.Errorf("could not lookup local state for %s: %w", sf.ID, err), Retry: false}
+	}
+	if !has {
+		logp.L().Infof("no previous state found for monitor %s in local store", sf.ID)
+		return nil, nil
+	}
+
+	var entry localStateEntry
+	if err := ls.store.Get(key, &entry); err != nil {
+		return nil, LoaderError{err: fmt.Errorf("could not read local state for %s: %w", sf.ID, err), Retry: false}
+	}
+
+	if entry.State == nil || ls.now().Sub(entry.Updated) > LocalStateMaxAge {
+		logp.L().Infof("discarding stale local state for monitor %s last updated at %s", sf.ID, entry.Updated)
+		return nil, nil
+	}
+
+	return entry.State, nil
+}
+
+// Save implements StateSaver, overwriting any previously stored state for the monitor.
+func (ls *LocalStore) Save(sf stdfields.StdMonitorFields, state *State) error {
+	if state == nil {
+		return nil
+	}
+
+	// Ends is not persisted, the prior state is only relevant to the event that ended it
+	return ls.store.Set(LocalStoreKey(sf), localStateEntry{Updated: ls.now(), State: state})
+}
+
+// Close releases the underlying store.
+func (ls *LocalStore) Close() error {
+	return ls.store.Close()
+}
+
+// LocalStoreKey returns the key used to persist the state of a monitor. States are
+// scoped by location, like the ES loader does, so that one data path shared by
+// several locations does not mix states.
+func LocalStoreKey(sf stdfields.StdMonitorFields) string {
+	rfid := "default"
+	if sf.RunFrom != nil {
+		rfid = normalizeRunFromIDRegexp.ReplaceAllString(sf.RunFrom.ID, "_")
+	}
+	return fmt.Sprintf("%s%s::%s::%s", localStateKeyPrefix, rfid, sf.Type, sf.ID)
+}
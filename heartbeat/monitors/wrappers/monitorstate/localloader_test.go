@@ -0,0 +1,98 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package monitorstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+
+	"github.com/elastic/beats/v7/heartbeat/config"
+	"github.com/elastic/beats/v7/heartbeat/monitors/stdfields"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+)
+
+func newTestLocalStore(t *testing.T, dir string) *LocalStore {
+	backend, err := memlog.New(logp.NewLogger("test"), memlog.Settings{Root: dir})
+	require.NoError(t, err)
+	reg := statestore.NewRegistry(backend)
+	t.Cleanup(func() { reg.Close() })
+
+	store, err := reg.Get("heartbeat")
+	require.NoError(t, err)
+	ls := NewLocalStore(store)
+	return ls
+}
+
+func TestLocalStoreSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	sf := stdfields.StdMonitorFields{ID: "mon1", Type: "http", RunFrom: &config.LocationWithID{ID: "us east"}}
+
+	ls := newTestLocalStore(t, dir)
+	mst := NewTracker(ls.Load, ls.Save, true)
+	// flap the monitor
+	mst.RecordStatus(sf, StatusUp, true)
+	ms := mst.RecordStatus(sf, StatusDown, true)
+	require.Equal(t, StatusFlapping, ms.Status)
+	require.NoError(t, ls.Close())
+
+	ls = newTestLocalStore(t, dir)
+	defer ls.Close()
+	mst = NewTracker(ls.Load, ls.Save, true)
+	loaded := mst.GetCurrentState(sf, RetryConfig{})
+	require.NotNil(t, loaded)
+	require.Equal(t, ms.ID, loaded.ID)
+	require.Equal(t, StatusFlapping, loaded.Status)
+	require.Equal(t, ms.FlapHistory, loaded.FlapHistory)
+	require.Equal(t, ms.Checks, loaded.Checks)
+	require.True(t, ms.StartedAt.Equal(loaded.StartedAt))
+	require.Nil(t, loaded.Ends)
+
+	// other locations and monitors do not see this state
+	other := sf
+	other.RunFrom = nil
+	otherState, err := ls.Load(other)
+	require.NoError(t, err)
+	require.Nil(t, otherState)
+}
+
+func TestLocalStoreDiscardsStaleStates(t *testing.T) {
+	sf := stdfields.StdMonitorFields{ID: "mon1", Type: "tcp"}
+	ls := newTestLocalStore(t, t.TempDir())
+	defer ls.Close()
+
+	ls.now = func() time.Time { return time.Now().Add(-LocalStateMaxAge - time.Minute) }
+	require.NoError(t, ls.Save(sf, newMonitorState(sf, StatusUp, 0, false)))
+	ls.now = time.Now
+
+	ms, err := ls.Load(sf)
+	require.NoError(t, err)
+	require.Nil(t, ms)
+}
+
+func TestLocalStoreKey(t *testing.T) {
+	sf := stdfields.StdMonitorFields{ID: "mon1", Type: "icmp"}
+	require.Equal(t, "monitorstate::default::icmp::mon1", LocalStoreKey(sf))
+
+	sf.RunFrom = &config.LocationWithID{ID: "us east/1"}
+	require.Equal(t, "monitorstate::us_east_1::icmp::mon1", LocalStoreKey(sf))
+}
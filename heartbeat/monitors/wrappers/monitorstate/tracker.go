@@ -32,15 +32,20 @@ import (
 // state loader, which will try to fetch the last known state for a never
 // before seen monitor, which usually means using ES. If set to nil
 // it will use ES if configured, otherwise it will only track state from
-// memory.
-func NewTracker(sl StateLoader, flappingEnabled bool) *Tracker {
+// memory. The optional state saver is invoked with every recorded state,
+// so that a later state loader can pick it up after a restart.
+func NewTracker(sl StateLoader, ss StateSaver, flappingEnabled bool) *Tracker {
 	if sl == nil {
 		sl = NilStateLoader
 	}
+	if ss == nil {
+		ss = NilStateSaver
+	}
 	return &Tracker{
 		states:          map[string]*State{},
 		mtx:             sync.Mutex{},
 		stateLoader:     sl,
+		stateSaver:      ss,
 		flappingEnabled: flappingEnabled,
 	}
 }
@@ -49,6 +54,7 @@ type Tracker struct {
 	states          map[string]*State
 	mtx             sync.Mutex
 	stateLoader     StateLoader
+	stateSaver      StateSaver
 	flappingEnabled bool
 }
 
@@ -56,7 +62,22 @@ type Tracker struct {
 // other than ES if necessary
 type StateLoader func(stdfields.StdMonitorFields) (*State, error)
 
+// StateSaver persists the most recent state of a monitor. It is the counterpart
+// of StateLoader for backends that, unlike ES, do not receive states through
+// the published events.
+type StateSaver func(stdfields.StdMonitorFields, *State) error
+
 func (t *Tracker) RecordStatus(sf stdfields.StdMonitorFields, newStatus StateStatus, isFinalAttempt bool) (ms *State) {
+	ms = t.recordStatus(sf, newStatus, isFinalAttempt)
+
+	// persist outside the lock, saving may involve IO
+	if err := t.stateSaver(sf, ms); err != nil {
+		logp.L().Warnf("could not save state for monitor %s: %v", sf.ID, err)
+	}
+	return ms
+}
+
+func (t *Tracker) recordStatus(sf stdfields.StdMonitorFields, newStatus StateStatus, isFinalAttempt bool) (ms *State) {
 	//note: the return values have no concurrency controls, they may be unsafely read unless
 	//copied to the stack, copying the structs before  returning
 	t.mtx.Lock()
@@ -143,6 +164,12 @@ func NilStateLoader(_ stdfields.StdMonitorFields) (*State, error) {
 	return nil, nil
 }
 
+// NilStateSaver discards all states. It's the default when no local state
+// store is configured, since ES receives states through the published events.
+func NilStateSaver(_ stdfields.StdMonitorFields, _ *State) error {
+	return nil
+}
+
 func AtomicStateLoader(inner StateLoader) (sl StateLoader, replace func(StateLoader)) {
 	mtx := &sync.Mutex{}
 	return func(currentSL stdfields.StdMonitorFields) (*State, error) {
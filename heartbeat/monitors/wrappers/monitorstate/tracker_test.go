@@ -28,7 +28,7 @@ import (
 )
 
 func TestTrackerRecord(t *testing.T) {
-	mst := NewTracker(NilStateLoader, true)
+	mst := NewTracker(NilStateLoader, nil, true)
 	ms := mst.RecordStatus(TestSf, StatusUp, true)
 	require.Equal(t, StatusUp, ms.Status)
 	requireMSStatusCount(t, ms, StatusUp, 1)
@@ -51,7 +51,7 @@ func TestTrackerRecord(t *testing.T) {
 }
 
 func TestTrackerRecordFlappingDisabled(t *testing.T) {
-	mst := NewTracker(NilStateLoader, false)
+	mst := NewTracker(NilStateLoader, nil, false)
 	ms := mst.RecordStatus(TestSf, StatusUp, true)
 	require.Equal(t, StatusUp, ms.Status)
 	requireMSStatusCount(t, ms, StatusUp, 1)
@@ -173,7 +173,7 @@ func TestStateLoaderRetry(t *testing.T) {
 				return nil, LoaderError{err: errors.New("test error"), Retry: tt.retryable}
 			}
 
-			mst := NewTracker(errorStateLoader, true)
+			mst := NewTracker(errorStateLoader, nil, true)
 			mst.GetCurrentState(stdfields.StdMonitorFields{}, tt.rc)
 
 			require.Equal(t, calls, tt.expectedCalls)
@@ -149,7 +149,7 @@ func TestSummarizer(t *testing.T) {
 				return nil, retErr
 			}
 
-			tracker := monitorstate.NewTracker(monitorstate.NilStateLoader, false)
+			tracker := monitorstate.NewTracker(monitorstate.NilStateLoader, nil, false)
 			sf := stdfields.StdMonitorFields{ID: "testmon", Name: "testmon", Type: "http", MaxAttempts: uint16(tt.maxAttempts)}
 
 			rcvdStatuses := ""
@@ -252,7 +252,7 @@ func TestSummarizerPluginOrder(t *testing.T) {
 			t.Parallel()
 
 			// Monitor setup
-			tracker := monitorstate.NewTracker(monitorstate.NilStateLoader, false)
+			tracker := monitorstate.NewTracker(monitorstate.NilStateLoader, nil, false)
 			sf := stdfields.StdMonitorFields{ID: "testmon", Name: "testmon", Type: "http", MaxAttempts: uint16(tt.maxAttempts)}
 
 			// Test locals
@@ -322,7 +322,7 @@ func TestRetryLightweightMonitorDuration(t *testing.T) {
 	t.Parallel()
 
 	// Monitor setup
-	tracker := monitorstate.NewTracker(monitorstate.NilStateLoader, false)
+	tracker := monitorstate.NewTracker(monitorstate.NilStateLoader, nil, false)
 	sf := stdfields.StdMonitorFields{ID: "testmon", Name: "testmon", Type: "http", MaxAttempts: uint16(2)}
 
 	// We simplify these to always down
@@ -36,8 +36,8 @@ import (
 )
 
 // WrapCommon applies the common wrappers that all monitor jobs get.
-func WrapCommon(js []jobs.Job, stdMonFields stdfields.StdMonitorFields, stateLoader monitorstate.StateLoader) []jobs.Job {
-	mst := monitorstate.NewTracker(stateLoader, false)
+func WrapCommon(js []jobs.Job, stdMonFields stdfields.StdMonitorFields, stateLoader monitorstate.StateLoader, stateSaver monitorstate.StateSaver) []jobs.Job {
+	mst := monitorstate.NewTracker(stateLoader, stateSaver, false)
 	var wrapped []jobs.Job
 	if stdMonFields.Type != "browser" || stdMonFields.BadConfig {
 		wrapped = WrapLightweight(js, stdMonFields, mst)
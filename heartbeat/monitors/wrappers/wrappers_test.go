@@ -76,7 +76,7 @@ var testBrowserMonFields = stdfields.StdMonitorFields{
 func testCommonWrap(t *testing.T, tt testDef) {
 	t.Helper()
 	t.Run(tt.name, func(t *testing.T) {
-		wrapped := WrapCommon(tt.jobs, tt.sFields, nil)
+		wrapped := WrapCommon(tt.jobs, tt.sFields, nil, nil)
 
 		core, observedLogs := observer.New(zapcore.InfoLevel)
 		logger.SetLogger(logp.NewLogger("t", zap.WrapCore(func(in zapcore.Core) zapcore.Core {
@@ -708,7 +708,7 @@ func TestECSErrors(t *testing.T) {
 				wrappedECSErr.Error(),
 			)
 
-			j := WrapCommon([]jobs.Job{makeProjectBrowserJob(t, "http://example.net", makeSummaryEvent, ecse, projectMonitorValues)}, testBrowserMonFields, nil)
+			j := WrapCommon([]jobs.Job{makeProjectBrowserJob(t, "http://example.net", makeSummaryEvent, ecse, projectMonitorValues)}, testBrowserMonFields, nil, nil)
 			event := &beat.Event{}
 			_, err := j[0](event)
 			require.NoError(t, err)
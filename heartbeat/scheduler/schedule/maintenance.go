@@ -0,0 +1,261 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorhill/cronexpr"
+)
+
+// MaintenanceMode defines what happens to checks falling into a maintenance window.
+type MaintenanceMode string
+
+const (
+	// MaintenanceSkip does not run checks during maintenance windows.
+	MaintenanceSkip MaintenanceMode = "skip"
+	// MaintenanceTag runs checks as usual, but tags their events.
+	MaintenanceTag MaintenanceMode = "tag"
+)
+
+// MaintenanceTagName is the tag added to events of checks run during a maintenance
+// window in MaintenanceTag mode.
+const MaintenanceTagName = "maintenance"
+
+func (m *MaintenanceMode) Unpack(str string) error {
+	switch mode := MaintenanceMode(strings.ToLower(str)); mode {
+	case MaintenanceSkip, MaintenanceTag:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("invalid maintenance mode '%s', expected one of '%s' or '%s'", str, MaintenanceSkip, MaintenanceTag)
+	}
+}
+
+// MaintenanceWindowConfig configures a single maintenance window. A window is
+// either one-off, defined by Start and End, or recurring, defined by a cron
+// expression or RRULE marking the start of each occurrence and a Duration.
+type MaintenanceWindowConfig struct {
+	Start    string        `config:"start"`
+	End      string        `config:"end"`
+	Cron     string        `config:"cron"`
+	RRule    string        `config:"rrule"`
+	Duration time.Duration `config:"duration"`
+	// Timezone in which all times of the window are interpreted, defaults to UTC.
+	Timezone string `config:"timezone"`
+}
+
+// MaintenanceWindows is a set of maintenance windows. A nil value has no windows.
+type MaintenanceWindows struct {
+	windows []maintenanceWindow
+}
+
+type maintenanceWindow struct {
+	location   *time.Location
+	start, end time.Time
+	recurrence *cronexpr.Expression
+	duration   time.Duration
+}
+
+// NewMaintenanceWindows validates the given configs and builds the windows they define.
+func NewMaintenanceWindows(cfgs []MaintenanceWindowConfig) (*MaintenanceWindows, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	mw := &MaintenanceWindows{windows: make([]maintenanceWindow, 0, len(cfgs))}
+	for i, cfg := range cfgs {
+		w, err := newMaintenanceWindow(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %d: %w", i, err)
+		}
+		mw.windows = append(mw.windows, w)
+	}
+	return mw, nil
+}
+
+// Contains returns true if t falls into any of the windows.
+func (mw *MaintenanceWindows) Contains(t time.Time) bool {
+	if mw == nil {
+		return false
+	}
+	for _, w := range mw.windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func newMaintenanceWindow(cfg MaintenanceWindowConfig) (maintenanceWindow, error) {
+	w := maintenanceWindow{location: time.UTC, duration: cfg.Duration}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return w, fmt.Errorf("invalid timezone: %w", err)
+		}
+		w.location = loc
+	}
+
+	isOneOff := cfg.Start != "" || cfg.End != ""
+	if isOneOff == (cfg.Cron != "" || cfg.RRule != "") {
+		return w, errors.New("either 'start' and 'end' or one of 'cron' or 'rrule' must be set")
+	}
+
+	if isOneOff {
+		var err error
+		if w.start, err = parseWindowTime(cfg.Start, w.location); err != nil {
+			return w, fmt.Errorf("invalid start: %w", err)
+		}
+		if w.end, err = parseWindowTime(cfg.End, w.location); err != nil {
+			return w, fmt.Errorf("invalid end: %w", err)
+		}
+		if !w.end.After(w.start) {
+			return w, errors.New("end must be after start")
+		}
+		return w, nil
+	}
+
+	if cfg.Cron != "" && cfg.RRule != "" {
+		return w, errors.New("'cron' and 'rrule' are mutually exclusive")
+	}
+	if cfg.Duration <= 0 {
+		return w, errors.New("recurring windows require a positive duration")
+	}
+
+	expr := cfg.Cron
+	if cfg.RRule != "" {
+		var err error
+		if expr, err = rruleToCron(cfg.RRule); err != nil {
+			return w, fmt.Errorf("invalid rrule: %w", err)
+		}
+	}
+	recurrence, err := cronexpr.Parse(expr)
+	if err != nil {
+		return w, fmt.Errorf("invalid recurrence '%s': %w", expr, err)
+	}
+	w.recurrence = recurrence
+
+	return w, nil
+}
+
+func (w maintenanceWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	if w.recurrence == nil {
+		return !t.Before(w.start) && t.Before(w.end)
+	}
+
+	// An occurrence that started within the last duration covers t
+	occurrence := w.recurrence.Next(t.Add(-w.duration))
+	return !occurrence.IsZero() && !occurrence.After(t)
+}
+
+var windowTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// parseWindowTime parses t in loc, unless t carries its own offset.
+func parseWindowTime(t string, loc *time.Location) (time.Time, error) {
+	if t == "" {
+		return time.Time{}, errors.New("value is required")
+	}
+	for _, layout := range windowTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, t, loc); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("could not parse '%s', expected one of the layouts %v", t, windowTimeLayouts)
+}
+
+var rruleWeekdays = map[string]string{"SU": "0", "MO": "1", "TU": "2", "WE": "3", "TH": "4", "FR": "5", "SA": "6"}
+
+// rruleToCron translates the subset of RFC 5545 recurrence rules that can be
+// expressed as a cron expression: DAILY, WEEKLY and MONTHLY frequencies with
+// BYMONTH, BYMONTHDAY, BYDAY, BYHOUR and BYMINUTE parts.
+func rruleToCron(rule string) (string, error) {
+	parts := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			return "", fmt.Errorf("malformed rule part '%s'", part)
+		}
+		parts[strings.ToUpper(key)] = strings.ToUpper(value)
+	}
+
+	minute, hour, dom, month, dow := "0", "0", "*", "*", "*"
+	for key, value := range parts {
+		switch key {
+		case "FREQ":
+			if value != "DAILY" && value != "WEEKLY" && value != "MONTHLY" {
+				return "", fmt.Errorf("unsupported frequency '%s'", value)
+			}
+		case "INTERVAL":
+			if value != "1" {
+				return "", errors.New("intervals other than 1 are not supported")
+			}
+		case "BYMINUTE":
+			minute = value
+		case "BYHOUR":
+			hour = value
+		case "BYMONTHDAY":
+			dom = value
+		case "BYMONTH":
+			month = value
+		case "BYDAY":
+			days := strings.Split(value, ",")
+			for i, day := range days {
+				num, ok := rruleWeekdays[day]
+				if !ok {
+					return "", fmt.Errorf("unsupported day '%s'", day)
+				}
+				days[i] = num
+			}
+			dow = strings.Join(days, ",")
+		default:
+			return "", fmt.Errorf("unsupported rule part '%s'", key)
+		}
+	}
+
+	switch parts["FREQ"] {
+	case "":
+		return "", errors.New("FREQ is required")
+	case "WEEKLY":
+		if dow == "*" {
+			return "", errors.New("weekly rules require BYDAY")
+		}
+	case "MONTHLY":
+		if dom == "*" {
+			return "", errors.New("monthly rules require BYMONTHDAY")
+		}
+	}
+
+	for _, numeric := range []string{minute, hour} {
+		for _, n := range strings.Split(numeric, ",") {
+			if _, err := strconv.Atoi(n); err != nil {
+				return "", fmt.Errorf("invalid numeric value '%s'", n)
+			}
+		}
+	}
+
+	return strings.Join([]string{minute, hour, dom, month, dow}, " "), nil
+}
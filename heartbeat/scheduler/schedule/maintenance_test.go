@@ -0,0 +1,147 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		cfg     MaintenanceWindowConfig
+		inside  []time.Time
+		outside []time.Time
+	}{
+		{
+			"one-off",
+			MaintenanceWindowConfig{Start: "2024-03-01T02:00", End: "2024-03-01T04:00", Timezone: "Europe/Berlin"},
+			[]time.Time{
+				time.Date(2024, 3, 1, 2, 0, 0, 0, berlin),
+				time.Date(2024, 3, 1, 2, 0, 0, 0, berlin).UTC(),
+				time.Date(2024, 3, 1, 3, 59, 59, 0, berlin),
+			},
+			[]time.Time{
+				time.Date(2024, 3, 1, 1, 59, 59, 0, berlin),
+				time.Date(2024, 3, 1, 4, 0, 0, 0, berlin),
+				time.Date(2024, 3, 1, 3, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			"one-off with offset",
+			MaintenanceWindowConfig{Start: "2024-03-01T02:00:00+01:00", End: "2024-03-01T04:00:00+01:00"},
+			[]time.Time{time.Date(2024, 3, 1, 1, 30, 0, 0, time.UTC)},
+			[]time.Time{time.Date(2024, 3, 1, 3, 30, 0, 0, time.UTC)},
+		},
+		{
+			"cron",
+			MaintenanceWindowConfig{Cron: "0 2 * * 0", Duration: 2 * time.Hour, Timezone: "Europe/Berlin"},
+			[]time.Time{
+				time.Date(2024, 3, 3, 2, 0, 0, 0, berlin),
+				time.Date(2024, 3, 10, 3, 59, 0, 0, berlin),
+			},
+			[]time.Time{
+				time.Date(2024, 3, 3, 1, 59, 0, 0, berlin),
+				time.Date(2024, 3, 3, 4, 0, 0, 0, berlin),
+				time.Date(2024, 3, 4, 2, 30, 0, 0, berlin),
+				time.Date(2024, 3, 3, 3, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			"rrule",
+			MaintenanceWindowConfig{RRule: "FREQ=WEEKLY;BYDAY=SA,SU;BYHOUR=23;BYMINUTE=30", Duration: time.Hour},
+			[]time.Time{
+				time.Date(2024, 3, 2, 23, 45, 0, 0, time.UTC),
+				time.Date(2024, 3, 4, 0, 15, 0, 0, time.UTC),
+			},
+			[]time.Time{
+				time.Date(2024, 3, 1, 23, 45, 0, 0, time.UTC),
+				time.Date(2024, 3, 4, 0, 30, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, err := NewMaintenanceWindows([]MaintenanceWindowConfig{tt.cfg})
+			require.NoError(t, err)
+			for _, in := range tt.inside {
+				require.True(t, mw.Contains(in), "expected %s to be in window", in)
+			}
+			for _, out := range tt.outside {
+				require.False(t, mw.Contains(out), "expected %s not to be in window", out)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowsInvalid(t *testing.T) {
+	tests := map[string]MaintenanceWindowConfig{
+		"empty":               {},
+		"missing end":         {Start: "2024-03-01"},
+		"end before start":    {Start: "2024-03-02", End: "2024-03-01"},
+		"mixed":               {Start: "2024-03-01", End: "2024-03-02", Cron: "* * * * *"},
+		"no duration":         {Cron: "0 2 * * *"},
+		"cron and rrule":      {Cron: "0 2 * * *", RRule: "FREQ=DAILY", Duration: time.Hour},
+		"bad cron":            {Cron: "foo", Duration: time.Hour},
+		"bad timezone":        {Cron: "0 2 * * *", Duration: time.Hour, Timezone: "Mars/Olympus"},
+		"rrule interval":      {RRule: "FREQ=DAILY;INTERVAL=2", Duration: time.Hour},
+		"rrule yearly":        {RRule: "FREQ=YEARLY", Duration: time.Hour},
+		"rrule weekly no day": {RRule: "FREQ=WEEKLY", Duration: time.Hour},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewMaintenanceWindows([]MaintenanceWindowConfig{cfg})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestRRuleToCron(t *testing.T) {
+	tests := map[string]string{
+		"FREQ=DAILY":                                       "0 0 * * *",
+		"RRULE:FREQ=DAILY;BYHOUR=3;BYMINUTE=15":            "15 3 * * *",
+		"FREQ=WEEKLY;BYDAY=MO,FR;BYHOUR=22":                "0 22 * * 1,5",
+		"FREQ=MONTHLY;BYMONTHDAY=1;BYMONTH=1,7;INTERVAL=1": "0 0 1 1,7 *",
+	}
+	for rule, expected := range tests {
+		got, err := rruleToCron(rule)
+		require.NoError(t, err)
+		require.Equal(t, expected, got, rule)
+	}
+}
+
+func TestMaintenanceModeUnpack(t *testing.T) {
+	var m MaintenanceMode
+	require.NoError(t, m.Unpack("TAG"))
+	require.Equal(t, MaintenanceTag, m)
+	require.Error(t, m.Unpack("ignore"))
+}
+
+func TestNilMaintenanceWindows(t *testing.T) {
+	mw, err := NewMaintenanceWindows(nil)
+	require.NoError(t, err)
+	require.False(t, mw.Contains(time.Now()))
+}
@@ -19,7 +19,9 @@ package schedule
 
 import (
 	"fmt"
+	"math/rand"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/elastic/beats/v7/heartbeat/scheduler"
@@ -81,3 +83,41 @@ func (s *Schedule) Unpack(str string) error {
 	}
 	return err
 }
+
+// WithJitter returns a copy of the schedule with all runs delayed by a random
+// offset below max. The offset is picked once, so that monitors sharing the
+// same schedule are spread out while keeping their own period. max should be
+// smaller than the period of the schedule.
+func WithJitter(s *Schedule, max time.Duration) *Schedule {
+	if max <= 0 {
+		return s
+	}
+	return &Schedule{&jitterScheduler{
+		inner:  s.Schedule,
+		offset: time.Duration(rand.Int63n(int64(max))),
+	}}
+}
+
+// jitterScheduler delays the runs of the inner schedule by a fixed offset.
+type jitterScheduler struct {
+	inner       scheduler.Schedule
+	offset      time.Duration
+	initialized atomic.Bool
+}
+
+// RunOnInit returns false, the first run must be delayed as well.
+func (s *jitterScheduler) RunOnInit() bool {
+	return false
+}
+
+func (s *jitterScheduler) Next(t time.Time) time.Time {
+	if s.inner.RunOnInit() {
+		// Interval schedules are relative to the previous run, delaying
+		// the first run is enough to offset all later ones.
+		if s.initialized.CompareAndSwap(false, true) {
+			return t.Add(s.offset)
+		}
+		return s.inner.Next(t)
+	}
+	return s.inner.Next(t).Add(s.offset)
+}
@@ -22,6 +22,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/elastic/beats/v7/heartbeat/scheduler"
 	"github.com/elastic/beats/v7/heartbeat/scheduler/schedule/cron"
 )
@@ -161,3 +163,37 @@ func TestSchedule_Unpack(t *testing.T) {
 		})
 	}
 }
+
+func TestWithJitter(t *testing.T) {
+	now := time.Now()
+	max := 10 * time.Second
+
+	t.Run("interval", func(t *testing.T) {
+		s := WithJitter(MustParse("@every 1m"), max)
+		require.False(t, s.RunOnInit())
+
+		first := s.Next(now)
+		offset := first.Sub(now)
+		require.GreaterOrEqual(t, offset, time.Duration(0))
+		require.Less(t, offset, max)
+		// later runs keep the interval
+		require.Equal(t, first.Add(time.Minute), s.Next(first))
+	})
+
+	t.Run("cron", func(t *testing.T) {
+		inner := cron.MustParse("*/15 * * * *")
+		s := WithJitter(&Schedule{inner}, max)
+		require.False(t, s.RunOnInit())
+
+		for i := 0; i < 3; i++ {
+			offset := s.Next(now).Sub(inner.Next(now))
+			require.GreaterOrEqual(t, offset, time.Duration(0))
+			require.Less(t, offset, max)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := MustParse("@every 1m")
+		require.Same(t, s, WithJitter(s, 0))
+	})
+}
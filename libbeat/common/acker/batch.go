@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package acker
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+)
+
+// BatchTracker invokes a callback when all events of a batch, for example
+// the events decoded from a single request, have been published and
+// acknowledged by an output. The events must hold the tracker in their
+// Private field and be published by a client using BatchTrackerReporter.
+type BatchTracker struct {
+	onACK func()
+
+	mutex   sync.Mutex // mutex synchronizes access to pending.
+	pending int64      // Number of events of the batch that are pending ACKs.
+}
+
+// NewBatchTracker returns a new BatchTracker. The onACK function is invoked
+// after the full batch has been acknowledged. Ready must be invoked after
+// all events of the batch are published.
+func NewBatchTracker(onACK func()) *BatchTracker {
+	return &BatchTracker{
+		onACK:   onACK,
+		pending: 1, // Ready() must be called to consume this "1".
+	}
+}
+
+// Ready signals that all events of the batch have been published. Only
+// after the batch is marked as ready can it be ACKed. This prevents the
+// batch from being ACKed prematurely.
+func (t *BatchTracker) Ready() {
+	t.ACK()
+}
+
+// Add increments the number of pending ACKs.
+func (t *BatchTracker) Add() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.pending++
+}
+
+// ACK decrements the number of pending event ACKs. When all pending ACKs are
+// received then the batch is ACKed.
+func (t *BatchTracker) ACK() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.pending <= 0 {
+		panic("misuse detected: negative ACK counter")
+	}
+
+	t.pending--
+	if t.pending == 0 {
+		t.onACK()
+	}
+}
+
+// BatchTrackerReporter returns an ACKer that invokes the ACK method of the
+// BatchTracker held in the Private field of each ACKed event.
+func BatchTrackerReporter() beat.EventListener {
+	return ConnectionOnly(
+		EventPrivateReporter(func(_ int, privates []interface{}) {
+			for _, private := range privates {
+				if tracker, ok := private.(*BatchTracker); ok {
+					tracker.ACK()
+				}
+			}
+		}),
+	)
+}
@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package acker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+)
+
+func TestBatchTracker(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		var acked bool
+		tracker := NewBatchTracker(func() { acked = true })
+		require.False(t, acked)
+
+		tracker.Ready()
+		require.True(t, acked)
+	})
+
+	t.Run("single_event", func(t *testing.T) {
+		var acked bool
+		tracker := NewBatchTracker(func() { acked = true })
+		tracker.Add()
+		tracker.ACK()
+		require.False(t, acked)
+
+		tracker.Ready()
+		require.True(t, acked)
+	})
+
+	t.Run("negative counter", func(t *testing.T) {
+		tracker := NewBatchTracker(func() {})
+		tracker.Ready()
+		require.Panics(t, tracker.ACK)
+	})
+}
+
+func TestBatchTrackerReporter(t *testing.T) {
+	var acked []string
+	first := NewBatchTracker(func() { acked = append(acked, "first") })
+	second := NewBatchTracker(func() { acked = append(acked, "second") })
+
+	acker := BatchTrackerReporter()
+	for _, tracker := range []*BatchTracker{first, first, second} {
+		tracker.Add()
+		acker.AddEvent(beat.Event{Private: tracker}, true)
+	}
+	acker.AddEvent(beat.Event{Private: "other"}, true)
+	first.Ready()
+	second.Ready()
+
+	acker.ACKEvents(1)
+	require.Empty(t, acked)
+	acker.ACKEvents(3)
+	require.Equal(t, []string{"first", "second"}, acked)
+}
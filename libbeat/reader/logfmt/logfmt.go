@@ -0,0 +1,208 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logfmt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/jsontransform"
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var (
+	// ErrUnterminatedQuote indicates a quoted value is missing its closing quote.
+	ErrUnterminatedQuote = errors.New("unterminated quoted value")
+	// ErrMissingKey indicates a value is not preceded by a key.
+	ErrMissingKey = errors.New("missing key")
+)
+
+// Config stores the configuration for the Parser.
+type Config struct {
+	// Target is the field the decoded keys are written to. If empty, the keys
+	// are written to the root of the event.
+	Target string `config:"target"`
+	// MessageKey is the key whose value replaces the message content.
+	MessageKey string `config:"message_key"`
+	// OverwriteKeys allows decoded keys to overwrite existing fields when
+	// writing to the root of the event.
+	OverwriteKeys bool `config:"overwrite_keys"`
+	// ExpandKeys expands dotted keys into objects when writing to the root
+	// of the event.
+	ExpandKeys bool `config:"expand_keys"`
+	// If true, errors will be logged.
+	LogErrors bool `config:"log_errors"`
+	// If true, errors will be added to the message fields under the error.message field.
+	AddErrorKey bool `config:"add_error_key"`
+}
+
+// DefaultConfig will return a Config with default values.
+func DefaultConfig() Config {
+	return Config{
+		Target:      "logfmt",
+		AddErrorKey: true,
+	}
+}
+
+// Parser is a logfmt parser that implements parser.Parser.
+type Parser struct {
+	cfg    *Config
+	reader reader.Reader
+	logger *logp.Logger
+}
+
+// NewParser creates a new logfmt parser.
+func NewParser(r reader.Reader, cfg *Config) *Parser {
+	return &Parser{
+		cfg:    cfg,
+		reader: r,
+		logger: logp.NewLogger("reader_logfmt"),
+	}
+}
+
+// Close closes this Parser.
+func (p *Parser) Close() error {
+	return p.reader.Close()
+}
+
+// Next reads the next message and decodes its logfmt encoded key-value pairs.
+func (p *Parser) Next() (reader.Message, error) {
+	msg, err := p.reader.Next()
+	if err != nil {
+		return msg, err
+	}
+
+	fields, err := Decode(string(msg.Content))
+	if err != nil {
+		if p.cfg.LogErrors {
+			p.logger.Errorf("Error parsing logfmt message: %v", err)
+		}
+		if p.cfg.AddErrorKey {
+			msg.AddFields(mapstr.M{"error": mapstr.M{"message": "Error parsing logfmt message: " + err.Error(), "type": "logfmt"}})
+		}
+		return msg, nil
+	}
+	if len(fields) == 0 {
+		return msg, nil
+	}
+
+	if p.cfg.MessageKey != "" {
+		if text, ok := fields[p.cfg.MessageKey].(string); ok {
+			msg.Content = []byte(text)
+		}
+	}
+
+	if p.cfg.Target == "" {
+		event := &beat.Event{
+			Timestamp: msg.Ts,
+			Meta:      msg.Meta,
+			Fields:    msg.Fields,
+		}
+		jsontransform.WriteJSONKeys(event, fields, p.cfg.ExpandKeys, p.cfg.OverwriteKeys, p.cfg.AddErrorKey)
+		msg.Ts = event.Timestamp
+		msg.Fields = event.Fields
+		msg.Meta = event.Meta
+	} else {
+		target := mapstr.M{}
+		_, _ = target.Put(p.cfg.Target, mapstr.M(fields))
+		msg.AddFields(target)
+	}
+
+	return msg, nil
+}
+
+// Decode decodes a single logfmt line into its key-value pairs. Values are
+// kept as strings, keys without a value are set to true. If a key is
+// repeated, the last value wins.
+func Decode(line string) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return fields, nil
+		}
+
+		start := i
+		for i < len(line) && isKeyChar(line[i]) {
+			i++
+		}
+		if i == start {
+			return fields, fmt.Errorf("position %d: %w", i, ErrMissingKey)
+		}
+		key := line[start:i]
+
+		if i == len(line) || line[i] != '=' {
+			if i < len(line) && !isSpace(line[i]) {
+				return fields, fmt.Errorf("position %d: unexpected character %q in key", i, line[i])
+			}
+			fields[key] = true
+			continue
+		}
+		i++ // skip '='
+
+		if i < len(line) && line[i] == '"' {
+			end, err := quotedValueEnd(line, i)
+			if err != nil {
+				return fields, err
+			}
+			value, err := strconv.Unquote(line[i:end])
+			if err != nil {
+				return fields, fmt.Errorf("position %d: invalid quoted value: %w", i, err)
+			}
+			fields[key] = value
+			i = end
+			continue
+		}
+
+		start = i
+		for i < len(line) && !isSpace(line[i]) {
+			i++
+		}
+		fields[key] = line[start:i]
+	}
+}
+
+// quotedValueEnd returns the position right after the closing quote of the
+// quoted value starting at start.
+func quotedValueEnd(line string, start int) (int, error) {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("position %d: %w", start, ErrUnterminatedQuote)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isKeyChar(c byte) bool {
+	return c > ' ' && c != '=' && c != '"' && c != 0x7f
+}
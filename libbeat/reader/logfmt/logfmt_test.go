@@ -0,0 +1,142 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package logfmt
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestDecode(t *testing.T) {
+	tests := map[string]struct {
+		line    string
+		want    map[string]interface{}
+		wantErr error
+	}{
+		"empty": {
+			line: "",
+			want: map[string]interface{}{},
+		},
+		"bare values": {
+			line: "level=info status=200 path=/index.html",
+			want: map[string]interface{}{"level": "info", "status": "200", "path": "/index.html"},
+		},
+		"quoted values": {
+			line: `msg="hello \"world\"" err="line1\nline2" empty=""`,
+			want: map[string]interface{}{"msg": `hello "world"`, "err": "line1\nline2", "empty": ""},
+		},
+		"keys without value": {
+			line: "debug level= cached",
+			want: map[string]interface{}{"debug": true, "level": "", "cached": true},
+		},
+		"dotted keys and extra spaces": {
+			line: "  http.method=GET \t http.status=404  ",
+			want: map[string]interface{}{"http.method": "GET", "http.status": "404"},
+		},
+		"repeated key": {
+			line: "a=1 a=2",
+			want: map[string]interface{}{"a": "2"},
+		},
+		"unterminated quote": {
+			line:    `msg="hello`,
+			wantErr: ErrUnterminatedQuote,
+		},
+		"missing key": {
+			line:    "=value",
+			wantErr: ErrMissingKey,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			got, err := Decode(tc.line)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParser(t *testing.T) {
+	tests := map[string]struct {
+		cfg         Config
+		content     string
+		wantContent string
+		wantFields  mapstr.M
+	}{
+		"default target": {
+			cfg:         DefaultConfig(),
+			content:     "level=warn msg=slow",
+			wantContent: "level=warn msg=slow",
+			wantFields:  mapstr.M{"logfmt": mapstr.M{"level": "warn", "msg": "slow"}},
+		},
+		"root target with message key": {
+			cfg:         Config{MessageKey: "msg"},
+			content:     `level=warn msg="slow query"`,
+			wantContent: "slow query",
+			wantFields:  mapstr.M{"level": "warn", "msg": "slow query"},
+		},
+		"parsing error": {
+			cfg:         DefaultConfig(),
+			content:     `msg="broken`,
+			wantContent: `msg="broken`,
+			wantFields: mapstr.M{"error": mapstr.M{
+				"message": "Error parsing logfmt message: position 4: unterminated quoted value",
+				"type":    "logfmt",
+			}},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			p := NewParser(&testReader{messages: []reader.Message{{Content: []byte(tc.content), Fields: mapstr.M{}}}}, &tc.cfg)
+			msg, err := p.Next()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantContent, string(msg.Content))
+			assert.Equal(t, tc.wantFields, msg.Fields)
+		})
+	}
+}
+
+type testReader struct {
+	messages []reader.Message
+}
+
+func (r *testReader) Next() (reader.Message, error) {
+	if len(r.messages) == 0 {
+		return reader.Message{}, io.EOF
+	}
+	msg := r.messages[0]
+	r.messages = r.messages[1:]
+	return msg, nil
+}
+
+func (r *testReader) Close() error {
+	return nil
+}
@@ -27,9 +27,12 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
 	"github.com/elastic/beats/v7/libbeat/reader"
 	"github.com/elastic/beats/v7/libbeat/reader/filter"
+	"github.com/elastic/beats/v7/libbeat/reader/logfmt"
 	"github.com/elastic/beats/v7/libbeat/reader/multiline"
+	"github.com/elastic/beats/v7/libbeat/reader/readcsv"
 	"github.com/elastic/beats/v7/libbeat/reader/readfile"
 	"github.com/elastic/beats/v7/libbeat/reader/readjson"
+	"github.com/elastic/beats/v7/libbeat/reader/readxml"
 	"github.com/elastic/beats/v7/libbeat/reader/syslog"
 	"github.com/elastic/elastic-agent-libs/config"
 )
@@ -50,6 +53,13 @@ type CommonConfig struct {
 	LineTerminator readfile.LineTerminator `config:"line_terminator"`
 }
 
+// State holds the state of the parsers that inputs persist alongside their
+// cursor, so parsing can be resumed in the middle of a source.
+type State struct {
+	// CSVHeader is the header line read by the csv parser.
+	CSVHeader []string `json:"csv_header,omitempty" struct:"csv_header,omitempty"`
+}
+
 type Config struct {
 	Suffix string
 
@@ -128,6 +138,27 @@ func NewConfig(pCfg CommonConfig, parsers []config.Namespace) (*Config, error) {
 			if err != nil {
 				return nil, fmt.Errorf("error while parsing include_message parser config: %w", err)
 			}
+		case "logfmt":
+			config := logfmt.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing logfmt parser config: %w", err)
+			}
+		case "csv":
+			config := readcsv.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing csv parser config: %w", err)
+			}
+		case "xml":
+			config := readxml.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing xml parser config: %w", err)
+			}
 		default:
 			return nil, fmt.Errorf("%s: %w", name, ErrNoSuchParser)
 		}
@@ -142,6 +173,13 @@ func NewConfig(pCfg CommonConfig, parsers []config.Namespace) (*Config, error) {
 }
 
 func (c *Config) Create(in reader.Reader) Parser {
+	return c.CreateWithState(in, nil)
+}
+
+// CreateWithState creates the parsers like Create. The parsers read and
+// update state, so it must be persisted with the position of the input in
+// the source. If state is nil, the state is only kept by the parsers.
+func (c *Config) CreateWithState(in reader.Reader, state *State) Parser {
 	p := in
 	for _, ns := range c.parsers {
 		name := ns.Name()
@@ -189,6 +227,34 @@ func (c *Config) Create(in reader.Reader) Parser {
 				return p
 			}
 			p = filter.NewParser(p, &config)
+		case "logfmt":
+			config := logfmt.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return p
+			}
+			p = logfmt.NewParser(p, &config)
+		case "csv":
+			config := readcsv.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return p
+			}
+			var header *[]string
+			if state != nil {
+				header = &state.CSVHeader
+			}
+			p = readcsv.NewParser(p, &config, header)
+		case "xml":
+			config := readxml.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return p
+			}
+			p = readxml.NewParser(p, &config, int(c.pCfg.MaxBytes))
 		default:
 			return p
 		}
@@ -816,3 +816,95 @@ func (r *messageReader) Close() error {
 	r.read = false
 	return nil
 }
+
+func TestParserLogfmt(t *testing.T) {
+	parserConfig := map[string]interface{}{
+		"parsers": []map[string]interface{}{
+			{
+				"logfmt": map[string]interface{}{
+					"message_key": "msg",
+				},
+			},
+		},
+	}
+
+	cfg := config.MustNewConfigFrom(parserConfig)
+	var c inputParsersConfig
+	err := cfg.Unpack(&c)
+	require.NoError(t, err)
+
+	p := c.Parsers.Create(testReader("level=info msg=\"request served\" status=200\n"))
+
+	msg, err := p.Next()
+	require.NoError(t, err)
+	require.Equal(t, "request served", string(msg.Content))
+	require.Equal(t, mapstr.M{
+		"logfmt": mapstr.M{
+			"level":  "info",
+			"msg":    "request served",
+			"status": "200",
+		},
+	}, msg.Fields)
+}
+
+func TestParserCSVState(t *testing.T) {
+	parserConfig := map[string]interface{}{
+		"parsers": []map[string]interface{}{
+			{
+				"csv": map[string]interface{}{},
+			},
+		},
+	}
+	lines := "host,status\nweb-1,ok\nweb-2,down\n"
+
+	cfg := config.MustNewConfigFrom(parserConfig)
+	var c inputParsersConfig
+	err := cfg.Unpack(&c)
+	require.NoError(t, err)
+
+	var state State
+	p := c.Parsers.CreateWithState(testReader(lines), &state)
+
+	msg, err := p.Next()
+	require.NoError(t, err)
+	require.Equal(t, len("host,status\n"), msg.Offset, "header bytes must be accounted in the offset")
+	require.Equal(t, mapstr.M{"csv": mapstr.M{"host": "web-1", "status": "ok"}}, msg.Fields)
+	require.Equal(t, []string{"host", "status"}, state.CSVHeader)
+
+	// resuming after the first record maps the columns with the stored header
+	p = c.Parsers.CreateWithState(testReader("web-2,down\n"), &state)
+	msg, err = p.Next()
+	require.NoError(t, err)
+	require.Equal(t, 0, msg.Offset)
+	require.Equal(t, mapstr.M{"csv": mapstr.M{"host": "web-2", "status": "down"}}, msg.Fields)
+}
+
+func TestParserXML(t *testing.T) {
+	parserConfig := map[string]interface{}{
+		"parsers": []map[string]interface{}{
+			{
+				"xml": map[string]interface{}{
+					"target": "appliance",
+				},
+			},
+		},
+	}
+	lines := "<Event>\n  <Severity>high</Severity>\n</Event>\n<Event><Severity>low</Severity></Event>\n"
+
+	cfg := config.MustNewConfigFrom(parserConfig)
+	var c inputParsersConfig
+	err := cfg.Unpack(&c)
+	require.NoError(t, err)
+
+	p := c.Parsers.Create(testReader(lines))
+
+	var severities []interface{}
+	msg, err := p.Next()
+	for err == nil {
+		v, getErr := msg.Fields.GetValue("appliance.event.severity")
+		require.NoError(t, getErr)
+		severities = append(severities, v)
+		msg, err = p.Next()
+	}
+	require.Equal(t, []interface{}{"high", "low"}, severities)
+}
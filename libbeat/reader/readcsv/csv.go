@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package readcsv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// Config stores the configuration for the Parser.
+type Config struct {
+	// Target is the field the decoded columns are written to.
+	Target string `config:"target" validate:"required"`
+	// Separator is the single character separating the columns.
+	Separator string `config:"separator"`
+	// Columns are the names of the columns. If not set, the first line of
+	// the source is used as header.
+	Columns []string `config:"columns"`
+	// TrimLeadingSpace ignores leading white space in a column.
+	TrimLeadingSpace bool `config:"trim_leading_space"`
+	// If true, errors will be logged.
+	LogErrors bool `config:"log_errors"`
+	// If true, errors will be added to the message fields under the error.message field.
+	AddErrorKey bool `config:"add_error_key"`
+}
+
+// DefaultConfig will return a Config with default values.
+func DefaultConfig() Config {
+	return Config{
+		Target:      "csv",
+		Separator:   ",",
+		AddErrorKey: true,
+	}
+}
+
+// Validate validates the Config options.
+func (c *Config) Validate() error {
+	if utf8.RuneCountInString(c.Separator) != 1 {
+		return fmt.Errorf("separator must be a single character, got '%s'", c.Separator)
+	}
+	return nil
+}
+
+// Parser is a CSV parser that implements parser.Parser.
+//
+// If no columns are configured, the first line read from the source is
+// consumed as header. The header is kept in the slice passed to NewParser, so
+// inputs can persist it and resume parsing in the middle of the source.
+type Parser struct {
+	cfg       *Config
+	reader    reader.Reader
+	logger    *logp.Logger
+	separator rune
+	header    *[]string
+}
+
+// NewParser creates a new CSV parser. header points to the header of the
+// source. If it is empty, the first line read is stored there as header.
+// If header is nil, the header is only kept by the parser.
+func NewParser(r reader.Reader, cfg *Config, header *[]string) *Parser {
+	if header == nil {
+		header = new([]string)
+	}
+	if len(cfg.Columns) > 0 {
+		header = &cfg.Columns
+	}
+	separator, _ := utf8.DecodeRuneInString(cfg.Separator)
+	return &Parser{
+		cfg:       cfg,
+		reader:    r,
+		logger:    logp.NewLogger("reader_csv"),
+		separator: separator,
+		header:    header,
+	}
+}
+
+// Close closes this Parser.
+func (p *Parser) Close() error {
+	return p.reader.Close()
+}
+
+// Next reads the next message and decodes its columns. The header line is
+// not returned, its bytes are accounted in the Offset of the next message.
+func (p *Parser) Next() (reader.Message, error) {
+	var discardedOffset int
+	for {
+		msg, err := p.reader.Next()
+		if err != nil {
+			return msg, err
+		}
+		msg.Offset += discardedOffset
+
+		if len(msg.Content) == 0 {
+			if len(*p.header) == 0 {
+				discardedOffset = msg.Offset + msg.Bytes
+				continue
+			}
+			return msg, nil
+		}
+
+		record, err := p.decode(msg.Content)
+		if err != nil {
+			if p.cfg.LogErrors {
+				p.logger.Errorf("Error parsing CSV message: %v", err)
+			}
+			if p.cfg.AddErrorKey {
+				msg.AddFields(mapstr.M{"error": mapstr.M{"message": "Error parsing CSV message: " + err.Error(), "type": "csv"}})
+			}
+			return msg, nil
+		}
+
+		if len(*p.header) == 0 {
+			*p.header = record
+			discardedOffset = msg.Offset + msg.Bytes
+			continue
+		}
+
+		fields := mapstr.M{}
+		_, _ = fields.Put(p.cfg.Target, p.columns(record))
+		msg.AddFields(fields)
+		return msg, nil
+	}
+}
+
+func (p *Parser) decode(line []byte) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(string(line)))
+	r.Comma = p.separator
+	r.TrimLeadingSpace = p.cfg.TrimLeadingSpace
+	r.FieldsPerRecord = -1
+	return r.Read()
+}
+
+// columns maps the values of a record to the names in the header. Values
+// without a column name are named after their 1-based position.
+func (p *Parser) columns(record []string) mapstr.M {
+	header := *p.header
+	columns := make(mapstr.M, len(record))
+	for i, v := range record {
+		name := "column" + strconv.Itoa(i+1)
+		if i < len(header) && header[i] != "" {
+			name = header[i]
+		}
+		columns[name] = v
+	}
+	return columns
+}
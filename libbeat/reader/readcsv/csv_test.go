@@ -0,0 +1,142 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package readcsv
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestParser(t *testing.T) {
+	tests := map[string]struct {
+		cfg        Config
+		header     []string
+		lines      []string
+		wantFields []mapstr.M
+		wantOffset []int
+		wantHeader []string
+	}{
+		"header from first line": {
+			cfg:        DefaultConfig(),
+			lines:      []string{"a,b", "1,2", "3,4"},
+			wantFields: []mapstr.M{{"csv": mapstr.M{"a": "1", "b": "2"}}, {"csv": mapstr.M{"a": "3", "b": "4"}}},
+			wantOffset: []int{4, 0},
+			wantHeader: []string{"a", "b"},
+		},
+		"empty lines before header": {
+			cfg:        DefaultConfig(),
+			lines:      []string{"", "a,b", "1,2"},
+			wantFields: []mapstr.M{{"csv": mapstr.M{"a": "1", "b": "2"}}},
+			wantOffset: []int{5},
+			wantHeader: []string{"a", "b"},
+		},
+		"stored header": {
+			cfg:        DefaultConfig(),
+			header:     []string{"a", "b"},
+			lines:      []string{"1,2"},
+			wantFields: []mapstr.M{{"csv": mapstr.M{"a": "1", "b": "2"}}},
+			wantOffset: []int{0},
+			wantHeader: []string{"a", "b"},
+		},
+		"configured columns": {
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Columns = []string{"x", "y"}
+				c.Separator = ";"
+				c.Target = "data"
+				return c
+			}(),
+			lines:      []string{"1;2", `"3;3";4`},
+			wantFields: []mapstr.M{{"data": mapstr.M{"x": "1", "y": "2"}}, {"data": mapstr.M{"x": "3;3", "y": "4"}}},
+			wantOffset: []int{0, 0},
+		},
+		"more values than columns": {
+			cfg:        DefaultConfig(),
+			header:     []string{"a"},
+			lines:      []string{"1,2"},
+			wantFields: []mapstr.M{{"csv": mapstr.M{"a": "1", "column2": "2"}}},
+			wantOffset: []int{0},
+			wantHeader: []string{"a"},
+		},
+		"parsing error": {
+			cfg:    DefaultConfig(),
+			header: []string{"a"},
+			lines:  []string{`"broken`},
+			wantFields: []mapstr.M{{"error": mapstr.M{
+				"message": "Error parsing CSV message: parse error on line 1, column 8: extraneous or missing \" in quoted-field",
+				"type":    "csv",
+			}}},
+			wantOffset: []int{0},
+			wantHeader: []string{"a"},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r := &testReader{}
+			for _, l := range tc.lines {
+				r.messages = append(r.messages, reader.Message{Content: []byte(l), Bytes: len(l) + 1, Fields: mapstr.M{}})
+			}
+			header := tc.header
+			p := NewParser(r, &tc.cfg, &header)
+
+			for i, want := range tc.wantFields {
+				msg, err := p.Next()
+				require.NoError(t, err)
+				assert.Equal(t, want, msg.Fields)
+				assert.Equal(t, tc.wantOffset[i], msg.Offset)
+			}
+			_, err := p.Next()
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, tc.wantHeader, header)
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Separator = "\t"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Separator = ";;"
+	assert.Error(t, cfg.Validate())
+}
+
+type testReader struct {
+	messages []reader.Message
+}
+
+func (r *testReader) Next() (reader.Message, error) {
+	if len(r.messages) == 0 {
+		return reader.Message{}, io.EOF
+	}
+	msg := r.messages[0]
+	r.messages = r.messages[1:]
+	return msg, nil
+}
+
+func (r *testReader) Close() error {
+	return nil
+}
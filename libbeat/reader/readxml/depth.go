@@ -0,0 +1,207 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package readxml
+
+import "bytes"
+
+type scanState uint8
+
+const (
+	stateText scanState = iota
+	stateMarkupStart
+	stateBang
+	stateTag
+	stateQuote
+	stateComment
+	stateCDATA
+	stateProcInst
+	stateDecl
+)
+
+var (
+	commentStart = []byte("!--")
+	cdataStart   = []byte("![CDATA[")
+)
+
+// depthTracker tracks the element depth of an XML document that is fed in
+// chunks, so complete documents can be framed without parsing them. It only
+// understands the lexical structure of XML: tags, quoted attribute values,
+// comments, CDATA sections, processing instructions and declarations.
+type depthTracker struct {
+	state scanState
+	// prev is the state to return to after a quoted value.
+	prev  scanState
+	quote byte
+	depth int
+
+	// started is set when the root element is opened.
+	started bool
+	// markup is set when the document contains markup outside of the
+	// root element, e.g. an XML declaration or a comment.
+	markup bool
+
+	endTag    bool
+	lastSlash bool
+	declDepth int
+	bang      []byte
+	tail      [2]byte
+}
+
+// Feed scans the next chunk of the document.
+func (t *depthTracker) Feed(b []byte) {
+	for _, c := range b {
+		t.scan(c)
+	}
+}
+
+// Done returns true if the root element of the document has been closed.
+func (t *depthTracker) Done() bool {
+	return t.started && t.depth == 0 && t.state == stateText
+}
+
+// Pending returns true if a document has been started and is not complete.
+func (t *depthTracker) Pending() bool {
+	return t.started || t.markup || t.state != stateText
+}
+
+func (t *depthTracker) Reset() {
+	*t = depthTracker{}
+}
+
+func (t *depthTracker) scan(c byte) {
+	switch t.state {
+	case stateText:
+		if c == '<' {
+			t.state = stateMarkupStart
+		}
+
+	case stateMarkupStart:
+		switch c {
+		case '/':
+			t.startTag(true)
+		case '?':
+			t.markup = true
+			t.tail = [2]byte{}
+			t.state = stateProcInst
+		case '!':
+			t.markup = true
+			t.bang = append(t.bang[:0], c)
+			t.state = stateBang
+		default:
+			t.startTag(false)
+			t.scan(c)
+		}
+
+	case stateBang:
+		t.bang = append(t.bang, c)
+		switch {
+		case bytes.Equal(t.bang, commentStart):
+			t.tail = [2]byte{}
+			t.state = stateComment
+		case bytes.Equal(t.bang, cdataStart):
+			t.tail = [2]byte{}
+			t.state = stateCDATA
+		case !bytes.HasPrefix(commentStart, t.bang) && !bytes.HasPrefix(cdataStart, t.bang):
+			t.declDepth = 0
+			t.state = stateDecl
+			for _, c := range t.bang[1:] {
+				t.scan(c)
+			}
+		}
+
+	case stateTag:
+		switch c {
+		case '"', '\'':
+			t.enterQuote(c)
+		case '>':
+			t.closeTag()
+		case ' ', '\t', '\r', '\n':
+		default:
+			t.lastSlash = c == '/'
+		}
+
+	case stateQuote:
+		if c == t.quote {
+			t.state = t.prev
+		}
+
+	case stateComment:
+		if c == '>' && t.tail == [2]byte{'-', '-'} {
+			t.state = stateText
+		}
+		t.shiftTail(c)
+
+	case stateCDATA:
+		if c == '>' && t.tail == [2]byte{']', ']'} {
+			t.state = stateText
+		}
+		t.shiftTail(c)
+
+	case stateProcInst:
+		if c == '>' && t.tail[1] == '?' {
+			t.state = stateText
+		}
+		t.shiftTail(c)
+
+	case stateDecl:
+		switch c {
+		case '"', '\'':
+			t.enterQuote(c)
+		case '[':
+			t.declDepth++
+		case ']':
+			t.declDepth--
+		case '>':
+			if t.declDepth <= 0 {
+				t.state = stateText
+			}
+		}
+	}
+}
+
+func (t *depthTracker) startTag(end bool) {
+	t.endTag = end
+	t.lastSlash = false
+	t.state = stateTag
+}
+
+func (t *depthTracker) closeTag() {
+	t.state = stateText
+	switch {
+	case t.endTag:
+		if t.depth > 0 {
+			t.depth--
+		}
+	case t.lastSlash:
+		// empty element, the depth is not changed
+		t.started = true
+	default:
+		t.depth++
+		t.started = true
+	}
+}
+
+func (t *depthTracker) enterQuote(c byte) {
+	t.prev = t.state
+	t.quote = c
+	t.state = stateQuote
+}
+
+func (t *depthTracker) shiftTail(c byte) {
+	t.tail[0], t.tail[1] = t.tail[1], c
+}
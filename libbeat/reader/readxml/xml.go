@@ -0,0 +1,198 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package readxml
+
+import (
+	"bytes"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/encoding/xml"
+	"github.com/elastic/beats/v7/libbeat/common/jsontransform"
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// Config stores the configuration for the Parser.
+type Config struct {
+	// Target is the field the decoded document is written to. If empty, the
+	// document is written to the root of the event.
+	Target string `config:"target"`
+	// DocumentID is the key of the decoded document used as document ID.
+	DocumentID string `config:"document_id"`
+	// ToLower converts all keys to lowercase.
+	ToLower bool `config:"to_lower"`
+	// OverwriteKeys allows decoded keys to overwrite existing fields when
+	// writing to the root of the event.
+	OverwriteKeys bool `config:"overwrite_keys"`
+	// If true, errors will be logged.
+	LogErrors bool `config:"log_errors"`
+	// If true, errors will be added to the message fields under the error.message field.
+	AddErrorKey bool `config:"add_error_key"`
+}
+
+// DefaultConfig will return a Config with default values.
+func DefaultConfig() Config {
+	return Config{
+		Target:      "xml",
+		ToLower:     true,
+		AddErrorKey: true,
+	}
+}
+
+// Parser is an XML parser that implements parser.Parser.
+//
+// Lines are combined until the root element of a document is closed, so a
+// document can span any number of lines. The combined document is decoded
+// like the decode_xml processor does.
+type Parser struct {
+	cfg      *Config
+	reader   reader.Reader
+	logger   *logp.Logger
+	maxBytes int
+
+	tracker depthTracker
+	message reader.Message
+	// err is the error of the underlying reader returned after the
+	// buffered document has been flushed.
+	err error
+}
+
+// NewParser creates a new XML parser. Documents exceeding maxBytes are
+// flushed without waiting for the root element to be closed.
+func NewParser(r reader.Reader, cfg *Config, maxBytes int) *Parser {
+	return &Parser{
+		cfg:      cfg,
+		reader:   r,
+		logger:   logp.NewLogger("reader_xml"),
+		maxBytes: maxBytes,
+	}
+}
+
+// Close closes this Parser.
+func (p *Parser) Close() error {
+	return p.reader.Close()
+}
+
+// Next returns the next complete XML document with its decoded fields.
+// Lines outside of an XML document are returned unchanged.
+func (p *Parser) Next() (reader.Message, error) {
+	if p.err != nil {
+		return reader.Message{}, p.err
+	}
+
+	for {
+		msg, err := p.reader.Next()
+		if err != nil {
+			if !p.tracker.Pending() {
+				return msg, err
+			}
+			// flush the incomplete document before returning the error
+			p.err = err
+			return p.flush(), nil
+		}
+
+		p.add(msg)
+
+		if p.tracker.Done() {
+			return p.flush(), nil
+		}
+		if !p.tracker.Pending() {
+			// not an XML document, return the line as is
+			msg = p.message
+			p.message = reader.Message{}
+			return msg, nil
+		}
+		if p.maxBytes > 0 && len(p.message.Content) > p.maxBytes {
+			return p.flush(), nil
+		}
+	}
+}
+
+func (p *Parser) add(msg reader.Message) {
+	if !p.tracker.Pending() {
+		p.message = msg
+		p.message.Content = append([]byte(nil), msg.Content...)
+	} else {
+		p.message.Content = append(p.message.Content, '\n')
+		p.message.Content = append(p.message.Content, msg.Content...)
+		p.message.Bytes += msg.Bytes
+		p.message.Offset += msg.Offset
+		p.message.AddFields(msg.Fields)
+	}
+	p.tracker.Feed(msg.Content)
+	p.tracker.Feed([]byte{'\n'})
+}
+
+func (p *Parser) flush() reader.Message {
+	msg := p.message
+	p.message = reader.Message{}
+	p.tracker.Reset()
+
+	doc, err := p.decode(msg.Content)
+	if err != nil {
+		if p.cfg.LogErrors {
+			p.logger.Errorf("Error decoding XML document: %v", err)
+		}
+		if p.cfg.AddErrorKey {
+			msg.AddFields(mapstr.M{"error": mapstr.M{"message": "Error decoding XML document: " + err.Error(), "type": "xml"}})
+		}
+		return msg
+	}
+
+	if key := p.cfg.DocumentID; key != "" {
+		if tmp, err := doc.GetValue(key); err == nil {
+			if id, ok := tmp.(string); ok {
+				_ = doc.Delete(key)
+				if msg.Meta == nil {
+					msg.Meta = mapstr.M{}
+				}
+				msg.Meta["_id"] = id
+			}
+		}
+	}
+
+	if p.cfg.Target == "" {
+		event := &beat.Event{
+			Timestamp: msg.Ts,
+			Meta:      msg.Meta,
+			Fields:    msg.Fields,
+		}
+		jsontransform.WriteJSONKeys(event, doc, false, p.cfg.OverwriteKeys, p.cfg.AddErrorKey)
+		msg.Ts = event.Timestamp
+		msg.Fields = event.Fields
+		msg.Meta = event.Meta
+	} else {
+		fields := mapstr.M{}
+		_, _ = fields.Put(p.cfg.Target, doc)
+		msg.AddFields(fields)
+	}
+	return msg
+}
+
+func (p *Parser) decode(content []byte) (mapstr.M, error) {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	if p.cfg.ToLower {
+		dec.LowercaseKeys()
+	}
+	out, err := dec.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return mapstr.M(out), nil
+}
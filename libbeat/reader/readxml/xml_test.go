@@ -0,0 +1,175 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package readxml
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestDepthTracker(t *testing.T) {
+	tests := map[string]struct {
+		lines []string
+		done  []bool
+	}{
+		"single line": {
+			lines: []string{`<event id="1"><name>a</name></event>`},
+			done:  []bool{true},
+		},
+		"empty root element": {
+			lines: []string{`<event id="1"/>`},
+			done:  []bool{true},
+		},
+		"multiple lines": {
+			lines: []string{`<event>`, `  <name>a</name>`, `  <empty />`, `</event>`},
+			done:  []bool{false, false, false, true},
+		},
+		"declaration and comments": {
+			lines: []string{`<?xml version="1.0"?>`, `<!-- <event> -->`, `<event>`, `</event>`},
+			done:  []bool{false, false, false, true},
+		},
+		"markup in quotes and CDATA": {
+			lines: []string{`<event attr="a>b" other='</event>'>`, `<![CDATA[</event>]]>`, `</event>`},
+			done:  []bool{false, false, true},
+		},
+		"comment spanning lines": {
+			lines: []string{`<event>`, `<!-- </event>`, `-->`, `</event>`},
+			done:  []bool{false, false, false, true},
+		},
+		"doctype with internal subset": {
+			lines: []string{`<!DOCTYPE event [ <!ELEMENT event (#PCDATA)> ]>`, `<event>a</event>`},
+			done:  []bool{false, true},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var tracker depthTracker
+			for i, line := range tc.lines {
+				tracker.Feed([]byte(line + "\n"))
+				assert.Equal(t, tc.done[i], tracker.Done(), "line %d: %s", i, line)
+			}
+		})
+	}
+}
+
+func TestParser(t *testing.T) {
+	lines := []string{
+		"plain line",
+		`<?xml version="1.0"?>`,
+		`<Event id="1">`,
+		`  <Name>first</Name>`,
+		`</Event>`,
+		`<Event id="2"><Name>second</Name></Event>`,
+	}
+
+	cfg := DefaultConfig()
+	p := NewParser(newTestReader(lines...), &cfg, 0)
+
+	msg, err := p.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "plain line", string(msg.Content))
+	assert.Equal(t, mapstr.M{}, msg.Fields)
+
+	msg, err = p.Next()
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(lines[1:5], "\n"), string(msg.Content))
+	assert.Equal(t, len(strings.Join(lines[1:5], "\n"))+1, msg.Bytes)
+	assert.Equal(t, mapstr.M{"xml": mapstr.M{"event": map[string]interface{}{"id": "1", "name": "first"}}}, msg.Fields)
+
+	msg, err = p.Next()
+	require.NoError(t, err)
+	assert.Equal(t, lines[5], string(msg.Content))
+	assert.Equal(t, mapstr.M{"xml": mapstr.M{"event": map[string]interface{}{"id": "2", "name": "second"}}}, msg.Fields)
+
+	_, err = p.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestParserDocumentID(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Target = ""
+	cfg.DocumentID = "event.id"
+	p := NewParser(newTestReader(`<Event id="abc">`, `<Name>a</Name>`, `</Event>`), &cfg, 0)
+
+	msg, err := p.Next()
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"_id": "abc"}, msg.Meta)
+	assert.Equal(t, mapstr.M{"event": mapstr.M{"name": "a"}}, msg.Fields)
+}
+
+func TestParserFlushesIncompleteDocument(t *testing.T) {
+	t.Run("on error", func(t *testing.T) {
+		cfg := DefaultConfig()
+		p := NewParser(newTestReader(`<Event>`, `<Name>a</Name>`), &cfg, 0)
+
+		msg, err := p.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "<Event>\n<Name>a</Name>", string(msg.Content))
+		assert.Contains(t, msg.Fields, "error")
+
+		_, err = p.Next()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("on max bytes", func(t *testing.T) {
+		cfg := DefaultConfig()
+		p := NewParser(newTestReader(`<Event>`, `<Name>a</Name>`, `<Name>b</Name>`, `</Event>`), &cfg, 10)
+
+		msg, err := p.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "<Event>\n<Name>a</Name>", string(msg.Content))
+
+		msg, err = p.Next()
+		require.NoError(t, err)
+		assert.Equal(t, "<Name>b</Name>", string(msg.Content))
+	})
+}
+
+type testReader struct {
+	messages []reader.Message
+}
+
+func newTestReader(lines ...string) *testReader {
+	r := &testReader{}
+	for _, l := range lines {
+		r.messages = append(r.messages, reader.Message{Content: []byte(l), Bytes: len(l) + 1, Fields: mapstr.M{}})
+	}
+	return r
+}
+
+func (r *testReader) Next() (reader.Message, error) {
+	if len(r.messages) == 0 {
+		return reader.Message{}, io.EOF
+	}
+	msg := r.messages[0]
+	r.messages = r.messages[1:]
+	return msg, nil
+}
+
+func (r *testReader) Close() error {
+	return nil
+}
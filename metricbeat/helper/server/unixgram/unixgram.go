@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package unixgram
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+
+	"github.com/elastic/beats/v7/metricbeat/helper/server"
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// UnixgramServer receives datagrams on a unix datagram socket.
+type UnixgramServer struct {
+	addr              *net.UnixAddr
+	listener          *net.UnixConn
+	receiveBufferSize int
+	done              chan struct{}
+	eventQueue        chan server.Event
+	logger            *logp.Logger
+}
+
+type UnixgramEvent struct {
+	event mapstr.M
+	meta  server.Meta
+}
+
+func (u *UnixgramEvent) GetEvent() mapstr.M {
+	return u.event
+}
+
+func (u *UnixgramEvent) GetMeta() server.Meta {
+	return u.meta
+}
+
+func NewUnixgramServer(base mb.BaseMetricSet) (server.Server, error) {
+	config := defaultUnixgramConfig()
+	err := base.Module().UnpackConfig(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := net.ResolveUnixAddr("unixgram", config.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnixgramServer{
+		addr:              addr,
+		receiveBufferSize: config.ReceiveBufferSize,
+		done:              make(chan struct{}),
+		eventQueue:        make(chan server.Event),
+		logger:            base.Logger(),
+	}, nil
+}
+
+func (g *UnixgramServer) GetHost() string {
+	return g.addr.String()
+}
+
+func (g *UnixgramServer) Start() error {
+	if err := cleanupStaleSocket(g.addr.Name); err != nil {
+		return fmt.Errorf("failed to start unix datagram server: %w", err)
+	}
+
+	listener, err := net.ListenUnixgram("unixgram", g.addr)
+	if err != nil {
+		return fmt.Errorf("failed to start unix datagram server: %w", err)
+	}
+
+	g.logger.Infof("Started listening for unix datagrams on: %s", g.addr.Name)
+	g.listener = listener
+
+	go g.watchMetrics()
+	return nil
+}
+
+func (g *UnixgramServer) watchMetrics() {
+	buffer := make([]byte, g.receiveBufferSize)
+	for {
+		length, _, err := g.listener.ReadFromUnix(buffer)
+		if err != nil {
+			select {
+			case <-g.done:
+				return
+			default:
+			}
+			g.logger.Errorf("Error reading from unix socket: %v", err)
+			continue
+		}
+
+		bufCopy := make([]byte, length)
+		copy(bufCopy, buffer)
+
+		select {
+		case <-g.done:
+			return
+		case g.eventQueue <- &UnixgramEvent{
+			event: mapstr.M{
+				server.EventDataKey: bufCopy,
+			},
+			meta: server.Meta{
+				"socket_path": g.addr.Name,
+			},
+		}:
+		}
+	}
+}
+
+func (g *UnixgramServer) GetEvents() chan server.Event {
+	return g.eventQueue
+}
+
+// Stop stops the server and removes its socket file.
+func (g *UnixgramServer) Stop() {
+	close(g.done)
+	if g.listener != nil {
+		g.listener.Close()
+		// Unlike stream listeners, datagram sockets don't remove their
+		// file when closed.
+		if err := os.Remove(g.addr.Name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			g.logger.Warnf("Failed to remove unix socket file %s: %v", g.addr.Name, err)
+		}
+	}
+}
+
+// cleanupStaleSocket removes the socket file left by a previous run, as
+// listening fails when the file exists.
+func cleanupStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("cannot lstat unix socket file at location %s: %w", path, err)
+	}
+
+	if runtime.GOOS != "windows" && info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("refusing to remove file at location %s, it is not a socket", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("cannot remove existing unix socket file at location %s: %w", path, err)
+	}
+	return nil
+}
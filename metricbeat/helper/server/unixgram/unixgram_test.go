@@ -0,0 +1,86 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration && !windows
+
+package unixgram
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/metricbeat/helper/server"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func GetTestUnixgramServer(t *testing.T, path string) *UnixgramServer {
+	addr, err := net.ResolveUnixAddr("unixgram", path)
+	require.NoError(t, err)
+
+	return &UnixgramServer{
+		addr:              addr,
+		receiveBufferSize: 8192,
+		done:              make(chan struct{}),
+		eventQueue:        make(chan server.Event),
+		logger:            logp.NewLogger("unixgram"),
+	}
+}
+
+func TestUnixgramServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+
+	// A socket left by a previous run is replaced.
+	stale, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	stale.Close()
+
+	svc := GetTestUnixgramServer(t, path)
+	require.NoError(t, svc.Start())
+
+	writeToServer(t, "test1", path)
+	msg := <-svc.GetEvents()
+
+	bytes, _ := msg.GetEvent()[server.EventDataKey].([]byte)
+	assert.Equal(t, "test1", string(bytes))
+	assert.Equal(t, path, msg.GetMeta()["socket_path"])
+
+	svc.Stop()
+	_, err = os.Lstat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestUnixgramServerNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	svc := GetTestUnixgramServer(t, path)
+	assert.ErrorContains(t, svc.Start(), "it is not a socket")
+}
+
+func writeToServer(t *testing.T, message, path string) {
+	conn, err := net.Dial("unixgram", path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(message))
+	require.NoError(t, err)
+}
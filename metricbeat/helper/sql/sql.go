@@ -63,8 +63,9 @@ func NewDBClient(driver, uri string, l *logp.Logger) (*DbClient, error) {
 }
 
 // FetchTableMode scan the rows and publishes the event for querys that return the response in a table format.
-func (d *DbClient) FetchTableMode(ctx context.Context, q string) ([]mapstr.M, error) {
-	rows, err := d.QueryContext(ctx, q)
+// The args are passed to the query for its placeholder parameters.
+func (d *DbClient) FetchTableMode(ctx context.Context, q string, args ...interface{}) ([]mapstr.M, error) {
+	rows, err := d.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
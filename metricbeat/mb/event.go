@@ -48,6 +48,11 @@ type Event struct {
 	Period    time.Duration // Period that is set to retrieve the events
 
 	DisableTimeSeries bool // true if the event doesn't contain timeseries data
+
+	// OnACK is called once the event has been acknowledged by the output.
+	// It is only called for MetricSets that request acknowledgements, see
+	// EventACKer.
+	OnACK func()
 }
 
 // BeatEvent returns a new beat.Event containing the data this Event. It does
@@ -107,6 +112,10 @@ func (e *Event) BeatEvent(module, metricSet string, modifiers ...EventModifier)
 		}
 	}
 
+	if e.OnACK != nil {
+		b.Private = e.OnACK
+	}
+
 	return b
 }
 
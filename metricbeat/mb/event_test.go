@@ -170,6 +170,23 @@ func TestEventConversionToBeatEvent(t *testing.T) {
 		}
 		assert.Equal(t, msg, errorMessage)
 	})
+
+	t.Run("ACK callback", func(t *testing.T) {
+		acked := false
+		e := (&Event{
+			OnACK: func() { acked = true },
+		}).BeatEvent(module, metricSet)
+
+		onACK, ok := e.Private.(func())
+		if !ok {
+			t.Fatalf("unexpected private field %T", e.Private)
+		}
+		onACK()
+		assert.True(t, acked)
+
+		e = (&Event{}).BeatEvent(module, metricSet)
+		assert.Nil(t, e.Private)
+	})
 }
 
 func TestAddMetricSetInfo(t *testing.T) {
@@ -168,6 +168,14 @@ type Closer interface {
 	Close() error
 }
 
+// EventACKer is an optional interface that a MetricSet can implement to be
+// notified once its events are acknowledged by the output, through their
+// OnACK callback.
+type EventACKer interface {
+	// ACKEvents returns if the OnACK callbacks of the events must be called.
+	ACKEvents() bool
+}
+
 // Reporter is used by a MetricSet to report events, errors, or errors with
 // metadata. The methods return false if and only if publishing failed because
 // the MetricSet is being closed.
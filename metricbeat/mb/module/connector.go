@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
 	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
 	"github.com/elastic/beats/v7/libbeat/processors"
 	"github.com/elastic/beats/v7/libbeat/processors/add_formatted_index"
@@ -35,6 +36,7 @@ type Connector struct {
 	processors *processors.Processors
 	eventMeta  mapstr.EventMetadata
 	keepNull   bool
+	ackEvents  bool
 }
 
 type connectorConfig struct {
@@ -107,13 +109,30 @@ func (c *Connector) addProcessors(procs []beat.Processor) {
 	}
 }
 
+// useACKs makes the connector call the OnACK callback of events once they
+// are acknowledged.
+func (c *Connector) useACKs() {
+	c.ackEvents = true
+}
+
 func (c *Connector) Connect() (beat.Client, error) {
+	var listener beat.EventListener
+	if c.ackEvents {
+		listener = acker.ConnectionOnly(acker.EventPrivateReporter(func(_ int, privates []interface{}) {
+			for _, private := range privates {
+				if onACK, ok := private.(func()); ok {
+					onACK()
+				}
+			}
+		}))
+	}
 	return c.pipeline.ConnectWith(beat.ClientConfig{
 		Processing: beat.ProcessingConfig{
 			EventMetadata: c.eventMeta,
 			Processor:     c.processors,
 			KeepNull:      c.keepNull,
 		},
+		EventListener: listener,
 	})
 }
 
@@ -77,6 +77,10 @@ func (r *Factory) Create(p beat.PipelineConnector, c *conf.C) (cfgfile.Runner, e
 			connector.addProcessors(msWithProcs.Processors())
 		}
 
+		if msWithACK, ok := metricSet.(mb.EventACKer); ok && msWithACK.ACKEvents() {
+			connector.useACKs()
+		}
+
 		client, err := connector.Connect()
 		if err != nil {
 			return nil, err
@@ -0,0 +1,178 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package eventlog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/elastic/beats/v7/winlogbeat/checkpoint"
+	"github.com/elastic/beats/v7/winlogbeat/sys/evtx"
+	"github.com/elastic/beats/v7/winlogbeat/sys/winevent"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const evtxAPIName = "evtx"
+
+// EvtxConfig is the configuration of an EventLog that reads an evtx file
+// without the Windows Event Log API.
+type EvtxConfig struct {
+	BatchReadSize int  `config:"batch_read_size" validate:"min=1"` // Maximum number of events that Read will return.
+	IncludeXML    bool `config:"include_xml"`
+}
+
+// DefaultEvtxConfig returns the default EvtxConfig.
+func DefaultEvtxConfig() EvtxConfig {
+	return EvtxConfig{
+		BatchReadSize: 100,
+	}
+}
+
+// evtxFile is an EventLog that decodes an exported evtx file itself. It does
+// not depend on the Windows Event Log API and is available on all platforms.
+// Messages are not rendered because the publisher metadata holding the
+// message templates is not available.
+type evtxFile struct {
+	config EvtxConfig
+	path   string
+	file   *os.File
+	reader *evtx.Reader
+	log    *logp.Logger
+}
+
+var _ EventLog = (*evtxFile)(nil)
+
+// NewEvtxFile returns an EventLog that reads the evtx file at path. The
+// records are read once; Read returns io.EOF at the end of the file.
+func NewEvtxFile(path string, config EvtxConfig, log *logp.Logger) EventLog {
+	return &evtxFile{
+		config: config,
+		path:   path,
+		log:    log.With("file", path),
+	}
+}
+
+func (l *evtxFile) Open(state checkpoint.EventLogState) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r, err := evtx.NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open %s: %w", l.path, err)
+	}
+	r.Seek(state.RecordNumber)
+
+	l.file, l.reader = f, r
+	return nil
+}
+
+func (l *evtxFile) Read() ([]Record, error) {
+	if l.reader == nil {
+		return nil, errors.New("evtx file is not open")
+	}
+
+	records := make([]Record, 0, l.config.BatchReadSize)
+	for len(records) < l.config.BatchReadSize {
+		rec, err := l.reader.Next()
+		switch {
+		case errors.Is(err, io.EOF):
+			if len(records) == 0 {
+				return nil, io.EOF
+			}
+			return records, nil
+		case errors.Is(err, evtx.ErrCorruptChunk):
+			l.log.Warnw("Skipping the remaining records of a corrupt chunk.", "error", err)
+			continue
+		case err != nil && rec.ID == 0:
+			return records, err
+		}
+		records = append(records, l.buildRecord(rec, err))
+	}
+	return records, nil
+}
+
+// buildRecord converts a record read from the file to a Record. A record that
+// could not be rendered is returned with the error so that it is still
+// accounted for.
+func (l *evtxFile) buildRecord(rec evtx.Record, renderErr error) Record {
+	includeXML := l.config.IncludeXML
+
+	var e winevent.Event
+	if renderErr != nil {
+		e.RecordID = rec.ID
+		e.TimeCreated.SystemTime = rec.Written
+		e.RenderErr = append(e.RenderErr, renderErr.Error())
+	} else {
+		var err error
+		e, err = winevent.UnmarshalXML(rec.XML)
+		if err != nil {
+			e.RenderErr = append(e.RenderErr, err.Error())
+			// Add raw XML to event.original when decoding fails
+			includeXML = true
+		}
+	}
+	if e.TimeCreated.SystemTime.IsZero() {
+		e.TimeCreated.SystemTime = rec.Written
+	}
+
+	// Get basic string values for raw fields.
+	winevent.EnrichRawValuesWithNames(nil, &e)
+
+	r := Record{
+		API:   evtxAPIName,
+		Event: e,
+		File:  l.path,
+		Offset: checkpoint.EventLogState{
+			Name:         l.path,
+			RecordNumber: rec.ID,
+			Timestamp:    rec.Written,
+		},
+	}
+	if includeXML {
+		r.XML = string(rec.XML)
+	}
+	return r
+}
+
+func (l *evtxFile) Reset() error {
+	return l.Close()
+}
+
+func (l *evtxFile) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file, l.reader = nil, nil
+	return err
+}
+
+func (l *evtxFile) Name() string { return l.path }
+
+func (l *evtxFile) Channel() string { return l.path }
+
+func (l *evtxFile) IsFile() bool { return true }
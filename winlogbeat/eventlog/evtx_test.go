@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package eventlog
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/winlogbeat/checkpoint"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const sysmonEvtx = "../sys/wineventlog/testdata/sysmon-9.01.evtx"
+
+func readEvtxFile(t *testing.T, log EventLog, state checkpoint.EventLogState) []Record {
+	t.Helper()
+	require.NoError(t, log.Open(state))
+	defer log.Close()
+
+	var records []Record
+	for {
+		batch, err := log.Read()
+		if err == io.EOF {
+			return records
+		}
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(batch), 10)
+		records = append(records, batch...)
+	}
+}
+
+func TestEvtxFile(t *testing.T) {
+	config := DefaultEvtxConfig()
+	config.BatchReadSize = 10
+	log := NewEvtxFile(sysmonEvtx, config, logp.NewLogger("evtx"))
+	assert.True(t, log.IsFile())
+	assert.Equal(t, sysmonEvtx, log.Name())
+
+	records := readEvtxFile(t, log, checkpoint.EventLogState{})
+	require.Len(t, records, 32)
+
+	first := records[0]
+	assert.Equal(t, uint64(1), first.Offset.RecordNumber)
+	assert.Equal(t, sysmonEvtx, first.Offset.Name)
+	assert.Empty(t, first.XML)
+
+	event := first.ToEvent()
+	for field, want := range map[string]interface{}{
+		"winlog.api":                "evtx",
+		"winlog.channel":            "Microsoft-Windows-Sysmon/Operational",
+		"winlog.provider_name":      "Microsoft-Windows-Sysmon",
+		"winlog.provider_guid":      "{5770385f-c22a-43e0-bf4c-06f5698ffbd9}",
+		"winlog.record_id":          uint64(1),
+		"winlog.computer_name":      "vagrant-2012-r2",
+		"winlog.user.identifier":    "S-1-5-21-3541430928-2051711210-1391384369-1001",
+		"winlog.event_data.UtcTime": "2019-03-18 16:57:37.933",
+		"winlog.process.pid":        uint32(4616),
+		"event.code":                "16",
+		"event.kind":                "event",
+		"log.level":                 "information",
+		"log.file.path":             sysmonEvtx,
+		"host.name":                 "vagrant-2012-r2",
+	} {
+		got, err := event.GetValue(field)
+		if assert.NoError(t, err, field) {
+			assert.Equal(t, want, got, field)
+		}
+	}
+	assert.Equal(t, "2019-03-18T16:57:37.933324Z", event.Timestamp.UTC().Format("2006-01-02T15:04:05.999999999Z"))
+	assert.Equal(t, first.Offset, event.Private)
+}
+
+func TestEvtxFileResume(t *testing.T) {
+	config := DefaultEvtxConfig()
+	config.IncludeXML = true
+	log := NewEvtxFile(sysmonEvtx, config, logp.NewLogger("evtx"))
+
+	records := readEvtxFile(t, log, checkpoint.EventLogState{Name: sysmonEvtx, RecordNumber: 30})
+	require.Len(t, records, 2)
+	assert.Equal(t, uint64(31), records[0].RecordID)
+	assert.Equal(t, uint64(32), records[1].RecordID)
+	assert.Contains(t, records[0].XML, "<EventRecordID>31</EventRecordID>")
+}
+
+func TestEvtxFileNotFound(t *testing.T) {
+	log := NewEvtxFile("testdata/missing.evtx", DefaultEvtxConfig(), logp.NewLogger("evtx"))
+	assert.Error(t, log.Open(checkpoint.EventLogState{}))
+}
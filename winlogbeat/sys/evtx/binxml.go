@@ -0,0 +1,533 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package evtx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/v7/winlogbeat/sys"
+)
+
+// BinXML tokens. The more data flag (0x40) is masked off before they are
+// compared.
+const (
+	tokEndOfStream          = 0x00
+	tokOpenStartElement     = 0x01
+	tokCloseStartElement    = 0x02
+	tokCloseEmptyElement    = 0x03
+	tokEndElement           = 0x04
+	tokValue                = 0x05
+	tokAttribute            = 0x06
+	tokCDATASection         = 0x07
+	tokCharRef              = 0x08
+	tokEntityRef            = 0x09
+	tokPITarget             = 0x0a
+	tokPIData               = 0x0b
+	tokTemplateInstance     = 0x0c
+	tokNormalSubstitution   = 0x0d
+	tokOptionalSubstitution = 0x0e
+	tokFragmentHeader       = 0x0f
+
+	tokMoreDataFlag = 0x40
+)
+
+// maxDepth limits the nesting of elements and templates in a record.
+const maxDepth = 64
+
+var errTruncated = errors.New("truncated binxml")
+
+// element is a rendered XML element. Its children are either *element or
+// string holding already escaped character data.
+type element struct {
+	name     string
+	attrs    []attribute
+	children []interface{}
+	empty    bool // Closed by a close empty element token.
+}
+
+type attribute struct {
+	name  string
+	value string // Escaped attribute value.
+}
+
+// substitution is a value from the substitution array of a template instance.
+type substitution struct {
+	typ    byte
+	offset int // Chunk offset of the value data.
+	size   int
+}
+
+// binXML renders the BinXML fragments stored in a chunk. All offsets in
+// BinXML, such as those of names and template definitions, are relative to
+// the start of the chunk.
+type binXML struct {
+	chunk []byte
+	names map[uint32]string
+	depth int
+}
+
+func newBinXML(chunk []byte) *binXML {
+	return &binXML{chunk: chunk, names: map[uint32]string{}}
+}
+
+// render renders the BinXML fragment in chunk[start:end] to XML.
+func (b *binXML) render(start, end int) ([]byte, error) {
+	b.depth = 0
+	var root element
+	if _, err := b.parseNodes(start, end, nil, &root); err != nil {
+		return nil, err
+	}
+	for _, c := range root.children {
+		if el, ok := c.(*element); ok {
+			var buf bytes.Buffer
+			el.writeTo(&buf)
+			return buf.Bytes(), nil
+		}
+	}
+	return nil, errors.New("binxml fragment has no root element")
+}
+
+// parseNodes parses the tokens starting at pos and adds the resulting nodes to
+// parent. It stops at the end of the stream or at the end element token of the
+// parent and returns the position of that token.
+func (b *binXML) parseNodes(pos, end int, subs []substitution, parent *element) (int, error) {
+	b.depth++
+	defer func() { b.depth-- }()
+	if b.depth > maxDepth {
+		return pos, errors.New("binxml nesting is too deep")
+	}
+
+	for pos < end {
+		tok := b.chunk[pos]
+		switch tok &^ tokMoreDataFlag {
+		case tokEndOfStream, tokEndElement:
+			return pos, nil
+
+		case tokFragmentHeader:
+			pos += 4
+
+		case tokOpenStartElement:
+			els, next, err := b.parseElement(pos, end, subs)
+			if err != nil {
+				return pos, err
+			}
+			for _, el := range els {
+				parent.children = append(parent.children, el)
+			}
+			pos = next
+
+		case tokValue, tokCharRef, tokEntityRef, tokCDATASection:
+			text, next, err := b.parseText(pos, end, textEscaper)
+			if err != nil {
+				return pos, err
+			}
+			parent.children = append(parent.children, text)
+			pos = next
+
+		case tokNormalSubstitution, tokOptionalSubstitution:
+			if pos+4 > end {
+				return pos, errTruncated
+			}
+			id := int(binary.LittleEndian.Uint16(b.chunk[pos+1:]))
+			pos += 4
+			if id >= len(subs) {
+				return pos, fmt.Errorf("substitution %d is out of range", id)
+			}
+			if err := b.substitute(subs[id], parent); err != nil {
+				return pos, err
+			}
+
+		case tokTemplateInstance:
+			next, err := b.parseTemplateInstance(pos, end, parent)
+			if err != nil {
+				return pos, err
+			}
+			pos = next
+
+		case tokPITarget:
+			pi, next, err := b.parsePI(pos, end)
+			if err != nil {
+				return pos, err
+			}
+			parent.children = append(parent.children, pi)
+			pos = next
+
+		default:
+			return pos, fmt.Errorf("unexpected binxml token 0x%02x at offset %d", tok, pos)
+		}
+	}
+	return pos, nil
+}
+
+// parseElement parses the element starting at the open start element token at
+// pos including its attributes and content. An element whose content is only
+// an optional substitution without value is left out, and an element whose
+// content is only an array substitution is repeated for each array item, like
+// the Windows Event Log renders them.
+func (b *binXML) parseElement(pos, end int, subs []substitution) ([]*element, int, error) {
+	start := pos
+	hasAttrs := b.chunk[pos]&tokMoreDataFlag != 0
+
+	// The element is followed by a dependency identifier, the data size and
+	// the name offset. Some writers omit the dependency identifier, which is
+	// detected by the name offset pointing neither backwards nor directly
+	// after itself.
+	pos = start + 11
+	if pos > end {
+		return nil, pos, errTruncated
+	}
+	nameOffset := binary.LittleEndian.Uint32(b.chunk[start+7:])
+	if int(nameOffset) != pos && int(nameOffset) >= start {
+		pos = start + 9
+		nameOffset = binary.LittleEndian.Uint32(b.chunk[start+5:])
+	}
+
+	name, pos, err := b.readName(nameOffset, pos)
+	if err != nil {
+		return nil, pos, err
+	}
+	if hasAttrs {
+		pos += 4 // Attribute list size.
+	}
+
+	el := &element{name: name}
+	for pos < end && b.chunk[pos]&^tokMoreDataFlag == tokAttribute {
+		if pos+5 > end {
+			return nil, pos, errTruncated
+		}
+		attrName, next, err := b.readName(binary.LittleEndian.Uint32(b.chunk[pos+1:]), pos+5)
+		if err != nil {
+			return nil, pos, err
+		}
+		value, present, next, err := b.parseAttributeValue(next, end, subs)
+		if err != nil {
+			return nil, pos, err
+		}
+		if present {
+			el.attrs = append(el.attrs, attribute{name: attrName, value: value})
+		}
+		pos = next
+	}
+
+	if pos >= end {
+		return nil, pos, errTruncated
+	}
+	switch b.chunk[pos] {
+	case tokCloseEmptyElement:
+		el.empty = true
+		return []*element{el}, pos + 1, nil
+	case tokCloseStartElement:
+		pos++
+	default:
+		return nil, pos, fmt.Errorf("unexpected binxml token 0x%02x in element %q", b.chunk[pos], el.name)
+	}
+
+	if sub, optional, ok := b.soleSubstitution(pos, end, subs); ok && sub.typ != typeBinXML {
+		pos += 5 // Substitution and end element tokens.
+		if sub.typ == typeNull || sub.size == 0 {
+			if optional {
+				return nil, pos, nil
+			}
+			return []*element{el}, pos, nil
+		}
+		values, err := formatValues(sub.typ, b.chunk[sub.offset:sub.offset+sub.size])
+		if err != nil {
+			return nil, pos, err
+		}
+		els := make([]*element, len(values))
+		for i, v := range values {
+			els[i] = &element{name: el.name, attrs: el.attrs, children: []interface{}{textEscaper.Replace(v)}}
+		}
+		return els, pos, nil
+	}
+
+	pos, err = b.parseNodes(pos, end, subs, el)
+	if err != nil {
+		return nil, pos, err
+	}
+	if pos >= end || b.chunk[pos] != tokEndElement {
+		return nil, pos, fmt.Errorf("element %q is not closed", el.name)
+	}
+	return []*element{el}, pos + 1, nil
+}
+
+// soleSubstitution returns the substitution at pos when it is the only
+// content of an element.
+func (b *binXML) soleSubstitution(pos, end int, subs []substitution) (sub substitution, optional, ok bool) {
+	if pos+5 > end || b.chunk[pos+4] != tokEndElement {
+		return sub, false, false
+	}
+	tok := b.chunk[pos]
+	if tok != tokNormalSubstitution && tok != tokOptionalSubstitution {
+		return sub, false, false
+	}
+	id := int(binary.LittleEndian.Uint16(b.chunk[pos+1:]))
+	if id >= len(subs) {
+		return sub, false, false
+	}
+	return subs[id], tok == tokOptionalSubstitution, true
+}
+
+// parseAttributeValue parses the tokens that make up an attribute value. An
+// attribute whose value consists only of empty optional substitutions is not
+// present in the rendered XML.
+func (b *binXML) parseAttributeValue(pos, end int, subs []substitution) (string, bool, int, error) {
+	var (
+		buf     strings.Builder
+		present bool
+	)
+	for pos < end {
+		switch b.chunk[pos] &^ tokMoreDataFlag {
+		case tokValue, tokCharRef, tokEntityRef:
+			text, next, err := b.parseText(pos, end, attrEscaper)
+			if err != nil {
+				return "", false, pos, err
+			}
+			buf.WriteString(text)
+			present = true
+			pos = next
+
+		case tokNormalSubstitution, tokOptionalSubstitution:
+			if pos+4 > end {
+				return "", false, pos, errTruncated
+			}
+			optional := b.chunk[pos] == tokOptionalSubstitution
+			id := int(binary.LittleEndian.Uint16(b.chunk[pos+1:]))
+			pos += 4
+			if id >= len(subs) {
+				return "", false, pos, fmt.Errorf("substitution %d is out of range", id)
+			}
+			sub := subs[id]
+			if optional && (sub.typ == typeNull || sub.size == 0) {
+				continue
+			}
+			value, err := formatValue(sub.typ, b.chunk[sub.offset:sub.offset+sub.size])
+			if err != nil {
+				return "", false, pos, err
+			}
+			buf.WriteString(attrEscaper.Replace(value))
+			present = true
+
+		default:
+			return buf.String(), present, pos, nil
+		}
+	}
+	return "", false, pos, errTruncated
+}
+
+// parseText parses a value, character reference, entity reference or CDATA
+// section token and returns its escaped XML representation.
+func (b *binXML) parseText(pos, end int, escaper *strings.Replacer) (string, int, error) {
+	switch b.chunk[pos] &^ tokMoreDataFlag {
+	case tokValue:
+		// Token, value type and a length-prefixed UTF-16 string.
+		if pos+2 > end {
+			return "", pos, errTruncated
+		}
+		s, next, err := b.readString(pos+2, end)
+		return escaper.Replace(s), next, err
+
+	case tokCDATASection:
+		s, next, err := b.readString(pos+1, end)
+		return "<![CDATA[" + s + "]]>", next, err
+
+	case tokCharRef:
+		if pos+3 > end {
+			return "", pos, errTruncated
+		}
+		return fmt.Sprintf("&#%d;", binary.LittleEndian.Uint16(b.chunk[pos+1:])), pos + 3, nil
+
+	default: // tokEntityRef
+		if pos+5 > end {
+			return "", pos, errTruncated
+		}
+		name, next, err := b.readName(binary.LittleEndian.Uint32(b.chunk[pos+1:]), pos+5)
+		return "&" + name + ";", next, err
+	}
+}
+
+// parsePI parses a processing instruction target and its data.
+func (b *binXML) parsePI(pos, end int) (string, int, error) {
+	if pos+5 > end {
+		return "", pos, errTruncated
+	}
+	target, pos, err := b.readName(binary.LittleEndian.Uint32(b.chunk[pos+1:]), pos+5)
+	if err != nil {
+		return "", pos, err
+	}
+	if pos >= end || b.chunk[pos] != tokPIData {
+		return "<?" + target + "?>", pos, nil
+	}
+	data, pos, err := b.readString(pos+1, end)
+	return "<?" + target + " " + data + "?>", pos, err
+}
+
+// parseTemplateInstance parses a template instance and renders its template
+// definition with the values of its substitution array into parent. The
+// definition is stored inline the first time a template is used in a chunk
+// and referenced by offset afterwards.
+func (b *binXML) parseTemplateInstance(pos, end int, parent *element) (int, error) {
+	// Token, unknown byte, template identifier and definition offset.
+	if pos+10 > end {
+		return pos, errTruncated
+	}
+	defOffset := int(binary.LittleEndian.Uint32(b.chunk[pos+6:]))
+	pos += 10
+
+	// Definition header: next definition offset, GUID and data size.
+	if defOffset+24 > len(b.chunk) {
+		return pos, fmt.Errorf("template definition offset %d is out of range", defOffset)
+	}
+	defSize := int(binary.LittleEndian.Uint32(b.chunk[defOffset+20:]))
+	defStart, defEnd := defOffset+24, defOffset+24+defSize
+	if defEnd > len(b.chunk) {
+		return pos, fmt.Errorf("template definition at offset %d is out of range", defOffset)
+	}
+	if defOffset == pos {
+		pos = defEnd
+	}
+
+	// Substitution array: the number of values, a descriptor with size and
+	// type for each value, followed by the data of all values.
+	if pos+4 > end {
+		return pos, errTruncated
+	}
+	n := int(binary.LittleEndian.Uint32(b.chunk[pos:]))
+	pos += 4
+	if n < 0 || pos+4*n > end {
+		return pos, fmt.Errorf("invalid number of template substitutions %d", n)
+	}
+	subs := make([]substitution, n)
+	dataPos := pos + 4*n
+	for i := range subs {
+		d := b.chunk[pos+4*i:]
+		subs[i] = substitution{
+			typ:    d[2],
+			offset: dataPos,
+			size:   int(binary.LittleEndian.Uint16(d)),
+		}
+		dataPos += subs[i].size
+	}
+	if dataPos > end {
+		return pos, errTruncated
+	}
+
+	if _, err := b.parseNodes(defStart, defEnd, subs, parent); err != nil {
+		return pos, err
+	}
+	return dataPos, nil
+}
+
+// substitute adds the rendered value of a substitution to the content of
+// parent. BinXML values are rendered as nested fragments.
+func (b *binXML) substitute(sub substitution, parent *element) error {
+	if sub.typ == typeBinXML {
+		_, err := b.parseNodes(sub.offset, sub.offset+sub.size, nil, parent)
+		return err
+	}
+	if sub.typ == typeNull || sub.size == 0 {
+		return nil
+	}
+	value, err := formatValue(sub.typ, b.chunk[sub.offset:sub.offset+sub.size])
+	if err != nil {
+		return err
+	}
+	parent.children = append(parent.children, textEscaper.Replace(value))
+	return nil
+}
+
+// readName returns the name at the given chunk offset. When the name is stored
+// inline at pos, the returned position is moved past it.
+func (b *binXML) readName(offset uint32, pos int) (string, int, error) {
+	name, ok := b.names[offset]
+	if !ok {
+		// Next name offset, hash and a length-prefixed, null-terminated
+		// UTF-16 string.
+		o := int(offset)
+		if o+8 > len(b.chunk) {
+			return "", pos, fmt.Errorf("name offset %d is out of range", offset)
+		}
+		n := int(binary.LittleEndian.Uint16(b.chunk[o+6:]))
+		if o+8+2*n > len(b.chunk) {
+			return "", pos, fmt.Errorf("name at offset %d is out of range", offset)
+		}
+		var err error
+		name, err = sys.UTF16BytesToString(b.chunk[o+8 : o+8+2*n])
+		if err != nil {
+			return "", pos, err
+		}
+		b.names[offset] = name
+	}
+	if int(offset) == pos {
+		pos += 10 + 2*int(binary.LittleEndian.Uint16(b.chunk[pos+6:]))
+	}
+	return name, pos, nil
+}
+
+// readString reads a UTF-16 string prefixed by its number of characters.
+func (b *binXML) readString(pos, end int) (string, int, error) {
+	if pos+2 > end {
+		return "", pos, errTruncated
+	}
+	n := int(binary.LittleEndian.Uint16(b.chunk[pos:]))
+	pos += 2
+	if pos+2*n > end {
+		return "", pos, errTruncated
+	}
+	s, err := sys.UTF16BytesToString(b.chunk[pos : pos+2*n])
+	return s, pos + 2*n, err
+}
+
+// Like the Windows Event Log, only the characters that would otherwise change
+// the meaning of the XML are escaped.
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+func (e *element) writeTo(buf *bytes.Buffer) {
+	buf.WriteByte('<')
+	buf.WriteString(e.name)
+	for _, a := range e.attrs {
+		buf.WriteByte(' ')
+		buf.WriteString(a.name)
+		buf.WriteString(`="`)
+		buf.WriteString(a.value)
+		buf.WriteByte('"')
+	}
+	if e.empty {
+		buf.WriteString("/>")
+		return
+	}
+	buf.WriteByte('>')
+	for _, c := range e.children {
+		switch c := c.(type) {
+		case *element:
+			c.writeTo(buf)
+		case string:
+			buf.WriteString(c)
+		}
+	}
+	buf.WriteString("</")
+	buf.WriteString(e.name)
+	buf.WriteByte('>')
+}
@@ -0,0 +1,209 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package evtx reads Windows event log files (.evtx) without the Windows API.
+// It decodes the file and chunk structures and renders the BinXML of each
+// event record, including its templates and substitutions, to the same XML
+// that the Windows Event Log API produces for the event.
+package evtx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	fileHeaderSize   = 4096
+	chunkSize        = 65536
+	chunkHeaderSize  = 512
+	recordHeaderSize = 24
+)
+
+var (
+	fileSignature   = []byte("ElfFile\x00")
+	chunkSignature  = []byte("ElfChnk\x00")
+	recordSignature = []byte{0x2a, 0x2a, 0x00, 0x00}
+)
+
+var (
+	// ErrNotEvtx is returned when the file header does not carry the EVTX
+	// signature.
+	ErrNotEvtx = errors.New("not an evtx file")
+
+	// ErrCorruptChunk is returned when the records of a chunk cannot be
+	// decoded. Reading continues with the next chunk.
+	ErrCorruptChunk = errors.New("corrupt evtx chunk")
+)
+
+// Record is a single event record read from an EVTX file.
+type Record struct {
+	ID      uint64    // Event record identifier.
+	Written time.Time // Time the record was written to the log.
+	XML     []byte    // Event rendered as XML.
+}
+
+// Reader reads the event records of an EVTX file in the order in which they
+// are stored.
+type Reader struct {
+	r     io.ReaderAt
+	size  int64
+	after uint64 // Records with an identifier up to and including after are skipped.
+
+	nextChunk int64  // Index of the next chunk to load.
+	chunk     []byte // Current chunk.
+	pos, end  int    // Position of the next record and end of the records in the chunk.
+	binxml    *binXML
+}
+
+// NewReader returns a Reader for the EVTX file of the given size that is
+// accessible through r. It returns ErrNotEvtx when the file header is not
+// valid.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	header := make([]byte, 128)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNotEvtx
+		}
+		return nil, fmt.Errorf("failed to read evtx file header: %w", err)
+	}
+	if !bytes.Equal(header[:8], fileSignature) {
+		return nil, ErrNotEvtx
+	}
+	if major := binary.LittleEndian.Uint16(header[38:]); major != 3 {
+		return nil, fmt.Errorf("unsupported evtx format version %d", major)
+	}
+
+	return &Reader{
+		r:     r,
+		size:  size,
+		chunk: make([]byte, chunkSize),
+	}, nil
+}
+
+// Seek positions the reader so that the next record returned by Next is the
+// first one with an identifier greater than recordID. Chunks that only hold
+// older records are skipped without being decoded.
+func (r *Reader) Seek(recordID uint64) {
+	r.after = recordID
+}
+
+// Next returns the next event record. It returns io.EOF when there are no
+// more records in the file. When the BinXML of a record cannot be rendered
+// the record is returned without XML along with the error, and the reader
+// moves on to the following record on the next call.
+func (r *Reader) Next() (Record, error) {
+	for {
+		if r.pos+recordHeaderSize > r.end {
+			if err := r.loadChunk(); err != nil {
+				return Record{}, err
+			}
+			continue
+		}
+
+		rec, ok, err := r.nextRecord()
+		if err != nil {
+			return rec, err
+		}
+		if !ok || rec.ID <= r.after {
+			continue
+		}
+		return rec, nil
+	}
+}
+
+// loadChunk loads the next chunk that holds records newer than r.after.
+func (r *Reader) loadChunk() error {
+	for {
+		offset := fileHeaderSize + r.nextChunk*chunkSize
+		if offset+chunkSize > r.size {
+			return io.EOF
+		}
+		r.nextChunk++
+
+		if _, err := r.r.ReadAt(r.chunk, offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.EOF
+			}
+			return fmt.Errorf("failed to read evtx chunk at offset %d: %w", offset, err)
+		}
+
+		// Chunks that have been allocated but never written are zero-filled.
+		if !bytes.Equal(r.chunk[:8], chunkSignature) {
+			continue
+		}
+		if lastID := binary.LittleEndian.Uint64(r.chunk[32:]); lastID <= r.after {
+			continue
+		}
+
+		end := int(binary.LittleEndian.Uint32(r.chunk[48:]))
+		if end < chunkHeaderSize || end > chunkSize {
+			end = chunkSize
+		}
+		r.pos, r.end = chunkHeaderSize, end
+		r.binxml = newBinXML(r.chunk)
+		return nil
+	}
+}
+
+// nextRecord decodes the record at the current position of the chunk. It
+// returns false when the remainder of the chunk does not hold any records.
+func (r *Reader) nextRecord() (Record, bool, error) {
+	b := r.chunk[r.pos:r.end]
+	if !bytes.Equal(b[:4], recordSignature) {
+		r.pos = r.end
+		return Record{}, false, nil
+	}
+
+	size := int(binary.LittleEndian.Uint32(b[4:]))
+	if size < recordHeaderSize+4 || size > len(b) {
+		offset := r.pos
+		r.pos = r.end
+		return Record{}, false, fmt.Errorf("%w: invalid record size %d at chunk %d offset %d", ErrCorruptChunk, size, r.nextChunk-1, offset)
+	}
+
+	rec := Record{
+		ID:      binary.LittleEndian.Uint64(b[8:]),
+		Written: filetimeToTime(binary.LittleEndian.Uint64(b[16:])),
+	}
+	start := r.pos + recordHeaderSize
+	end := r.pos + size - 4
+	r.pos += size
+
+	if rec.ID <= r.after {
+		return rec, true, nil
+	}
+
+	xml, err := r.binxml.render(start, end)
+	if err != nil {
+		return rec, true, fmt.Errorf("failed to render evtx record %d: %w", rec.ID, err)
+	}
+	rec.XML = xml
+	return rec, true, nil
+}
+
+// filetimeToTime converts a Windows FILETIME, the number of 100-nanosecond
+// intervals since January 1, 1601 UTC, to a time.Time.
+func filetimeToTime(ft uint64) time.Time {
+	const epochDelta = 11644473600 // Seconds between 1601-01-01 and 1970-01-01.
+	sec := int64(ft/1e7) - epochDelta
+	nsec := int64(ft%1e7) * 100
+	return time.Unix(sec, nsec).UTC()
+}
@@ -0,0 +1,486 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package evtx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/winlogbeat/sys/winevent"
+)
+
+// chunkBuilder writes BinXML records into a chunk. Offsets in BinXML are
+// relative to the start of the chunk, so everything is written to a single
+// buffer holding the chunk.
+type chunkBuilder struct {
+	buf       []byte
+	names     map[string]int
+	templates map[uint32]int
+	firstID   uint64
+	lastID    uint64
+}
+
+type testValue struct {
+	typ  byte
+	data []byte
+	xml  func(*chunkBuilder) // Writes a BinXML value.
+}
+
+func newChunkBuilder() *chunkBuilder {
+	c := &chunkBuilder{
+		buf:       make([]byte, chunkHeaderSize),
+		names:     map[string]int{},
+		templates: map[uint32]int{},
+	}
+	copy(c.buf, chunkSignature)
+	return c
+}
+
+func (c *chunkBuilder) bytes(b ...byte) { c.buf = append(c.buf, b...) }
+
+func (c *chunkBuilder) u16(v uint16) { c.buf = binary.LittleEndian.AppendUint16(c.buf, v) }
+
+func (c *chunkBuilder) u32(v uint32) { c.buf = binary.LittleEndian.AppendUint32(c.buf, v) }
+
+func (c *chunkBuilder) u64(v uint64) { c.buf = binary.LittleEndian.AppendUint64(c.buf, v) }
+
+func (c *chunkBuilder) utf16(s string) {
+	for _, u := range utf16.Encode([]rune(s)) {
+		c.u16(u)
+	}
+}
+
+// name writes a name offset followed by the name itself when it has not been
+// written to the chunk before.
+func (c *chunkBuilder) name(s string) {
+	if off, ok := c.names[s]; ok {
+		c.u32(uint32(off))
+		return
+	}
+	off := len(c.buf) + 4
+	c.names[s] = off
+	c.u32(uint32(off))
+	c.u32(0) // Next name offset.
+	c.u16(0) // Hash.
+	c.u16(uint16(len(utf16.Encode([]rune(s)))))
+	c.utf16(s)
+	c.u16(0)
+}
+
+func (c *chunkBuilder) open(name string, attrs bool) {
+	if attrs {
+		c.bytes(tokOpenStartElement | tokMoreDataFlag)
+	} else {
+		c.bytes(tokOpenStartElement)
+	}
+	c.u16(0xffff) // Dependency identifier.
+	c.u32(0)      // Data size.
+	c.name(name)
+	if attrs {
+		c.u32(0) // Attribute list size.
+	}
+}
+
+func (c *chunkBuilder) attr(name string) {
+	c.bytes(tokAttribute)
+	c.name(name)
+}
+
+func (c *chunkBuilder) value(s string) {
+	c.bytes(tokValue, typeString)
+	c.u16(uint16(len(utf16.Encode([]rune(s)))))
+	c.utf16(s)
+}
+
+func (c *chunkBuilder) sub(id uint16, typ byte, optional bool) {
+	if optional {
+		c.bytes(tokOptionalSubstitution)
+	} else {
+		c.bytes(tokNormalSubstitution)
+	}
+	c.u16(id)
+	c.bytes(typ)
+}
+
+// template writes a template instance. The definition is written inline the
+// first time the template identifier is used.
+func (c *chunkBuilder) template(id uint32, def func(), values []testValue) {
+	c.bytes(tokTemplateInstance, 0x01)
+	c.u32(id)
+	if off, ok := c.templates[id]; ok {
+		c.u32(uint32(off))
+	} else {
+		off := len(c.buf) + 4
+		c.templates[id] = off
+		c.u32(uint32(off))
+		c.u32(0)                     // Next template offset.
+		c.bytes(make([]byte, 16)...) // GUID.
+		sizeAt := len(c.buf)
+		c.u32(0)
+		def()
+		binary.LittleEndian.PutUint32(c.buf[sizeAt:], uint32(len(c.buf)-sizeAt-4))
+	}
+
+	c.u32(uint32(len(values)))
+	descAt := len(c.buf)
+	c.bytes(make([]byte, 4*len(values))...)
+	for i, v := range values {
+		start := len(c.buf)
+		if v.xml != nil {
+			v.xml(c)
+		} else {
+			c.bytes(v.data...)
+		}
+		binary.LittleEndian.PutUint16(c.buf[descAt+4*i:], uint16(len(c.buf)-start))
+		c.buf[descAt+4*i+2] = v.typ
+	}
+}
+
+func (c *chunkBuilder) record(id uint64, written time.Time, body func()) {
+	if c.firstID == 0 {
+		c.firstID = id
+	}
+	c.lastID = id
+
+	start := len(c.buf)
+	c.bytes(recordSignature...)
+	c.u32(0)
+	c.u64(id)
+	c.u64(timeToFiletime(written))
+	body()
+	c.u32(uint32(len(c.buf) - start + 4))
+	binary.LittleEndian.PutUint32(c.buf[start+4:], uint32(len(c.buf)-start))
+}
+
+func (c *chunkBuilder) finish() []byte {
+	binary.LittleEndian.PutUint64(c.buf[8:], c.firstID)
+	binary.LittleEndian.PutUint64(c.buf[16:], c.lastID)
+	binary.LittleEndian.PutUint64(c.buf[24:], c.firstID)
+	binary.LittleEndian.PutUint64(c.buf[32:], c.lastID)
+	binary.LittleEndian.PutUint32(c.buf[40:], 128)
+	binary.LittleEndian.PutUint32(c.buf[48:], uint32(len(c.buf)))
+	chunk := make([]byte, chunkSize)
+	copy(chunk, c.buf)
+	return chunk
+}
+
+func timeToFiletime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
+}
+
+func newTestFile(chunks ...[]byte) []byte {
+	header := make([]byte, fileHeaderSize)
+	copy(header, fileSignature)
+	binary.LittleEndian.PutUint32(header[32:], 128)
+	binary.LittleEndian.PutUint16(header[36:], 1)
+	binary.LittleEndian.PutUint16(header[38:], 3)
+	binary.LittleEndian.PutUint16(header[40:], fileHeaderSize)
+	binary.LittleEndian.PutUint16(header[42:], uint16(len(chunks)))
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	for _, c := range chunks {
+		buf.Write(c)
+	}
+	return buf.Bytes()
+}
+
+var (
+	testGUID = []byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x78, 0x56, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}
+	testSID  = []byte{1, 5, 0, 0, 0, 0, 0, 5, 21, 0, 0, 0, 0xe8, 0x03, 0, 0, 0xe9, 0x03, 0, 0, 0xea, 0x03, 0, 0, 0xf4, 0x01, 0, 0}
+)
+
+// eventTemplate writes the definition of an event template similar to the
+// ones written by the Windows Event Log service.
+func eventTemplate(c *chunkBuilder) func() {
+	return func() {
+		c.bytes(tokFragmentHeader, 1, 1, 0)
+		c.open("Event", true)
+		c.attr("xmlns")
+		c.value("http://schemas.microsoft.com/win/2004/08/events/event")
+		c.bytes(tokCloseStartElement)
+
+		c.open("System", false)
+		c.bytes(tokCloseStartElement)
+		c.open("Provider", true)
+		c.bytes(tokAttribute | tokMoreDataFlag)
+		c.name("Name")
+		c.sub(0, typeString, true)
+		c.attr("Guid")
+		c.sub(1, typeGUID, true)
+		c.bytes(tokCloseEmptyElement)
+		for i, name := range []string{"EventID", "Level", "Keywords"} {
+			c.open(name, false)
+			c.bytes(tokCloseStartElement)
+			c.sub(uint16(2+i), []byte{typeUint16, typeUint8, typeHexInt64}[i], true)
+			c.bytes(tokEndElement)
+		}
+		c.open("TimeCreated", true)
+		c.attr("SystemTime")
+		c.sub(5, typeFileTime, true)
+		c.bytes(tokCloseEmptyElement)
+		c.open("EventRecordID", false)
+		c.bytes(tokCloseStartElement)
+		c.sub(6, typeUint64, true)
+		c.bytes(tokEndElement)
+		c.open("Channel", false)
+		c.bytes(tokCloseStartElement)
+		c.value("Application")
+		c.bytes(tokEndElement)
+		c.open("Computer", false)
+		c.bytes(tokCloseStartElement)
+		c.sub(7, typeString, true)
+		c.bytes(tokEndElement)
+		c.open("Security", true)
+		c.attr("UserID")
+		c.sub(8, typeSID, true)
+		c.bytes(tokCloseEmptyElement)
+		c.bytes(tokEndElement) // System
+
+		c.sub(9, typeBinXML, true)
+		c.bytes(tokEndElement) // Event
+		c.bytes(tokEndOfStream)
+	}
+}
+
+// eventData returns a BinXML value holding an EventData element.
+func eventData(pairs ...string) testValue {
+	return testValue{typ: typeBinXML, xml: func(c *chunkBuilder) {
+		c.bytes(tokFragmentHeader, 1, 1, 0)
+		c.open("EventData", false)
+		c.bytes(tokCloseStartElement)
+		for i := 0; i < len(pairs); i += 2 {
+			c.open("Data", true)
+			c.attr("Name")
+			c.value(pairs[i])
+			c.bytes(tokCloseStartElement)
+			c.value(pairs[i+1])
+			c.bytes(tokEndElement)
+		}
+		c.bytes(tokEndElement)
+		c.bytes(tokEndOfStream)
+	}}
+}
+
+func stringValue(s string) testValue {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return testValue{typ: typeString, data: b}
+}
+
+func uintValue(typ byte, v uint64, size int) testValue {
+	b := binary.LittleEndian.AppendUint64(nil, v)
+	return testValue{typ: typ, data: b[:size]}
+}
+
+func testEvent(c *chunkBuilder, id uint64, created time.Time, sid []byte, data testValue) {
+	c.record(id, created, func() {
+		c.bytes(tokFragmentHeader, 1, 1, 0)
+		c.template(0x1234, eventTemplate(c), []testValue{
+			stringValue("Microsoft-Windows-Security-Auditing"),
+			{typ: typeGUID, data: testGUID},
+			uintValue(typeUint16, 4624, 2),
+			uintValue(typeUint8, 0, 1),
+			uintValue(typeHexInt64, 0x8020000000000000, 8),
+			uintValue(typeFileTime, timeToFiletime(created), 8),
+			uintValue(typeUint64, id, 8),
+			stringValue("WIN-HOST"),
+			{typ: typeSID, data: sid},
+			data,
+		})
+		c.bytes(tokEndOfStream)
+	})
+}
+
+func testFile(t *testing.T) []byte {
+	t.Helper()
+	created := time.Date(2024, 5, 1, 10, 30, 0, 123456700, time.UTC)
+
+	first := newChunkBuilder()
+	testEvent(first, 1, created, testSID, eventData("TargetUserName", "alice", "LogonType", "3"))
+	testEvent(first, 2, created.Add(time.Second), nil, eventData("TargetUserName", "bob & eve"))
+
+	second := newChunkBuilder()
+	testEvent(second, 3, created.Add(2*time.Second), testSID, eventData())
+
+	// The unused chunk at the end is zero-filled.
+	return newTestFile(first.finish(), second.finish(), make([]byte, chunkSize))
+}
+
+func readAll(t *testing.T, r *Reader) []Record {
+	t.Helper()
+	var records []Record
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, rec)
+	}
+}
+
+func TestReader(t *testing.T) {
+	data := testFile(t)
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	records := readAll(t, r)
+	require.Len(t, records, 3)
+
+	created := time.Date(2024, 5, 1, 10, 30, 0, 123456700, time.UTC)
+	assert.Equal(t, uint64(1), records[0].ID)
+	assert.Equal(t, created, records[0].Written)
+	assert.Equal(t, `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">`+
+		`<System><Provider Name="Microsoft-Windows-Security-Auditing" Guid="{12345678-1234-5678-1234-56789abcdef0}"/>`+
+		`<EventID>4624</EventID><Level>0</Level><Keywords>0x8020000000000000</Keywords>`+
+		`<TimeCreated SystemTime="2024-05-01T10:30:00.1234567Z"/><EventRecordID>1</EventRecordID>`+
+		`<Channel>Application</Channel><Computer>WIN-HOST</Computer>`+
+		`<Security UserID="S-1-5-21-1000-1001-1002-500"/></System>`+
+		`<EventData><Data Name="TargetUserName">alice</Data><Data Name="LogonType">3</Data></EventData>`+
+		`</Event>`, string(records[0].XML))
+
+	evt, err := winevent.UnmarshalXML(records[0].XML)
+	require.NoError(t, err)
+	assert.Equal(t, "Microsoft-Windows-Security-Auditing", evt.Provider.Name)
+	assert.Equal(t, uint32(4624), evt.EventIdentifier.ID)
+	assert.Equal(t, created, evt.TimeCreated.SystemTime)
+	assert.Equal(t, "S-1-5-21-1000-1001-1002-500", evt.User.Identifier)
+	assert.Equal(t, "Application", evt.Channel)
+
+	// The second record references the template of the first one and its
+	// optional, empty user identifier is left out.
+	evt, err = winevent.UnmarshalXML(records[1].XML)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), evt.RecordID)
+	assert.Empty(t, evt.User.Identifier)
+	assert.NotContains(t, string(records[1].XML), "UserID")
+	assert.Contains(t, string(records[1].XML), "bob &amp; eve")
+
+	assert.Equal(t, uint64(3), records[2].ID)
+	assert.Contains(t, string(records[2].XML), "<EventData></EventData>")
+}
+
+func TestReaderSeek(t *testing.T) {
+	data := testFile(t)
+
+	for after, want := range map[uint64][]uint64{
+		0: {1, 2, 3},
+		1: {2, 3},
+		2: {3},
+		3: nil,
+	} {
+		r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		r.Seek(after)
+
+		var ids []uint64
+		for _, rec := range readAll(t, r) {
+			ids = append(ids, rec.ID)
+		}
+		assert.Equal(t, want, ids, "seek to %d", after)
+	}
+}
+
+// TestReaderWindowsSamples compares the rendered XML of the sample files with
+// the XML rendered by the Windows Event Log API.
+func TestReaderWindowsSamples(t *testing.T) {
+	files, err := filepath.Glob("../wineventlog/testdata/*.evtx")
+	require.NoError(t, err)
+
+	eventRE := regexp.MustCompile(`(?s)<Event .*?</Event>`)
+	renderingInfoRE := regexp.MustCompile(`(?s)<RenderingInfo.*?</RenderingInfo>`)
+	recordIDRE := regexp.MustCompile(`<EventRecordID>(\d+)</EventRecordID>`)
+
+	var compared int
+	for _, file := range files {
+		want, err := os.ReadFile(strings.TrimSuffix(file, ".evtx") + ".xml")
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		require.NoError(t, err)
+
+		f, err := os.Open(file)
+		require.NoError(t, err)
+		defer f.Close()
+		info, err := f.Stat()
+		require.NoError(t, err)
+		r, err := NewReader(f, info.Size())
+		require.NoError(t, err)
+
+		got := map[string]string{}
+		for _, rec := range readAll(t, r) {
+			got[recordIDRE.FindStringSubmatch(string(rec.XML))[1]] = string(rec.XML)
+		}
+
+		for _, event := range eventRE.FindAllString(string(want), -1) {
+			// Windows quotes attributes with apostrophes.
+			event = strings.ReplaceAll(renderingInfoRE.ReplaceAllString(event, ""), "'", `"`)
+			id := recordIDRE.FindStringSubmatch(event)[1]
+			assert.Equal(t, event, got[id], "record %s of %s", id, file)
+			compared++
+		}
+	}
+	assert.NotZero(t, compared)
+}
+
+func TestReaderNotEvtx(t *testing.T) {
+	for _, data := range [][]byte{nil, bytes.Repeat([]byte("x"), fileHeaderSize)} {
+		_, err := NewReader(bytes.NewReader(data), int64(len(data)))
+		assert.ErrorIs(t, err, ErrNotEvtx)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		typ  byte
+		data []byte
+		want string
+	}{
+		{typeInt32, []byte{0xff, 0xff, 0xff, 0xff}, "-1"},
+		{typeBool, []byte{1, 0, 0, 0}, "true"},
+		{typeHexInt32, []byte{0x10, 0, 0, 0}, "0x10"},
+		{typeSizeT, []byte{0x20, 0, 0, 0}, "0x20"},
+		{typeBinary, []byte{0xde, 0xad}, "DEAD"},
+		{typeAnsiString, []byte("abc\x00"), "abc"},
+		{typeSysTime, []byte{0xe8, 0x07, 5, 0, 3, 0, 1, 0, 10, 0, 30, 0, 0, 0, 0x7b, 0}, "2024-05-01T10:30:00.1230000Z"},
+		{typeString | typeArrayFlag, []byte{'a', 0, 0, 0, 'b', 0, 'c', 0, 0, 0}, "a,bc"},
+		{typeUint16 | typeArrayFlag, []byte{1, 0, 2, 0}, "1,2"},
+		{typeSID | typeArrayFlag, []byte{1, 1, 0, 0, 0, 0, 0, 5, 18, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}, "S-1-5-18,S-1-1-0"},
+	}
+	for _, tc := range tests {
+		got, err := formatValue(tc.typ, tc.data)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got, "type 0x%02x", tc.typ)
+	}
+}
@@ -0,0 +1,230 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package evtx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/winlogbeat/sys"
+)
+
+// BinXML value types.
+const (
+	typeNull       = 0x00
+	typeString     = 0x01
+	typeAnsiString = 0x02
+	typeInt8       = 0x03
+	typeUint8      = 0x04
+	typeInt16      = 0x05
+	typeUint16     = 0x06
+	typeInt32      = 0x07
+	typeUint32     = 0x08
+	typeInt64      = 0x09
+	typeUint64     = 0x0a
+	typeReal32     = 0x0b
+	typeReal64     = 0x0c
+	typeBool       = 0x0d
+	typeBinary     = 0x0e
+	typeGUID       = 0x0f
+	typeSizeT      = 0x10
+	typeFileTime   = 0x11
+	typeSysTime    = 0x12
+	typeSID        = 0x13
+	typeHexInt32   = 0x14
+	typeHexInt64   = 0x15
+	typeBinXML     = 0x21
+
+	typeArrayFlag = 0x80
+)
+
+// timeLayout is the layout used by the Windows Event Log to render times.
+const timeLayout = "2006-01-02T15:04:05.0000000Z"
+
+// fixedSizes holds the size of the value types with a fixed size.
+var fixedSizes = map[byte]int{
+	typeInt8:     1,
+	typeUint8:    1,
+	typeInt16:    2,
+	typeUint16:   2,
+	typeInt32:    4,
+	typeUint32:   4,
+	typeInt64:    8,
+	typeUint64:   8,
+	typeReal32:   4,
+	typeReal64:   8,
+	typeBool:     4,
+	typeGUID:     16,
+	typeSizeT:    8,
+	typeFileTime: 8,
+	typeSysTime:  16,
+	typeHexInt32: 4,
+	typeHexInt64: 8,
+}
+
+// formatValue renders a substitution value the way the Windows Event Log
+// renders it in event XML. The items of array values are separated by
+// commas.
+func formatValue(typ byte, data []byte) (string, error) {
+	values, err := formatValues(typ, data)
+	return strings.Join(values, ","), err
+}
+
+// formatValues renders a substitution value. Array values are rendered as one
+// string per item.
+func formatValues(typ byte, data []byte) ([]string, error) {
+	if typ&typeArrayFlag == 0 {
+		s, err := formatScalar(typ, data)
+		return []string{s}, err
+	}
+
+	typ &^= typeArrayFlag
+	var values []string
+	switch typ {
+	case typeString:
+		// Null-terminated UTF-16 strings.
+		for i := 0; i+1 < len(data); {
+			j := i
+			for j+1 < len(data) && (data[j] != 0 || data[j+1] != 0) {
+				j += 2
+			}
+			s, err := sys.UTF16BytesToString(data[i:j])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+			i = j + 2
+		}
+	case typeAnsiString:
+		values = strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+	case typeSID:
+		for len(data) >= 8 {
+			size := 8 + 4*int(data[1])
+			if size > len(data) {
+				return nil, errors.New("invalid sid array")
+			}
+			s, _ := formatScalar(typ, data[:size])
+			values = append(values, s)
+			data = data[size:]
+		}
+	default:
+		size, ok := fixedSizes[typ]
+		if !ok {
+			return []string{sys.BinaryToString(data)}, nil
+		}
+		if typ == typeSizeT && len(data)%8 != 0 {
+			size = 4
+		}
+		for ; len(data) >= size; data = data[size:] {
+			s, err := formatScalar(typ, data[:size])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+	}
+	return values, nil
+}
+
+func formatScalar(typ byte, data []byte) (string, error) {
+	if size, ok := fixedSizes[typ]; ok && len(data) < size && typ != typeSizeT {
+		return "", fmt.Errorf("value of type 0x%02x has invalid size %d", typ, len(data))
+	}
+
+	le := binary.LittleEndian
+	switch typ {
+	case typeNull:
+		return "", nil
+	case typeString:
+		return sys.UTF16BytesToString(data[:len(data)&^1])
+	case typeAnsiString:
+		return strings.TrimRight(string(data), "\x00"), nil
+	case typeInt8:
+		return strconv.FormatInt(int64(int8(data[0])), 10), nil
+	case typeUint8:
+		return strconv.FormatUint(uint64(data[0]), 10), nil
+	case typeInt16:
+		return strconv.FormatInt(int64(int16(le.Uint16(data))), 10), nil
+	case typeUint16:
+		return strconv.FormatUint(uint64(le.Uint16(data)), 10), nil
+	case typeInt32:
+		return strconv.FormatInt(int64(int32(le.Uint32(data))), 10), nil
+	case typeUint32:
+		return strconv.FormatUint(uint64(le.Uint32(data)), 10), nil
+	case typeInt64:
+		return strconv.FormatInt(int64(le.Uint64(data)), 10), nil
+	case typeUint64:
+		return strconv.FormatUint(le.Uint64(data), 10), nil
+	case typeReal32:
+		return strconv.FormatFloat(float64(math.Float32frombits(le.Uint32(data))), 'g', -1, 32), nil
+	case typeReal64:
+		return strconv.FormatFloat(math.Float64frombits(le.Uint64(data)), 'g', -1, 64), nil
+	case typeBool:
+		return strconv.FormatBool(le.Uint32(data) != 0), nil
+	case typeGUID:
+		return fmt.Sprintf("{%08x-%04x-%04x-%x-%x}",
+			le.Uint32(data), le.Uint16(data[4:]), le.Uint16(data[6:]), data[8:10], data[10:16]), nil
+	case typeSizeT:
+		switch len(data) {
+		case 4:
+			return fmt.Sprintf("0x%x", le.Uint32(data)), nil
+		case 8:
+			return fmt.Sprintf("0x%x", le.Uint64(data)), nil
+		}
+		return "", fmt.Errorf("value of type 0x%02x has invalid size %d", typ, len(data))
+	case typeFileTime:
+		return filetimeToTime(le.Uint64(data)).Format(timeLayout), nil
+	case typeSysTime:
+		t := time.Date(int(le.Uint16(data)), time.Month(le.Uint16(data[2:])), int(le.Uint16(data[6:])),
+			int(le.Uint16(data[8:])), int(le.Uint16(data[10:])), int(le.Uint16(data[12:])),
+			int(le.Uint16(data[14:]))*int(time.Millisecond), time.UTC)
+		return t.Format(timeLayout), nil
+	case typeSID:
+		return formatSID(data)
+	case typeHexInt32:
+		return fmt.Sprintf("0x%x", le.Uint32(data)), nil
+	case typeHexInt64:
+		return fmt.Sprintf("0x%x", le.Uint64(data)), nil
+	default:
+		// Binary data and types that do not occur in event files.
+		return sys.BinaryToString(data), nil
+	}
+}
+
+// formatSID renders a binary security identifier in its S-R-I-S-S... form.
+func formatSID(data []byte) (string, error) {
+	if len(data) < 8 || len(data) < 8+4*int(data[1]) {
+		return "", fmt.Errorf("invalid sid of size %d", len(data))
+	}
+	var authority uint64
+	for _, b := range data[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "S-%d-%d", data[0], authority)
+	for i := 0; i < int(data[1]); i++ {
+		fmt.Fprintf(&buf, "-%d", binary.LittleEndian.Uint32(data[8+4*i:]))
+	}
+	return buf.String(), nil
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/o365audit"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/salesforce"
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/sftp"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/streaming"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
@@ -44,9 +45,10 @@ func xpackInputs(info beat.Info, log *logp.Logger, store beater.StateStore) []v2
 		awscloudwatch.Plugin(),
 		lumberjack.Plugin(),
 		salesforce.Plugin(log, store),
+		sftp.Plugin(log, store),
 		streaming.Plugin(log, store),
 		streaming.PluginWebsocketAlias(log, store),
-		netflow.Plugin(log),
+		netflow.Plugin(log, store),
 		benchmark.Plugin(),
 	}
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/o365audit"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/salesforce"
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/sftp"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
 
@@ -44,8 +45,9 @@ func xpackInputs(info beat.Info, log *logp.Logger, store beater.StateStore) []v2
 		awscloudwatch.Plugin(),
 		lumberjack.Plugin(),
 		etw.Plugin(),
-		netflow.Plugin(log),
+		netflow.Plugin(log, store),
 		salesforce.Plugin(log, store),
+		sftp.Plugin(log, store),
 		benchmark.Plugin(),
 	}
 }
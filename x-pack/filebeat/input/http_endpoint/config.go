@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/textproto"
 	"strings"
+	"time"
 
 	"gopkg.in/natefinch/lumberjack.v2"
 
@@ -52,6 +53,37 @@ type config struct {
 	IncludeHeaders        []string                `config:"include_headers"`
 	PreserveOriginalEvent bool                    `config:"preserve_original_event"`
 	Tracer                *tracerConfig           `config:"tracer"`
+	Mode                  string                  `config:"mode"`
+	HEC                   hecConfig               `config:"hec"`
+}
+
+const modeHEC = "hec"
+
+// hecConfig configures the Splunk HTTP Event Collector compatibility mode.
+type hecConfig struct {
+	Tokens []string        `config:"tokens"`
+	ACK    hecACKConfig    `config:"ack"`
+	Fields hecFieldsConfig `config:"fields"`
+}
+
+// hecACKConfig configures indexer acknowledgement.
+type hecACKConfig struct {
+	Enabled bool `config:"enabled"`
+	// MaxPending is the maximum number of acknowledgement IDs of a channel
+	// that have not been queried yet.
+	MaxPending int `config:"max_pending" validate:"positive"`
+	// ChannelTimeout is the time after which idle channels are removed.
+	ChannelTimeout time.Duration `config:"channel_timeout" validate:"positive"`
+}
+
+// hecFieldsConfig holds the event fields the HEC metadata is stored in.
+// Metadata mapped to an empty field is dropped.
+type hecFieldsConfig struct {
+	Time       string `config:"time"`
+	Host       string `config:"host"`
+	Source     string `config:"source"`
+	SourceType string `config:"sourcetype"`
+	Index      string `config:"index"`
 }
 
 type tracerConfig struct {
@@ -75,6 +107,19 @@ func defaultConfig() config {
 		URL:           "/",
 		Prefix:        "json",
 		ContentType:   "application/json",
+		HEC: hecConfig{
+			ACK: hecACKConfig{
+				MaxPending:     10000,
+				ChannelTimeout: 10 * time.Minute,
+			},
+			Fields: hecFieldsConfig{
+				Time:       "@timestamp",
+				Host:       "host.name",
+				Source:     "splunk.source",
+				SourceType: "splunk.sourcetype",
+				Index:      "splunk.index",
+			},
+		},
 	}
 }
 
@@ -121,6 +166,19 @@ func (c *config) Validate() error {
 		return fmt.Errorf("max_body_bytes is negative: %d", *c.MaxBodySize)
 	}
 
+	switch c.Mode {
+	case "":
+	case modeHEC:
+		if len(c.HEC.Tokens) == 0 {
+			return errors.New("hec.tokens is required in hec mode")
+		}
+		if c.BasicAuth || c.SecretHeader != "" || c.HMACHeader != "" || c.CRCProvider != "" || c.Program != "" {
+			return errors.New("basic_auth, secret, hmac, crc and program can not be used in hec mode")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q, must be empty or %s", c.Mode, modeHEC)
+	}
+
 	return nil
 }
 
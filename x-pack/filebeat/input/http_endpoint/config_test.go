@@ -46,6 +46,40 @@ func Test_validateConfig(t *testing.T) {
 			},
 			wantError: "response_body must be valid JSON",
 		},
+		{
+			name: "hec mode without tokens",
+			config: config{
+				URL:          "/",
+				ResponseBody: `{"message": "success"}`,
+				Method:       http.MethodPost,
+				Mode:         modeHEC,
+			},
+			wantError: "hec.tokens is required in hec mode",
+		},
+		{
+			name: "hec mode with basic auth",
+			config: config{
+				URL:          "/",
+				ResponseBody: `{"message": "success"}`,
+				Method:       http.MethodPost,
+				Mode:         modeHEC,
+				HEC:          hecConfig{Tokens: []string{"token"}},
+				BasicAuth:    true,
+				Username:     "user",
+				Password:     "pass",
+			},
+			wantError: "can not be used in hec mode",
+		},
+		{
+			name: "unknown mode",
+			config: config{
+				URL:          "/",
+				ResponseBody: `{"message": "success"}`,
+				Method:       http.MethodPost,
+				Mode:         "other",
+			},
+			wantError: `unknown mode "other"`,
+		},
 	}
 
 	for _, tc := range testCases {
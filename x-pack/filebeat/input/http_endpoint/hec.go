@@ -0,0 +1,552 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package http_endpoint
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/jsontransform"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// hecBasePath is the path of the HEC endpoints, relative to url.
+const hecBasePath = "services/collector"
+
+const headerHECChannel = "X-Splunk-Request-Channel"
+
+// hecError is an error of the HEC API, with its status and HEC error code.
+type hecError struct {
+	status int
+	code   int
+	text   string
+}
+
+func (e *hecError) Error() string { return e.text }
+
+var (
+	errHECTokenRequired       = &hecError{http.StatusUnauthorized, 2, "Token is required"}
+	errHECInvalidAuth         = &hecError{http.StatusUnauthorized, 3, "Invalid authorization"}
+	errHECInvalidToken        = &hecError{http.StatusForbidden, 4, "Invalid token"}
+	errHECNoData              = &hecError{http.StatusBadRequest, 5, "No data"}
+	errHECInvalidFormat       = &hecError{http.StatusBadRequest, 6, "Invalid data format"}
+	errHECServerBusy          = &hecError{http.StatusServiceUnavailable, 9, "Server is busy"}
+	errHECChannelMissing      = &hecError{http.StatusBadRequest, 10, "Data channel is missing"}
+	errHECInvalidChannel      = &hecError{http.StatusBadRequest, 11, "Invalid data channel"}
+	errHECEventRequired       = &hecError{http.StatusBadRequest, 12, "Event field is required"}
+	errHECEventBlank          = &hecError{http.StatusBadRequest, 13, "Event field cannot be blank"}
+	errHECACKDisabled         = &hecError{http.StatusBadRequest, 14, "ACK is disabled"}
+	errHECNotFound            = &hecError{http.StatusNotFound, 404, "The requested URL was not found on this server."}
+	errHECMethodNotAllowed    = &hecError{http.StatusMethodNotAllowed, 405, "Method Not Allowed"}
+	errHECRequestTooLarge     = &hecError{http.StatusRequestEntityTooLarge, 413, "Content-Length of request exceeds max_body_bytes"}
+	errHECUnsupportedEncoding = &hecError{http.StatusUnsupportedMediaType, 415, "Unsupported Content-Encoding"}
+)
+
+// hecHandler serves the Splunk HTTP Event Collector API under base.
+type hecHandler struct {
+	log     *logp.Logger
+	publish func(beat.Event)
+	metrics *inputMetrics
+
+	base         string
+	tokens       []string
+	fields       hecFieldsConfig
+	messageField string
+	maxBodySize  int64
+
+	// channels holds the acknowledgement state of the channels, it is
+	// nil when indexer acknowledgement is disabled.
+	channels *hecChannels
+}
+
+func newHECHandler(c config, base string, pub func(beat.Event), log *logp.Logger, metrics *inputMetrics) *hecHandler {
+	h := &hecHandler{
+		log:          log,
+		publish:      pub,
+		metrics:      metrics,
+		base:         base,
+		tokens:       c.HEC.Tokens,
+		fields:       c.HEC.Fields,
+		messageField: c.Prefix,
+		maxBodySize:  -1,
+	}
+	if c.MaxBodySize != nil {
+		h.maxBodySize = *c.MaxBodySize
+	}
+	if c.HEC.ACK.Enabled {
+		h.channels = newHECChannels(c.HEC.ACK.MaxPending, c.HEC.ACK.ChannelTimeout)
+	}
+	return h
+}
+
+func (h *hecHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch strings.TrimPrefix(r.URL.Path, h.base) {
+	case "", "/", "/event", "/event/1.0":
+		err = h.serveEvents(w, r, h.readEvents)
+	case "/raw", "/raw/1.0":
+		err = h.serveEvents(w, r, h.readRaw)
+	case "/ack":
+		err = h.serveACK(w, r)
+	case "/health", "/health/1.0":
+		h.sendResponse(w, http.StatusOK, map[string]interface{}{"text": "HEC is healthy", "code": 17})
+	default:
+		err = errHECNotFound
+	}
+	if err != nil {
+		h.metrics.apiErrors.Add(1)
+		h.sendError(w, r, err)
+	}
+}
+
+// serveEvents publishes the events read from the request by read.
+func (h *hecHandler) serveEvents(w http.ResponseWriter, r *http.Request, read func(io.Reader, *http.Request) ([]beat.Event, error)) error {
+	if r.Method != http.MethodPost {
+		return errHECMethodNotAllowed
+	}
+	if err := h.authorize(r); err != nil {
+		return err
+	}
+	channel, err := h.channel(r, h.channels != nil)
+	if err != nil {
+		return err
+	}
+
+	body, status, err := getBodyReader(r)
+	if err != nil {
+		if status == http.StatusUnsupportedMediaType {
+			return errHECUnsupportedEncoding
+		}
+		return err
+	}
+	defer body.Close()
+	if h.maxBodySize >= 0 {
+		body = http.MaxBytesReader(w, body, h.maxBodySize)
+	}
+
+	h.metrics.contentLength.Update(r.ContentLength)
+	events, err := read(body, r)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return errHECRequestTooLarge
+		}
+		return err
+	}
+	if len(events) == 0 {
+		return errHECNoData
+	}
+
+	resp := map[string]interface{}{"text": "Success", "code": 0}
+	onACK := func() {}
+	if h.channels != nil {
+		id, ok := h.channels.newACKID(channel, time.Now())
+		if !ok {
+			return errHECServerBusy
+		}
+		resp["ackId"] = id
+		onACK = func() { h.channels.ack(channel, id) }
+	}
+
+	start := time.Now()
+	acker := newBatchACKTracker(func() {
+		h.metrics.batchACKTime.Update(time.Since(start).Nanoseconds())
+		h.metrics.batchesACKedTotal.Inc()
+		onACK()
+	})
+	h.metrics.batchesReceived.Add(1)
+	h.metrics.batchSize.Update(int64(len(events)))
+	for _, evt := range events {
+		acker.Add()
+		evt.Private = acker
+		h.publish(evt)
+		h.metrics.eventsPublished.Add(1)
+	}
+	acker.Ready()
+	h.metrics.batchesPublished.Add(1)
+
+	h.sendResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+// readEvents reads the concatenated JSON events of an event endpoint
+// request.
+func (h *hecHandler) readEvents(body io.Reader, _ *http.Request) ([]beat.Event, error) {
+	var events []beat.Event
+	dec := newJSONDecoder(body)
+	for i := 0; ; i++ {
+		var obj map[string]interface{}
+		err := dec.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return nil, err
+			}
+			return nil, invalidEvent{errHECInvalidFormat, i}
+		}
+		jsontransform.TransformNumbers(obj)
+
+		evt, err := h.newEvent(obj)
+		if err != nil {
+			return nil, invalidEvent{err.(*hecError), i} //nolint:errorlint // newEvent only returns *hecError.
+		}
+		events = append(events, evt)
+	}
+}
+
+// newEvent converts a HEC event to an event.
+func (h *hecHandler) newEvent(obj map[string]interface{}) (beat.Event, error) {
+	raw, ok := obj["event"]
+	if !ok {
+		return beat.Event{}, errHECEventRequired
+	}
+
+	evt := beat.Event{
+		Timestamp: time.Now().UTC(),
+		Fields:    mapstr.M{},
+	}
+	switch v := raw.(type) {
+	case nil:
+		return beat.Event{}, errHECEventBlank
+	case string:
+		if v == "" {
+			return beat.Event{}, errHECEventBlank
+		}
+		evt.Fields["message"] = v
+	case map[string]interface{}:
+		if h.messageField == "." {
+			evt.Fields = v
+		} else {
+			evt.Fields[h.messageField] = mapstr.M(v)
+		}
+	default:
+		if h.messageField == "." {
+			b, _ := json.Marshal(v)
+			evt.Fields["message"] = string(b)
+		} else {
+			evt.Fields[h.messageField] = v
+		}
+	}
+
+	h.putMetadata(&evt, obj["time"], hecString(obj["host"]), hecString(obj["source"]), hecString(obj["sourcetype"]), hecString(obj["index"]))
+	if fields, ok := obj["fields"].(map[string]interface{}); ok && len(fields) != 0 {
+		evt.Fields.Put("splunk.fields", fields)
+	}
+	return evt, nil
+}
+
+// readRaw reads the lines of a raw endpoint request, each line is an event.
+// The metadata of the events is set by the query parameters.
+func (h *hecHandler) readRaw(body io.Reader, r *http.Request) ([]beat.Event, error) {
+	q := r.URL.Query()
+	var ts interface{}
+	if t := q.Get("time"); t != "" {
+		ts = t
+	}
+
+	// The lines are not limited in length, the size of requests is limited by
+	// max_body_bytes.
+	var events []beat.Event
+	br := bufio.NewReader(body)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return nil, err
+			}
+			return nil, errHECInvalidFormat
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(bytes.TrimSpace(line)) != 0 {
+			evt := beat.Event{
+				Timestamp: time.Now().UTC(),
+				Fields:    mapstr.M{"message": string(line)},
+			}
+			h.putMetadata(&evt, ts, q.Get("host"), q.Get("source"), q.Get("sourcetype"), q.Get("index"))
+			events = append(events, evt)
+		}
+		if err != nil {
+			return events, nil
+		}
+	}
+}
+
+// putMetadata stores the HEC metadata of an event in the configured fields.
+func (h *hecHandler) putMetadata(evt *beat.Event, ts interface{}, host, source, sourceType, index string) {
+	if t, ok := parseHECTime(ts); ok && h.fields.Time != "" {
+		if h.fields.Time == "@timestamp" {
+			evt.Timestamp = t
+		} else {
+			evt.Fields.Put(h.fields.Time, t)
+		}
+	}
+	for _, m := range []struct{ field, value string }{
+		{h.fields.Host, host},
+		{h.fields.Source, source},
+		{h.fields.SourceType, sourceType},
+		{h.fields.Index, index},
+	} {
+		if m.field != "" && m.value != "" {
+			evt.Fields.Put(m.field, m.value)
+		}
+	}
+}
+
+// parseHECTime parses a time in seconds since the epoch, with an optional
+// fractional part.
+func parseHECTime(v interface{}) (time.Time, bool) {
+	var secs float64
+	switch v := v.(type) {
+	case int64:
+		return time.Unix(v, 0).UTC(), true
+	case float64:
+		secs = v
+	case string:
+		var err error
+		secs, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+	default:
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(secs * 1000)).UTC(), true
+}
+
+func hecString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// serveACK returns the acknowledgement status of the queried IDs of a
+// channel. Acknowledged IDs are only reported once.
+func (h *hecHandler) serveACK(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errHECMethodNotAllowed
+	}
+	if err := h.authorize(r); err != nil {
+		return err
+	}
+	if h.channels == nil {
+		return errHECACKDisabled
+	}
+	channel, err := h.channel(r, true)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		ACKs []uint64 `json:"acks"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		return errHECInvalidFormat
+	}
+	acks, ok := h.channels.query(channel, req.ACKs, time.Now())
+	if !ok {
+		return errHECInvalidChannel
+	}
+	h.sendResponse(w, http.StatusOK, map[string]interface{}{"acks": acks})
+	return nil
+}
+
+// authorize checks the HEC token of the request.
+func (h *hecHandler) authorize(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return errHECTokenRequired
+	}
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Splunk") {
+		return errHECInvalidAuth
+	}
+	for _, t := range h.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return errHECInvalidToken
+}
+
+// channel returns the channel of the request, set in the channel header
+// or query parameter.
+func (h *hecHandler) channel(r *http.Request, required bool) (string, error) {
+	channel := r.Header.Get(headerHECChannel)
+	if channel == "" {
+		channel = r.URL.Query().Get("channel")
+	}
+	if channel == "" {
+		if required {
+			return "", errHECChannelMissing
+		}
+		return "", nil
+	}
+	if !isGUID(channel) {
+		return "", errHECInvalidChannel
+	}
+	return channel, nil
+}
+
+// isGUID returns whether s is a GUID like 0aeeac95-ac74-4aa9-b30d-6c4c0ac581ba.
+func isGUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// invalidEvent is an error caused by an event of a batch.
+type invalidEvent struct {
+	*hecError
+	index int
+}
+
+func (h *hecHandler) sendError(w http.ResponseWriter, r *http.Request, err error) {
+	resp := map[string]interface{}{}
+	var (
+		herr    *hecError
+		invalid invalidEvent
+	)
+	switch {
+	case errors.As(err, &invalid):
+		herr = invalid.hecError
+		resp["invalid-event-number"] = invalid.index
+	case errors.As(err, &herr):
+	default:
+		herr = &hecError{http.StatusInternalServerError, 8, "Internal server error"}
+	}
+	resp["text"] = herr.text
+	resp["code"] = herr.code
+	h.log.Debugw("hec request error", "url", r.URL, "status_code", herr.status, "error", err)
+	h.sendResponse(w, herr.status, resp)
+}
+
+func (h *hecHandler) sendResponse(w http.ResponseWriter, status int, resp map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.log.Debugw("Failed writing response to client.", "error", err)
+	}
+}
+
+// hecChannels holds the indexer acknowledgement state of the channels.
+type hecChannels struct {
+	maxPending int
+	timeout    time.Duration
+
+	mu       sync.Mutex
+	channels map[string]*hecChannel
+}
+
+type hecChannel struct {
+	nextID   uint64
+	acks     map[uint64]bool // Whether the requests with the ID are acknowledged.
+	lastUsed time.Time
+}
+
+func newHECChannels(maxPending int, timeout time.Duration) *hecChannels {
+	return &hecChannels{
+		maxPending: maxPending,
+		timeout:    timeout,
+		channels:   make(map[string]*hecChannel),
+	}
+}
+
+// newACKID returns the acknowledgement ID of a new request of the channel.
+// It returns false if the channel has too many IDs that were not queried.
+func (c *hecChannels) newACKID(channel string, now time.Time) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch, ok := c.channels[channel]
+	if !ok {
+		c.expire(now)
+		ch = &hecChannel{acks: make(map[uint64]bool)}
+		c.channels[channel] = ch
+	}
+	if len(ch.acks) >= c.maxPending {
+		return 0, false
+	}
+	ch.lastUsed = now
+	id := ch.nextID
+	ch.nextID++
+	ch.acks[id] = false
+	return id, true
+}
+
+// ack marks the request with the ID as acknowledged.
+func (c *hecChannels) ack(channel string, id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ch, ok := c.channels[channel]; ok {
+		if _, ok := ch.acks[id]; ok {
+			ch.acks[id] = true
+		}
+	}
+}
+
+// query returns the acknowledgement status of the IDs, and removes the IDs
+// that are acknowledged. It returns false if the channel is unknown.
+func (c *hecChannels) query(channel string, ids []uint64, now time.Time) (map[string]bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch, ok := c.channels[channel]
+	if !ok {
+		return nil, false
+	}
+	ch.lastUsed = now
+	acks := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		acked := ch.acks[id]
+		if acked {
+			delete(ch.acks, id)
+		}
+		acks[strconv.FormatUint(id, 10)] = acked
+	}
+	return acks, true
+}
+
+// expire removes the channels that have not been used within the timeout.
+func (c *hecChannels) expire(now time.Time) {
+	for name, ch := range c.channels {
+		if now.Sub(ch.lastUsed) > c.timeout {
+			delete(c.channels, name)
+		}
+	}
+}
+
+// hecPatterns returns the mux patterns of the HEC endpoints under url.
+func hecPatterns(url string) (base string, patterns []string) {
+	base = strings.TrimSuffix(url, "/") + "/" + hecBasePath
+	return base, []string{base, base + "/"}
+}
@@ -0,0 +1,297 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package http_endpoint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	testHECToken   = "11111111-2222-3333-4444-555555555555"
+	testHECChannel = "0aeeac95-ac74-4aa9-b30d-6c4c0ac581ba"
+)
+
+func newTestHECHandler(t *testing.T, ack bool, pub func(beat.Event)) *hecHandler {
+	t.Helper()
+	c := defaultConfig()
+	c.Mode = modeHEC
+	c.HEC.Tokens = []string{testHECToken}
+	c.HEC.ACK.Enabled = ack
+	metrics := newInputMetrics("")
+	t.Cleanup(metrics.Close)
+	base, _ := hecPatterns("/")
+	return newHECHandler(c, base, pub, logp.NewLogger("http_endpoint.test"), metrics)
+}
+
+func hecRequest(method, target, body string, header map[string]string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Splunk "+testHECToken)
+	for k, v := range header {
+		if v == "" {
+			req.Header.Del(k)
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestHECHandler(t *testing.T) {
+	now := time.Now().UTC()
+	testCases := []struct {
+		name         string
+		request      *http.Request
+		wantStatus   int
+		wantResponse string
+		wantEvents   []beat.Event
+	}{
+		{
+			name:         "missing token",
+			request:      hecRequest(http.MethodPost, "/services/collector/event", `{"event":"hello"}`, map[string]string{"Authorization": ""}),
+			wantStatus:   http.StatusUnauthorized,
+			wantResponse: `{"code":2,"text":"Token is required"}`,
+		},
+		{
+			name:         "invalid authorization",
+			request:      hecRequest(http.MethodPost, "/services/collector/event", `{"event":"hello"}`, map[string]string{"Authorization": "Bearer " + testHECToken}),
+			wantStatus:   http.StatusUnauthorized,
+			wantResponse: `{"code":3,"text":"Invalid authorization"}`,
+		},
+		{
+			name:         "invalid token",
+			request:      hecRequest(http.MethodPost, "/services/collector/event", `{"event":"hello"}`, map[string]string{"Authorization": "Splunk wrong"}),
+			wantStatus:   http.StatusForbidden,
+			wantResponse: `{"code":4,"text":"Invalid token"}`,
+		},
+		{
+			name: "batched events",
+			request: hecRequest(http.MethodPost, "/services/collector/event",
+				`{"event":"hello","time":1700000000.5,"host":"web-1","source":"app.log","sourcetype":"app","index":"main","fields":{"env":"prod"}}
+				{"event":{"user":"jane","id":1}}`, nil),
+			wantStatus:   http.StatusOK,
+			wantResponse: `{"code":0,"text":"Success"}`,
+			wantEvents: []beat.Event{
+				{
+					Timestamp: time.UnixMilli(1700000000500).UTC(),
+					Fields: mapstr.M{
+						"message": "hello",
+						"host":    mapstr.M{"name": "web-1"},
+						"splunk": mapstr.M{
+							"source":     "app.log",
+							"sourcetype": "app",
+							"index":      "main",
+							"fields":     map[string]interface{}{"env": "prod"},
+						},
+					},
+				},
+				{
+					Fields: mapstr.M{
+						"json": mapstr.M{"user": "jane", "id": int64(1)},
+					},
+				},
+			},
+		},
+		{
+			name:         "missing event",
+			request:      hecRequest(http.MethodPost, "/services/collector", `{"event":"hello"}{"host":"web-1"}`, nil),
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: `{"code":12,"invalid-event-number":1,"text":"Event field is required"}`,
+		},
+		{
+			name:         "blank event",
+			request:      hecRequest(http.MethodPost, "/services/collector/event", `{"event":""}`, nil),
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: `{"code":13,"invalid-event-number":0,"text":"Event field cannot be blank"}`,
+		},
+		{
+			name:         "invalid json",
+			request:      hecRequest(http.MethodPost, "/services/collector/event", `{"event":"hello"}{"event":`, nil),
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: `{"code":6,"invalid-event-number":1,"text":"Invalid data format"}`,
+		},
+		{
+			name:         "no data",
+			request:      hecRequest(http.MethodPost, "/services/collector/event", ``, nil),
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: `{"code":5,"text":"No data"}`,
+		},
+		{
+			name:         "raw",
+			request:      hecRequest(http.MethodPost, "/services/collector/raw?host=web-1&sourcetype=app", "line 1\r\n\nline 2", nil),
+			wantStatus:   http.StatusOK,
+			wantResponse: `{"code":0,"text":"Success"}`,
+			wantEvents: []beat.Event{
+				{Fields: mapstr.M{"message": "line 1", "host": mapstr.M{"name": "web-1"}, "splunk": mapstr.M{"sourcetype": "app"}}},
+				{Fields: mapstr.M{"message": "line 2", "host": mapstr.M{"name": "web-1"}, "splunk": mapstr.M{"sourcetype": "app"}}},
+			},
+		},
+		{
+			name:         "raw long line",
+			request:      hecRequest(http.MethodPost, "/services/collector/raw", strings.Repeat("x", 100000)+"\nline 2\n", nil),
+			wantStatus:   http.StatusOK,
+			wantResponse: `{"code":0,"text":"Success"}`,
+			wantEvents: []beat.Event{
+				{Fields: mapstr.M{"message": strings.Repeat("x", 100000)}},
+				{Fields: mapstr.M{"message": "line 2"}},
+			},
+		},
+		{
+			name:         "invalid channel",
+			request:      hecRequest(http.MethodPost, "/services/collector/event?channel=abc", `{"event":"hello"}`, nil),
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: `{"code":11,"text":"Invalid data channel"}`,
+		},
+		{
+			name:         "ack disabled",
+			request:      hecRequest(http.MethodPost, "/services/collector/ack", `{"acks":[0]}`, map[string]string{headerHECChannel: testHECChannel}),
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: `{"code":14,"text":"ACK is disabled"}`,
+		},
+		{
+			name:         "health",
+			request:      httptest.NewRequest(http.MethodGet, "/services/collector/health", nil),
+			wantStatus:   http.StatusOK,
+			wantResponse: `{"code":17,"text":"HEC is healthy"}`,
+		},
+		{
+			name:         "not found",
+			request:      hecRequest(http.MethodPost, "/services/collector/other", `{"event":"hello"}`, nil),
+			wantStatus:   http.StatusNotFound,
+			wantResponse: `{"code":404,"text":"The requested URL was not found on this server."}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pub := new(publisher)
+			h := newTestHECHandler(t, false, pub.Publish)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, tc.request)
+
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			assert.JSONEq(t, tc.wantResponse, rec.Body.String())
+			require.Len(t, pub.events, len(tc.wantEvents))
+			for i, evt := range pub.events {
+				assert.Equal(t, tc.wantEvents[i].Fields, evt.Fields)
+				if !tc.wantEvents[i].Timestamp.IsZero() {
+					assert.Equal(t, tc.wantEvents[i].Timestamp, evt.Timestamp)
+				} else {
+					assert.False(t, evt.Timestamp.Before(now), "event timestamp should default to the receive time")
+				}
+			}
+		})
+	}
+}
+
+func TestHECFieldMapping(t *testing.T) {
+	pub := new(publisher)
+	h := newTestHECHandler(t, false, pub.Publish)
+	h.fields = hecFieldsConfig{Time: "splunk.time", Host: "observer.hostname"}
+	h.messageField = "."
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, hecRequest(http.MethodPost, "/services/collector/event",
+		`{"event":{"user":"jane"},"time":"1700000000","host":"web-1","source":"app.log"}`, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	require.Len(t, pub.events, 1)
+	assert.Equal(t, mapstr.M{
+		"user":     "jane",
+		"observer": mapstr.M{"hostname": "web-1"},
+		"splunk":   mapstr.M{"time": time.Unix(1700000000, 0).UTC()},
+	}, pub.events[0].Fields)
+}
+
+// pendingPublisher holds the published events without acknowledging them.
+type pendingPublisher struct {
+	mu     sync.Mutex
+	events []beat.Event
+}
+
+func (p *pendingPublisher) Publish(e beat.Event) {
+	p.mu.Lock()
+	p.events = append(p.events, e)
+	p.mu.Unlock()
+}
+
+func (p *pendingPublisher) ackAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.events {
+		e.Private.(*batchACKTracker).ACK()
+	}
+	p.events = nil
+}
+
+func TestHECIndexerAcknowledgement(t *testing.T) {
+	pub := new(pendingPublisher)
+	h := newTestHECHandler(t, true, pub.Publish)
+	h.channels.maxPending = 2
+	channel := map[string]string{headerHECChannel: testHECChannel}
+
+	serve := func(req *http.Request) (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := serve(hecRequest(http.MethodPost, "/services/collector/event", `{"event":"hello"}`, nil))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.JSONEq(t, `{"code":10,"text":"Data channel is missing"}`, body)
+
+	code, body = serve(hecRequest(http.MethodPost, "/services/collector/event", `{"event":"hello"}`, channel))
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"code":0,"text":"Success","ackId":0}`, body)
+	code, body = serve(hecRequest(http.MethodPost, "/services/collector/raw?channel="+testHECChannel, "hello", nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"code":0,"text":"Success","ackId":1}`, body)
+
+	code, body = serve(hecRequest(http.MethodPost, "/services/collector/event", `{"event":"hello"}`, channel))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.JSONEq(t, `{"code":9,"text":"Server is busy"}`, body)
+
+	code, body = serve(hecRequest(http.MethodPost, "/services/collector/ack", `{"acks":[0,1,2]}`, channel))
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"acks":{"0":false,"1":false,"2":false}}`, body)
+
+	pub.ackAll()
+	code, body = serve(hecRequest(http.MethodPost, "/services/collector/ack", `{"acks":[0,1]}`, channel))
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"acks":{"0":true,"1":true}}`, body)
+
+	// Acknowledged IDs are only reported once.
+	code, body = serve(hecRequest(http.MethodPost, "/services/collector/ack", `{"acks":[0]}`, channel))
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"acks":{"0":false}}`, body)
+
+	code, body = serve(hecRequest(http.MethodPost, "/services/collector/ack", `{"acks":[0]}`, map[string]string{headerHECChannel: "ffffffff-ac74-4aa9-b30d-6c4c0ac581ba"}))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.JSONEq(t, `{"code":11,"text":"Invalid data channel"}`, body)
+}
+
+func TestHECChannelsExpire(t *testing.T) {
+	c := newHECChannels(10, time.Minute)
+	now := time.Now()
+	_, ok := c.newACKID(testHECChannel, now)
+	require.True(t, ok)
+
+	_, ok = c.newACKID("ffffffff-ac74-4aa9-b30d-6c4c0ac581ba", now.Add(2*time.Minute))
+	require.True(t, ok)
+	_, ok = c.query(testHECChannel, []uint64{0}, now.Add(2*time.Minute))
+	assert.False(t, ok, "idle channel should have been removed")
+}
@@ -170,6 +170,20 @@ func (p *pool) serve(ctx v2.Context, e *httpEndpoint, pub func(beat.Event), metr
 		}
 	}
 
+	// The HEC endpoints are served under the configured URL.
+	patterns := []string{pattern}
+	var hecBase string
+	if e.config.Mode == modeHEC {
+		hecBase, patterns = hecPatterns(u.Path)
+		pattern = hecBase
+	}
+	newEndpointHandler := func(ctx context.Context) http.Handler {
+		if e.config.Mode == modeHEC {
+			return newHECHandler(e.config, hecBase, pub, log, metrics)
+		}
+		return newHandler(ctx, e.config, prg, pub, log, metrics)
+	}
+
 	p.mu.Lock()
 	s, ok := p.servers[e.addr]
 	if ok {
@@ -179,17 +193,22 @@ func (p *pool) serve(ctx v2.Context, e *httpEndpoint, pub func(beat.Event), metr
 			return err
 		}
 
-		if old, ok := s.idOf[pattern]; ok {
-			err = fmt.Errorf("pattern already exists for %s: %s old=%s new=%s",
-				e.addr, pattern, old, ctx.ID)
-			s.setErr(err)
-			s.cancel()
-			p.mu.Unlock()
-			return err
+		for _, pattern := range patterns {
+			if old, ok := s.idOf[pattern]; ok {
+				err = fmt.Errorf("pattern already exists for %s: %s old=%s new=%s",
+					e.addr, pattern, old, ctx.ID)
+				s.setErr(err)
+				s.cancel()
+				p.mu.Unlock()
+				return err
+			}
 		}
 		log.Infof("Adding %s end point to server on %s", pattern, e.addr)
-		s.mux.Handle(pattern, newHandler(s.ctx, e.config, prg, pub, log, metrics))
-		s.idOf[pattern] = ctx.ID
+		h := newEndpointHandler(s.ctx)
+		for _, pattern := range patterns {
+			s.mux.Handle(pattern, h)
+			s.idOf[pattern] = ctx.ID
+		}
 		p.mu.Unlock()
 		<-s.ctx.Done()
 		return s.getErr()
@@ -198,13 +217,17 @@ func (p *pool) serve(ctx v2.Context, e *httpEndpoint, pub func(beat.Event), metr
 	mux := http.NewServeMux()
 	srv := &http.Server{Addr: e.addr, TLSConfig: e.tlsConfig, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
 	s = &server{
-		idOf: map[string]string{pattern: ctx.ID},
+		idOf: make(map[string]string),
 		tls:  e.config.TLS,
 		mux:  mux,
 		srv:  srv,
 	}
 	s.ctx, s.cancel = ctxtool.WithFunc(ctx.Cancelation, func() { srv.Close() })
-	mux.Handle(pattern, newHandler(s.ctx, e.config, prg, pub, log, metrics))
+	h := newEndpointHandler(s.ctx)
+	for _, pattern := range patterns {
+		mux.Handle(pattern, h)
+		s.idOf[pattern] = ctx.ID
+	}
 	p.servers[e.addr] = s
 	p.mu.Unlock()
 
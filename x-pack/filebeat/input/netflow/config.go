@@ -24,6 +24,8 @@ type config struct {
 	DetectSequenceReset       bool          `config:"detect_sequence_reset"`
 	ShareTemplates            bool          `config:"share_templates"`
 	NumberOfWorkers           uint32        `config:"workers"`
+	PersistTemplates          bool          `config:"persist_templates"`
+	TemplateTTL               time.Duration `config:"template_ttl" validate:"min=0"`
 }
 
 var defaultConfig = config{
@@ -42,4 +44,6 @@ var defaultConfig = config{
 	DetectSequenceReset: true,
 	ShareTemplates:      false,
 	NumberOfWorkers:     1,
+	PersistTemplates:    false,
+	TemplateTTL:         24 * time.Hour,
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/fields"
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/template"
 )
 
 type ActiveSessionsMetric interface {
@@ -26,6 +27,8 @@ type Config struct {
 	sharedTemplates      bool
 	withCache            bool
 	activeSessionsMetric ActiveSessionsMetric
+	templateStore        template.Store
+	templateTTL          time.Duration
 }
 
 var defaultCfg = Config{
@@ -115,6 +118,16 @@ func (c *Config) WithActiveSessionsMetric(metric ActiveSessionsMetric) *Config {
 	return c
 }
 
+// WithTemplateStore configures the store used to persist NetFlow V9 and
+// IPFIX templates, so that they are restored at startup. Stored templates
+// that have not been received for longer than ttl are not restored. A ttl
+// of zero disables their expiration.
+func (c *Config) WithTemplateStore(store template.Store, ttl time.Duration) *Config {
+	c.templateStore = store
+	c.templateTTL = ttl
+	return c
+}
+
 // Protocols returns a list of the protocols enabled.
 func (c *Config) Protocols() []string {
 	return c.protocols
@@ -157,3 +170,13 @@ func (c *Config) ActiveSessionsMetric() ActiveSessionsMetric {
 
 	return c.activeSessionsMetric
 }
+
+// TemplateStore returns the store used to persist templates, if any.
+func (c *Config) TemplateStore() template.Store {
+	return c.templateStore
+}
+
+// TemplateTTL returns the expiration time for stored templates.
+func (c *Config) TemplateTTL() time.Duration {
+	return c.templateTTL
+}
@@ -34,7 +34,7 @@ func New(config config.Config) protocol.Protocol {
 		DecoderV9: v9.DecoderV9{Logger: logger, Fields: config.Fields()},
 	}
 	proto := &IPFixProtocol{
-		NetflowV9Protocol: *v9.NewProtocolWithDecoder(decoder, config, logger),
+		NetflowV9Protocol: *v9.NewProtocolWithDecoder(ProtocolID, decoder, config, logger),
 	}
 	return proto
 }
@@ -0,0 +1,123 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package template
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/fields"
+)
+
+// Store persists templates so that they are available after a restart.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Load returns all the stored templates.
+	Load() ([]Stored, error)
+	// Save stores a template, replacing the template stored with the same
+	// key.
+	Save(Stored) error
+	// Remove removes the template stored with the given key.
+	Remove(StoredKey) error
+}
+
+// StoredKey identifies a stored template: the protocol version, the session
+// it belongs to and its ID.
+type StoredKey struct {
+	Version uint16 `struct:"version"`
+	// Exporter is the address of the exporter, it is empty when templates
+	// are shared between exporters.
+	Exporter string `struct:"exporter"`
+	// Domain is the observation domain (IPFIX) or source ID (V9).
+	Domain uint32 `struct:"domain"`
+	ID     uint16 `struct:"id"`
+}
+
+func (k StoredKey) String() string {
+	return fmt.Sprintf("%d::%s::%d::%d", k.Version, k.Exporter, k.Domain, k.ID)
+}
+
+// Stored is the serializable form of a template.
+type Stored struct {
+	StoredKey `struct:",inline"`
+	// Updated is the last time the template was received.
+	Updated     time.Time     `struct:"updated"`
+	ScopeFields int           `struct:"scope_fields"`
+	IsOptions   bool          `struct:"is_options"`
+	Fields      []StoredField `struct:"fields"`
+}
+
+// StoredField is the serializable form of a field template.
+type StoredField struct {
+	EnterpriseID uint32 `struct:"enterprise_id"`
+	FieldID      uint16 `struct:"field_id"`
+	Length       uint16 `struct:"length"`
+}
+
+// Store returns the serializable form of the template.
+func (t *Template) Store(key StoredKey, updated time.Time) Stored {
+	key.ID = t.ID
+	st := Stored{
+		StoredKey:   key,
+		Updated:     updated,
+		ScopeFields: t.ScopeFields,
+		IsOptions:   t.IsOptions,
+		Fields:      make([]StoredField, len(t.Fields)),
+	}
+	for i, f := range t.Fields {
+		st.Fields[i] = StoredField{
+			EnterpriseID: f.Key.EnterpriseID,
+			FieldID:      f.Key.FieldID,
+			Length:       f.Length,
+		}
+	}
+	return st
+}
+
+// Template rebuilds the stored template, looking up its fields in the given
+// dictionary.
+func (s *Stored) Template(dict fields.FieldDict) (*Template, error) {
+	if s.ScopeFields < 0 || s.ScopeFields > len(s.Fields) {
+		return nil, fmt.Errorf("invalid number of scope fields %d for %d fields", s.ScopeFields, len(s.Fields))
+	}
+	t := &Template{
+		ID:          s.ID,
+		Fields:      make([]FieldTemplate, len(s.Fields)),
+		ScopeFields: s.ScopeFields,
+		IsOptions:   s.IsOptions,
+	}
+	for i, f := range s.Fields {
+		field := FieldTemplate{
+			Length: f.Length,
+			Key:    fields.Key{EnterpriseID: f.EnterpriseID, FieldID: f.FieldID},
+		}
+		if f.Length == VariableLength {
+			t.VariableLength = true
+			t.Length++
+		} else {
+			t.Length += int(f.Length)
+		}
+		if info, found := dict[field.Key]; found {
+			if f.Length == VariableLength || info.Decoder.MinLength() <= f.Length && f.Length <= info.Decoder.MaxLength() {
+				field.Info = info
+			}
+		}
+		t.Fields[i] = field
+	}
+	return t, nil
+}
+
+// SameDefinition returns if both templates have the same ID and fields.
+func (t *Template) SameDefinition(other *Template) bool {
+	if t.ID != other.ID || t.IsOptions != other.IsOptions || t.ScopeFields != other.ScopeFields || len(t.Fields) != len(other.Fields) {
+		return false
+	}
+	for i := range t.Fields {
+		if t.Fields[i].Key != other.Fields[i].Key || t.Fields[i].Length != other.Fields[i].Length {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package template
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/fields"
+)
+
+func TestStored(t *testing.T) {
+	dict := fields.FieldDict{
+		fields.Key{FieldID: 8}:                  &fields.Field{Name: "sourceIPv4Address", Decoder: fields.Ipv4Address},
+		fields.Key{FieldID: 7}:                  &fields.Field{Name: "sourceTransportPort", Decoder: fields.Unsigned16},
+		fields.Key{EnterpriseID: 9, FieldID: 1}: &fields.Field{Name: "customString", Decoder: fields.String},
+	}
+	original := &Template{
+		ID: 300,
+		Fields: []FieldTemplate{
+			{Length: 4, Info: dict[fields.Key{FieldID: 8}], Key: fields.Key{FieldID: 8}},
+			// Out of bounds length.
+			{Length: 4, Key: fields.Key{FieldID: 7}},
+			{Length: VariableLength, Info: dict[fields.Key{EnterpriseID: 9, FieldID: 1}], Key: fields.Key{EnterpriseID: 9, FieldID: 1}},
+			// Unknown field.
+			{Length: 2, Key: fields.Key{FieldID: 60000}},
+		},
+		Length:         11,
+		VariableLength: true,
+		ScopeFields:    1,
+		IsOptions:      true,
+	}
+	now := time.Now()
+	stored := original.Store(StoredKey{Version: 10, Exporter: "192.0.2.1:4739", Domain: 42}, now)
+	assert.Equal(t, StoredKey{Version: 10, Exporter: "192.0.2.1:4739", Domain: 42, ID: 300}, stored.StoredKey)
+	assert.Equal(t, "10::192.0.2.1:4739::42::300", stored.StoredKey.String())
+	assert.Equal(t, now, stored.Updated)
+
+	restored, err := stored.Template(dict)
+	require.NoError(t, err)
+	assert.Equal(t, original, restored)
+	assert.True(t, original.SameDefinition(restored))
+
+	stored.Fields[3].Length = 4
+	changed, err := stored.Template(dict)
+	require.NoError(t, err)
+	assert.False(t, original.SameDefinition(changed))
+
+	stored.ScopeFields = 5
+	_, err = stored.Template(dict)
+	assert.Error(t, err)
+}
@@ -35,6 +35,9 @@ type Template struct {
 type FieldTemplate struct {
 	Length uint16
 	Info   *fields.Field
+	// Key identifies the field. It is kept so that the template can be
+	// persisted and decoded again with a field dictionary.
+	Key fields.Key
 }
 
 func PopulateFieldMap(dest record.Map, fields []FieldTemplate, variableLength bool, buffer *bytes.Buffer) error {
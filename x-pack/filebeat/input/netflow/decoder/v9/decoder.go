@@ -112,6 +112,7 @@ func ReadFields(d Decoder, buf *bytes.Buffer, count int) (record template.Templa
 		}
 		field := template.FieldTemplate{
 			Length: length,
+			Key:    key,
 		}
 		if length == template.VariableLength {
 			record.VariableLength = true
@@ -11,8 +11,15 @@ import (
 	"time"
 )
 
+// pendingKey identifies the data sets of a session waiting for the same
+// template.
+type pendingKey struct {
+	session    SessionKey
+	templateID uint16
+}
+
 type eventWithMissingTemplate struct {
-	key       SessionKey
+	key       pendingKey
 	entryTime time.Time
 }
 
@@ -50,25 +57,27 @@ type pendingTemplatesCache struct {
 	wg      sync.WaitGroup
 	hp      pendingEventsHeap
 	started bool
-	events  map[SessionKey][]*bytes.Buffer
+	events  map[pendingKey][]*bytes.Buffer
 }
 
 func newPendingTemplatesCache() *pendingTemplatesCache {
 	cache := &pendingTemplatesCache{
-		events: make(map[SessionKey][]*bytes.Buffer),
+		events: make(map[pendingKey][]*bytes.Buffer),
 		hp:     pendingEventsHeap{},
 	}
 	return cache
 }
 
-// GetAndRemove returns all events for a given session key and removes them from the cache
-func (h *pendingTemplatesCache) GetAndRemove(key SessionKey) []*bytes.Buffer {
+// GetAndRemove returns all events for a given session key and template ID and
+// removes them from the cache
+func (h *pendingTemplatesCache) GetAndRemove(session SessionKey, templateID uint16) []*bytes.Buffer {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
 	if len(h.events) == 0 {
 		return nil
 	}
 
+	key := pendingKey{session: session, templateID: templateID}
 	events, ok := h.events[key]
 	if !ok {
 		return nil
@@ -77,11 +86,13 @@ func (h *pendingTemplatesCache) GetAndRemove(key SessionKey) []*bytes.Buffer {
 	return events
 }
 
-// Add adds an event to the pending templates cache
-func (h *pendingTemplatesCache) Add(key SessionKey, events *bytes.Buffer) {
+// Add adds an event waiting for the given template to the pending templates
+// cache
+func (h *pendingTemplatesCache) Add(session SessionKey, templateID uint16, events *bytes.Buffer) {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
 
+	key := pendingKey{session: session, templateID: templateID}
 	h.events[key] = append(h.events[key], events)
 	h.hp.Push(eventWithMissingTemplate{key: key, entryTime: time.Now()})
 }
@@ -16,14 +16,15 @@ import (
 func TestPendingTemplatesCache(t *testing.T) {
 
 	type testEvent struct {
-		key SessionKey
-		buf *bytes.Buffer
+		key        SessionKey
+		templateID uint16
+		buf        *bytes.Buffer
 	}
 
 	tests := []struct {
 		name             string
 		eventsToAdd      []testEvent
-		eventsToGet      []SessionKey
+		eventsToGet      []testEvent
 		eventsExpected   []*bytes.Buffer
 		getDelay         time.Duration
 		cleanInterval    time.Duration
@@ -32,12 +33,12 @@ func TestPendingTemplatesCache(t *testing.T) {
 		{
 			name: "Add and GetAndRemove different sessions with cache hit",
 			eventsToAdd: []testEvent{
-				{SessionKey{"127.0.0.1", 0}, bytes.NewBufferString("test-event-1")},
-				{SessionKey{"127.0.0.2", 0}, bytes.NewBufferString("test-event-1")},
+				{SessionKey{"127.0.0.1", 0}, 256, bytes.NewBufferString("test-event-1")},
+				{SessionKey{"127.0.0.2", 0}, 256, bytes.NewBufferString("test-event-1")},
 			},
-			eventsToGet: []SessionKey{
-				{"127.0.0.1", 0},
-				{"127.0.0.2", 0},
+			eventsToGet: []testEvent{
+				{key: SessionKey{"127.0.0.1", 0}, templateID: 256},
+				{key: SessionKey{"127.0.0.2", 0}, templateID: 256},
 			},
 			eventsExpected: []*bytes.Buffer{
 				bytes.NewBufferString("test-event-1"),
@@ -50,11 +51,11 @@ func TestPendingTemplatesCache(t *testing.T) {
 		{
 			name: "Add and GetAndRemove same sessions with cache hit",
 			eventsToAdd: []testEvent{
-				{SessionKey{"127.0.0.1", 0}, bytes.NewBufferString("test-event-1")},
-				{SessionKey{"127.0.0.1", 0}, bytes.NewBufferString("test-event-1")},
+				{SessionKey{"127.0.0.1", 0}, 256, bytes.NewBufferString("test-event-1")},
+				{SessionKey{"127.0.0.1", 0}, 256, bytes.NewBufferString("test-event-1")},
 			},
-			eventsToGet: []SessionKey{
-				{"127.0.0.1", 0},
+			eventsToGet: []testEvent{
+				{key: SessionKey{"127.0.0.1", 0}, templateID: 256},
 			},
 			eventsExpected: []*bytes.Buffer{
 				bytes.NewBufferString("test-event-1"),
@@ -64,15 +65,32 @@ func TestPendingTemplatesCache(t *testing.T) {
 			cleanInterval:    2 * time.Second,
 			removalThreshold: 2 * time.Second,
 		},
+		{
+			name: "Add and GetAndRemove different templates",
+			eventsToAdd: []testEvent{
+				{SessionKey{"127.0.0.1", 0}, 256, bytes.NewBufferString("test-event-1")},
+				{SessionKey{"127.0.0.1", 0}, 257, bytes.NewBufferString("test-event-2")},
+			},
+			eventsToGet: []testEvent{
+				{key: SessionKey{"127.0.0.1", 0}, templateID: 257},
+				{key: SessionKey{"127.0.0.1", 0}, templateID: 258},
+			},
+			eventsExpected: []*bytes.Buffer{
+				bytes.NewBufferString("test-event-2"),
+			},
+			getDelay:         1 * time.Second,
+			cleanInterval:    2 * time.Second,
+			removalThreshold: 2 * time.Second,
+		},
 		{
 			name: "Add and GetAndRemove with cache miss",
 			eventsToAdd: []testEvent{
-				{SessionKey{"127.0.0.1", 0}, bytes.NewBufferString("test-event-1")},
-				{SessionKey{"127.0.0.2", 0}, bytes.NewBufferString("test-event-1")},
+				{SessionKey{"127.0.0.1", 0}, 256, bytes.NewBufferString("test-event-1")},
+				{SessionKey{"127.0.0.2", 0}, 256, bytes.NewBufferString("test-event-1")},
 			},
-			eventsToGet: []SessionKey{
-				{"127.0.0.1", 0},
-				{"127.0.0.2", 0},
+			eventsToGet: []testEvent{
+				{key: SessionKey{"127.0.0.1", 0}, templateID: 256},
+				{key: SessionKey{"127.0.0.2", 0}, templateID: 256},
 			},
 			eventsExpected:   []*bytes.Buffer(nil),
 			getDelay:         2 * time.Second,
@@ -88,12 +106,12 @@ func TestPendingTemplatesCache(t *testing.T) {
 			cache := newPendingTemplatesCache()
 			cache.start(ctx.Done(), tt.cleanInterval, tt.removalThreshold)
 			for _, event := range tt.eventsToAdd {
-				cache.Add(event.key, event.buf)
+				cache.Add(event.key, event.templateID, event.buf)
 			}
 			time.Sleep(tt.getDelay)
 			var readEvents []*bytes.Buffer
-			for _, key := range tt.eventsToGet {
-				if events := cache.GetAndRemove(key); events != nil {
+			for _, event := range tt.eventsToGet {
+				if events := cache.GetAndRemove(event.key, event.templateID); events != nil {
 					readEvents = append(readEvents, events...)
 				}
 			}
@@ -38,6 +38,8 @@ type TemplateKey uint16
 type TemplateWrapper struct {
 	Template *template.Template
 	Delete   atomic.Bool
+	// persisted is the last time the template was persisted.
+	persisted time.Time
 }
 
 // SessionState holds the state for a single session (observation domain).
@@ -47,6 +49,10 @@ type SessionState struct {
 	lastSequence uint32
 	logger       *log.Logger
 	Delete       atomic.Bool
+	// restored signals that the session templates were restored from the
+	// template store, so that the sequence number of the first packet can't
+	// be checked for resets.
+	restored bool
 }
 
 // NewSession creates a new session.
@@ -62,7 +68,37 @@ func (s *SessionState) AddTemplate(t *template.Template) {
 	s.logger.Printf("state %p addTemplate %d %p", s, t.ID, t)
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.Templates[TemplateKey(t.ID)] = &TemplateWrapper{Template: t}
+	wrapper := &TemplateWrapper{Template: t}
+	if prev, found := s.Templates[TemplateKey(t.ID)]; found && prev.Template.SameDefinition(t) {
+		wrapper.persisted = prev.persisted
+	}
+	s.Templates[TemplateKey(t.ID)] = wrapper
+}
+
+// restoreTemplate adds a template restored from the template store, which
+// was persisted at the given time.
+func (s *SessionState) restoreTemplate(t *template.Template, persisted time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Templates[TemplateKey(t.ID)] = &TemplateWrapper{Template: t, persisted: persisted}
+	s.restored = true
+}
+
+// markPersisted returns if the template with the given ID must be
+// persisted, because it never was or it was persisted longer than refresh
+// ago. A refresh of zero only persists templates that changed.
+func (s *SessionState) markPersisted(id uint16, now time.Time, refresh time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	wrapper, found := s.Templates[TemplateKey(id)]
+	if !found {
+		return false
+	}
+	if !wrapper.persisted.IsZero() && (refresh <= 0 || now.Sub(wrapper.persisted) < refresh) {
+		return false
+	}
+	wrapper.persisted = now
+	return true
 }
 
 // GetTemplate returns a template by ID.
@@ -107,14 +143,27 @@ func (s *SessionState) ExpireTemplates() (alive int, removed int) {
 // CheckReset returns if the session must be reset after the receipt of the
 // given sequence number.
 func (s *SessionState) CheckReset(seqNum uint32) (prev uint32, reset bool) {
+	prev, _, reset = s.checkReset(seqNum)
+	return prev, reset
+}
+
+// checkReset is CheckReset, also returning the IDs of the templates removed
+// by a reset.
+func (s *SessionState) checkReset(seqNum uint32) (prev uint32, removed []TemplateKey, reset bool) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	prev = s.lastSequence
-	if reset = !isValidSequence(prev, seqNum); reset {
+	if s.restored {
+		// The previous sequence number is unknown after a restore.
+		s.restored = false
+	} else if reset = !isValidSequence(prev, seqNum); reset {
+		for id := range s.Templates {
+			removed = append(removed, id)
+		}
 		s.Templates = make(map[TemplateKey]*TemplateWrapper)
 	}
 	s.lastSequence = seqNum
-	return prev, reset
+	return prev, removed, reset
 }
 
 func isValidSequence(current, next uint32) bool {
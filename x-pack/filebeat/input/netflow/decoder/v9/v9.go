@@ -15,6 +15,7 @@ import (
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/config"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/protocol"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/record"
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/template"
 )
 
 const (
@@ -29,11 +30,14 @@ const (
 type NetflowV9Protocol struct {
 	ctx            context.Context
 	cancel         context.CancelFunc
+	version        uint16
 	decoder        Decoder
 	logger         *log.Logger
 	Session        SessionMap
 	timeout        time.Duration
 	cache          *pendingTemplatesCache
+	store          template.Store
+	storeTTL       time.Duration
 	detectReset    bool
 	shareTemplates bool
 }
@@ -44,18 +48,23 @@ func init() {
 
 func New(config config.Config) protocol.Protocol {
 	logger := log.New(config.LogOutput(), LogPrefix, 0)
-	return NewProtocolWithDecoder(DecoderV9{Logger: logger, Fields: config.Fields()}, config, logger)
+	return NewProtocolWithDecoder(ProtocolID, DecoderV9{Logger: logger, Fields: config.Fields()}, config, logger)
 }
 
-func NewProtocolWithDecoder(decoder Decoder, config config.Config, logger *log.Logger) *NetflowV9Protocol {
+// NewProtocolWithDecoder returns a protocol using the given decoder. The
+// version identifies the templates of the protocol in the template store.
+func NewProtocolWithDecoder(version uint16, decoder Decoder, config config.Config, logger *log.Logger) *NetflowV9Protocol {
 	ctx, cancel := context.WithCancel(context.Background())
 	pd := &NetflowV9Protocol{
 		ctx:            ctx,
 		cancel:         cancel,
+		version:        version,
 		decoder:        decoder,
 		logger:         logger,
 		Session:        NewSessionMap(logger, config.ActiveSessionsMetric()),
 		timeout:        config.ExpirationTimeout(),
+		store:          config.TemplateStore(),
+		storeTTL:       config.TemplateTTL(),
 		detectReset:    config.SequenceResetEnabled(),
 		shareTemplates: config.ShareTemplatesEnabled(),
 	}
@@ -72,6 +81,12 @@ func (*NetflowV9Protocol) Version() uint16 {
 }
 
 func (p *NetflowV9Protocol) Start() error {
+	if p.store != nil {
+		if err := p.restoreTemplates(time.Now()); err != nil {
+			return fmt.Errorf("error restoring templates: %w", err)
+		}
+	}
+
 	if p.timeout != time.Duration(0) {
 		go p.Session.CleanupLoop(p.timeout, p.ctx.Done())
 	}
@@ -106,8 +121,9 @@ func (p *NetflowV9Protocol) OnPacket(buf *bytes.Buffer, source net.Addr) (flows
 
 	p.logger.Printf("Packet from:%s src:%d seq:%d", remote, header.SourceID, header.SequenceNo)
 	if p.detectReset {
-		if prev, reset := session.CheckReset(header.SequenceNo); reset {
+		if prev, removed, reset := session.checkReset(header.SequenceNo); reset {
 			p.logger.Printf("Session %s reset (sequence=%d last=%d)", remote, header.SequenceNo, prev)
+			p.removeStoredTemplates(sessionKey, removed)
 		}
 	}
 
@@ -150,7 +166,7 @@ func (p *NetflowV9Protocol) parseSet(
 
 		if template == nil {
 			if p.cache != nil {
-				p.cache.Add(key, buf)
+				p.cache.Add(key, setID, buf)
 			} else {
 				p.logger.Printf("No template for ID %d", setID)
 			}
@@ -167,11 +183,12 @@ func (p *NetflowV9Protocol) parseSet(
 	}
 	for _, template := range templates {
 		session.AddTemplate(template)
+		p.persistTemplate(key, session, template)
 
 		if p.cache == nil {
 			continue
 		}
-		events := p.cache.GetAndRemove(key)
+		events := p.cache.GetAndRemove(key, template.ID)
 		for _, e := range events {
 			f, err := template.Apply(e, 0)
 			if err != nil {
@@ -183,3 +200,74 @@ func (p *NetflowV9Protocol) parseSet(
 
 	return flows, nil
 }
+
+func (p *NetflowV9Protocol) storedKey(key SessionKey) template.StoredKey {
+	return template.StoredKey{Version: p.version, Exporter: key.Addr, Domain: key.SourceID}
+}
+
+// restoreTemplates adds the templates from the template store to their
+// sessions. Expired templates are removed from the store.
+func (p *NetflowV9Protocol) restoreTemplates(now time.Time) error {
+	stored, err := p.store.Load()
+	if err != nil {
+		return err
+	}
+	var restored, expired int
+	for _, st := range stored {
+		if st.Version != p.version {
+			continue
+		}
+		if p.storeTTL > 0 && now.Sub(st.Updated) > p.storeTTL {
+			if err := p.store.Remove(st.StoredKey); err != nil {
+				p.logger.Printf("Failed to remove expired template %s: %v", st.StoredKey, err)
+			}
+			expired++
+			continue
+		}
+		if p.shareTemplates != (st.Exporter == "") {
+			// Stored with a different share_templates setting.
+			continue
+		}
+		t, err := st.Template(p.decoder.GetFields())
+		if err != nil {
+			p.logger.Printf("Failed to restore template %s: %v", st.StoredKey, err)
+			continue
+		}
+		key := SessionKey{Addr: st.Exporter, SourceID: st.Domain}
+		p.Session.GetOrCreate(key).restoreTemplate(t, st.Updated)
+		restored++
+	}
+	p.logger.Printf("Restored %d templates, %d expired", restored, expired)
+	return nil
+}
+
+// persistTemplate saves a received template to the template store, unless
+// it was already persisted recently.
+func (p *NetflowV9Protocol) persistTemplate(key SessionKey, session *SessionState, t *template.Template) {
+	if p.store == nil {
+		return
+	}
+	now := time.Now()
+	// Refresh stored templates before they expire.
+	if !session.markPersisted(t.ID, now, p.storeTTL/2) {
+		return
+	}
+	if err := p.store.Save(t.Store(p.storedKey(key), now)); err != nil {
+		p.logger.Printf("Failed to persist template %d: %v", t.ID, err)
+	}
+}
+
+// removeStoredTemplates removes the templates of a session that was reset
+// from the template store.
+func (p *NetflowV9Protocol) removeStoredTemplates(key SessionKey, ids []TemplateKey) {
+	if p.store == nil {
+		return
+	}
+	storedKey := p.storedKey(key)
+	for _, id := range ids {
+		storedKey.ID = uint16(id)
+		if err := p.store.Remove(storedKey); err != nil {
+			p.logger.Printf("Failed to remove template %d: %v", id, err)
+		}
+	}
+}
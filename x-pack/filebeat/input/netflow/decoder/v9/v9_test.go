@@ -6,12 +6,16 @@ package v9
 
 import (
 	"bytes"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/config"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/fields"
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/template"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/test"
 )
 
@@ -299,3 +303,181 @@ func TestSharedTemplates(t *testing.T) {
 		assert.Empty(t, flows)
 	})
 }
+
+type memoryTemplateStore struct {
+	mutex     sync.Mutex
+	templates map[template.StoredKey]template.Stored
+}
+
+func newMemoryTemplateStore() *memoryTemplateStore {
+	return &memoryTemplateStore{templates: make(map[template.StoredKey]template.Stored)}
+}
+
+func (s *memoryTemplateStore) Load() ([]template.Stored, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var stored []template.Stored
+	for _, st := range s.templates {
+		stored = append(stored, st)
+	}
+	return stored, nil
+}
+
+func (s *memoryTemplateStore) Save(st template.Stored) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.templates[st.StoredKey] = st
+	return nil
+}
+
+func (s *memoryTemplateStore) Remove(key template.StoredKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.templates, key)
+	return nil
+}
+
+func TestTemplateStore(t *testing.T) {
+	addr := test.MakeAddress(t, "127.0.0.1:12345")
+	templatePacket := []uint16{
+		// Header
+		// Version, Count, Uptime, Ts, SeqNo, Source
+		9, 1, 11, 11, 22, 22, 0, 10, 0, 1234,
+		// Set #1 (template)
+		0, 20, /*len of set*/
+		999, 3, /*len*/
+		1, 4, // Fields
+		2, 4,
+		3, 4,
+	}
+	flowsPacket := []uint16{
+		// Header
+		// Version, Count, Uptime, Ts, SeqNo, Source
+		9, 1, 11, 11, 22, 22, 0, 5000, 0, 1234,
+		// Set #1 (flows)
+		999, 16, /*len of set*/
+		1, 1,
+		2, 2,
+		3, 3,
+	}
+	key := template.StoredKey{Version: ProtocolID, Exporter: addr.String(), Domain: 1234, ID: 999}
+	newProtocol := func(t *testing.T, store template.Store, ttl time.Duration) *NetflowV9Protocol {
+		cfg := config.Defaults()
+		cfg.WithLogOutput(test.TestLogWriter{TB: t}).WithTemplateStore(store, ttl)
+		proto, ok := New(cfg).(*NetflowV9Protocol)
+		if !ok {
+			t.Fatal("unexpected protocol type")
+		}
+		return proto
+	}
+
+	t.Run("restored after restart", func(t *testing.T) {
+		store := newMemoryTemplateStore()
+		proto := newProtocol(t, store, time.Hour)
+		require.NoError(t, proto.Start())
+		flows, err := proto.OnPacket(test.MakePacket(templatePacket), addr)
+		require.NoError(t, err)
+		assert.Empty(t, flows)
+		require.NoError(t, proto.Stop())
+		require.Contains(t, store.templates, key)
+		assert.Len(t, store.templates[key].Fields, 3)
+
+		proto = newProtocol(t, store, time.Hour)
+		require.NoError(t, proto.Start())
+		defer proto.Stop()
+		// The sequence number isn't checked against the one before the
+		// restart.
+		flows, err = proto.OnPacket(test.MakePacket(flowsPacket), addr)
+		require.NoError(t, err)
+		assert.Len(t, flows, 1)
+	})
+
+	t.Run("expired templates are removed", func(t *testing.T) {
+		store := newMemoryTemplateStore()
+		proto := newProtocol(t, store, time.Hour)
+		require.NoError(t, proto.Start())
+		_, err := proto.OnPacket(test.MakePacket(templatePacket), addr)
+		require.NoError(t, err)
+		require.NoError(t, proto.Stop())
+
+		st := store.templates[key]
+		st.Updated = st.Updated.Add(-2 * time.Hour)
+		store.templates[key] = st
+
+		proto = newProtocol(t, store, time.Hour)
+		require.NoError(t, proto.Start())
+		defer proto.Stop()
+		assert.Empty(t, store.templates)
+		flows, err := proto.OnPacket(test.MakePacket(flowsPacket), addr)
+		require.NoError(t, err)
+		assert.Empty(t, flows)
+	})
+
+	t.Run("reset removes templates", func(t *testing.T) {
+		store := newMemoryTemplateStore()
+		proto := newProtocol(t, store, time.Hour)
+		require.NoError(t, proto.Start())
+		defer proto.Stop()
+		_, err := proto.OnPacket(test.MakePacket(templatePacket), addr)
+		require.NoError(t, err)
+		require.Contains(t, store.templates, key)
+
+		_, err = proto.OnPacket(test.MakePacket(flowsPacket), addr)
+		require.NoError(t, err)
+		assert.Empty(t, store.templates)
+	})
+
+	t.Run("unchanged templates are not saved again", func(t *testing.T) {
+		store := newMemoryTemplateStore()
+		proto := newProtocol(t, store, time.Hour)
+		require.NoError(t, proto.Start())
+		defer proto.Stop()
+		_, err := proto.OnPacket(test.MakePacket(templatePacket), addr)
+		require.NoError(t, err)
+		updated := store.templates[key].Updated
+		_, err = proto.OnPacket(test.MakePacket(templatePacket), addr)
+		require.NoError(t, err)
+		assert.Equal(t, updated, store.templates[key].Updated)
+	})
+}
+
+func TestPendingDataSets(t *testing.T) {
+	addr := test.MakeAddress(t, "127.0.0.1:12345")
+	flowsPacket := []uint16{
+		// Header
+		// Version, Count, Uptime, Ts, SeqNo, Source
+		9, 2, 11, 11, 22, 22, 0, 10, 0, 1234,
+		// Set #1 (flows)
+		999, 16, /*len of set*/
+		1, 1,
+		2, 2,
+		3, 3,
+		// Set #2 (flows)
+		998, 8, /*len of set*/
+		4, 4,
+	}
+	templatePacket := []uint16{
+		// Header
+		// Version, Count, Uptime, Ts, SeqNo, Source
+		9, 1, 11, 11, 22, 22, 0, 11, 0, 1234,
+		// Set #1 (template)
+		0, 20, /*len of set*/
+		999, 3, /*len*/
+		1, 4, // Fields
+		2, 4,
+		3, 4,
+	}
+	cfg := config.Defaults()
+	cfg.WithLogOutput(test.TestLogWriter{TB: t}).WithCache(true)
+	proto := New(cfg)
+	require.NoError(t, proto.Start())
+	defer proto.Stop()
+
+	flows, err := proto.OnPacket(test.MakePacket(flowsPacket), addr)
+	require.NoError(t, err)
+	assert.Empty(t, flows)
+	// Only the data set of the received template is decoded.
+	flows, err = proto.OnPacket(test.MakePacket(templatePacket), addr)
+	require.NoError(t, err)
+	assert.Len(t, flows, 1)
+}
@@ -7,11 +7,13 @@ package netflow
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
 	"time"
 
+	"github.com/elastic/beats/v7/filebeat/beater"
 	"github.com/elastic/beats/v7/filebeat/input/netmetrics"
 	v2 "github.com/elastic/beats/v7/filebeat/input/v2"
 	"github.com/elastic/beats/v7/filebeat/inputsource"
@@ -31,20 +33,22 @@ const (
 	inputName = "netflow"
 )
 
-func Plugin(log *logp.Logger) v2.Plugin {
+func Plugin(log *logp.Logger, store beater.StateStore) v2.Plugin {
 	return v2.Plugin{
 		Name:       inputName,
 		Stability:  feature.Stable,
 		Deprecated: false,
 		Info:       "collect and decode packets of netflow protocol",
 		Manager: &netflowInputManager{
-			log: log.Named(inputName),
+			log:   log.Named(inputName),
+			store: store,
 		},
 	}
 }
 
 type netflowInputManager struct {
-	log *logp.Logger
+	log   *logp.Logger
+	store beater.StateStore
 }
 
 func (im *netflowInputManager) Init(_ unison.Group) error {
@@ -72,6 +76,7 @@ func (im *netflowInputManager) Create(cfg *conf.C) (v2.Input, error) {
 		internalNetworks: inputCfg.InternalNetworks,
 		logger:           im.log,
 		queueSize:        inputCfg.PacketQueueSize,
+		stateStore:       im.store,
 	}
 
 	return input, nil
@@ -92,6 +97,8 @@ type netflowInput struct {
 	customFields     []fields.FieldDict
 	internalNetworks []string
 	logger           *logp.Logger
+	stateStore       beater.StateStore
+	templateStore    *templateStore
 	queueC           chan packet
 	wg               sync.WaitGroup
 	ctx              context.Context
@@ -128,8 +135,7 @@ func (n *netflowInput) Run(env v2.Context, connector beat.PipelineConnector) err
 	defer n.udpMetrics.Close()
 
 	n.metrics = newInputMetrics(n.udpMetrics.Registry())
-	var err error
-	n.decoder, err = decoder.NewDecoder(decoder.NewConfig().
+	decoderCfg := decoder.NewConfig().
 		WithProtocols(n.cfg.Protocols...).
 		WithExpiration(n.cfg.ExpirationTimeout).
 		WithLogOutput(&logDebugWrapper{Logger: n.logger}).
@@ -137,7 +143,21 @@ func (n *netflowInput) Run(env v2.Context, connector beat.PipelineConnector) err
 		WithSequenceResetEnabled(n.cfg.DetectSequenceReset).
 		WithSharedTemplates(n.cfg.ShareTemplates).
 		WithActiveSessionsMetric(n.metrics.ActiveSessions()).
-		WithCache(n.cfg.NumberOfWorkers > 1))
+		// Keep data sets received before their template for a short time,
+		// to decode them once it arrives. Multiple workers can reorder
+		// packets, and after a restart the templates missing from the
+		// template store are only learnt from the exporters.
+		WithCache(n.cfg.NumberOfWorkers > 1 || n.cfg.PersistTemplates)
+	if n.cfg.PersistTemplates {
+		if err := n.openTemplateStore(env.ID); err != nil {
+			env.UpdateStatus(status.Failed, fmt.Sprintf("Failed to open template store: %v", err))
+			return err
+		}
+		defer n.closeTemplateStore()
+		decoderCfg.WithTemplateStore(n.templateStore, n.cfg.TemplateTTL)
+	}
+	var err error
+	n.decoder, err = decoder.NewDecoder(decoderCfg)
 	if err != nil {
 		env.UpdateStatus(status.Failed, fmt.Sprintf("Failed to initialize netflow decoder: %v", err))
 		return fmt.Errorf("error initializing netflow decoder: %w", err)
@@ -256,6 +276,26 @@ func (w *logDebugWrapper) Write(p []byte) (n int, err error) {
 	return n, nil
 }
 
+// openTemplateStore opens the store used to persist templates. Templates are
+// stored under the input ID, or the listening address when it has none.
+func (n *netflowInput) openTemplateStore(inputID string) error {
+	if n.stateStore == nil {
+		return errors.New("persist_templates requires a state store")
+	}
+	if inputID == "" {
+		inputID = n.cfg.Host
+	}
+	var err error
+	n.templateStore, err = newTemplateStore(n.logger, n.stateStore, inputID)
+	return err
+}
+
+func (n *netflowInput) closeTemplateStore() {
+	if err := n.templateStore.Close(); err != nil {
+		n.logger.Errorw("Error closing template store", "error", err)
+	}
+}
+
 // stop stops the netflow input
 func (n *netflowInput) stop() {
 	n.mtx.Lock()
@@ -27,7 +27,7 @@ func TestNewInputDone(t *testing.T) {
 	config, err := conf.NewConfigFrom(mapstr.M{})
 	require.NoError(t, err)
 
-	_, err = Plugin(logp.NewLogger("netflow_test")).Manager.Create(config)
+	_, err = Plugin(logp.NewLogger("netflow_test"), nil).Manager.Create(config)
 	require.NoError(t, err)
 }
 
@@ -44,7 +44,7 @@ func TestConfig_CustomDefinitions(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	v2input, err := Plugin(logp.NewLogger("netflow_test")).Manager.Create(config)
+	v2input, err := Plugin(logp.NewLogger("netflow_test"), nil).Manager.Create(config)
 	require.NoError(t, err)
 
 	input := v2input.(*netflowInput)
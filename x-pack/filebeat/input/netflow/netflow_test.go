@@ -98,7 +98,7 @@ func TestNetFlow(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			netflowPlugin, err := Plugin(logp.NewLogger("netflow_test")).Manager.Create(pluginCfg)
+			netflowPlugin, err := Plugin(logp.NewLogger("netflow_test"), nil).Manager.Create(pluginCfg)
 			require.NoError(t, err)
 
 			mockPipeline := &pipelinemock.MockPipelineConnector{}
@@ -0,0 +1,90 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package netflow
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/elastic/beats/v7/filebeat/beater"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/template"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const templateStatePrefix = "filebeat::netflow::template::"
+
+// templateStore persists NetFlow V9 and IPFIX templates in the registry.
+// Each input uses its own key prefix.
+type templateStore struct {
+	log    *logp.Logger
+	prefix string
+
+	// mutex must be held to access store, which isn't safe for concurrent
+	// use.
+	mutex sync.Mutex
+	store *statestore.Store
+}
+
+var _ template.Store = (*templateStore)(nil)
+
+func newTemplateStore(log *logp.Logger, stateStore beater.StateStore, inputID string) (*templateStore, error) {
+	store, err := stateStore.Access("")
+	if err != nil {
+		return nil, fmt.Errorf("can't access persistent store: %w", err)
+	}
+	return &templateStore{
+		log:    log,
+		prefix: templateStatePrefix + inputID + "::",
+		store:  store,
+	}, nil
+}
+
+// Load returns the templates stored by the input.
+func (s *templateStore) Load() ([]template.Stored, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var stored []template.Stored
+	err := s.store.Each(func(key string, dec statestore.ValueDecoder) (bool, error) {
+		if !strings.HasPrefix(key, s.prefix) {
+			return true, nil
+		}
+		var st template.Stored
+		if err := dec.Decode(&st); err != nil {
+			// Skip invalid entries but continue iteration.
+			s.log.Warnw("Invalid stored template.", "key", key, "error", err)
+			return true, nil
+		}
+		stored = append(stored, st)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// Save stores a template.
+func (s *templateStore) Save(st template.Stored) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store.Set(s.prefix+st.StoredKey.String(), st)
+}
+
+// Remove removes a stored template.
+func (s *templateStore) Remove(key template.StoredKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store.Remove(s.prefix + key.String())
+}
+
+// Close releases the store.
+func (s *templateStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store.Close()
+}
@@ -0,0 +1,90 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build !integration
+
+package netflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/storetest"
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow/decoder/template"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+type testInputStore struct {
+	registry *statestore.Registry
+}
+
+func (s *testInputStore) Close() {
+	_ = s.registry.Close()
+}
+
+func (s *testInputStore) Access(_ string) (*statestore.Store, error) {
+	return s.registry.Get("filebeat")
+}
+
+func (s *testInputStore) CleanupInterval() time.Duration {
+	return 24 * time.Hour
+}
+
+func TestTemplateStore(t *testing.T) {
+	stateStore := &testInputStore{registry: statestore.NewRegistry(storetest.NewMemoryStoreBackend())}
+	defer stateStore.Close()
+	log := logp.NewLogger("netflow_test")
+
+	updated := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	stored := template.Stored{
+		StoredKey: template.StoredKey{
+			Version:  10,
+			Exporter: "192.0.2.1:4739",
+			Domain:   42,
+			ID:       256,
+		},
+		Updated:     updated,
+		ScopeFields: 1,
+		IsOptions:   true,
+		Fields: []template.StoredField{
+			{FieldID: 8, Length: 4},
+			{EnterpriseID: 9, FieldID: 12232, Length: 0xffff},
+		},
+	}
+
+	store, err := newTemplateStore(log, stateStore, "input-a")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(stored))
+
+	other, err := newTemplateStore(log, stateStore, "input-b")
+	require.NoError(t, err)
+	otherStored := stored
+	otherStored.ID = 257
+	require.NoError(t, other.Save(otherStored))
+
+	loaded, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, stored.StoredKey, loaded[0].StoredKey)
+	assert.True(t, updated.Equal(loaded[0].Updated))
+	assert.Equal(t, stored.ScopeFields, loaded[0].ScopeFields)
+	assert.Equal(t, stored.IsOptions, loaded[0].IsOptions)
+	assert.Equal(t, stored.Fields, loaded[0].Fields)
+
+	require.NoError(t, store.Remove(stored.StoredKey))
+	loaded, err = store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+
+	loaded, err = other.Load()
+	require.NoError(t, err)
+	assert.Len(t, loaded, 1)
+
+	require.NoError(t, store.Close())
+	require.NoError(t, other.Close())
+}
@@ -0,0 +1,182 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// client is a connection to the server of the files.
+type client interface {
+	// readDir returns the entries of a directory, symbolic links are not
+	// followed.
+	readDir(dir string) ([]fs.FileInfo, error)
+	stat(p string) (fs.FileInfo, error)
+	// open returns the content of a file from offset.
+	open(p string, offset int64) (io.ReadCloser, error)
+	remove(p string) error
+	mkdirAll(dir string) error
+	// rename renames a file, replacing dst when it exists and the server
+	// supports it.
+	rename(src, dst string) error
+	Close() error
+}
+
+// sftpClient is an SFTP session and its SSH connection.
+type sftpClient struct {
+	*sftp.Client
+	conn *ssh.Client
+}
+
+// dialSFTP connects to the SFTP server.
+func dialSFTP(ctx context.Context, cfg *config, sshConfig *ssh.ClientConfig) (*sftpClient, error) {
+	addr := address(cfg.Host, "22")
+	d := net.Dialer{Timeout: cfg.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sshClient := ssh.NewClient(c, chans, reqs)
+	session, err := sftp.NewClient(sshClient, sftp.UseConcurrentReads(true))
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("starting sftp session: %w", err)
+	}
+	return &sftpClient{Client: session, conn: sshClient}, nil
+}
+
+func (c *sftpClient) readDir(dir string) ([]fs.FileInfo, error) { return c.ReadDir(dir) }
+func (c *sftpClient) stat(p string) (fs.FileInfo, error)        { return c.Stat(p) }
+func (c *sftpClient) remove(p string) error                     { return c.Remove(p) }
+func (c *sftpClient) mkdirAll(dir string) error                 { return c.MkdirAll(dir) }
+
+func (c *sftpClient) open(p string, offset int64) (io.ReadCloser, error) {
+	f, err := c.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (c *sftpClient) rename(src, dst string) error {
+	// The posix rename extension replaces existing files, which the
+	// standard rename does not.
+	err := c.PosixRename(src, dst)
+	var status *sftp.StatusError
+	if errors.As(err, &status) && status.FxCode() == sftp.ErrSSHFxOpUnsupported {
+		err = c.Rename(src, dst)
+	}
+	return err
+}
+
+func (c *sftpClient) Close() error {
+	return errors.Join(c.Client.Close(), c.conn.Close())
+}
+
+// address returns the host and port of the server, using the default port
+// of the protocol when host has no port.
+func address(host, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
+}
+
+func newSSHConfig(cfg *config) (*ssh.ClientConfig, error) {
+	var auth []ssh.AuthMethod
+	if cfg.Auth.PrivateKey != "" {
+		signer, err := readPrivateKey(cfg.Auth.PrivateKey, cfg.Auth.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Auth.Password != "" {
+		password := cfg.Auth.Password
+		auth = append(auth,
+			ssh.Password(password),
+			// Some servers only accept passwords with keyboard interactive
+			// authentication.
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+		)
+	}
+
+	hostKeyCallback, err := newHostKeyCallback(cfg.HostKey)
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            cfg.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         cfg.Timeout,
+	}, nil
+}
+
+func readPrivateKey(path, passphrase string) (ssh.Signer, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key: %w", err)
+	}
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(pem)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+func newHostKeyCallback(cfg hostKeyConfig) (ssh.HostKeyCallback, error) {
+	switch {
+	case cfg.KnownHosts != "":
+		cb, err := knownhosts.New(cfg.KnownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("reading known hosts: %w", err)
+		}
+		return cb, nil
+	case len(cfg.Fingerprints) != 0:
+		return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			fingerprint := ssh.FingerprintSHA256(key)
+			for _, f := range cfg.Fingerprints {
+				if f == fingerprint {
+					return nil
+				}
+			}
+			return fmt.Errorf("host key fingerprint %s is not accepted", fingerprint)
+		}, nil
+	default:
+		return ssh.InsecureIgnoreHostKey(), nil //nolint:gosec // The verification is explicitly disabled.
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/beats/v7/libbeat/reader/parser"
+	"github.com/elastic/beats/v7/libbeat/reader/readfile"
+	"github.com/elastic/beats/v7/libbeat/reader/readfile/encoding"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+// MaxWorkers, Poll, PollInterval, Recursive, Include & Exclude can be configured at a global level,
+// which applies to all directories, as well as at the directory level.
+// Directory level configurations will always override global level values.
+type config struct {
+	// Protocol - Defines the protocol of the server, sftp or ftps.
+	Protocol string `config:"protocol"`
+	// Host - Defines the address of the server, as host or host:port. The default port is 22 for SFTP,
+	// 21 for explicit FTPS and 990 for implicit FTPS.
+	Host string `config:"host" validate:"required"`
+	// Username - Defines the user to authenticate as.
+	Username string `config:"username" validate:"required"`
+	// Auth - Defines the authentication methods, the private key is tried before the password.
+	Auth authConfig `config:"auth"`
+	// HostKey - Defines how the host key of the SFTP server is verified.
+	HostKey hostKeyConfig `config:"host_key"`
+	// TLS - Defines the TLS options of the FTPS connections.
+	TLS *tlscommon.Config `config:"ssl"`
+	// FTPS - Defines the FTPS specific options.
+	FTPS ftpsConfig `config:"ftps"`
+	// Timeout - Defines the timeout of the connection establishment, and of the command responses with FTPS.
+	Timeout time.Duration `config:"timeout" validate:"min=0"`
+	// MaxWorkers - Defines the maximum number of files read concurrently.
+	MaxWorkers int `config:"max_workers" validate:"min=1,max=5000"`
+	// Poll - Defines if the directories are polled or only listed once.
+	Poll bool `config:"poll"`
+	// PollInterval - Defines the time to wait between two listings of a directory.
+	PollInterval time.Duration `config:"poll_interval" validate:"min=0"`
+	// Recursive - Defines if the sub directories are listed.
+	Recursive bool `config:"recursive"`
+	// Include - Defines glob patterns of the files to read, all files are read when empty.
+	Include []string `config:"include"`
+	// Exclude - Defines glob patterns of the files to ignore.
+	Exclude []string `config:"exclude"`
+	// Directories - Defines the list of directories that will be polled for files.
+	Directories []directory `config:"directories" validate:"required"`
+	// AfterRead - Defines what is done with the files once all their events are acknowledged.
+	AfterRead afterReadConfig `config:"after_read"`
+	// ReaderConfig - Defines the parser and decoding options.
+	ReaderConfig readerConfig `config:",inline"`
+}
+
+// directory contains the config for each remote directory.
+type directory struct {
+	Path         string         `config:"path" validate:"required"`
+	MaxWorkers   *int           `config:"max_workers" validate:"min=1,max=5000"`
+	Poll         *bool          `config:"poll"`
+	PollInterval *time.Duration `config:"poll_interval" validate:"min=0"`
+	Recursive    *bool          `config:"recursive"`
+	Include      []string       `config:"include"`
+	Exclude      []string       `config:"exclude"`
+}
+
+type authConfig struct {
+	Password string `config:"password"`
+	// PrivateKey is the path of a PEM encoded private key file.
+	PrivateKey           string `config:"private_key"`
+	PrivateKeyPassphrase string `config:"private_key_passphrase"`
+}
+
+type hostKeyConfig struct {
+	// KnownHosts is the path of an OpenSSH known_hosts file.
+	KnownHosts string `config:"known_hosts"`
+	// Fingerprints are the accepted SHA256 fingerprints, as printed by ssh-keygen -l.
+	Fingerprints []string `config:"fingerprints"`
+	// Insecure disables the verification of the host key.
+	Insecure bool `config:"insecure"`
+}
+
+const (
+	protocolSFTP = "sftp"
+	protocolFTPS = "ftps"
+)
+
+type ftpsConfig struct {
+	// Mode is explicit, the connection is secured with the AUTH TLS command,
+	// or implicit, the connection is secured from the start.
+	Mode string `config:"mode"`
+}
+
+const (
+	afterReadNone   = "none"
+	afterReadDelete = "delete"
+	afterReadMove   = "move"
+)
+
+type afterReadConfig struct {
+	// Action is one of none, delete or move.
+	Action string `config:"action"`
+	// MoveTo is the directory the files are moved to, with their path relative to their directory.
+	MoveTo string `config:"move_to"`
+}
+
+// readerConfig defines the options for reading the content of a file.
+type readerConfig struct {
+	BufferSize     cfgtype.ByteSize        `config:"buffer_size"`
+	Encoding       string                  `config:"encoding"`
+	LineTerminator readfile.LineTerminator `config:"line_terminator"`
+	MaxBytes       cfgtype.ByteSize        `config:"max_bytes"`
+	Parsers        parser.Config           `config:",inline"`
+}
+
+func (c *authConfig) Validate() error {
+	if c.Password == "" && c.PrivateKey == "" {
+		return errors.New("at least one of auth.password or auth.private_key must be set")
+	}
+	return nil
+}
+
+func (c *hostKeyConfig) validate() error {
+	n := 0
+	for _, set := range []bool{c.KnownHosts != "", len(c.Fingerprints) != 0, c.Insecure} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("exactly one of host_key.known_hosts, host_key.fingerprints or host_key.insecure must be set")
+	}
+	return nil
+}
+
+func (c *afterReadConfig) Validate() error {
+	switch c.Action {
+	case afterReadNone, afterReadDelete:
+		if c.MoveTo != "" {
+			return fmt.Errorf("after_read.move_to can only be set with the %s action", afterReadMove)
+		}
+	case afterReadMove:
+		if !path.IsAbs(c.MoveTo) {
+			return fmt.Errorf("after_read.move_to must be an absolute path: %q", c.MoveTo)
+		}
+	default:
+		return fmt.Errorf("invalid after_read.action %q, must be one of %s, %s or %s", c.Action, afterReadNone, afterReadDelete, afterReadMove)
+	}
+	return nil
+}
+
+func (c *config) Validate() error {
+	switch c.Protocol {
+	case protocolSFTP:
+		if err := c.HostKey.validate(); err != nil {
+			return err
+		}
+		if c.TLS != nil {
+			return fmt.Errorf("ssl can only be set with the %s protocol", protocolFTPS)
+		}
+	case protocolFTPS:
+		if c.HostKey.KnownHosts != "" || len(c.HostKey.Fingerprints) != 0 || c.HostKey.Insecure {
+			return fmt.Errorf("host_key can only be set with the %s protocol", protocolSFTP)
+		}
+		if c.Auth.PrivateKey != "" {
+			return fmt.Errorf("auth.private_key is not supported with the %s protocol", protocolFTPS)
+		}
+		if c.TLS != nil && !c.TLS.IsEnabled() {
+			return fmt.Errorf("ssl can not be disabled with the %s protocol", protocolFTPS)
+		}
+		if c.FTPS.Mode != ftpsModeExplicit && c.FTPS.Mode != ftpsModeImplicit {
+			return fmt.Errorf("invalid ftps.mode %q, must be one of %s or %s", c.FTPS.Mode, ftpsModeExplicit, ftpsModeImplicit)
+		}
+	default:
+		return fmt.Errorf("invalid protocol %q, must be one of %s or %s", c.Protocol, protocolSFTP, protocolFTPS)
+	}
+	for _, d := range c.Directories {
+		if !path.IsAbs(d.Path) {
+			return fmt.Errorf("directory path must be absolute: %q", d.Path)
+		}
+		if c.AfterRead.Action == afterReadMove && path.Clean(d.Path) == path.Clean(c.AfterRead.MoveTo) {
+			return fmt.Errorf("after_read.move_to can not be the directory %q", d.Path)
+		}
+		if err := validatePatterns(d.Include, d.Exclude); err != nil {
+			return err
+		}
+	}
+	return validatePatterns(c.Include, c.Exclude)
+}
+
+func validatePatterns(lists ...[]string) error {
+	for _, patterns := range lists {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid glob pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (rc *readerConfig) Validate() error {
+	if rc.BufferSize <= 0 {
+		return fmt.Errorf("buffer_size <%v> must be greater than 0", rc.BufferSize)
+	}
+	if rc.MaxBytes <= 0 {
+		return fmt.Errorf("max_bytes <%v> must be greater than 0", rc.MaxBytes)
+	}
+	if _, found := encoding.FindEncoding(rc.Encoding); !found {
+		return fmt.Errorf("encoding type <%v> not found", rc.Encoding)
+	}
+	return nil
+}
+
+func (rc *readerConfig) InitDefaults() {
+	rc.BufferSize = 16 * humanize.KiByte
+	rc.MaxBytes = 10 * humanize.MiByte
+	rc.LineTerminator = readfile.AutoLineTerminator
+}
+
+// defaultConfig returns the default configuration for the input
+func defaultConfig() config {
+	return config{
+		Protocol:     protocolSFTP,
+		Timeout:      30 * time.Second,
+		MaxWorkers:   1,
+		Poll:         true,
+		PollInterval: 5 * time.Minute,
+		FTPS: ftpsConfig{
+			Mode: ftpsModeExplicit,
+		},
+		AfterRead: afterReadConfig{
+			Action: afterReadNone,
+		},
+	}
+}
@@ -0,0 +1,545 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/textproto"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ftpsModeExplicit = "explicit"
+	ftpsModeImplicit = "implicit"
+)
+
+// ftpsClient is the control connection of an FTPS session. FTP transfers
+// one file at a time on a control connection, so the operations are
+// serialized and a file being read holds the connection until it is closed.
+type ftpsClient struct {
+	mu        sync.Mutex
+	conn      net.Conn
+	text      *textproto.Conn
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	// mlsd is whether the server supports the machine readable listings of
+	// RFC 3659, the LIST output is parsed otherwise.
+	mlsd bool
+}
+
+// dialFTPS connects to the FTPS server and logs in.
+func dialFTPS(ctx context.Context, cfg *config, tlsConfig *tls.Config) (*ftpsClient, error) {
+	implicit := cfg.FTPS.Mode == ftpsModeImplicit
+	port := "21"
+	if implicit {
+		port = "990"
+	}
+	d := net.Dialer{Timeout: cfg.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", address(cfg.Host, port))
+	if err != nil {
+		return nil, err
+	}
+
+	// The data connections resume the TLS session of the control
+	// connection, which many servers require.
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+
+	c := &ftpsClient{tlsConfig: tlsConfig, timeout: cfg.Timeout}
+	if implicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c.setConn(conn)
+	if err := c.login(ctx, cfg, implicit); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ftpsClient) setConn(conn net.Conn) {
+	c.conn = conn
+	c.text = textproto.NewConn(conn)
+}
+
+// login secures the control connection, authenticates and sets up the
+// session for binary transfers over protected data connections.
+func (c *ftpsClient) login(ctx context.Context, cfg *config, implicit bool) error {
+	if err := c.setDeadline(); err != nil {
+		return err
+	}
+	if _, _, err := c.text.ReadResponse(220); err != nil {
+		return fmt.Errorf("reading greeting: %w", err)
+	}
+	if !implicit {
+		if _, _, err := c.cmd(234, "AUTH TLS"); err != nil {
+			return err
+		}
+		conn := tls.Client(c.conn, c.tlsConfig)
+		if err := conn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		c.setConn(conn)
+	}
+
+	code, _, err := c.cmd(0, "USER %s", cfg.Username)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case 230:
+	case 331:
+		if _, _, err := c.cmd(230, "PASS %s", cfg.Auth.Password); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("USER command failed: unexpected response code %d", code)
+	}
+
+	for _, cmd := range []string{"PBSZ 0", "PROT P", "TYPE I"} {
+		if _, _, err := c.cmd(200, "%s", cmd); err != nil {
+			return err
+		}
+	}
+	if _, msg, err := c.cmd(211, "FEAT"); err == nil {
+		for _, feature := range strings.Split(msg, "\n") {
+			if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(feature)), "MLST") {
+				c.mlsd = true
+			}
+		}
+	}
+	return nil
+}
+
+func (c *ftpsClient) setDeadline() error {
+	if c.timeout <= 0 {
+		return nil
+	}
+	return c.conn.SetDeadline(time.Now().Add(c.timeout))
+}
+
+// cmd sends a command and reads its response. The response code must be
+// expected, or in the class of expected when it has less than three digits.
+func (c *ftpsClient) cmd(expected int, format string, args ...interface{}) (int, string, error) {
+	verb, _, _ := strings.Cut(format, " ")
+	if err := c.setDeadline(); err != nil {
+		return 0, "", err
+	}
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return 0, "", fmt.Errorf("%s command failed: %w", verb, err)
+	}
+	code, msg, err := c.text.ReadResponse(expected)
+	if err != nil {
+		return code, msg, fmt.Errorf("%s command failed: %w", verb, err)
+	}
+	return code, msg, nil
+}
+
+// transfer starts a command transferring data, from offset when it is not
+// zero, and returns its data connection. The final response of the command
+// is read by finishTransfer once the data connection is closed.
+func (c *ftpsClient) transfer(offset int64, format string, args ...interface{}) (net.Conn, error) {
+	conn, err := c.dataConn()
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, _, err := c.cmd(350, "REST %d", offset); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if _, _, err := c.cmd(1, format, args...); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tls.Client(conn, c.tlsConfig), nil
+}
+
+func (c *ftpsClient) finishTransfer() error {
+	if err := c.setDeadline(); err != nil {
+		return err
+	}
+	if _, _, err := c.text.ReadResponse(2); err != nil {
+		return fmt.Errorf("transfer failed: %w", err)
+	}
+	return nil
+}
+
+// dataConn opens a passive data connection. The address of the passive
+// replies is ignored, the data connection is made to the host of the
+// control connection.
+func (c *ftpsClient) dataConn() (net.Conn, error) {
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return nil, err
+	}
+	var port int
+	_, msg, err := c.cmd(229, "EPSV")
+	if err == nil {
+		port, err = parseEPSV(msg)
+	} else {
+		_, msg, err = c.cmd(227, "PASV")
+		if err == nil {
+			port, err = parsePASV(msg)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := net.Dialer{Timeout: c.timeout}
+	return d.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// parseEPSV returns the port of an extended passive reply, like
+// "Entering Extended Passive Mode (|||6446|)".
+func parseEPSV(msg string) (int, error) {
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start+2 {
+		return 0, fmt.Errorf("invalid EPSV response: %q", msg)
+	}
+	fields := strings.Split(msg[start+2:end], msg[start+1:start+2])
+	if len(fields) != 4 {
+		return 0, fmt.Errorf("invalid EPSV response: %q", msg)
+	}
+	port, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return 0, fmt.Errorf("invalid EPSV response: %q", msg)
+	}
+	return port, nil
+}
+
+var pasvAddress = regexp.MustCompile(`(\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+
+// parsePASV returns the port of a passive reply, like
+// "Entering Passive Mode (192,168,1,2,25,46)".
+func parsePASV(msg string) (int, error) {
+	m := pasvAddress.FindStringSubmatch(msg)
+	if m == nil {
+		return 0, fmt.Errorf("invalid PASV response: %q", msg)
+	}
+	p1, _ := strconv.Atoi(m[5])
+	p2, _ := strconv.Atoi(m[6])
+	return p1<<8 + p2, nil
+}
+
+func (c *ftpsClient) readDir(dir string) ([]fs.FileInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	verb := "LIST"
+	if c.mlsd {
+		verb = "MLSD"
+	}
+	conn, err := c.transfer(0, "%s %s", verb, dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(conn)
+	conn.Close()
+	if ferr := c.finishTransfer(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []*ftpsFileInfo
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		var info *ftpsFileInfo
+		if c.mlsd {
+			info, err = parseMLSxEntry(line)
+		} else {
+			info, err = parseListEntry(line, time.Now())
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info == nil || info.name == "." || info.name == ".." {
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	entries := make([]fs.FileInfo, 0, len(infos))
+	for _, info := range infos {
+		// The modification times of LIST are not precise and change format
+		// with the age of the files, the exact times are used when the
+		// server supports them.
+		if !c.mlsd && info.mode.IsRegular() {
+			if t, ok := c.modTime(path.Join(dir, info.name)); ok {
+				info.modTime = t
+			}
+		}
+		entries = append(entries, info)
+	}
+	return entries, nil
+}
+
+// modTime returns the modification time of a file with the MDTM command.
+func (c *ftpsClient) modTime(p string) (time.Time, bool) {
+	_, msg, err := c.cmd(213, "MDTM %s", p)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t, err := parseFTPTime(strings.TrimSpace(msg))
+	return t, err == nil
+}
+
+func (c *ftpsClient) stat(p string) (fs.FileInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.mlsd {
+		_, msg, err := c.cmd(250, "MLST %s", p)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(msg, "\n") {
+			if strings.HasPrefix(line, " ") {
+				info, err := parseMLSxEntry(strings.TrimPrefix(line, " "))
+				if err != nil {
+					return nil, err
+				}
+				if info == nil {
+					break
+				}
+				info.name = path.Base(info.name)
+				return info, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid MLST response: %q", msg)
+	}
+
+	if _, _, err := c.cmd(250, "CWD %s", p); err == nil {
+		return &ftpsFileInfo{name: path.Base(p), mode: fs.ModeDir}, nil
+	}
+	_, msg, err := c.cmd(213, "SIZE %s", p)
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SIZE response: %q", msg)
+	}
+	modTime, _ := c.modTime(p)
+	return &ftpsFileInfo{name: path.Base(p), size: size, modTime: modTime}, nil
+}
+
+func (c *ftpsClient) open(p string, offset int64) (io.ReadCloser, error) {
+	c.mu.Lock()
+	conn, err := c.transfer(offset, "RETR %s", p)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	return &ftpsFile{client: c, conn: conn}, nil
+}
+
+func (c *ftpsClient) remove(p string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, _, err := c.cmd(250, "DELE %s", p)
+	return err
+}
+
+func (c *ftpsClient) mkdirAll(dir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, _, err := c.cmd(250, "CWD %s", dir); err == nil {
+		return nil
+	}
+	var parent string
+	for _, name := range strings.Split(strings.Trim(dir, "/"), "/") {
+		parent += "/" + name
+		// The directories that exist already can not be created, an error
+		// creating a missing directory is returned by the next operation.
+		_, _, _ = c.cmd(257, "MKD %s", parent)
+	}
+	return nil
+}
+
+func (c *ftpsClient) rename(src, dst string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, _, err := c.cmd(350, "RNFR %s", src); err != nil {
+		return err
+	}
+	_, _, err := c.cmd(250, "RNTO %s", dst)
+	return err
+}
+
+func (c *ftpsClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, _, _ = c.cmd(221, "QUIT")
+	return c.conn.Close()
+}
+
+// ftpsFile is the content of a file being transferred, it holds the control
+// connection until it is closed.
+type ftpsFile struct {
+	client *ftpsClient
+	conn   net.Conn
+	eof    bool
+}
+
+func (f *ftpsFile) Read(p []byte) (int, error) {
+	n, err := f.conn.Read(p)
+	if errors.Is(err, io.EOF) {
+		f.eof = true
+	}
+	return n, err
+}
+
+func (f *ftpsFile) Close() error {
+	defer f.client.mu.Unlock()
+
+	err := f.conn.Close()
+	// Closing the data connection before the end aborts the transfer, the
+	// server answers with an error then.
+	if ferr := f.client.finishTransfer(); f.eof {
+		err = errors.Join(err, ferr)
+	}
+	return err
+}
+
+// ftpsFileInfo is an entry of a directory listing.
+type ftpsFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi *ftpsFileInfo) Name() string       { return fi.name }
+func (fi *ftpsFileInfo) Size() int64        { return fi.size }
+func (fi *ftpsFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *ftpsFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *ftpsFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *ftpsFileInfo) Sys() interface{}   { return nil }
+
+// parseMLSxEntry parses an entry of a MLSD or MLST response, like
+// "type=file;size=14;modify=20240501100000; b.log". Entries without type
+// are ignored.
+func parseMLSxEntry(line string) (*ftpsFileInfo, error) {
+	facts, name, ok := strings.Cut(line, " ")
+	if !ok {
+		return nil, fmt.Errorf("invalid listing entry: %q", line)
+	}
+	info := &ftpsFileInfo{name: name}
+	var typ string
+	for _, fact := range strings.Split(facts, ";") {
+		key, value, _ := strings.Cut(fact, "=")
+		switch strings.ToLower(key) {
+		case "type":
+			typ = strings.ToLower(value)
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid size of listing entry: %q", line)
+			}
+			info.size = size
+		case "modify":
+			t, err := parseFTPTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid modification time of listing entry: %q", line)
+			}
+			info.modTime = t
+		}
+	}
+	switch {
+	case typ == "file":
+	case typ == "dir":
+		info.mode = fs.ModeDir
+	case typ == "cdir" || typ == "pdir" || typ == "":
+		return nil, nil
+	case strings.HasPrefix(typ, "os.unix=slink") || strings.HasPrefix(typ, "os.unix=symlink"):
+		info.mode = fs.ModeSymlink
+	default:
+		info.mode = fs.ModeIrregular
+	}
+	return info, nil
+}
+
+// parseFTPTime parses the UTC times of MLSx facts and MDTM responses, like
+// 20240501100000 or 20240501100000.123.
+func parseFTPTime(s string) (time.Time, error) {
+	layout := "20060102150405"
+	if len(s) > len(layout) {
+		layout += "." + strings.Repeat("0", len(s)-len(layout)-1)
+	}
+	return time.ParseInLocation(layout, s, time.UTC)
+}
+
+// listEntry matches the Unix style lines of LIST responses, like
+// "-rw-r--r--   1 owner group   14 May  1 10:00 b.log". The group column
+// is missing on some servers.
+var listEntry = regexp.MustCompile(`^([-dlbcps])\S{9}\S*\s+\d+\s+\S+\s+(?:\S+\s+)?(\d+)\s+(\w{3})\s+(\d{1,2})\s+(\d{1,2}:\d{2}|\d{4})\s+(.+)$`)
+
+// parseListEntry parses a Unix style line of a LIST response. The times
+// of the last six months have no year, they are in the past year before now.
+func parseListEntry(line string, now time.Time) (*ftpsFileInfo, error) {
+	if strings.HasPrefix(line, "total ") {
+		return nil, nil
+	}
+	m := listEntry.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported listing entry: %q", line)
+	}
+	info := &ftpsFileInfo{name: m[6]}
+	switch m[1] {
+	case "-":
+	case "d":
+		info.mode = fs.ModeDir
+	case "l":
+		info.mode = fs.ModeSymlink
+		info.name, _, _ = strings.Cut(info.name, " -> ")
+	default:
+		info.mode = fs.ModeIrregular
+	}
+	size, err := strconv.ParseInt(m[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid size of listing entry: %q", line)
+	}
+	info.size = size
+
+	if strings.Contains(m[5], ":") {
+		t, err := time.ParseInLocation("Jan 2 15:04 2006", fmt.Sprintf("%s %s %s %d", m[3], m[4], m[5], now.Year()), time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modification time of listing entry: %q", line)
+		}
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		info.modTime = t
+	} else {
+		t, err := time.ParseInLocation("Jan 2 2006", fmt.Sprintf("%s %s %s", m[3], m[4], m[5]), time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modification time of listing entry: %q", line)
+		}
+		info.modTime = t
+	}
+	return info, nil
+}
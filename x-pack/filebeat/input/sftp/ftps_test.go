@@ -0,0 +1,391 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+)
+
+// newTestFTPSServer runs an FTPS server serving the local file system, and
+// returns its address and the PEM encoded certificate of its CA. Without
+// mlsd, the server only supports the LIST listings.
+func newTestFTPSServer(t *testing.T, implicit, mlsd bool) (addr, ca string) {
+	t.Helper()
+	cert, ca := newTestCertificate(t)
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if implicit {
+				conn = tls.Server(conn, tlsConfig)
+			}
+			s := &ftpsSession{conn: conn, text: textproto.NewConn(conn), tlsConfig: tlsConfig, mlsd: mlsd}
+			go s.serve()
+		}
+	}()
+	return l.Addr().String(), ca
+}
+
+func newTestCertificate(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return cert, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// ftpsSession is a control connection of the test FTPS server.
+type ftpsSession struct {
+	conn      net.Conn
+	text      *textproto.Conn
+	tlsConfig *tls.Config
+	mlsd      bool
+	loggedIn  bool
+	data      net.Listener
+	rest      int64
+	renameSrc string
+}
+
+func (s *ftpsSession) reply(code int, msg string) {
+	s.text.PrintfLine("%d %s", code, msg) //nolint:errcheck // Test server.
+}
+
+func (s *ftpsSession) serve() {
+	defer s.conn.Close()
+	s.reply(220, "ready")
+	for {
+		line, err := s.text.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		cmd = strings.ToUpper(cmd)
+		if !s.loggedIn && cmd != "AUTH" && cmd != "USER" && cmd != "PASS" && cmd != "QUIT" {
+			s.reply(530, "not logged in")
+			continue
+		}
+		switch cmd {
+		case "AUTH":
+			s.reply(234, "AUTH TLS successful")
+			s.conn = tls.Server(s.conn, s.tlsConfig)
+			s.text = textproto.NewConn(s.conn)
+		case "USER":
+			s.reply(331, "password required")
+		case "PASS":
+			if arg != testPassword {
+				s.reply(530, "login incorrect")
+				continue
+			}
+			s.loggedIn = true
+			s.reply(230, "logged in")
+		case "PBSZ", "PROT", "TYPE":
+			s.reply(200, "ok")
+		case "FEAT":
+			if s.mlsd {
+				s.text.PrintfLine("211-Features:\r\n MLST type*;size*;modify*;\r\n211 End") //nolint:errcheck // Test server.
+			} else {
+				s.text.PrintfLine("211-Features:\r\n UTF8\r\n211 End") //nolint:errcheck // Test server.
+			}
+		case "EPSV":
+			if s.data != nil {
+				s.data.Close()
+			}
+			s.data, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				s.reply(425, err.Error())
+				continue
+			}
+			s.reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", s.data.Addr().(*net.TCPAddr).Port))
+		case "REST":
+			s.rest, _ = strconv.ParseInt(arg, 10, 64)
+			s.reply(350, "restarting")
+		case "RETR":
+			f, err := os.Open(arg)
+			if err == nil {
+				_, err = f.Seek(s.rest, io.SeekStart)
+			}
+			if err != nil {
+				s.reply(550, err.Error())
+				continue
+			}
+			s.transfer(func(w io.Writer) error {
+				_, err := io.Copy(w, f)
+				return err
+			})
+			f.Close()
+		case "MLSD", "LIST":
+			if (cmd == "MLSD") != s.mlsd {
+				s.reply(502, "not implemented")
+				continue
+			}
+			entries, err := os.ReadDir(arg)
+			if err != nil {
+				s.reply(550, err.Error())
+				continue
+			}
+			s.transfer(func(w io.Writer) error {
+				for _, e := range entries {
+					info, err := e.Info()
+					if err != nil {
+						return err
+					}
+					if cmd == "MLSD" {
+						fmt.Fprintf(w, "%s %s\r\n", mlsxFacts(info), info.Name())
+					} else {
+						fmt.Fprintf(w, "%s\r\n", listLine(info))
+					}
+				}
+				return nil
+			})
+		case "MLST":
+			info, err := os.Stat(arg)
+			if err != nil || !s.mlsd {
+				s.reply(550, "no such file")
+				continue
+			}
+			s.text.PrintfLine("250-Listing %s\r\n %s %s\r\n250 End", arg, mlsxFacts(info), arg) //nolint:errcheck // Test server.
+		case "MDTM":
+			info, err := os.Stat(arg)
+			if err != nil {
+				s.reply(550, err.Error())
+				continue
+			}
+			s.reply(213, info.ModTime().UTC().Format("20060102150405"))
+		case "SIZE":
+			info, err := os.Stat(arg)
+			if err != nil {
+				s.reply(550, err.Error())
+				continue
+			}
+			s.reply(213, strconv.FormatInt(info.Size(), 10))
+		case "CWD":
+			if info, err := os.Stat(arg); err != nil || !info.IsDir() {
+				s.reply(550, "not a directory")
+				continue
+			}
+			s.reply(250, "ok")
+		case "DELE":
+			if err := os.Remove(arg); err != nil {
+				s.reply(550, err.Error())
+				continue
+			}
+			s.reply(250, "deleted")
+		case "MKD":
+			if err := os.Mkdir(arg, 0o755); err != nil {
+				s.reply(550, err.Error())
+				continue
+			}
+			s.reply(257, "created")
+		case "RNFR":
+			s.renameSrc = arg
+			s.reply(350, "ready for RNTO")
+		case "RNTO":
+			if err := os.Rename(s.renameSrc, arg); err != nil {
+				s.reply(550, err.Error())
+				continue
+			}
+			s.reply(250, "renamed")
+		case "QUIT":
+			s.reply(221, "bye")
+			return
+		default:
+			s.reply(502, "not implemented")
+		}
+	}
+}
+
+// transfer accepts the passive data connection and writes the data of a
+// command.
+func (s *ftpsSession) transfer(write func(io.Writer) error) {
+	defer func() { s.rest = 0 }()
+	if s.data == nil {
+		s.reply(425, "use EPSV first")
+		return
+	}
+	s.reply(150, "opening data connection")
+	conn, err := s.data.Accept()
+	s.data.Close()
+	s.data = nil
+	if err != nil {
+		s.reply(425, err.Error())
+		return
+	}
+	dataConn := tls.Server(conn, s.tlsConfig)
+	err = write(dataConn)
+	dataConn.Close()
+	if err != nil {
+		s.reply(426, "transfer aborted")
+		return
+	}
+	s.reply(226, "transfer complete")
+}
+
+func mlsxFacts(info fs.FileInfo) string {
+	typ := "file"
+	if info.IsDir() {
+		typ = "dir"
+	}
+	return fmt.Sprintf("type=%s;size=%d;modify=%s;", typ, info.Size(), info.ModTime().UTC().Format("20060102150405.000"))
+}
+
+func listLine(info fs.FileInfo) string {
+	mtime := info.ModTime().UTC().Format("Jan _2 15:04")
+	return fmt.Sprintf("%s 1 owner group %d %s %s", info.Mode(), info.Size(), mtime, info.Name())
+}
+
+func TestParseListEntry(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		line string
+		want *ftpsFileInfo
+	}{
+		{
+			line: "-rw-r--r--    1 owner    group          14 Feb 28 10:00 b.log",
+			want: &ftpsFileInfo{name: "b.log", size: 14, modTime: time.Date(2024, 2, 28, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			line: "-rw-r--r--    1 owner    group          14 Dec  3 10:00 last year.log",
+			want: &ftpsFileInfo{name: "last year.log", size: 14, modTime: time.Date(2023, 12, 3, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			line: "-rw-r--r--    1 owner         5 May  1  2020 no group.log",
+			want: &ftpsFileInfo{name: "no group.log", size: 5, modTime: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			line: "drwxr-xr-x    2 owner    group        4096 May  1  2020 sub",
+			want: &ftpsFileInfo{name: "sub", size: 4096, mode: fs.ModeDir, modTime: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			line: "lrwxrwxrwx    1 owner    group           5 May  1  2020 link -> b.log",
+			want: &ftpsFileInfo{name: "link", size: 5, mode: fs.ModeSymlink, modTime: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{line: "total 12"},
+	} {
+		got, err := parseListEntry(tc.line, now)
+		require.NoError(t, err, tc.line)
+		assert.Equal(t, tc.want, got, tc.line)
+	}
+
+	_, err := parseListEntry("05-01-20  10:00AM       14 b.log", now)
+	assert.ErrorContains(t, err, "unsupported listing entry")
+}
+
+func TestParseMLSxEntry(t *testing.T) {
+	got, err := parseMLSxEntry("Type=file;Size=14;Modify=20240501100000.123;perm=r; a b.log")
+	require.NoError(t, err)
+	assert.Equal(t, &ftpsFileInfo{name: "a b.log", size: 14, modTime: time.Date(2024, 5, 1, 10, 0, 0, 123e6, time.UTC)}, got)
+
+	got, err = parseMLSxEntry("type=cdir;modify=20240501100000; /upload")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	_, err = parseMLSxEntry("type=file;size=x; a.log")
+	assert.ErrorContains(t, err, "invalid size")
+}
+
+func TestParsePassiveReplies(t *testing.T) {
+	port, err := parseEPSV("Entering Extended Passive Mode (|||6446|)")
+	require.NoError(t, err)
+	assert.Equal(t, 6446, port)
+	_, err = parseEPSV("Entering Extended Passive Mode")
+	assert.Error(t, err)
+
+	port, err = parsePASV("Entering Passive Mode (192,168,1,2,25,46)")
+	require.NoError(t, err)
+	assert.Equal(t, 25*256+46, port)
+	_, err = parsePASV("Entering Passive Mode")
+	assert.Error(t, err)
+}
+
+func TestDialFTPS(t *testing.T) {
+	addr, ca := newTestFTPSServer(t, false, true)
+	_, otherCA := newTestCertificate(t)
+	for _, tc := range []struct {
+		name    string
+		cfg     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "trusted certificate",
+			cfg:  map[string]interface{}{"ssl.certificate_authorities": []string{ca}, "auth.password": testPassword},
+		},
+		{
+			name:    "untrusted certificate",
+			cfg:     map[string]interface{}{"ssl.certificate_authorities": []string{otherCA}, "auth.password": testPassword},
+			wantErr: "certificate",
+		},
+		{
+			name:    "invalid password",
+			cfg:     map[string]interface{}{"ssl.verification_mode": "none", "auth.password": "invalid"},
+			wantErr: "PASS command failed: 530",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := conf.MustNewConfigFrom(map[string]interface{}{
+				"protocol":    protocolFTPS,
+				"host":        addr,
+				"username":    testUser,
+				"directories": []map[string]interface{}{{"path": "/"}},
+			})
+			require.NoError(t, c.Merge(tc.cfg))
+			_, in, err := configure(c)
+			require.NoError(t, err)
+			input := in.(*sftpInput)
+			client, err := input.dial(context.Background())
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			defer client.Close()
+			info, err := client.stat(t.TempDir())
+			require.NoError(t, err)
+			assert.True(t, info.IsDir())
+		})
+	}
+}
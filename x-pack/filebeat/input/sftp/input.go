@@ -0,0 +1,171 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+
+	v2 "github.com/elastic/beats/v7/filebeat/input/v2"
+	cursor "github.com/elastic/beats/v7/filebeat/input/v2/input-cursor"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+	"github.com/elastic/go-concert/ctxtool"
+)
+
+type sftpInput struct {
+	config    config
+	sshConfig *ssh.ClientConfig
+	tlsConfig *tlscommon.TLSConfig
+}
+
+const (
+	inputName = "sftp"
+)
+
+func Plugin(log *logp.Logger, store cursor.StateStore) v2.Plugin {
+	return v2.Plugin{
+		Name:       inputName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "SFTP",
+		Doc:        "Collect logs from files on SFTP and FTPS servers",
+		Manager: &cursor.InputManager{
+			Logger:     log,
+			StateStore: store,
+			Type:       inputName,
+			Configure:  configure,
+		},
+	}
+}
+
+func configure(cfg *conf.C) ([]cursor.Source, cursor.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, nil, err
+	}
+	input := &sftpInput{config: config}
+	var err error
+	switch config.Protocol {
+	case protocolSFTP:
+		input.sshConfig, err = newSSHConfig(&config)
+	case protocolFTPS:
+		input.tlsConfig, err = tlscommon.LoadTLSConfig(config.TLS)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	sources := make([]cursor.Source, 0, len(config.Directories))
+	for _, d := range config.Directories {
+		dir := tryOverrideOrDefault(config, d)
+		sources = append(sources, &Source{
+			Protocol:     config.Protocol,
+			Host:         config.Host,
+			Username:     config.Username,
+			Path:         dir.Path,
+			MaxWorkers:   *dir.MaxWorkers,
+			Poll:         *dir.Poll,
+			PollInterval: *dir.PollInterval,
+			Recursive:    *dir.Recursive,
+			Include:      dir.Include,
+			Exclude:      dir.Exclude,
+		})
+	}
+	return sources, input, nil
+}
+
+// tryOverrideOrDefault, overrides the directory level values with global values if the directory fields are not set
+func tryOverrideOrDefault(cfg config, d directory) directory {
+	if d.MaxWorkers == nil {
+		d.MaxWorkers = &cfg.MaxWorkers
+	}
+	if d.Poll == nil {
+		d.Poll = &cfg.Poll
+	}
+	if d.PollInterval == nil {
+		d.PollInterval = &cfg.PollInterval
+	}
+	if d.Recursive == nil {
+		d.Recursive = &cfg.Recursive
+	}
+	if len(d.Include) == 0 {
+		d.Include = cfg.Include
+	}
+	if len(d.Exclude) == 0 {
+		d.Exclude = cfg.Exclude
+	}
+	return d
+}
+
+func (input *sftpInput) Name() string {
+	return inputName
+}
+
+// dial connects to the server with the configured protocol.
+func (input *sftpInput) dial(ctx context.Context) (client, error) {
+	if input.config.Protocol == protocolFTPS {
+		host, _, err := net.SplitHostPort(address(input.config.Host, "21"))
+		if err != nil {
+			return nil, err
+		}
+		c, err := dialFTPS(ctx, &input.config, input.tlsConfig.BuildModuleClientConfig(host))
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	c, err := dialSFTP(ctx, &input.config, input.sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (input *sftpInput) Test(src cursor.Source, ctx v2.TestContext) error {
+	c, err := input.dial(ctxtool.FromCanceller(ctx.Cancelation))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	dir := src.(*Source).Path
+	info, err := c.stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
+
+func (input *sftpInput) Run(inputCtx v2.Context, src cursor.Source, cursor cursor.Cursor, publisher cursor.Publisher) error {
+	currentSource := src.(*Source)
+
+	log := inputCtx.Logger.With("host", currentSource.Host).With("directory", currentSource.Path)
+	log.Infof("Running %s input for directory: %s", currentSource.Protocol, currentSource.Path)
+	// create a new inputMetrics instance
+	metrics := newInputMetrics(inputCtx.ID+":"+currentSource.Path, nil)
+	metrics.url.Set(fileURL(currentSource, currentSource.Path))
+	defer metrics.Close()
+
+	// The map must be allocated, the state store can not unpack into a nil
+	// map of structs.
+	cp := &Checkpoint{Files: make(map[string]fileState)}
+	if !cursor.IsNew() {
+		if err := cursor.Unpack(cp); err != nil {
+			metrics.errorsTotal.Inc()
+			return err
+		}
+	}
+	st := newState(cp)
+
+	scheduler := newScheduler(publisher, input.dial, currentSource, &input.config, st, metrics, log)
+	return scheduler.schedule(ctxtool.FromCanceller(inputCtx.Cancelation))
+}
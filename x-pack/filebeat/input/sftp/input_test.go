@@ -0,0 +1,449 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	cursor "github.com/elastic/beats/v7/filebeat/input/v2/input-cursor"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/transform/typeconv"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	testUser     = "beats"
+	testPassword = "secret"
+)
+
+// newTestServer runs an SSH server with the sftp subsystem serving the local
+// file system, and returns its address and host key fingerprint.
+func newTestServer(t *testing.T) (addr, fingerprint string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == testUser && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("invalid credentials")
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, cfg)
+		}
+	}()
+	return l.Addr().String(), ssh.FingerprintSHA256(signer.PublicKey())
+}
+
+func serveSFTP(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "unknown channel type") //nolint:errcheck // Test server.
+			continue
+		}
+		ch, reqs, err := newCh.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range reqs {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil) //nolint:errcheck // Test server.
+				if ok {
+					server, err := sftp.NewServer(ch)
+					if err != nil {
+						return
+					}
+					go func() {
+						server.Serve() //nolint:errcheck // Test server.
+						server.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// testServers are the servers the input is tested with.
+var testServers = []string{"sftp", "ftps", "ftps implicit without mlsd"}
+
+// newTestServerConfig runs a test server and returns the settings of the
+// input connecting to it, merged with settings.
+func newTestServerConfig(t *testing.T, server string, settings map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	var cfg map[string]interface{}
+	switch server {
+	case "sftp":
+		addr, fingerprint := newTestServer(t)
+		cfg = map[string]interface{}{
+			"host":                  addr,
+			"host_key.fingerprints": []string{fingerprint},
+		}
+	case "ftps", "ftps implicit without mlsd":
+		implicit := server != "ftps"
+		addr, ca := newTestFTPSServer(t, implicit, !implicit)
+		mode := ftpsModeExplicit
+		if implicit {
+			mode = ftpsModeImplicit
+		}
+		cfg = map[string]interface{}{
+			"protocol":                    protocolFTPS,
+			"host":                        addr,
+			"ssl.certificate_authorities": []string{ca},
+			"ftps.mode":                   mode,
+		}
+	default:
+		t.Fatalf("unknown test server %s", server)
+	}
+	cfg["username"] = testUser
+	cfg["auth.password"] = testPassword
+	for k, v := range settings {
+		cfg[k] = v
+	}
+	return cfg
+}
+
+// testPublisher records the published events and checkpoints, the events
+// are acknowledged once published.
+type testPublisher struct {
+	mu          sync.Mutex
+	events      []beat.Event
+	checkpoints []Checkpoint
+}
+
+func (p *testPublisher) Publish(evt beat.Event, update interface{}) error {
+	var onACK func()
+	if u, ok := update.(cursor.UpdateWithACK); ok {
+		update, onACK = u.Update, u.OnACK
+	}
+	p.mu.Lock()
+	p.events = append(p.events, evt)
+	if update != nil {
+		p.checkpoints = append(p.checkpoints, update.(Checkpoint))
+	}
+	p.mu.Unlock()
+	if onACK != nil {
+		onACK()
+	}
+	return nil
+}
+
+func (p *testPublisher) messages() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var msgs []string
+	for _, e := range p.events {
+		msgs = append(msgs, e.Fields["message"].(string))
+	}
+	return msgs
+}
+
+func writeFile(t *testing.T, path string, data []byte, mtime time.Time) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func gzipData(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// runInput runs the input for a directory once, with the given checkpoint.
+func runInput(t *testing.T, cfg map[string]interface{}, cp *Checkpoint) (*testPublisher, *state) {
+	t.Helper()
+	sources, in, err := configure(conf.MustNewConfigFrom(cfg))
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+	input := in.(*sftpInput)
+	src := sources[0].(*Source)
+
+	pub := &testPublisher{}
+	st := newState(cp)
+	s := newScheduler(pub, input.dial, src, &input.config, st, nil, logp.NewLogger(inputName))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(t, s.schedule(ctx))
+	require.NoError(t, ctx.Err())
+	return pub, st
+}
+
+func TestInput(t *testing.T) {
+	for _, server := range testServers {
+		t.Run(server, func(t *testing.T) {
+			testInput(t, server)
+		})
+	}
+}
+
+func testInput(t *testing.T, server string) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "logs")
+	archive := filepath.Join(root, "archive")
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFile(t, filepath.Join(dir, "b.log"), []byte("one\ntwo\n\nthree"), base)
+	writeFile(t, filepath.Join(dir, "a.log.gz"), gzipData(t, "four\nfive\n"), base.Add(time.Second))
+	writeFile(t, filepath.Join(dir, "sub", "c.log"), []byte("six\n"), base.Add(2*time.Second))
+	writeFile(t, filepath.Join(dir, "ignored.txt"), []byte("ignored\n"), base)
+	writeFile(t, filepath.Join(dir, "skip.log"), []byte("skipped\n"), base)
+
+	cfg := newTestServerConfig(t, server, map[string]interface{}{
+		"poll":               false,
+		"recursive":          true,
+		"include":            []string{"*.log", "*.log.gz"},
+		"exclude":            []string{"skip.*"},
+		"after_read.action":  "move",
+		"after_read.move_to": archive,
+		"directories":        []map[string]interface{}{{"path": dir}},
+	})
+	pub, st := runInput(t, cfg, nil)
+
+	assert.Equal(t, []string{"one", "two", "three", "four", "five", "six"}, pub.messages())
+
+	evt := pub.events[2]
+	offset, _ := evt.GetValue("log.offset")
+	assert.Equal(t, int64(9), offset)
+	path, _ := evt.GetValue("sftp.file.path")
+	assert.Equal(t, filepath.Join(dir, "b.log"), path)
+	fileURL, _ := evt.GetValue("log.file.path")
+	scheme := protocolSFTP
+	if p, ok := cfg["protocol"]; ok {
+		scheme = p.(string)
+	}
+	assert.Equal(t, scheme+"://"+cfg["host"].(string)+filepath.Join(dir, "b.log"), fileURL)
+	assert.NotEmpty(t, evt.Meta["_id"])
+
+	// The final event of each file is checkpointed.
+	require.Len(t, pub.checkpoints, 3)
+	assert.Equal(t, fileState{Size: 14, ModTime: base.UTC(), Offset: 14, Done: true}, normalize(pub.checkpoints[0].Files[filepath.Join(dir, "b.log")]))
+	assert.Equal(t, int64(10), pub.checkpoints[1].Files[filepath.Join(dir, "a.log.gz")].Offset)
+
+	// The acknowledged files are moved and their state removed.
+	assert.FileExists(t, filepath.Join(archive, "b.log"))
+	assert.FileExists(t, filepath.Join(archive, "a.log.gz"))
+	assert.FileExists(t, filepath.Join(archive, "sub", "c.log"))
+	assert.NoFileExists(t, filepath.Join(dir, "b.log"))
+	assert.FileExists(t, filepath.Join(dir, "skip.log"))
+	assert.Equal(t, 0, st.len())
+}
+
+func TestInputResume(t *testing.T) {
+	for _, server := range testServers {
+		t.Run(server, func(t *testing.T) {
+			testInputResume(t, server)
+		})
+	}
+}
+
+func testInputResume(t *testing.T, server string) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFile(t, filepath.Join(dir, "done.log"), []byte("old\n"), base)
+	writeFile(t, filepath.Join(dir, "partial.log"), []byte("read\nunread\n"), base)
+	writeFile(t, filepath.Join(dir, "grown.log"), []byte("read\nappended\n"), base.Add(time.Minute))
+	writeFile(t, filepath.Join(dir, "replaced.log"), []byte("new\n"), base.Add(time.Minute))
+
+	cp := &Checkpoint{Files: map[string]fileState{
+		filepath.Join(dir, "done.log"):     {Size: 4, ModTime: base, Offset: 4, Done: true},
+		filepath.Join(dir, "partial.log"):  {Size: 12, ModTime: base, Offset: 5},
+		filepath.Join(dir, "grown.log"):    {Size: 5, ModTime: base, Offset: 5, Done: true},
+		filepath.Join(dir, "replaced.log"): {Size: 20, ModTime: base, Offset: 20, Done: true},
+		filepath.Join(dir, "deleted.log"):  {Size: 20, ModTime: base, Offset: 20, Done: true},
+	}}
+	cfg := newTestServerConfig(t, server, map[string]interface{}{
+		"poll":        false,
+		"directories": []map[string]interface{}{{"path": dir}},
+	})
+	pub, st := runInput(t, cfg, cp)
+
+	assert.ElementsMatch(t, []string{"unread", "appended", "new"}, pub.messages())
+	// The state of deleted files is pruned.
+	_, ok := st.get(filepath.Join(dir, "deleted.log"))
+	assert.False(t, ok)
+	grown, _ := st.get(filepath.Join(dir, "grown.log"))
+	assert.Equal(t, int64(14), grown.Offset)
+	assert.True(t, grown.Done)
+}
+
+func TestInputDelete(t *testing.T) {
+	for _, server := range testServers {
+		t.Run(server, func(t *testing.T) {
+			testInputDelete(t, server)
+		})
+	}
+}
+
+func testInputDelete(t *testing.T, server string) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.log"), []byte("one\n"), time.Now())
+	writeFile(t, filepath.Join(dir, "empty.log"), nil, time.Now())
+
+	cfg := newTestServerConfig(t, server, map[string]interface{}{
+		"poll":              false,
+		"after_read.action": "delete",
+		"directories":       []map[string]interface{}{{"path": dir}},
+	})
+	pub, _ := runInput(t, cfg, nil)
+	assert.Equal(t, []string{"one"}, pub.messages())
+	assert.NoFileExists(t, filepath.Join(dir, "a.log"))
+	assert.NoFileExists(t, filepath.Join(dir, "empty.log"))
+}
+
+func TestDialHostKey(t *testing.T) {
+	addr, fingerprint := newTestServer(t)
+	for _, tc := range []struct {
+		name    string
+		cfg     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "accepted fingerprint",
+			cfg:  map[string]interface{}{"host_key.fingerprints": []string{fingerprint}, "auth.password": testPassword},
+		},
+		{
+			name:    "unknown fingerprint",
+			cfg:     map[string]interface{}{"host_key.fingerprints": []string{"SHA256:unknown"}, "auth.password": testPassword},
+			wantErr: "host key fingerprint " + fingerprint + " is not accepted",
+		},
+		{
+			name:    "invalid password",
+			cfg:     map[string]interface{}{"host_key.insecure": true, "auth.password": "invalid"},
+			wantErr: "unable to authenticate",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := conf.MustNewConfigFrom(map[string]interface{}{
+				"host":        addr,
+				"username":    testUser,
+				"directories": []map[string]interface{}{{"path": "/"}},
+			})
+			require.NoError(t, c.Merge(tc.cfg))
+			_, in, err := configure(c)
+			require.NoError(t, err)
+			input := in.(*sftpInput)
+			client, err := input.dial(context.Background())
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			client.Close()
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	base := func() map[string]interface{} {
+		return map[string]interface{}{
+			"host":              "localhost",
+			"username":          testUser,
+			"auth.password":     testPassword,
+			"host_key.insecure": true,
+			"directories":       []map[string]interface{}{{"path": "/upload"}},
+		}
+	}
+	for _, tc := range []struct {
+		name       string
+		cfg        map[string]interface{}
+		wantSource string
+		wantErr    string
+	}{
+		{name: "valid"},
+		{name: "valid ftps", cfg: map[string]interface{}{"protocol": "ftps", "host_key.insecure": false, "ssl.verification_mode": "none"}, wantSource: "ftps::beats@localhost::/upload"},
+		{name: "directory override", cfg: map[string]interface{}{"directories": []map[string]interface{}{{"path": "/upload", "poll": false, "max_workers": 3, "include": []string{"*.csv"}}}}},
+		{name: "no auth", cfg: map[string]interface{}{"auth.password": ""}, wantErr: "at least one of auth.password or auth.private_key must be set"},
+		{name: "several host key methods", cfg: map[string]interface{}{"host_key.fingerprints": []string{"SHA256:x"}}, wantErr: "exactly one of host_key.known_hosts"},
+		{name: "relative directory", cfg: map[string]interface{}{"directories": []map[string]interface{}{{"path": "upload"}}}, wantErr: "directory path must be absolute"},
+		{name: "invalid pattern", cfg: map[string]interface{}{"include": []string{"[a"}}, wantErr: "invalid glob pattern"},
+		{name: "move without target", cfg: map[string]interface{}{"after_read.action": "move"}, wantErr: "after_read.move_to must be an absolute path"},
+		{name: "move to directory", cfg: map[string]interface{}{"after_read.action": "move", "after_read.move_to": "/upload"}, wantErr: "after_read.move_to can not be the directory"},
+		{name: "invalid action", cfg: map[string]interface{}{"after_read.action": "archive"}, wantErr: "invalid after_read.action"},
+		{name: "invalid encoding", cfg: map[string]interface{}{"encoding": "unknown"}, wantErr: "encoding type <unknown> not found"},
+		{name: "invalid protocol", cfg: map[string]interface{}{"protocol": "ftp"}, wantErr: "invalid protocol"},
+		{name: "ssl with sftp", cfg: map[string]interface{}{"ssl.verification_mode": "none"}, wantErr: "ssl can only be set with the ftps protocol"},
+		{name: "ftps host key", cfg: map[string]interface{}{"protocol": "ftps"}, wantErr: "host_key can only be set with the sftp protocol"},
+		{name: "ftps private key", cfg: map[string]interface{}{"protocol": "ftps", "host_key.insecure": false, "auth.private_key": "/id_ed25519"}, wantErr: "auth.private_key is not supported with the ftps protocol"},
+		{name: "ftps without ssl", cfg: map[string]interface{}{"protocol": "ftps", "host_key.insecure": false, "ssl.enabled": false}, wantErr: "ssl can not be disabled with the ftps protocol"},
+		{name: "invalid ftps mode", cfg: map[string]interface{}{"protocol": "ftps", "host_key.insecure": false, "ftps.mode": "auto"}, wantErr: "invalid ftps.mode"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := conf.MustNewConfigFrom(base())
+			require.NoError(t, c.Merge(tc.cfg))
+			sources, _, err := configure(c)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, sources, 1)
+			want := tc.wantSource
+			if want == "" {
+				want = "sftp::beats@localhost::/upload"
+			}
+			assert.Equal(t, want, sources[0].Name())
+		})
+	}
+}
+
+func TestCheckpointConversion(t *testing.T) {
+	// The checkpoint must survive the conversions of the cursor store.
+	cp := Checkpoint{Files: map[string]fileState{
+		"/upload/a.log": {Size: 10, ModTime: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Offset: 5},
+	}}
+	var stored interface{}
+	require.NoError(t, typeconv.Convert(&stored, cp))
+	got := &Checkpoint{Files: make(map[string]fileState)}
+	require.NoError(t, typeconv.Convert(got, stored))
+	assert.Equal(t, cp.Files["/upload/a.log"], normalize(got.Files["/upload/a.log"]))
+}
+
+// normalize removes the location of the modification time for comparisons.
+func normalize(st fileState) fileState {
+	st.ModTime = st.ModTime.UTC()
+	return st
+}
@@ -0,0 +1,315 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	cursor "github.com/elastic/beats/v7/filebeat/input/v2/input-cursor"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/beats/v7/libbeat/reader/readfile"
+	"github.com/elastic/beats/v7/libbeat/reader/readfile/encoding"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	// checkpointInterval is the number of events after which the offset of
+	// a file being read is checkpointed. Checkpoints contain the state of all
+	// the files of the directory, the final event of a file is always
+	// checkpointed.
+	checkpointInterval = 1000
+	// readBufferSize is the size of the reads from the server, large reads
+	// are split in concurrent requests.
+	readBufferSize = 256 * 1024
+)
+
+// remoteFile is a file listed in a directory.
+type remoteFile struct {
+	// path is the absolute path of the file.
+	path string
+	// rel is the path of the file relative to the directory.
+	rel     string
+	size    int64
+	modTime time.Time
+}
+
+type job struct {
+	// connection used to read the file
+	client client
+	// file to read
+	file remoteFile
+	// sftp or ftps url of the file
+	fileURL string
+	// file hash, used in setting event id
+	hash string
+	// offset the file is read from
+	offset int64
+	// number of events published
+	count int
+	// directory state
+	state *state
+	// directory source struct used for storing directory related data
+	src *Source
+	// reader configuration
+	readerConfig *readerConfig
+	// publisher is used to publish a beat event to the output stream
+	publisher cursor.Publisher
+	// onACK is called once all the events of the file were acknowledged,
+	// nil when nothing is done with files after they are read.
+	onACK func()
+	// metrics used to track the errors and success of jobs
+	metrics *inputMetrics
+	// custom logger
+	log *logp.Logger
+}
+
+// newJob, returns an instance of a job, which is a unit of work that can be assigned to a go routine
+func newJob(client client, file remoteFile, offset int64, state *state, src *Source, readerConfig *readerConfig,
+	publisher cursor.Publisher, onACK func(), metrics *inputMetrics, log *logp.Logger,
+) *job {
+	return &job{
+		client:       client,
+		file:         file,
+		fileURL:      fileURL(src, file.path),
+		hash:         fileHash(src, file),
+		offset:       offset,
+		state:        state,
+		src:          src,
+		readerConfig: readerConfig,
+		publisher:    publisher,
+		onACK:        onACK,
+		metrics:      metrics,
+		log:          log.With("file", file.path),
+	}
+}
+
+// fileHash returns a short sha256 hash of the host, file path and
+// modification time, a file rewritten with new content gets new event ids.
+func fileHash(src *Source, file remoteFile) string {
+	h := sha256.New()
+	h.Write([]byte(src.Host))
+	h.Write([]byte(file.path))
+	var mtime [8]byte
+	binary.BigEndian.PutUint64(mtime[:], uint64(file.modTime.UnixNano()))
+	h.Write(mtime[:])
+	return hex.EncodeToString(h.Sum(nil)[:5])
+}
+
+func (j *job) do(ctx context.Context) error {
+	j.log.Debugw("begin sftp file processing.", "offset", j.offset)
+	j.metrics.sftpFilesRequestedTotal.Inc()
+	j.metrics.sftpFilesInflight.Inc()
+	start := time.Now()
+	defer func() {
+		j.metrics.sftpFilesInflight.Dec()
+		j.log.Debugw("end sftp file processing.", "elapsed_time_ns", time.Since(start))
+	}()
+
+	r, err := j.open()
+	if err != nil {
+		return fmt.Errorf("failed to read file: %s, with error: %w", j.file.path, err)
+	}
+	defer r.Close()
+	return j.readAndPublish(ctx, r)
+}
+
+// open returns a reader of the file content from the job offset, gzip
+// compressed files are decompressed.
+func (j *job) open() (io.ReadCloser, error) {
+	f, err := j.client.open(j.file.path, 0)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(f, readBufferSize)
+	magic, err := br.Peek(3)
+	if err != nil && !errors.Is(err, io.EOF) {
+		f.Close()
+		return nil, err
+	}
+	// gzip magic number (1f 8b) and the compression method (08 for DEFLATE).
+	if bytes.Equal(magic, []byte{0x1F, 0x8B, 0x08}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		// The offset of compressed files is in the decompressed content.
+		if _, err := io.CopyN(io.Discard, gz, j.offset); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("skipping to offset %d: %w", j.offset, err)
+		}
+		return readCloser{Reader: gz, Closer: f}, nil
+	}
+	if j.offset == 0 {
+		return readCloser{Reader: br, Closer: f}, nil
+	}
+	// The file is opened again at the offset, so that the content before
+	// it is not transferred.
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	f, err = j.client.open(j.file.path, j.offset)
+	if err != nil {
+		return nil, err
+	}
+	return readCloser{Reader: bufio.NewReaderSize(f, readBufferSize), Closer: f}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// readAndPublish reads the messages of r with the configured encoding and
+// parsers, and publishes them. The publication of each event is deferred
+// until the next message is read, so that the final event of the file is
+// known.
+func (j *job) readAndPublish(ctx context.Context, r io.Reader) error {
+	encodingFactory, ok := encoding.FindEncoding(j.readerConfig.Encoding)
+	if !ok || encodingFactory == nil {
+		return fmt.Errorf("failed to find '%v' encoding", j.readerConfig.Encoding)
+	}
+	enc, err := encodingFactory(r)
+	if err != nil {
+		return fmt.Errorf("failed to initialize encoding: %w", err)
+	}
+
+	var msgReader reader.Reader
+	msgReader, err = readfile.NewEncodeReader(io.NopCloser(r), readfile.Config{
+		Codec:        enc,
+		BufferSize:   int(j.readerConfig.BufferSize),
+		Terminator:   j.readerConfig.LineTerminator,
+		CollectOnEOF: true,
+		MaxBytes:     int(j.readerConfig.MaxBytes) * 4,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create encode reader: %w", err)
+	}
+	msgReader = readfile.NewStripNewline(msgReader, j.readerConfig.LineTerminator)
+	msgReader = j.readerConfig.Parsers.Create(msgReader)
+	msgReader = readfile.NewLimitReader(msgReader, int(j.readerConfig.MaxBytes))
+	defer msgReader.Close()
+
+	offset := j.offset
+	var pending *beat.Event
+	for ctx.Err() == nil {
+		message, err := msgReader.Next()
+		if len(message.Content) > 0 {
+			if pending != nil {
+				if err := j.publish(*pending, offset, false); err != nil {
+					return err
+				}
+			}
+			evt := j.createEvent(message, offset)
+			pending = &evt
+		}
+		offset += int64(message.Bytes)
+		j.metrics.sftpBytesProcessedTotal.Add(uint64(message.Bytes))
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if pending != nil {
+				if err := j.publish(*pending, offset, false); err != nil {
+					return err
+				}
+			}
+			return fmt.Errorf("error reading message: %w", err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	j.metrics.sftpFilesPublishedTotal.Inc()
+	if pending == nil {
+		// Without events there is nothing to acknowledge, the state is
+		// persisted with the next checkpoint.
+		j.state.set(j.file.path, j.fileState(offset, true))
+		if j.onACK != nil {
+			j.onACK()
+		}
+		return nil
+	}
+	return j.publish(*pending, offset, true)
+}
+
+// publish publishes an event, end is the offset of the file content up to
+// which events were published with it. The state is checkpointed with the
+// final event of the file and every checkpointInterval events.
+func (j *job) publish(evt beat.Event, end int64, last bool) error {
+	j.count++
+	st := j.fileState(end, last)
+	if !last && j.count%checkpointInterval != 0 {
+		err := j.publisher.Publish(evt, nil)
+		// The state is updated once the event is published, so that it
+		// is only part of the checkpoints published after the event.
+		j.state.set(j.file.path, st)
+		return err
+	}
+
+	// locks while data is being saved and published to publish the checkpoints in order
+	cp, done := j.state.saveForTx(j.file.path, st)
+	defer done()
+	var update interface{} = cp
+	if last && j.onACK != nil {
+		update = cursor.UpdateWithACK{Update: cp, OnACK: j.onACK}
+	}
+	return j.publisher.Publish(evt, update)
+}
+
+func (j *job) fileState(offset int64, done bool) fileState {
+	return fileState{
+		Size:    j.file.size,
+		ModTime: j.file.modTime,
+		Offset:  offset,
+		Done:    done,
+	}
+}
+
+func (j *job) createEvent(message reader.Message, offset int64) beat.Event {
+	event := beat.Event{
+		Timestamp: time.Now(),
+		Fields: mapstr.M{
+			"message": string(message.Content),
+			"log": mapstr.M{
+				"offset": offset,
+				"file": mapstr.M{
+					"path": j.fileURL,
+				},
+			},
+			"sftp": mapstr.M{
+				"host":      j.src.Host,
+				"directory": j.src.Path,
+				"file": mapstr.M{
+					"path":  j.file.path,
+					"size":  j.file.size,
+					"mtime": j.file.modTime,
+				},
+			},
+		},
+	}
+	event.Fields.DeepUpdate(message.Fields)
+	event.SetID(fileID(j.hash, offset))
+	j.metrics.sftpEventsCreatedTotal.Inc()
+	return event
+}
+
+func fileID(fileHash string, offset int64) string {
+	return fmt.Sprintf("%s-%012d", fileHash, offset)
+}
@@ -0,0 +1,50 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"github.com/elastic/beats/v7/libbeat/monitoring/inputmon"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// inputMetrics handles the input's metric reporting.
+type inputMetrics struct {
+	unregister  func()
+	url         *monitoring.String // URL of the input resource.
+	errorsTotal *monitoring.Uint   // Number of errors encountered.
+
+	sftpFilesTracked           *monitoring.Uint // Number of files currently tracked in the state registry (gauge).
+	sftpFilesListedTotal       *monitoring.Uint // Number of files returned by list operations.
+	sftpFilesRequestedTotal    *monitoring.Uint // Number of files opened.
+	sftpFilesPublishedTotal    *monitoring.Uint // Number of files whose events were all published.
+	sftpFilesAfterReadTotal    *monitoring.Uint // Number of files deleted or moved once acknowledged.
+	sftpBytesProcessedTotal    *monitoring.Uint // Number of bytes of file content processed.
+	sftpEventsCreatedTotal     *monitoring.Uint // Number of events created from processing files.
+	sftpFilesInflight          *monitoring.Uint // Number of files being read (gauge).
+	sftpConnectionsOpenedTotal *monitoring.Uint // Number of SFTP connections established.
+}
+
+func newInputMetrics(id string, optionalParent *monitoring.Registry) *inputMetrics {
+	reg, unreg := inputmon.NewInputRegistry(inputName, id, optionalParent)
+	return &inputMetrics{
+		unregister:  unreg,
+		url:         monitoring.NewString(reg, "url"),
+		errorsTotal: monitoring.NewUint(reg, "errors_total"),
+
+		sftpFilesTracked:           monitoring.NewUint(reg, "sftp_files_tracked_gauge"),
+		sftpFilesListedTotal:       monitoring.NewUint(reg, "sftp_files_listed_total"),
+		sftpFilesRequestedTotal:    monitoring.NewUint(reg, "sftp_files_requested_total"),
+		sftpFilesPublishedTotal:    monitoring.NewUint(reg, "sftp_files_published_total"),
+		sftpFilesAfterReadTotal:    monitoring.NewUint(reg, "sftp_files_after_read_total"),
+		sftpBytesProcessedTotal:    monitoring.NewUint(reg, "sftp_bytes_processed_total"),
+		sftpEventsCreatedTotal:     monitoring.NewUint(reg, "sftp_events_created_total"),
+		sftpFilesInflight:          monitoring.NewUint(reg, "sftp_files_inflight_gauge"),
+		sftpConnectionsOpenedTotal: monitoring.NewUint(reg, "sftp_connections_opened_total"),
+	}
+}
+
+func (m *inputMetrics) Close() {
+	m.unregister()
+}
@@ -0,0 +1,428 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"context"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cursor "github.com/elastic/beats/v7/filebeat/input/v2/input-cursor"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// limiter, is used to limit the number of goroutines from blowing up the stack
+type limiter struct {
+	wg sync.WaitGroup
+	// limit specifies the maximum number
+	// of concurrent jobs to perform.
+	limit chan struct{}
+}
+
+// acquire gets an available worker thread.
+func (l *limiter) acquire() {
+	l.wg.Add(1)
+	l.limit <- struct{}{}
+}
+
+func (l *limiter) wait() {
+	l.wg.Wait()
+}
+
+// release puts back a worker thread.
+func (l *limiter) release() {
+	<-l.limit
+	l.wg.Done()
+}
+
+type scheduler struct {
+	publisher cursor.Publisher
+	src       *Source
+	cfg       *config
+	state     *state
+	log       *logp.Logger
+	limiter   *limiter
+	metrics   *inputMetrics
+	afterRead *afterReadQueue
+
+	// dial connects to the server, the connection is kept between
+	// listings until an error occurs.
+	dial   func(context.Context) (client, error)
+	client client
+}
+
+// newScheduler, returns a new scheduler instance
+func newScheduler(publisher cursor.Publisher, dial func(context.Context) (client, error), src *Source, cfg *config,
+	state *state, metrics *inputMetrics, log *logp.Logger,
+) *scheduler {
+	if metrics == nil {
+		// metrics are optional, initialize a stub if not provided
+		metrics = newInputMetrics("", nil)
+	}
+	return &scheduler{
+		publisher: publisher,
+		src:       src,
+		cfg:       cfg,
+		state:     state,
+		log:       log,
+		limiter:   &limiter{limit: make(chan struct{}, src.MaxWorkers)},
+		metrics:   metrics,
+		afterRead: newAfterReadQueue(),
+		dial:      dial,
+	}
+}
+
+// schedule lists the directory and reads the new and updated files, every
+// poll interval when polling. Errors are logged and the directory listed
+// again at the next interval when polling.
+func (s *scheduler) schedule(ctx context.Context) error {
+	defer s.closeClient()
+
+	for {
+		err := s.scheduleOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !s.src.Poll {
+			// Wait for the events to be acknowledged to delete or move
+			// the files.
+			for !s.afterRead.idle() {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-s.afterRead.ready:
+					s.runAfterRead(ctx)
+				}
+			}
+			return err
+		}
+		if err != nil {
+			s.metrics.errorsTotal.Inc()
+			s.log.Errorw("scheduler: failed to list directory, retrying at next poll interval", "error", err)
+		}
+
+		timer := time.NewTimer(s.src.PollInterval)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-s.afterRead.ready:
+				s.runAfterRead(ctx)
+			case <-timer.C:
+				break wait
+			}
+		}
+	}
+}
+
+func (s *scheduler) scheduleOnce(ctx context.Context) error {
+	c, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	// The files acknowledged since the last listing are handled first, so
+	// that they are not listed again.
+	s.runAfterRead(ctx)
+
+	files, err := s.list(c)
+	if err != nil {
+		s.closeClient()
+		return err
+	}
+	s.metrics.sftpFilesListedTotal.Add(uint64(len(files)))
+	listed := make(map[string]bool, len(files))
+	for _, f := range files {
+		listed[f.path] = true
+	}
+	s.state.prune(listed)
+	s.metrics.sftpFilesTracked.Set(uint64(s.state.len()))
+
+	jobs := s.createJobs(c, files)
+	s.log.Debugf("scheduler: %d files listed, %d jobs scheduled", len(files), len(jobs))
+
+	// distributes jobs among workers with the help of a limiter
+	var failed atomic.Bool
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		job := job
+		s.limiter.acquire()
+		go func() {
+			defer s.limiter.release()
+			err := job.do(ctx)
+			if err != nil && job.onACK != nil {
+				s.afterRead.untrack(job.file)
+			}
+			if err != nil && ctx.Err() == nil {
+				failed.Store(true)
+				s.metrics.errorsTotal.Inc()
+				s.log.Errorw("job encountered an error, the file will be read again at next poll interval", "file", job.file.path, "error", err)
+			}
+		}()
+	}
+	s.limiter.wait()
+
+	if failed.Load() {
+		// Reconnect at the next listing in case the connection failed.
+		s.closeClient()
+	}
+	return nil
+}
+
+// connect returns the current connection, connecting when needed.
+func (s *scheduler) connect(ctx context.Context) (client, error) {
+	if s.client != nil {
+		return s.client, nil
+	}
+	c, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.sftpConnectionsOpenedTotal.Inc()
+	s.client = c
+	return c, nil
+}
+
+func (s *scheduler) closeClient() {
+	if s.client == nil {
+		return
+	}
+	if err := s.client.Close(); err != nil {
+		s.log.Debugw("scheduler: error closing connection", "error", err)
+	}
+	s.client = nil
+}
+
+// list returns the selected regular files of the directory, the oldest
+// first.
+func (s *scheduler) list(c client) ([]remoteFile, error) {
+	root := path.Clean(s.src.Path)
+	entries, err := c.readDir(root)
+	if err != nil {
+		return nil, err
+	}
+	files := s.walk(c, root, root, entries, nil)
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
+
+// walk appends the selected regular files of the entries of dir to files,
+// and of its sub directories when recursive.
+func (s *scheduler) walk(c client, root, dir string, entries []fs.FileInfo, files []remoteFile) []remoteFile {
+	for _, info := range entries {
+		p := path.Join(dir, info.Name())
+		if info.IsDir() {
+			if !s.src.Recursive || s.isMoveTarget(p) {
+				continue
+			}
+			sub, err := c.readDir(p)
+			if err != nil {
+				s.log.Warnw("scheduler: failed to list path", "path", p, "error", err)
+				continue
+			}
+			files = s.walk(c, root, p, sub, files)
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		rel := strings.TrimPrefix(p, strings.TrimSuffix(root, "/")+"/")
+		if !s.isFileSelected(rel) {
+			continue
+		}
+		files = append(files, remoteFile{
+			path:    p,
+			rel:     rel,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return files
+}
+
+// isMoveTarget returns whether dir is the directory files are moved to.
+func (s *scheduler) isMoveTarget(dir string) bool {
+	return s.cfg.AfterRead.Action == afterReadMove && dir == path.Clean(s.cfg.AfterRead.MoveTo)
+}
+
+// isFileSelected returns whether a file matches the include patterns and
+// none of the exclude patterns. Patterns containing a slash are matched
+// against the path relative to the directory, others against the file
+// name.
+func (s *scheduler) isFileSelected(rel string) bool {
+	if len(s.src.Include) != 0 && !matchAny(s.src.Include, rel) {
+		return false
+	}
+	return !matchAny(s.src.Exclude, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		name := rel
+		if !strings.Contains(p, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// createJobs returns the jobs of the new files and of the files that changed
+// or were not read completely. Files that grew are read from their previous
+// offset, files that were otherwise changed from the start.
+func (s *scheduler) createJobs(c client, files []remoteFile) []*job {
+	//nolint:prealloc // No need to preallocate the slice
+	var jobs []*job
+	for _, f := range files {
+		var offset int64
+		if st, ok := s.state.get(f.path); ok {
+			unchanged := st.Size == f.size && st.ModTime.Equal(f.modTime)
+			switch {
+			case unchanged && st.Done:
+				// Files that were read but not deleted or moved yet, e.g.
+				// because the input was restarted or the operation
+				// failed, are handled once acknowledged.
+				if s.cfg.AfterRead.Action != afterReadNone && !s.afterRead.isPending(f.path) {
+					s.afterRead.add(f)
+				}
+				continue
+			case unchanged || f.size > st.Size:
+				offset = st.Offset
+			}
+		}
+
+		var onACK func()
+		if s.cfg.AfterRead.Action != afterReadNone {
+			onACK = s.afterRead.track(f)
+		}
+		jobs = append(jobs, newJob(c, f, offset, s.state, s.src, &s.cfg.ReaderConfig, s.publisher, onACK, s.metrics, s.log))
+	}
+	return jobs
+}
+
+// runAfterRead deletes or moves the files whose events were all
+// acknowledged. Files that could not be handled are handled at the next
+// listing.
+func (s *scheduler) runAfterRead(ctx context.Context) {
+	files := s.afterRead.take()
+	if len(files) == 0 {
+		return
+	}
+	c, err := s.connect(ctx)
+	if err != nil {
+		s.metrics.errorsTotal.Inc()
+		s.log.Errorw("scheduler: failed to connect to handle read files", "error", err)
+		return
+	}
+	for _, f := range files {
+		if err := s.afterReadFile(c, f); err != nil {
+			s.metrics.errorsTotal.Inc()
+			s.log.Errorw("scheduler: failed to handle read file", "file", f.path, "action", s.cfg.AfterRead.Action, "error", err)
+			continue
+		}
+		s.state.remove(f.path)
+		s.metrics.sftpFilesAfterReadTotal.Inc()
+	}
+}
+
+func (s *scheduler) afterReadFile(c client, f remoteFile) error {
+	switch s.cfg.AfterRead.Action {
+	case afterReadDelete:
+		return c.remove(f.path)
+	case afterReadMove:
+		dst := path.Join(s.cfg.AfterRead.MoveTo, f.rel)
+		if err := c.mkdirAll(path.Dir(dst)); err != nil {
+			return err
+		}
+		return c.rename(f.path, dst)
+	}
+	return nil
+}
+
+// afterReadQueue keeps track of the files to delete or move once all their
+// events are acknowledged.
+type afterReadQueue struct {
+	mu      sync.Mutex
+	pending map[string]bool
+	files   []remoteFile
+	// ready is signaled when files are added.
+	ready chan struct{}
+}
+
+func newAfterReadQueue() *afterReadQueue {
+	return &afterReadQueue{
+		pending: make(map[string]bool),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// track marks a file as being read, and returns the function adding it to
+// the queue once its events are acknowledged.
+func (q *afterReadQueue) track(f remoteFile) func() {
+	q.mu.Lock()
+	q.pending[f.path] = true
+	q.mu.Unlock()
+	return func() { q.add(f) }
+}
+
+// untrack stops tracking a file that could not be read completely.
+func (q *afterReadQueue) untrack(f remoteFile) {
+	q.mu.Lock()
+	delete(q.pending, f.path)
+	q.mu.Unlock()
+}
+
+func (q *afterReadQueue) add(f remoteFile) {
+	q.mu.Lock()
+	delete(q.pending, f.path)
+	q.files = append(q.files, f)
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *afterReadQueue) take() []remoteFile {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	files := q.files
+	q.files = nil
+	return files
+}
+
+func (q *afterReadQueue) isPending(path string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending[path]
+}
+
+// idle returns whether no file is waiting to be acknowledged or handled.
+func (q *afterReadQueue) idle() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending) == 0 && len(q.files) == 0
+}
+
+// fileURL returns the sftp or ftps URL of a file.
+func fileURL(src *Source, p string) string {
+	return (&url.URL{Scheme: src.Protocol, Host: src.Host, Path: p}).String()
+}
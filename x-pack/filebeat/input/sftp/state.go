@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"sync"
+	"time"
+)
+
+// Checkpoint is the cursor state of a directory, the state of the files
+// that were read keyed by their path.
+type Checkpoint struct {
+	Files map[string]fileState `struct:"files"`
+}
+
+// fileState is the state of a file. Offset is the number of bytes of the
+// file content, once decompressed, whose events were published.
+type fileState struct {
+	Size    int64     `struct:"size"`
+	ModTime time.Time `struct:"mtime"`
+	Offset  int64     `struct:"offset"`
+	// Done is set once all the events of the file were published.
+	Done bool `struct:"done"`
+}
+
+// state contains the current state of the files of a directory.
+type state struct {
+	mu    sync.Mutex
+	files map[string]fileState
+}
+
+func newState(cp *Checkpoint) *state {
+	files := make(map[string]fileState)
+	if cp != nil {
+		for k, v := range cp.Files {
+			files[k] = v
+		}
+	}
+	return &state{files: files}
+}
+
+func (s *state) get(path string) (fileState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.files[path]
+	return st, ok
+}
+
+// set updates the state of a file without creating a checkpoint.
+func (s *state) set(path string, st fileState) {
+	s.mu.Lock()
+	s.files[path] = st
+	s.mu.Unlock()
+}
+
+// saveForTx updates the state of a file and returns a copy of the current
+// checkpoint, locks the state and returns an unlock function, done. The caller
+// must call done once the event with the checkpoint was published, so that
+// checkpoints are published in the order they are created.
+func (s *state) saveForTx(path string, st fileState) (cp Checkpoint, done func()) {
+	s.mu.Lock()
+	s.files[path] = st
+	cp = Checkpoint{Files: make(map[string]fileState, len(s.files))}
+	for k, v := range s.files {
+		cp.Files[k] = v
+	}
+	return cp, s.mu.Unlock
+}
+
+func (s *state) remove(path string) {
+	s.mu.Lock()
+	delete(s.files, path)
+	s.mu.Unlock()
+}
+
+// prune removes the state of the files that are not listed anymore. The
+// change is persisted with the next checkpoint.
+func (s *state) prune(listed map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.files {
+		if !listed[k] {
+			delete(s.files, k)
+		}
+	}
+}
+
+func (s *state) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
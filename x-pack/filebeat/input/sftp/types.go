@@ -0,0 +1,28 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package sftp
+
+import (
+	"time"
+)
+
+// Source, it is the cursor source
+type Source struct {
+	// Protocol is sftp or ftps.
+	Protocol     string
+	Host         string
+	Username     string
+	Path         string
+	MaxWorkers   int
+	Poll         bool
+	PollInterval time.Duration
+	Recursive    bool
+	Include      []string
+	Exclude      []string
+}
+
+func (s *Source) Name() string {
+	return s.Protocol + "::" + s.Username + "@" + s.Host + "::" + s.Path
+}
@@ -0,0 +1,320 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package query
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	"github.com/elastic/beats/v7/metricbeat/helper/sql"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// cursorPlaceholder is replaced in cursor queries by the driver specific
+// placeholder of the cursor value parameter.
+const cursorPlaceholder = ":cursor"
+
+const (
+	cursorTypeInteger   = "integer"
+	cursorTypeTimestamp = "timestamp"
+)
+
+const cursorStatePrefix = "sql::query::cursor::"
+
+var cursorPlaceholderRe = regexp.MustCompile(cursorPlaceholder + `\b`)
+
+// cursorConfig configures the incremental fetch of rows, using the value of
+// a column of the last row fetched as cursor.
+type cursorConfig struct {
+	Enabled bool `config:"enabled"`
+	// Column is the column holding the cursor value, it must increase
+	// monotonically.
+	Column string `config:"column"`
+	// Type is the type of the cursor column, integer or timestamp.
+	Type string `config:"type"`
+	// Default is the cursor value used before any row has been fetched.
+	Default string `config:"default"`
+}
+
+func (c *cursorConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Column == "" {
+		return errors.New("cursor.column must be set")
+	}
+	switch c.Type {
+	case cursorTypeInteger, cursorTypeTimestamp:
+	default:
+		return fmt.Errorf("invalid cursor.type %q, must be one of %s or %s", c.Type, cursorTypeInteger, cursorTypeTimestamp)
+	}
+	if c.Default == "" {
+		return errors.New("cursor.default must be set")
+	}
+	if _, err := parseCursorValue(c.Type, c.Default); err != nil {
+		return fmt.Errorf("invalid cursor.default: %w", err)
+	}
+	return nil
+}
+
+// cursorState is the state persisted for a cursor.
+type cursorState struct {
+	Value string `struct:"value"`
+}
+
+// cursor tracks the value used by the next cursor query and persists the
+// value of the last acknowledged row.
+type cursor struct {
+	log    *logp.Logger
+	key    string
+	column string
+	typ    string
+
+	mu sync.Mutex
+	// next is the value of the last row fetched.
+	next interface{}
+	// acked is the value of the last row acknowledged.
+	acked interface{}
+	// persisted is the value of the last row persisted.
+	persisted interface{}
+	store     *statestore.Store
+}
+
+var cursorRegistry struct {
+	once sync.Once
+	reg  *statestore.Registry
+	err  error
+}
+
+// openCursorStore returns a store for cursors, located in the data path.
+func openCursorStore(log *logp.Logger) (*statestore.Store, error) {
+	cursorRegistry.once.Do(func() {
+		var reg *memlog.Registry
+		reg, cursorRegistry.err = memlog.New(log, memlog.Settings{
+			Root: paths.Resolve(paths.Data, filepath.Join("state", "sql", "query")),
+		})
+		if cursorRegistry.err == nil {
+			cursorRegistry.reg = statestore.NewRegistry(reg)
+		}
+	})
+	if cursorRegistry.err != nil {
+		return nil, cursorRegistry.err
+	}
+	return cursorRegistry.reg.Get("cursor")
+}
+
+// cursorKey returns the key of the cursor of a query, so that each database
+// and query has its own cursor.
+func cursorKey(driver, uri, query, column string) string {
+	h := sha256.New()
+	for _, s := range []string{driver, uri, query, column} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return cursorStatePrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// newCursor returns a cursor starting at the persisted value, or at the
+// configured default when there is none.
+func newCursor(log *logp.Logger, store *statestore.Store, key string, cfg cursorConfig) (*cursor, error) {
+	c := &cursor{
+		log:    log,
+		key:    key,
+		column: strings.ToLower(cfg.Column),
+		typ:    cfg.Type,
+		store:  store,
+	}
+	value := cfg.Default
+	has, err := store.Has(key)
+	if err != nil {
+		return nil, err
+	}
+	if has {
+		var st cursorState
+		if err := store.Get(key, &st); err != nil {
+			return nil, err
+		}
+		value = st.Value
+	}
+	v, err := parseCursorValue(c.typ, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor value %q: %w", value, err)
+	}
+	c.next, c.acked, c.persisted = v, v, v
+	return c, nil
+}
+
+// value returns the value to use in the next query.
+func (c *cursor) value() interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.next
+}
+
+// advance sets the value to use in the next query to the value of the last
+// row fetched.
+func (c *cursor) advance(v interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if compareCursorValues(v, c.next) > 0 {
+		c.next = v
+	}
+}
+
+// ack records that the row with the given value was acknowledged. The value
+// is persisted when persist is true, which is set for the last row of each
+// query, to limit the writes to the store.
+func (c *cursor) ack(v interface{}, persist bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if compareCursorValues(v, c.acked) > 0 {
+		c.acked = v
+	}
+	if persist {
+		c.persist()
+	}
+}
+
+// persist stores the last acknowledged value, c.mu must be held.
+func (c *cursor) persist() {
+	if compareCursorValues(c.acked, c.persisted) <= 0 {
+		return
+	}
+	err := c.store.Set(c.key, cursorState{Value: formatCursorValue(c.acked)})
+	if err != nil {
+		c.log.Errorw("Failed to persist cursor", "error", err)
+		return
+	}
+	c.persisted = c.acked
+}
+
+// close persists the last acknowledged value and closes the store.
+func (c *cursor) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.persist()
+	return c.store.Close()
+}
+
+// rowValue returns the cursor value of a row.
+func (c *cursor) rowValue(v interface{}) (interface{}, error) {
+	switch c.typ {
+	case cursorTypeInteger:
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case int:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, fmt.Errorf("value %d overflows integer cursor", v)
+			}
+			return int64(v), nil
+		case uint32:
+			return int64(v), nil
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, fmt.Errorf("value %v is not an integer", v)
+			}
+			return int64(v), nil
+		case string:
+			return parseCursorValue(c.typ, v)
+		}
+	case cursorTypeTimestamp:
+		switch v := v.(type) {
+		case time.Time:
+			return v.UTC(), nil
+		case string:
+			return parseCursorValue(c.typ, v)
+		}
+	}
+	return nil, fmt.Errorf("unsupported %s cursor value of type %T", c.typ, v)
+}
+
+// timestampLayouts are the layouts accepted for timestamp cursor values.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func parseCursorValue(typ, s string) (interface{}, error) {
+	switch typ {
+	case cursorTypeInteger:
+		return strconv.ParseInt(s, 10, 64)
+	case cursorTypeTimestamp:
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return nil, fmt.Errorf("unsupported timestamp format: %q", s)
+	}
+	return nil, fmt.Errorf("unsupported cursor type %q", typ)
+}
+
+func formatCursorValue(v interface{}) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// compareCursorValues compares two cursor values of the same type.
+func compareCursorValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64:
+		b, _ := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case time.Time:
+		b, _ := b.(time.Time)
+		return a.Compare(b)
+	}
+	return 0
+}
+
+// bindCursor replaces the cursor placeholders of the query by the driver
+// specific query parameter placeholders. It returns the query and the
+// number of arguments to pass the cursor value as.
+func bindCursor(driver, query string) (string, int) {
+	var placeholder string
+	switch sql.SwitchDriverName(driver) {
+	case "postgres":
+		placeholder = "$1"
+	case "godror":
+		placeholder = ":1"
+	case "mssql", "sqlserver":
+		placeholder = "@p1"
+	default:
+		// Positional placeholders need an argument per placeholder.
+		n := len(cursorPlaceholderRe.FindAllStringIndex(query, -1))
+		return cursorPlaceholderRe.ReplaceAllLiteralString(query, "?"), n
+	}
+	return cursorPlaceholderRe.ReplaceAllLiteralString(query, placeholder), 1
+}
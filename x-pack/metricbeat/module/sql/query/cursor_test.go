@@ -0,0 +1,181 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/storetest"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func TestCursorConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		cfg     cursorConfig
+		wantErr string
+	}{
+		"disabled": {
+			cfg: cursorConfig{},
+		},
+		"integer": {
+			cfg: cursorConfig{Enabled: true, Column: "id", Type: cursorTypeInteger, Default: "0"},
+		},
+		"timestamp": {
+			cfg: cursorConfig{Enabled: true, Column: "ts", Type: cursorTypeTimestamp, Default: "2024-01-01T00:00:00Z"},
+		},
+		"missing column": {
+			cfg:     cursorConfig{Enabled: true, Type: cursorTypeInteger, Default: "0"},
+			wantErr: "cursor.column must be set",
+		},
+		"invalid type": {
+			cfg:     cursorConfig{Enabled: true, Column: "id", Type: "uuid", Default: "0"},
+			wantErr: `invalid cursor.type "uuid"`,
+		},
+		"missing default": {
+			cfg:     cursorConfig{Enabled: true, Column: "id", Type: cursorTypeInteger},
+			wantErr: "cursor.default must be set",
+		},
+		"invalid default": {
+			cfg:     cursorConfig{Enabled: true, Column: "ts", Type: cursorTypeTimestamp, Default: "yesterday"},
+			wantErr: "invalid cursor.default",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestCursorValues(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 10, 20, 30, 123456000, time.UTC)
+
+	for _, s := range []string{
+		"2024-03-01T10:20:30.123456Z",
+		"2024-03-01T11:20:30.123456+01:00",
+		"2024-03-01 10:20:30.123456",
+		"2024-03-01T10:20:30.123456",
+	} {
+		v, err := parseCursorValue(cursorTypeTimestamp, s)
+		require.NoError(t, err, s)
+		assert.Equal(t, ts, v, s)
+	}
+	assert.Equal(t, "2024-03-01T10:20:30.123456Z", formatCursorValue(ts))
+
+	v, err := parseCursorValue(cursorTypeInteger, "42")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), v)
+	assert.Equal(t, "42", formatCursorValue(v))
+
+	_, err = parseCursorValue(cursorTypeInteger, "4.2")
+	assert.Error(t, err)
+
+	assert.Equal(t, -1, compareCursorValues(int64(1), int64(2)))
+	assert.Equal(t, 0, compareCursorValues(int64(2), int64(2)))
+	assert.Equal(t, 1, compareCursorValues(ts.Add(time.Nanosecond), ts))
+}
+
+func TestCursorRowValue(t *testing.T) {
+	integer := &cursor{typ: cursorTypeInteger}
+	for _, v := range []interface{}{int64(7), 7, int32(7), uint32(7), uint64(7), float64(7), "7"} {
+		got, err := integer.rowValue(v)
+		require.NoError(t, err, "%T", v)
+		assert.Equal(t, int64(7), got, "%T", v)
+	}
+	for _, v := range []interface{}{7.5, uint64(1 << 63), "seven", nil} {
+		_, err := integer.rowValue(v)
+		assert.Error(t, err, "%T", v)
+	}
+
+	timestamp := &cursor{typ: cursorTypeTimestamp}
+	ts := time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC)
+	for _, v := range []interface{}{ts.In(time.FixedZone("CET", 3600)), "2024-03-01T10:20:30Z"} {
+		got, err := timestamp.rowValue(v)
+		require.NoError(t, err, "%T", v)
+		assert.Equal(t, ts, got, "%T", v)
+	}
+	_, err := timestamp.rowValue(int64(1))
+	assert.Error(t, err)
+}
+
+func TestBindCursor(t *testing.T) {
+	const q = "SELECT id FROM t WHERE id > :cursor AND id <= :cursor + 100 AND name <> ':cursorx'"
+
+	tests := map[string]struct {
+		query string
+		args  int
+	}{
+		"postgres": {"SELECT id FROM t WHERE id > $1 AND id <= $1 + 100 AND name <> ':cursorx'", 1},
+		"oracle":   {"SELECT id FROM t WHERE id > :1 AND id <= :1 + 100 AND name <> ':cursorx'", 1},
+		"mssql":    {"SELECT id FROM t WHERE id > @p1 AND id <= @p1 + 100 AND name <> ':cursorx'", 1},
+		"mysql":    {"SELECT id FROM t WHERE id > ? AND id <= ? + 100 AND name <> ':cursorx'", 2},
+	}
+
+	for driver, tc := range tests {
+		t.Run(driver, func(t *testing.T) {
+			query, args := bindCursor(driver, q)
+			assert.Equal(t, tc.query, query)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+}
+
+func TestCursorPersistence(t *testing.T) {
+	log := logp.NewLogger("test")
+	reg := statestore.NewRegistry(storetest.NewMemoryStoreBackend())
+	defer reg.Close()
+	cfg := cursorConfig{Enabled: true, Column: "ID", Type: cursorTypeInteger, Default: "10"}
+	key := cursorKey("postgres", "postgres://localhost", "SELECT id FROM t WHERE id > :cursor", "ID")
+
+	open := func(t *testing.T) *cursor {
+		store, err := reg.Get("cursor")
+		require.NoError(t, err)
+		c, err := newCursor(log, store, key, cfg)
+		require.NoError(t, err)
+		return c
+	}
+	// restored returns the value restored by a new cursor.
+	restored := func(t *testing.T) interface{} {
+		c := open(t)
+		defer c.close()
+		return c.value()
+	}
+
+	c := open(t)
+	assert.Equal(t, "id", c.column)
+	assert.Equal(t, int64(10), c.value())
+
+	c.advance(int64(11))
+	c.advance(int64(12))
+	assert.Equal(t, int64(12), c.value())
+
+	// Only the last row of a fetch is persisted.
+	c.ack(int64(11), false)
+	assert.Equal(t, int64(10), restored(t))
+	c.ack(int64(12), true)
+	assert.Equal(t, int64(12), restored(t))
+
+	// Rows fetched but not acknowledged are fetched again after a restart.
+	c.advance(int64(13))
+	c.advance(int64(14))
+	c.ack(int64(13), false)
+	require.NoError(t, c.close())
+	assert.Equal(t, int64(13), restored(t))
+
+	// Each query has its own cursor.
+	key = cursorKey("postgres", "postgres://localhost", "SELECT id FROM u WHERE id > :cursor", "ID")
+	assert.Equal(t, int64(10), restored(t))
+}
@@ -52,6 +52,9 @@ type config struct {
 	// Support fetch response for given queries from all databases.
 	// NOTE: Currently, mssql driver only respects FetchFromAllDatabases.
 	FetchFromAllDatabases bool `config:"fetch_from_all_databases"`
+
+	// Cursor enables the incremental fetch of rows.
+	Cursor cursorConfig `config:"cursor"`
 }
 
 // MetricSet holds any configuration or state information. It must implement
@@ -61,6 +64,11 @@ type config struct {
 type MetricSet struct {
 	mb.BaseMetricSet
 	Config config
+
+	// cursor is set in cursor mode, with the query using its parameter.
+	cursor      *cursor
+	cursorQuery string
+	cursorArgs  int
 }
 
 // rawData is the minimum required set of fields to generate fully customized events with their own module key space
@@ -102,9 +110,59 @@ func New(base mb.BaseMetricSet) (mb.MetricSet, error) {
 		return nil, fmt.Errorf("both query inputs provided, must provide either sql_query or sql_queries")
 	}
 
+	if b.Config.Cursor.Enabled {
+		if err := b.initCursor(); err != nil {
+			return nil, err
+		}
+	}
+
 	return b, nil
 }
 
+// initCursor validates the configuration of the cursor mode and restores
+// the cursor.
+func (m *MetricSet) initCursor() error {
+	switch {
+	case m.Config.Query == "":
+		return errors.New("cursor requires sql_query to be set")
+	case m.Config.ResponseFormat != tableResponseFormat:
+		return fmt.Errorf("cursor requires sql_response_format to be %s", tableResponseFormat)
+	case m.Config.MergeResults:
+		return errors.New("cursor can not be used with merge_results")
+	case m.Config.FetchFromAllDatabases:
+		return errors.New("cursor can not be used with fetch_from_all_databases")
+	case !cursorPlaceholderRe.MatchString(m.Config.Query):
+		return fmt.Errorf("cursor requires sql_query to use the %s placeholder", cursorPlaceholder)
+	}
+
+	store, err := openCursorStore(m.Logger())
+	if err != nil {
+		return fmt.Errorf("cannot open cursor store: %w", err)
+	}
+	key := cursorKey(m.Config.Driver, m.HostData().URI, m.Config.Query, m.Config.Cursor.Column)
+	m.cursor, err = newCursor(m.Logger(), store, key, m.Config.Cursor)
+	if err != nil {
+		store.Close()
+		return fmt.Errorf("cannot restore cursor: %w", err)
+	}
+	m.cursorQuery, m.cursorArgs = bindCursor(m.Config.Driver, m.Config.Query)
+	return nil
+}
+
+// ACKEvents returns true in cursor mode, as the cursor is persisted once
+// the events are acknowledged.
+func (m *MetricSet) ACKEvents() bool {
+	return m.cursor != nil
+}
+
+// Close persists the cursor in cursor mode.
+func (m *MetricSet) Close() error {
+	if m.cursor == nil {
+		return nil
+	}
+	return m.cursor.close()
+}
+
 // queryDBNames returns the query to list databases present in a server
 // as per the driver name. If the given driver is not supported, queryDBNames
 // returns an empty query.
@@ -204,6 +262,42 @@ func (m *MetricSet) fetch(ctx context.Context, db *sql.DbClient, reporter mb.Rep
 	return ok, nil
 }
 
+// fetchCursor runs the cursor query with the current cursor value and
+// reports each row as its own event. The cursor is advanced to the value of
+// the last row reported, and persisted once its event is acknowledged.
+func (m *MetricSet) fetchCursor(ctx context.Context, db *sql.DbClient, reporter mb.ReporterV2) error {
+	value := m.cursor.value()
+	args := make([]interface{}, m.cursorArgs)
+	for i := range args {
+		args[i] = value
+	}
+	rows, err := db.FetchTableMode(ctx, m.cursorQuery, args...)
+	if err != nil {
+		return fmt.Errorf("fetch cursor query failed: %w", err)
+	}
+
+	for i, row := range rows {
+		v, err := row.GetValue(m.cursor.column)
+		if err != nil {
+			return fmt.Errorf("cursor column %q not found in row: %w", m.cursor.column, err)
+		}
+		rowValue, err := m.cursor.rowValue(v)
+		if err != nil {
+			return fmt.Errorf("invalid cursor column %q: %w", m.cursor.column, err)
+		}
+
+		event := m.newEvent(row, m.Config.Query)
+		last := i == len(rows)-1
+		event.OnACK = func() { m.cursor.ack(rowValue, last) }
+		if !reporter.Event(event) {
+			// The metricset is stopping.
+			return nil
+		}
+		m.cursor.advance(rowValue)
+	}
+	return nil
+}
+
 // Fetch method implements the data gathering and data conversion to the right
 // format. It publishes the event which is then forwarded to the output. In case
 // of an error set the Error field of mb.Event or simply call report.Error().
@@ -216,6 +310,10 @@ func (m *MetricSet) Fetch(ctx context.Context, reporter mb.ReporterV2) error {
 	}
 	defer db.Close()
 
+	if m.cursor != nil {
+		return m.fetchCursor(ctx, db, reporter)
+	}
+
 	queries := m.Config.Queries
 	if len(queries) == 0 {
 		one_query := query{Query: m.Config.Query, ResponseFormat: m.Config.ResponseFormat}
@@ -299,41 +397,42 @@ func (m *MetricSet) Fetch(ctx context.Context, reporter mb.ReporterV2) error {
 // reportEvent using 'user' mode with keys under `sql.metrics.*` or using Raw data mode (module and metricset key spaces
 // provided by the user)
 func (m *MetricSet) reportEvent(ms mapstr.M, reporter mb.ReporterV2, qry ...string) bool {
-	var ok bool
+	return reporter.Event(m.newEvent(ms, qry...))
+}
+
+// newEvent returns the event reported for the given metrics, see reportEvent.
+func (m *MetricSet) newEvent(ms mapstr.M, qry ...string) mb.Event {
 	if m.Config.RawData.Enabled {
 		// New usage.
 		// Only driver & query field mapped.
 		// metrics to be mapped by end user.
 		if len(qry) > 0 {
 			// set query.
-			ok = reporter.Event(mb.Event{
+			return mb.Event{
 				ModuleFields: mapstr.M{
 					"metrics": ms, // Individual metric
 					"driver":  m.Config.Driver,
 					"query":   qry,
 				},
-			})
-		} else {
-			ok = reporter.Event(mb.Event{
-				// Do not set query.
-				ModuleFields: mapstr.M{
-					"metrics": ms, // Individual metric
-					"driver":  m.Config.Driver,
-				},
-			})
+			}
 		}
-	} else {
-		// Previous usage. Backward compatibility.
-		// Supports field mapping.
-		ok = reporter.Event(mb.Event{
+		return mb.Event{
+			// Do not set query.
 			ModuleFields: mapstr.M{
+				"metrics": ms, // Individual metric
 				"driver":  m.Config.Driver,
-				"query":   qry,
-				"metrics": inferTypeFromMetrics(ms),
 			},
-		})
+		}
+	}
+	// Previous usage. Backward compatibility.
+	// Supports field mapping.
+	return mb.Event{
+		ModuleFields: mapstr.M{
+			"driver":  m.Config.Driver,
+			"query":   qry,
+			"metrics": inferTypeFromMetrics(ms),
+		},
 	}
-	return ok
 }
 
 // inferTypeFromMetrics to organize the output event into 'numeric', 'strings', 'floats' and 'boolean' values
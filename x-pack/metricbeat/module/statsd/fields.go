@@ -19,5 +19,5 @@ func init() {
 // AssetStatsd returns asset data.
 // This is the base64 encoded zlib format compressed contents of module/statsd.
 func AssetStatsd() string {
-	return "eJyUkTtqxTAQRXut4qLGYHAWoCKbyAKMIo2NEv2QxoV3H/wL4uFXeMp7z4gzaMAvrQqVNVcrAHbsSUF+7YEUgKVqisvsUlT4FABwlAjJLp4EUMiTrqQwawFMjrytaicHRB2oeX8bXvPGlrTkM2lX2rWu/zBpidz9N9d2+v4hw018BOPR+hTn+24MOmcX5xOUGykb9Obaa86rdyEq9U62fyo6+aTflK+m/TPNQFycaS3bX/oLAAD//wYwi2Y="
+	return "eJzElk9r20AQxe/6FIMvSYxjCr35UCjkUCgthfQutquRvJW0I2ZHUfTty64Us7bXiVMEwbeZ3fd++/aPdQ81jjtwosQVGYAYaXAHq8dQWGUABTrNphNDdgdfMgCAqQktFX2DGQBjg8rhDiqVAZQGm8Ltwsh7sKrFSN8XZez8WKa+myvxlHgaPqGVQzU1EyBJ+PJ7oCrAPkxS26h56hn7hhSOOi/eNY4DcZEdtV4j8L/fXg6oBNnjOUhki8+SdE003rLEZ7nCcU9OvPdya/02K4JDKzAY2b/F0LEhNjIux/BrVjwKYAOWuFUNEENDQ5pFNciS+9CXo/nqNYPACQ/ZUEFm4g0Miq2x1QaMLclDul5rdO4CaFUxVsp75jUumN13HKF3WIDQdEX97VaCxbSFF3Ac9awxBJcve54eg/J5fNvsjAH5yWjM9R51vcyjMUtCkLz28Vh2/T9Ve1j4RZwoBFHSu+Xs/evZuyTA4QBTHZ1ezUaMnq5Zb2tLg32NNNdUpNNqyFb/xeoVLwCXTC18gluq7/z5/gy3M+LdBz+PR5xplhadUxUu9P/wY1JL5rTNTr1v1ltNvZWbSGdypj9/UcfeUyFP7mHUy1vVdcZW88CV3+1VdhX+/PURgJBdCnb9XtCyISXXka7fh9misNExZfy19G8ALHqAPg=="
 }
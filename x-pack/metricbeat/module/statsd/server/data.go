@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/elastic/beats/v7/libbeat/common"
@@ -21,17 +22,37 @@ var errInvalidPacket = errors.New("invalid statsd packet")
 
 type metricProcessor struct {
 	registry *registry
+
+	// events, serviceChecks and timestamped hold the items received since
+	// the last report that are reported on their own.
+	events        []dogstatsdEvent
+	serviceChecks []serviceCheck
+	timestamped   []timestampedMetric
 }
 
 type statsdMetric struct {
 	name       string
 	metricType string
 	sampleRate string
-	value      string
-	tags       map[string]string
+	// value holds one or more values separated by `:`.
+	value string
+	tags  map[string]string
+	// containerID and timestamp are set by the DogStatsD extensions.
+	containerID string
+	timestamp   string
+}
+
+// packet holds the metrics, events and service checks of a packet.
+type packet struct {
+	metrics       []statsdMetric
+	events        []dogstatsdEvent
+	serviceChecks []serviceCheck
 }
 
-func splitTags(rawTags, kvSep []byte) map[string]string {
+// splitTags splits tags in <k><kvSep><v> format. When allowNoValue is set,
+// tags without value, as sent by DogStatsD clients, are set with an empty
+// value.
+func splitTags(rawTags, kvSep []byte, allowNoValue bool) map[string]string {
 	tags := map[string]string{}
 	var tagSplit [][]byte
 
@@ -44,6 +65,10 @@ func splitTags(rawTags, kvSep []byte) map[string]string {
 	for _, kv := range tagSplit {
 		kvSplit := bytes.SplitN(kv, kvSep, 2)
 		if len(kvSplit) != 2 {
+			if allowNoValue && len(kv) > 0 {
+				tags[string(kv)] = ""
+				continue
+			}
 			logger.Warn("could not parse tags")
 			continue
 		}
@@ -53,30 +78,33 @@ func splitTags(rawTags, kvSep []byte) map[string]string {
 }
 
 func parseSingle(b []byte) (statsdMetric, error) {
-	// format: <metric name>:<value>|<type>[|@samplerate][|#<k>:<v>,<k>:<v>]
+	// format: <metric name>:<value>[:<value>...]|<type>[|@samplerate][|#<k>:<v>,<k>:<v>][|c:<container id>][|T<timestamp>]
 	// alternative: <metric name>[,<k>=<v>,<k>=<v>]:<value>|<type>[|@samplerate]
 	// alternative: <metric name>[;<k>=<v>;<k>=<v>]:<value>|<type>[|@samplerate]
 	s := statsdMetric{}
 
-	parts := bytes.SplitN(b, []byte("|"), 4)
+	parts := bytes.Split(b, []byte("|"))
 	if len(parts) < 2 {
 		return s, errInvalidPacket
 	}
 
-	if len(parts) > 2 && len(parts[2]) > 0 && parts[2][0] == '@' {
-		s.sampleRate = string(parts[2][1:])
-
-		if len(parts) > 3 {
-			parts = [][]byte{parts[0], parts[1], parts[3]}
-		} else {
-			parts = [][]byte{parts[0], parts[1]}
+	for _, field := range parts[2:] {
+		switch {
+		case len(field) == 0:
+		case field[0] == '@':
+			s.sampleRate = string(field[1:])
+		case field[0] == '#':
+			s.tags = splitTags(field[1:], []byte(":"), true)
+		case bytes.HasPrefix(field, []byte("c:")):
+			s.containerID = string(field[2:])
+		case field[0] == 'T':
+			s.timestamp = string(field[1:])
+		default:
+			// Unknown fields are ignored, for compatibility with newer
+			// versions of the protocol.
 		}
 	}
 
-	if len(parts) > 2 && len(parts[2]) > 0 && parts[2][0] == '#' {
-		s.tags = splitTags(parts[2][1:], []byte(":"))
-	}
-
 	nameSplit := bytes.SplitN(parts[0], []byte{':'}, 2)
 	if len(nameSplit) != 2 {
 		return s, errInvalidPacket
@@ -93,7 +121,7 @@ func parseSingle(b []byte) (statsdMetric, error) {
 
 	s.name = string(nameTagsSplit[0])
 	if len(nameTagsSplit) > 1 {
-		s.tags = splitTags(nameTagsSplit[1], []byte("="))
+		s.tags = splitTags(nameTagsSplit[1], []byte("="), false)
 	}
 
 	s.value = string(nameSplit[1])
@@ -102,21 +130,39 @@ func parseSingle(b []byte) (statsdMetric, error) {
 	return s, nil
 }
 
-// parse will parse statsd metrics into individual metric and then its components
-func parse(b []byte) ([]statsdMetric, error) {
+// parse will parse statsd metrics, and DogStatsD events and service checks,
+// into individual items and then their components
+func parse(b []byte) (packet, error) {
 	rawMetrics := bytes.Split(b, []byte("\n"))
-	metrics := make([]statsdMetric, 0, len(rawMetrics))
+	p := packet{metrics: make([]statsdMetric, 0, len(rawMetrics))}
 	for i := range rawMetrics {
-		if len(rawMetrics[i]) > 0 {
-			metric, err := parseSingle(rawMetrics[i])
+		raw := rawMetrics[i]
+		switch {
+		case len(raw) == 0:
+		case bytes.HasPrefix(raw, eventPrefix):
+			event, err := parseEvent(raw)
+			if err != nil {
+				logger.Warnf("invalid event: %s", err)
+				continue
+			}
+			p.events = append(p.events, event)
+		case bytes.HasPrefix(raw, serviceCheckPrefix):
+			sc, err := parseServiceCheck(raw)
+			if err != nil {
+				logger.Warnf("invalid service check: %s", err)
+				continue
+			}
+			p.serviceChecks = append(p.serviceChecks, sc)
+		default:
+			metric, err := parseSingle(raw)
 			if err != nil {
 				logger.Warnf("invalid packet: %s", err)
 				continue
 			}
-			metrics = append(metrics, metric)
+			p.metrics = append(p.metrics, metric)
 		}
 	}
-	return metrics, nil
+	return p, nil
 }
 
 func eventMapping(metricName string, metricValue interface{}, mappings map[string]StatsdMapping) mapstr.M {
@@ -187,55 +233,145 @@ func (p *metricProcessor) processSingle(m statsdMetric) error {
 		}
 	}
 
+	// Timestamps are only supported for counters and gauges, and ignored
+	// for other types.
+	if m.timestamp != "" && (m.metricType == "c" || m.metricType == "g") {
+		return p.processTimestamped(m, sampleRate)
+	}
+
+	// DogStatsD clients can send multiple values in a single metric. Set
+	// members are not split, as they can contain colons.
+	if m.metricType == "s" {
+		return p.processValue(m, m.value, sampleRate)
+	}
+	for _, value := range strings.Split(m.value, ":") {
+		if value == "" {
+			continue
+		}
+		if err := p.processValue(m, value, sampleRate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *metricProcessor) processValue(m statsdMetric, value string, sampleRate float64) error {
 	switch m.metricType {
 	case "c":
-		c := p.registry.GetOrNewCounter(m.name, m.tags)
-		v, err := strconv.ParseInt(m.value, 10, 64)
+		c := p.registry.GetOrNewCounter(m.name, m.tags, m.containerID)
+		v, err := parseCounterValue(m.name, value)
 		if err != nil {
-			v1, err := strconv.ParseFloat(m.value, 64)
-			if err != nil {
-				return fmt.Errorf("failed to process counter `%s` with value `%s`: %w", m.name, m.value, err)
-			}
-			v = int64(v1) // cast to int64
+			return err
 		}
 		// apply sample rate
 		v = int64(float64(v) * (1.0 / sampleRate))
 		c.Inc(v)
 	case "g":
-		c := p.registry.GetOrNewGauge64(m.name, m.tags)
-		v, err := strconv.ParseFloat(m.value, 64)
+		c := p.registry.GetOrNewGauge64(m.name, m.tags, m.containerID)
+		v, err := strconv.ParseFloat(value, 64)
 		if err != nil {
-			return fmt.Errorf("failed to process gauge `%s` with value `%s`: %w", m.name, m.value, err)
+			return fmt.Errorf("failed to process gauge `%s` with value `%s`: %w", m.name, value, err)
 		}
 		// inc/dec or set
-		if m.value[0] == '+' || m.value[0] == '-' {
+		if value[0] == '+' || value[0] == '-' {
 			c.Inc(v)
 		} else {
 			c.Set(v)
 		}
 	case "ms":
-		c := p.registry.GetOrNewTimer(m.name, m.tags)
-		v, err := strconv.ParseFloat(m.value, 64)
+		c := p.registry.GetOrNewTimer(m.name, m.tags, m.containerID)
+		v, err := strconv.ParseFloat(value, 64)
 		if err != nil {
-			return fmt.Errorf("failed to process timer `%s` with value `%s`: %w", m.name, m.value, err)
+			return fmt.Errorf("failed to process timer `%s` with value `%s`: %w", m.name, value, err)
 		}
 		c.SampledUpdate(time.Duration(v), sampleRate)
 	case "h": // TODO: can these be floats?
-		c := p.registry.GetOrNewHistogram(m.name, m.tags)
-		v, err := strconv.ParseInt(m.value, 10, 64)
+		c := p.registry.GetOrNewHistogram(m.name, m.tags, m.containerID)
+		v, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
-			return fmt.Errorf("failed to process histogram `%s` with value `%s`: %w", m.name, m.value, err)
+			return fmt.Errorf("failed to process histogram `%s` with value `%s`: %w", m.name, value, err)
 		}
 		c.Update(v)
+	case "d":
+		c := p.registry.GetOrNewDistribution(m.name, m.tags, m.containerID)
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("failed to process distribution `%s` with value `%s`: %w", m.name, value, err)
+		}
+		c.Update(v, sampleRate)
 	case "s":
-		c := p.registry.GetOrNewSet(m.name, m.tags)
-		c.Add(m.value)
+		c := p.registry.GetOrNewSet(m.name, m.tags, m.containerID)
+		c.Add(value)
 	default:
 		logp.NewLogger("statsd").Debugf("metric type `%s` is not supported", m.metricType)
 	}
 	return nil
 }
 
+func parseCounterValue(name, value string) (int64, error) {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		v1, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to process counter `%s` with value `%s`: %w", name, value, err)
+		}
+		v = int64(v1) // cast to int64
+	}
+	return v, nil
+}
+
+// timestampedMetric is a counter or gauge sent with a timestamp. It is not
+// aggregated, but reported on its own with its timestamp.
+type timestampedMetric struct {
+	name        string
+	tags        map[string]string
+	containerID string
+	timestamp   time.Time
+	value       map[string]interface{}
+}
+
+func (p *metricProcessor) processTimestamped(m statsdMetric, sampleRate float64) error {
+	ts, err := strconv.ParseInt(m.timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to process metric `%s` timestamp `%s`: %w", m.name, m.timestamp, err)
+	}
+
+	var count int64
+	var gauge float64
+	for _, value := range strings.Split(m.value, ":") {
+		if value == "" {
+			continue
+		}
+		switch m.metricType {
+		case "c":
+			v, err := parseCounterValue(m.name, value)
+			if err != nil {
+				return err
+			}
+			count += int64(float64(v) * (1.0 / sampleRate))
+		case "g":
+			gauge, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("failed to process gauge `%s` with value `%s`: %w", m.name, value, err)
+			}
+		}
+	}
+
+	tm := timestampedMetric{
+		name:        m.name,
+		tags:        m.tags,
+		containerID: m.containerID,
+		timestamp:   time.Unix(ts, 0).UTC(),
+	}
+	if m.metricType == "c" {
+		tm.value = map[string]interface{}{"count": count}
+	} else {
+		tm.value = map[string]interface{}{"value": gauge}
+	}
+	p.timestamped = append(p.timestamped, tm)
+	return nil
+}
+
 func (p *metricProcessor) Process(event server.Event) error {
 	bytesRaw, ok := event.GetEvent()[server.EventDataKey]
 	if !ok {
@@ -247,12 +383,15 @@ func (p *metricProcessor) Process(event server.Event) error {
 		return errors.New("packet has no data")
 	}
 
-	metrics, err := parse(b)
+	packet, err := parse(b)
 	if err != nil {
 		return err
 	}
 
-	for _, m := range metrics {
+	p.events = append(p.events, packet.events...)
+	p.serviceChecks = append(p.serviceChecks, packet.serviceChecks...)
+
+	for _, m := range packet.metrics {
 		if err := p.processSingle(m); err != nil {
 			return err
 		}
@@ -264,3 +403,11 @@ func (p *metricProcessor) Process(event server.Event) error {
 func (p *metricProcessor) GetAll() []metricsGroup {
 	return p.registry.GetAll()
 }
+
+// TakePending returns the events, service checks and timestamped metrics
+// received since the last call, which are not aggregated.
+func (p *metricProcessor) TakePending() ([]dogstatsdEvent, []serviceCheck, []timestampedMetric) {
+	events, serviceChecks, timestamped := p.events, p.serviceChecks, p.timestamped
+	p.events, p.serviceChecks, p.timestamped = nil, nil, nil
+	return events, serviceChecks, timestamped
+}
@@ -1036,6 +1036,48 @@ func TestParseMetrics(t *testing.T) {
 				},
 			},
 		},
+		/// DogStatsD extensions
+		{
+			input: "dist1:1.5:2:3.25|d|@0.5|#env:prod,canary|c:abc123",
+			expected: []statsdMetric{
+				{
+					name:        "dist1",
+					metricType:  "d",
+					value:       "1.5:2:3.25",
+					sampleRate:  "0.5",
+					containerID: "abc123",
+					tags: map[string]string{
+						"env":    "prod",
+						"canary": "",
+					},
+				},
+			},
+		},
+		{
+			input: "counter3:3|c|#k1:v1|T1700000000|x:unknown",
+			expected: []statsdMetric{
+				{
+					name:       "counter3",
+					metricType: "c",
+					value:      "3",
+					timestamp:  "1700000000",
+					tags: map[string]string{
+						"k1": "v1",
+					},
+				},
+			},
+		},
+		{
+			// Events and service checks are not metrics
+			input: "_e{5,4}:title|text\n_sc|check|0\ngauge2:2|g",
+			expected: []statsdMetric{
+				{
+					name:       "gauge2",
+					metricType: "g",
+					value:      "2",
+				},
+			},
+		},
 		/// errors
 		{
 			input:    "meter1-1.4|m",
@@ -1048,10 +1090,10 @@ func TestParseMetrics(t *testing.T) {
 	} {
 		actual, err := parse([]byte(test.input))
 		assert.Equal(t, test.err, err, test.input)
-		assert.Equal(t, test.expected, actual, test.input)
+		assert.Equal(t, test.expected, actual.metrics, test.input)
 
 		processor := newMetricProcessor(time.Second)
-		for _, e := range actual {
+		for _, e := range actual.metrics {
 			err := processor.processSingle(e)
 
 			assert.NoError(t, err)
@@ -1435,3 +1477,166 @@ func BenchmarkIngest(b *testing.B) {
 		assert.NoError(b, err)
 	}
 }
+
+func TestMultipleValues(t *testing.T) {
+	ms := mbtest.NewMetricSet(t, map[string]interface{}{"module": "statsd"}).(*MetricSet)
+	testData := []string{
+		"metric01:1:2:3|c",
+		"metric02:1:+2:-0.5|g",
+		// set members are not split
+		"metric03:a:b:a|s",
+	}
+	err := process(testData, ms)
+	require.NoError(t, err)
+
+	events := ms.getEvents()
+	require.Len(t, events, 3)
+
+	fields := mapstr.M{}
+	for _, e := range events {
+		for k, v := range e.MetricSetFields {
+			fields[k] = v
+		}
+	}
+	assert.Equal(t, mapstr.M{
+		"metric01": map[string]interface{}{"count": int64(6)},
+		"metric02": map[string]interface{}{"value": 2.5},
+		"metric03": map[string]interface{}{"count": 1},
+	}, fields)
+}
+
+func TestSetMemberWithColon(t *testing.T) {
+	ms := mbtest.NewMetricSet(t, map[string]interface{}{"module": "statsd"}).(*MetricSet)
+	testData := []string{
+		"users:alice:admin|s",
+		"users:alice:admin|s",
+	}
+	err := process(testData, ms)
+	require.NoError(t, err)
+
+	events := ms.getEvents()
+	require.Len(t, events, 1)
+	assert.Equal(t, map[string]interface{}{"count": 1}, events[0].MetricSetFields["users"])
+}
+
+func TestDistribution(t *testing.T) {
+	ms := mbtest.NewMetricSet(t, map[string]interface{}{"module": "statsd"}).(*MetricSet)
+	testData := []string{
+		"metric01:0.5:1.5|d|#k1:v1",
+		"metric01:4|d|@0.5|#k1:v1",
+	}
+	err := process(testData, ms)
+	require.NoError(t, err)
+
+	events := ms.getEvents()
+	require.Len(t, events, 1)
+
+	values := events[0].MetricSetFields["metric01"].(map[string]interface{})
+	assert.Equal(t, int64(4), values["count"])
+	assert.Equal(t, 0.5, values["min"])
+	assert.Equal(t, 4.0, values["max"])
+	assert.Equal(t, 10.0, values["sum"])
+	assert.Equal(t, 2.5, values["mean"])
+	assert.Equal(t, 1.5, values["median"])
+	assert.Equal(t, 4.0, values["p99"])
+
+	// Distributions are reset after each report.
+	events = ms.getEvents()
+	require.Len(t, events, 1)
+	assert.Equal(t, map[string]interface{}{"count": int64(0)}, events[0].MetricSetFields["metric01"])
+}
+
+func TestContainerID(t *testing.T) {
+	ms := mbtest.NewMetricSet(t, map[string]interface{}{"module": "statsd"}).(*MetricSet)
+	testData := []string{
+		"metric01:1|c|#k1:v1|c:container1",
+		"metric01:2|c|#k1:v1|c:container2",
+		"metric01:3|c|#k1:v1",
+	}
+	err := process(testData, ms)
+	require.NoError(t, err)
+
+	events := ms.getEvents()
+	require.Len(t, events, 3)
+
+	counts := map[string]interface{}{}
+	for _, e := range events {
+		containerID, _ := e.RootFields.GetValue("container.id")
+		id, _ := containerID.(string)
+		counts[id] = e.MetricSetFields["metric01"].(map[string]interface{})["count"]
+		assert.Equal(t, mapstr.M{"k1": "v1"}, e.RootFields["labels"])
+	}
+	assert.Equal(t, map[string]interface{}{
+		"container1": int64(1),
+		"container2": int64(2),
+		"":           int64(3),
+	}, counts)
+}
+
+func TestTimestampedMetrics(t *testing.T) {
+	ms := mbtest.NewMetricSet(t, map[string]interface{}{"module": "statsd"}).(*MetricSet)
+	testData := []string{
+		"metric01:1:2|c|@0.5|#k1:v1|T1700000000",
+		"metric01:5|c|#k1:v1",
+		"metric02:7|g|T1700000010|c:container1",
+	}
+	err := process(testData, ms)
+	require.NoError(t, err)
+
+	events := ms.getEvents()
+	require.Len(t, events, 3)
+
+	// Timestamped metrics are not aggregated with the others.
+	assert.True(t, events[0].Timestamp.IsZero())
+	assert.Equal(t, mapstr.M{"metric01": map[string]interface{}{"count": int64(5)}}, events[0].MetricSetFields)
+
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), events[1].Timestamp)
+	assert.Equal(t, mapstr.M{"metric01": map[string]interface{}{"count": int64(6)}}, events[1].MetricSetFields)
+	assert.Equal(t, mapstr.M{"labels": mapstr.M{"k1": "v1"}}, events[1].RootFields)
+
+	assert.Equal(t, time.Unix(1700000010, 0).UTC(), events[2].Timestamp)
+	assert.Equal(t, mapstr.M{"metric02": map[string]interface{}{"value": 7.0}}, events[2].MetricSetFields)
+	assert.Equal(t, mapstr.M{"labels": mapstr.M{}, "container": mapstr.M{"id": "container1"}}, events[2].RootFields)
+
+	// They are only reported once.
+	assert.Len(t, ms.getEvents(), 1)
+}
+
+func TestEventsAndServiceChecks(t *testing.T) {
+	ms := mbtest.NewMetricSet(t, map[string]interface{}{"module": "statsd"}).(*MetricSet)
+	testData := []string{
+		"_e{6,11}:Deploy|Deployed v2|d:1700000000|t:success|#env:prod|c:container1\n_sc|db.up|2|m:connection refused",
+	}
+	err := process(testData, ms)
+	require.NoError(t, err)
+
+	events := ms.getEvents()
+	require.Len(t, events, 2)
+
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), events[0].Timestamp)
+	assert.Equal(t, "statsd", events[0].Namespace)
+	assert.Equal(t, mapstr.M{
+		"event": mapstr.M{
+			"title":      "Deploy",
+			"text":       "Deployed v2",
+			"priority":   "normal",
+			"alert_type": "success",
+		},
+	}, events[0].MetricSetFields)
+	assert.Equal(t, mapstr.M{
+		"labels":    mapstr.M{"env": "prod"},
+		"container": mapstr.M{"id": "container1"},
+	}, events[0].RootFields)
+
+	assert.True(t, events[1].Timestamp.IsZero())
+	assert.Equal(t, mapstr.M{
+		"service_check": mapstr.M{
+			"name":        "db.up",
+			"status":      "critical",
+			"status_code": 2,
+			"message":     "connection refused",
+		},
+	}, events[1].MetricSetFields)
+
+	assert.Empty(t, ms.getEvents())
+}
@@ -0,0 +1,204 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var (
+	eventPrefix        = []byte("_e{")
+	serviceCheckPrefix = []byte("_sc|")
+)
+
+// dogstatsdEvent is a DogStatsD event, reported on its own.
+type dogstatsdEvent struct {
+	title          string
+	text           string
+	timestamp      time.Time
+	hostname       string
+	priority       string
+	alertType      string
+	aggregationKey string
+	sourceType     string
+	tags           map[string]string
+	containerID    string
+}
+
+// serviceCheck is a DogStatsD service check, reported on its own.
+type serviceCheck struct {
+	name        string
+	status      int
+	timestamp   time.Time
+	hostname    string
+	message     string
+	tags        map[string]string
+	containerID string
+}
+
+var serviceCheckStatuses = []string{"ok", "warning", "critical", "unknown"}
+
+// parseEvent parses a DogStatsD event.
+func parseEvent(b []byte) (dogstatsdEvent, error) {
+	// format: _e{<title length>,<text length>}:<title>|<text>[|d:<timestamp>][|h:<hostname>][|p:<priority>][|t:<alert type>][|#<k>:<v>,<k>:<v>][|k:<aggregation key>][|s:<source type>][|c:<container id>]
+	e := dogstatsdEvent{}
+
+	b = b[len(eventPrefix):]
+	end := bytes.Index(b, []byte("}:"))
+	if end < 0 {
+		return e, errInvalidPacket
+	}
+	lengths := bytes.SplitN(b[:end], []byte(","), 2)
+	if len(lengths) != 2 {
+		return e, errInvalidPacket
+	}
+	titleLen, err := strconv.Atoi(string(lengths[0]))
+	if err != nil || titleLen < 1 {
+		return e, errInvalidPacket
+	}
+	textLen, err := strconv.Atoi(string(lengths[1]))
+	if err != nil || textLen < 0 {
+		return e, errInvalidPacket
+	}
+
+	// The lengths are in bytes, the title and text are separated by a |.
+	b = b[end+2:]
+	if len(b) < titleLen+1+textLen || b[titleLen] != '|' {
+		return e, errInvalidPacket
+	}
+	e.title = unescapeNewlines(b[:titleLen])
+	e.text = unescapeNewlines(b[titleLen+1 : titleLen+1+textLen])
+
+	rest := b[titleLen+1+textLen:]
+	if len(rest) > 0 && rest[0] != '|' {
+		return e, errInvalidPacket
+	}
+	for _, field := range bytes.Split(rest, []byte("|")) {
+		switch {
+		case len(field) == 0:
+		case bytes.HasPrefix(field, []byte("d:")):
+			e.timestamp, err = parseUnixTimestamp(field[2:])
+			if err != nil {
+				return e, err
+			}
+		case bytes.HasPrefix(field, []byte("h:")):
+			e.hostname = string(field[2:])
+		case bytes.HasPrefix(field, []byte("p:")):
+			e.priority = string(field[2:])
+		case bytes.HasPrefix(field, []byte("t:")):
+			e.alertType = string(field[2:])
+		case bytes.HasPrefix(field, []byte("k:")):
+			e.aggregationKey = string(field[2:])
+		case bytes.HasPrefix(field, []byte("s:")):
+			e.sourceType = string(field[2:])
+		case bytes.HasPrefix(field, []byte("c:")):
+			e.containerID = string(field[2:])
+		case field[0] == '#':
+			e.tags = splitTags(field[1:], []byte(":"), true)
+		}
+	}
+
+	if e.priority == "" {
+		e.priority = "normal"
+	}
+	if e.alertType == "" {
+		e.alertType = "info"
+	}
+	return e, nil
+}
+
+// parseServiceCheck parses a DogStatsD service check.
+func parseServiceCheck(b []byte) (serviceCheck, error) {
+	// format: _sc|<name>|<status>[|d:<timestamp>][|h:<hostname>][|#<k>:<v>,<k>:<v>][|m:<message>][|c:<container id>]
+	sc := serviceCheck{}
+
+	parts := bytes.Split(b, []byte("|"))
+	if len(parts) < 3 || len(parts[1]) == 0 {
+		return sc, errInvalidPacket
+	}
+	sc.name = string(parts[1])
+
+	status, err := strconv.Atoi(string(parts[2]))
+	if err != nil || status < 0 || status >= len(serviceCheckStatuses) {
+		return sc, fmt.Errorf("invalid status `%s` of service check `%s`: %w", parts[2], sc.name, errInvalidPacket)
+	}
+	sc.status = status
+
+	for _, field := range parts[3:] {
+		switch {
+		case len(field) == 0:
+		case bytes.HasPrefix(field, []byte("d:")):
+			sc.timestamp, err = parseUnixTimestamp(field[2:])
+			if err != nil {
+				return sc, err
+			}
+		case bytes.HasPrefix(field, []byte("h:")):
+			sc.hostname = string(field[2:])
+		case bytes.HasPrefix(field, []byte("m:")):
+			sc.message = unescapeNewlines(field[2:])
+		case bytes.HasPrefix(field, []byte("c:")):
+			sc.containerID = string(field[2:])
+		case field[0] == '#':
+			sc.tags = splitTags(field[1:], []byte(":"), true)
+		}
+	}
+	return sc, nil
+}
+
+func (e *dogstatsdEvent) fields() mapstr.M {
+	fields := mapstr.M{
+		"title":      e.title,
+		"priority":   e.priority,
+		"alert_type": e.alertType,
+	}
+	if e.text != "" {
+		fields["text"] = e.text
+	}
+	if e.hostname != "" {
+		fields["hostname"] = e.hostname
+	}
+	if e.aggregationKey != "" {
+		fields["aggregation_key"] = e.aggregationKey
+	}
+	if e.sourceType != "" {
+		fields["source_type_name"] = e.sourceType
+	}
+	return mapstr.M{"event": fields}
+}
+
+func (sc *serviceCheck) fields() mapstr.M {
+	fields := mapstr.M{
+		"name":        sc.name,
+		"status":      serviceCheckStatuses[sc.status],
+		"status_code": sc.status,
+	}
+	if sc.hostname != "" {
+		fields["hostname"] = sc.hostname
+	}
+	if sc.message != "" {
+		fields["message"] = sc.message
+	}
+	return mapstr.M{"service_check": fields}
+}
+
+// parseUnixTimestamp parses a timestamp in seconds since the epoch.
+func parseUnixTimestamp(b []byte) (time.Time, error) {
+	ts, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp `%s`: %w", b, errInvalidPacket)
+	}
+	return time.Unix(ts, 0).UTC(), nil
+}
+
+// unescapeNewlines replaces the \n sequences used to send multi-line texts.
+func unescapeNewlines(b []byte) string {
+	return strings.ReplaceAll(string(b), `\n`, "\n")
+}
@@ -0,0 +1,122 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		err   bool
+		want  dogstatsdEvent
+	}{
+		"minimal": {
+			input: "_e{5,4}:title|text",
+			want: dogstatsdEvent{
+				title:     "title",
+				text:      "text",
+				priority:  "normal",
+				alertType: "info",
+			},
+		},
+		"all fields": {
+			input: "_e{5,10}:title|line\\nline|d:1700000000|h:host1|p:low|t:error|#k1:v1,k2|k:agg|s:source|c:abc",
+			want: dogstatsdEvent{
+				title:          "title",
+				text:           "line\nline",
+				timestamp:      time.Unix(1700000000, 0).UTC(),
+				hostname:       "host1",
+				priority:       "low",
+				alertType:      "error",
+				aggregationKey: "agg",
+				sourceType:     "source",
+				tags:           map[string]string{"k1": "v1", "k2": ""},
+				containerID:    "abc",
+			},
+		},
+		"text with separators": {
+			input: "_e{3,5}:a|b|c|d|e|p:low",
+			want: dogstatsdEvent{
+				title:     "a|b",
+				text:      "c|d|e",
+				priority:  "low",
+				alertType: "info",
+			},
+		},
+		"empty text": {
+			input: "_e{5,0}:title|",
+			want: dogstatsdEvent{
+				title:     "title",
+				priority:  "normal",
+				alertType: "info",
+			},
+		},
+		"missing lengths":    {input: "_e{5}:title|text", err: true},
+		"invalid length":     {input: "_e{x,4}:title|text", err: true},
+		"title too long":     {input: "_e{6,4}:title|text", err: true},
+		"text too long":      {input: "_e{5,5}:title|text", err: true},
+		"text too short":     {input: "_e{5,3}:title|text", err: true},
+		"invalid timestamp":  {input: "_e{5,4}:title|text|d:now", err: true},
+		"missing separators": {input: "_e{5,4}title|text", err: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseEvent([]byte(tc.input))
+			if tc.err {
+				assert.ErrorIs(t, err, errInvalidPacket)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseServiceCheck(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		err   bool
+		want  serviceCheck
+	}{
+		"minimal": {
+			input: "_sc|check|0",
+			want:  serviceCheck{name: "check", status: 0},
+		},
+		"all fields": {
+			input: "_sc|check|3|d:1700000000|h:host1|#k1:v1|m:first\\nsecond|c:abc",
+			want: serviceCheck{
+				name:        "check",
+				status:      3,
+				timestamp:   time.Unix(1700000000, 0).UTC(),
+				hostname:    "host1",
+				message:     "first\nsecond",
+				tags:        map[string]string{"k1": "v1"},
+				containerID: "abc",
+			},
+		},
+		"missing status":    {input: "_sc|check", err: true},
+		"missing name":      {input: "_sc||0", err: true},
+		"invalid status":    {input: "_sc|check|4", err: true},
+		"invalid timestamp": {input: "_sc|check|0|d:now", err: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseServiceCheck([]byte(tc.input))
+			if tc.err {
+				assert.ErrorIs(t, err, errInvalidPacket)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
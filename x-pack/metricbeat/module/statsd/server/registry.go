@@ -5,6 +5,9 @@
 package server
 
 import (
+	"math"
+	"math/rand/v2"
+	"sort"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
@@ -17,10 +20,11 @@ import (
 var logger = logp.NewLogger("statd")
 
 type metric struct {
-	name     string
-	tags     map[string]string
-	lastSeen time.Time
-	metric   interface{}
+	name        string
+	tags        map[string]string
+	containerID string
+	lastSeen    time.Time
+	metric      interface{}
 }
 
 type registry struct {
@@ -51,6 +55,83 @@ func newSetMetric() *setMetric {
 	return &s
 }
 
+// distributionMetric aggregates the values of a DogStatsD distribution
+// received since the last report. Percentiles are computed from a uniform
+// sample of the values.
+type distributionMetric struct {
+	count    int64
+	observed int64
+	min      float64
+	max      float64
+	sum      float64
+	sample   []float64
+}
+
+const distributionSampleSize = 1028
+
+func newDistributionMetric() *distributionMetric {
+	d := distributionMetric{}
+	d.Reset()
+	return &d
+}
+
+// Update records a value sampled at the given rate.
+func (d *distributionMetric) Update(val, sampleRate float64) {
+	weight := int64(1 / sampleRate)
+	if weight < 1 {
+		weight = 1
+	}
+	if d.count == 0 || val < d.min {
+		d.min = val
+	}
+	if d.count == 0 || val > d.max {
+		d.max = val
+	}
+	d.count += weight
+	d.sum += val * float64(weight)
+
+	// Reservoir sampling of the observed values.
+	d.observed++
+	if len(d.sample) < distributionSampleSize {
+		d.sample = append(d.sample, val)
+	} else if i := rand.Int64N(d.observed); i < distributionSampleSize {
+		d.sample[i] = val
+	}
+}
+
+// Percentiles returns the percentiles of the sampled values.
+func (d *distributionMetric) Percentiles(ps []float64) []float64 {
+	values := make([]float64, len(d.sample))
+	copy(values, d.sample)
+	sort.Float64s(values)
+
+	scores := make([]float64, len(ps))
+	size := len(values)
+	if size == 0 {
+		return scores
+	}
+	for i, p := range ps {
+		pos := p * float64(size+1)
+		switch {
+		case pos < 1.0:
+			scores[i] = values[0]
+		case pos >= float64(size):
+			scores[i] = values[size-1]
+		default:
+			lower := values[int(pos)-1]
+			upper := values[int(pos)]
+			scores[i] = lower + (pos-math.Floor(pos))*(upper-lower)
+		}
+	}
+	return scores
+}
+
+func (d *distributionMetric) Reset() {
+	d.count, d.observed = 0, 0
+	d.min, d.max, d.sum = 0, 0, 0
+	d.sample = d.sample[:0]
+}
+
 type deltaGaugeMetric struct {
 	value float64
 }
@@ -180,8 +261,9 @@ func (t *samplingTimerSnapshot) Variance() float64 {
 }
 
 type metricsGroup struct {
-	tags    map[string]string
-	metrics mapstr.M
+	tags        map[string]string
+	containerID string
+	metrics     mapstr.M
 }
 
 func (r *registry) getMetric(metric interface{}) map[string]interface{} {
@@ -225,12 +307,28 @@ func (r *registry) getMetric(metric interface{}) map[string]interface{} {
 	case *setMetric:
 		values["count"] = m.Count()
 		m.Reset()
+	case *distributionMetric:
+		values["count"] = m.count
+		if m.count > 0 {
+			ps := m.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+			values["min"] = m.min
+			values["max"] = m.max
+			values["sum"] = m.sum
+			values["mean"] = m.sum / float64(m.count)
+			values["median"] = ps[0]
+			values["p75"] = ps[1]
+			values["p95"] = ps[2]
+			values["p99"] = ps[3]
+			values["p99_9"] = ps[4]
+		}
+		m.Reset()
 	}
 	return values
 }
 
 func (r *registry) GetAll() []metricsGroup {
 	var tags map[string]string
+	var containerID string
 	now := time.Now()
 	cutOff := now.Add(-r.ttl)
 
@@ -256,6 +354,7 @@ func (r *registry) GetAll() []metricsGroup {
 			// all the .tags are the same for this metricsMap
 			// we just need one
 			tags = m.tags
+			containerID = m.containerID
 			fields[m.name] = r.getMetric(m.metric)
 		}
 
@@ -266,8 +365,9 @@ func (r *registry) GetAll() []metricsGroup {
 		}
 
 		tagGroups = append(tagGroups, metricsGroup{
-			metrics: fields,
-			tags:    tags,
+			metrics:     fields,
+			tags:        tags,
+			containerID: containerID,
 		})
 
 	}
@@ -275,22 +375,23 @@ func (r *registry) GetAll() []metricsGroup {
 	return tagGroups
 }
 
-func (r *registry) Delete(name string, tags map[string]string) {
-	if group, ok := r.metrics[r.metricHash(tags)]; ok {
+func (r *registry) Delete(name string, tags map[string]string, containerID string) {
+	if group, ok := r.metrics[r.metricHash(tags, containerID)]; ok {
 		delete(group, name)
 	}
 }
 
-func (r *registry) getOrNew(name string, tags map[string]string, new func() interface{}) interface{} {
-	tagsKey := r.metricHash(tags)
+func (r *registry) getOrNew(name string, tags map[string]string, containerID string, new func() interface{}) interface{} {
+	tagsKey := r.metricHash(tags, containerID)
 	tc, ok := r.metrics[tagsKey]
 	if !ok {
 		counter := new()
 		r.metrics[tagsKey] = map[string]*metric{name: {
-			metric:   counter,
-			name:     name,
-			tags:     tags,
-			lastSeen: time.Now(),
+			metric:      counter,
+			name:        name,
+			tags:        tags,
+			containerID: containerID,
+			lastSeen:    time.Now(),
 		}}
 		return counter
 	}
@@ -299,10 +400,11 @@ func (r *registry) getOrNew(name string, tags map[string]string, new func() inte
 	if !ok {
 		counter := new()
 		tc[name] = &metric{
-			metric:   counter,
-			name:     name,
-			tags:     tags,
-			lastSeen: time.Now(),
+			metric:      counter,
+			name:        name,
+			tags:        tags,
+			containerID: containerID,
+			lastSeen:    time.Now(),
 		}
 		return counter
 	}
@@ -312,69 +414,85 @@ func (r *registry) getOrNew(name string, tags map[string]string, new func() inte
 	return c.metric
 }
 
-func (r *registry) clearTypeChanged(name string, tags map[string]string) {
+func (r *registry) clearTypeChanged(name string, tags map[string]string, containerID string) {
 	// type was changed
 	// we can try to support the situation where a new version of the app has changed a type in
 	// a metric by deleting the old one and creating a new one
 	logger.With("name", name).Warn("metric changed type")
-	r.Delete(name, tags)
+	r.Delete(name, tags, containerID)
 }
 
-func (r *registry) GetOrNewCounter(name string, tags map[string]string) metrics.Counter {
-	maybeCounter := r.getOrNew(name, tags, func() interface{} { return metrics.NewCounter() })
+func (r *registry) GetOrNewCounter(name string, tags map[string]string, containerID string) metrics.Counter {
+	maybeCounter := r.getOrNew(name, tags, containerID, func() interface{} { return metrics.NewCounter() })
 	counter, ok := maybeCounter.(metrics.Counter)
 	if ok {
 		return counter
 	}
 
-	r.clearTypeChanged(name, tags)
-	return r.GetOrNewCounter(name, tags)
+	r.clearTypeChanged(name, tags, containerID)
+	return r.GetOrNewCounter(name, tags, containerID)
 }
 
-func (r *registry) GetOrNewTimer(name string, tags map[string]string) *samplingTimer {
-	timer, ok := r.getOrNew(name, tags, func() interface{} { return newSamplingTimer() }).(*samplingTimer)
+func (r *registry) GetOrNewTimer(name string, tags map[string]string, containerID string) *samplingTimer {
+	timer, ok := r.getOrNew(name, tags, containerID, func() interface{} { return newSamplingTimer() }).(*samplingTimer)
 	if ok {
 		return timer
 	}
 
-	r.clearTypeChanged(name, tags)
-	return r.GetOrNewTimer(name, tags)
+	r.clearTypeChanged(name, tags, containerID)
+	return r.GetOrNewTimer(name, tags, containerID)
 }
 
-func (r *registry) GetOrNewGauge64(name string, tags map[string]string) *deltaGaugeMetric {
-	gauge, ok := r.getOrNew(name, tags, func() interface{} { return &deltaGaugeMetric{} }).(*deltaGaugeMetric)
+func (r *registry) GetOrNewGauge64(name string, tags map[string]string, containerID string) *deltaGaugeMetric {
+	gauge, ok := r.getOrNew(name, tags, containerID, func() interface{} { return &deltaGaugeMetric{} }).(*deltaGaugeMetric)
 	if ok {
 		return gauge
 	}
 
-	r.clearTypeChanged(name, tags)
-	return r.GetOrNewGauge64(name, tags)
+	r.clearTypeChanged(name, tags, containerID)
+	return r.GetOrNewGauge64(name, tags, containerID)
 }
 
-func (r *registry) GetOrNewHistogram(name string, tags map[string]string) metrics.Histogram {
-	histogram, ok := r.getOrNew(name, tags, func() interface{} { return metrics.NewHistogram(metrics.NewExpDecaySample(1028, 0.015)) }).(metrics.Histogram)
+func (r *registry) GetOrNewHistogram(name string, tags map[string]string, containerID string) metrics.Histogram {
+	histogram, ok := r.getOrNew(name, tags, containerID, func() interface{} { return metrics.NewHistogram(metrics.NewExpDecaySample(1028, 0.015)) }).(metrics.Histogram)
 	if ok {
 		return histogram
 	}
 
-	r.clearTypeChanged(name, tags)
-	return r.GetOrNewHistogram(name, tags)
+	r.clearTypeChanged(name, tags, containerID)
+	return r.GetOrNewHistogram(name, tags, containerID)
 }
 
-func (r *registry) GetOrNewSet(name string, tags map[string]string) *setMetric {
-	setmetric, ok := r.getOrNew(name, tags, func() interface{} { return newSetMetric() }).(*setMetric)
+func (r *registry) GetOrNewDistribution(name string, tags map[string]string, containerID string) *distributionMetric {
+	distribution, ok := r.getOrNew(name, tags, containerID, func() interface{} { return newDistributionMetric() }).(*distributionMetric)
+	if ok {
+		return distribution
+	}
+
+	r.clearTypeChanged(name, tags, containerID)
+	return r.GetOrNewDistribution(name, tags, containerID)
+}
+
+func (r *registry) GetOrNewSet(name string, tags map[string]string, containerID string) *setMetric {
+	setmetric, ok := r.getOrNew(name, tags, containerID, func() interface{} { return newSetMetric() }).(*setMetric)
 	if ok {
 		return setmetric
 	}
 
-	r.clearTypeChanged(name, tags)
-	return r.GetOrNewSet(name, tags)
+	r.clearTypeChanged(name, tags, containerID)
+	return r.GetOrNewSet(name, tags, containerID)
 }
 
-func (r *registry) metricHash(tags map[string]string) string {
+// metricHash returns the key of the group of metrics with the given tags and
+// container ID, which are reported in the same event.
+func (r *registry) metricHash(tags map[string]string, containerID string) string {
 	mapstrTags := mapstr.M{}
 	for k, v := range tags {
 		mapstrTags[k] = v
 	}
-	return labelhash.LabelHash(mapstrTags)
+	hash := labelhash.LabelHash(mapstrTags)
+	if containerID != "" {
+		hash += "|" + containerID
+	}
+	return hash
 }
@@ -12,6 +12,7 @@ import (
 
 	serverhelper "github.com/elastic/beats/v7/metricbeat/helper/server"
 	"github.com/elastic/beats/v7/metricbeat/helper/server/udp"
+	"github.com/elastic/beats/v7/metricbeat/helper/server/unixgram"
 	"github.com/elastic/beats/v7/metricbeat/mb"
 	"github.com/elastic/elastic-agent-libs/mapstr"
 )
@@ -56,6 +57,9 @@ func newErrInvalidMapping(metricLabels []string, attrLabels []Label) error {
 type Config struct {
 	TTL      time.Duration   `config:"ttl"`
 	Mappings []StatsdMapping `config:"statsd.mappings"`
+	// SocketPath is the path of a unix datagram socket to listen on,
+	// instead of UDP.
+	SocketPath string `config:"socket_path"`
 }
 
 func defaultConfig() Config {
@@ -86,7 +90,13 @@ func New(base mb.BaseMetricSet) (mb.MetricSet, error) {
 		return nil, err
 	}
 
-	svc, err := udp.NewUdpServer(base)
+	var svc serverhelper.Server
+	var err error
+	if config.SocketPath != "" {
+		svc, err = unixgram.NewUnixgramServer(base)
+	} else {
+		svc, err = udp.NewUdpServer(base)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +118,7 @@ func New(base mb.BaseMetricSet) (mb.MetricSet, error) {
 // Host returns the hostname or other module specific value that identifies a
 // specific host or service instance from which to collect metrics.
 func (m *MetricSet) Host() string {
-	return m.server.(*udp.UdpServer).GetHost()
+	return m.server.(interface{ GetHost() string }).GetHost()
 }
 
 func buildMappings(config []StatsdMapping) (map[string]StatsdMapping, error) {
@@ -165,20 +175,20 @@ func buildMappings(config []StatsdMapping) (map[string]StatsdMapping, error) {
 
 // It processes metric groups, applies event mappings, and creates Metricbeat events.
 // The generated events include metric fields, labels, and the namespace associated with the MetricSet.
+// DogStatsD events, service checks and timestamped metrics received since the last call
+// are reported as their own events.
 // Returns a slice of Metricbeat events.
 func (m *MetricSet) getEvents() []*mb.Event {
 	groups := m.processor.GetAll()
+	dogstatsdEvents, serviceChecks, timestamped := m.processor.TakePending()
 
 	// If there are no metric groups, return nil to indicate no events.
-	if len(groups) == 0 {
+	if len(groups) == 0 && len(dogstatsdEvents) == 0 && len(serviceChecks) == 0 && len(timestamped) == 0 {
 		return nil
 	}
-	events := make([]*mb.Event, 0, len(groups))
+	events := make([]*mb.Event, 0, len(groups)+len(dogstatsdEvents)+len(serviceChecks)+len(timestamped))
 	for _, tagGroup := range groups {
-		mapstrTags := make(mapstr.M, len(tagGroup.tags))
-		for k, v := range tagGroup.tags {
-			mapstrTags[k] = v
-		}
+		rootFields := m.rootFields(tagGroup.tags, tagGroup.containerID)
 
 		for k, v := range tagGroup.metrics {
 			// Apply event mapping to the metric and get MetricSetFields.
@@ -190,14 +200,56 @@ func (m *MetricSet) getEvents() []*mb.Event {
 			}
 			events = append(events, &mb.Event{
 				MetricSetFields: ms,
-				RootFields:      mapstr.M{"labels": mapstrTags},
+				RootFields:      rootFields,
 				Namespace:       m.Module().Name(),
 			})
 		}
 	}
+
+	for _, tm := range timestamped {
+		ms := eventMapping(tm.name, tm.value, m.mappings)
+		if len(ms) == 0 {
+			continue
+		}
+		events = append(events, &mb.Event{
+			Timestamp:       tm.timestamp,
+			MetricSetFields: ms,
+			RootFields:      m.rootFields(tm.tags, tm.containerID),
+			Namespace:       m.Module().Name(),
+		})
+	}
+	for _, e := range dogstatsdEvents {
+		events = append(events, &mb.Event{
+			Timestamp:       e.timestamp,
+			MetricSetFields: e.fields(),
+			RootFields:      m.rootFields(e.tags, e.containerID),
+			Namespace:       m.Module().Name(),
+		})
+	}
+	for _, sc := range serviceChecks {
+		events = append(events, &mb.Event{
+			Timestamp:       sc.timestamp,
+			MetricSetFields: sc.fields(),
+			RootFields:      m.rootFields(sc.tags, sc.containerID),
+			Namespace:       m.Module().Name(),
+		})
+	}
 	return events
 }
 
+// rootFields returns the labels and container fields of an event.
+func (m *MetricSet) rootFields(tags map[string]string, containerID string) mapstr.M {
+	mapstrTags := make(mapstr.M, len(tags))
+	for k, v := range tags {
+		mapstrTags[k] = v
+	}
+	rootFields := mapstr.M{"labels": mapstrTags}
+	if containerID != "" {
+		rootFields["container"] = mapstr.M{"id": containerID}
+	}
+	return rootFields
+}
+
 // ServerStart starts the underlying m.server
 func (m *MetricSet) ServerStart() {
 	if m.serverStarted {